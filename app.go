@@ -0,0 +1,12 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"webook/internal/job"
+)
+
+// App web 服务和后台任务
+type App struct {
+	Server       *gin.Engine
+	AccountPurge *job.AccountPurgeJob
+}
@@ -1,16 +1,271 @@
 package config
 
-type config struct {
-	DB    DBConfig
-	Redis RedisConfig
+import "time"
+
+// Config 启动时加载的配置，见 Load，配置文件变更之后会整体替换
+// 运行期间读取配置用 Get，支持热更新的配置通过 OnChange 获取最新的值
+var Config AppConfig
+
+type AppConfig struct {
+	Server    ServerConfig
+	Log       LogConfig
+	AccessLog AccessLogConfig
+	Trace     TraceConfig
+	DB        DBConfig
+	Redis     RedisConfig
+	Limiter   LimiterConfig
+	JWT       JWTConfig
+	Password  PasswordConfig
+	MFA       MFAConfig
+	WebAuthn  WebAuthnConfig
+	SMS       SMSConfig
+	Captcha   CaptchaConfig
+	WeChat    WeChatConfig
+	OAuth2    OAuth2Config
+	Account   AccountConfig
+	Security  SecurityConfig
+}
+
+type ServerConfig struct {
+	Addr string `validate:"required"`
+}
+
+type LogConfig struct {
+	// Level 日志级别，支持热更新
+	Level string `validate:"oneof=debug info warn error"`
+}
+
+// AccessLogConfig 访问日志，支持热更新，线上排查问题的时候可以临时打开请求体记录
+type AccessLogConfig struct {
+	Enabled     bool
+	ReqBody     bool
+	RespBody    bool
+	MaxBodySize int `validate:"min=0"`
+	// SampleRate 默认采样率，0 到 1
+	SampleRate float64 `validate:"min=0,max=1"`
+	// Routes 路由维度的采样率
+	Routes []AccessLogRoute `validate:"dive"`
+}
+
+type AccessLogRoute struct {
+	Method     string
+	Path       string  `validate:"required"`
+	SampleRate float64 `validate:"min=0,max=1"`
+}
+
+type TraceConfig struct {
+	// Exporter none 不上报，stdout 输出到标准输出（本地调试），otlp 通过 HTTP 上报到 collector
+	Exporter string `validate:"oneof=none stdout otlp"`
+	// Endpoint otlp collector 的地址，比如 otel-collector:4318
+	Endpoint string
+	// SampleRate 采样率，0 到 1，上游已经决定采样的请求不受影响
+	SampleRate float64 `validate:"min=0,max=1"`
 }
 
 type DBConfig struct {
-	DSN string
+	DSN     string `validate:"required"`
+	DSNFile string
 }
 
 type RedisConfig struct {
-	Addr     string
-	Password string
-	DB       int
+	Addr         string `validate:"required"`
+	Password     string
+	PasswordFile string
+	DB           int
+}
+
+type LimiterConfig struct {
+	// Type 限流算法：sliding_window, fixed_window, token_bucket, local
+	Type string `validate:"oneof=sliding_window fixed_window token_bucket local"`
+	// FailPolicy Redis 不可用时的策略：closed 拒绝，open 放行，local 降级到本地限流
+	FailPolicy string `validate:"oneof=closed open local"`
+	// Instances 实例数量，降级到本地限流时阈值按实例数等比例缩小
+	Instances int `validate:"min=1"`
+	// Rules 路由维度的限流规则，支持热更新
+	Rules []LimiterRule `validate:"dive"`
+}
+
+type LimiterRule struct {
+	Method string
+	Path   string `validate:"required"`
+	// Key 限流对象：ip、user、route、body:phone，user 的规则在登录校验之后执行，其他的在之前
+	Key    string `validate:"required"`
+	Window string `validate:"required"`
+	Rate   int    `validate:"min=1"`
+}
+
+type JWTConfig struct {
+	AccessKey      string `validate:"required,min=16"`
+	AccessKeyFile  string
+	RefreshKey     string `validate:"required,min=16"`
+	RefreshKeyFile string
+}
+
+// PasswordConfig 密码策略
+type PasswordConfig struct {
+	MinLength int `validate:"min=6"`
+	// MaxLength 按字节计算，bcrypt 最多支持 72 字节
+	MaxLength      int `validate:"gtefield=MinLength"`
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	// HistorySize 不能和最近用过的几个密码重复，包括当前密码，0 表示不限制
+	HistorySize int `validate:"min=0,max=24"`
+	// BreachedListFile 泄露密码列表，一行一个，按频率从高到低排列，为空使用内置的列表
+	BreachedListFile string
+	// BreachedTopN 只加载列表里面前 N 个密码，0 表示全部加载
+	BreachedTopN int `validate:"min=0"`
+	Hash         PasswordHashConfig
+}
+
+// PasswordHashConfig 修改之后，老用户下次登录的时候会重新哈希
+type PasswordHashConfig struct {
+	Algorithm  string `validate:"oneof=bcrypt argon2id"`
+	BcryptCost int    `validate:"min=4,max=31"`
+	// Argon2Memory 单位 KiB
+	Argon2Memory      uint32 `validate:"min=1024"`
+	Argon2Iterations  uint32 `validate:"min=1"`
+	Argon2Parallelism uint8  `validate:"min=1"`
+}
+
+// MFAConfig 二次验证
+type MFAConfig struct {
+	// Issuer 身份验证器 App 里面显示的名字
+	Issuer string `validate:"required"`
+	// EncryptKey 加密 TOTP 密钥用的 AES-256 密钥，32 字节，修改之后已经开启的用户都要重新绑定
+	EncryptKey     string `validate:"required,len=32"`
+	EncryptKeyFile string
+}
+
+// WebAuthnConfig 通行密钥，RPID 修改之后已经注册的通行密钥都不能用了
+type WebAuthnConfig struct {
+	// RPID 网站的域名，不带协议和端口，比如 webook.com
+	RPID          string `validate:"required"`
+	RPDisplayName string `validate:"required"`
+	// RPOrigins 允许发起 WebAuthn 的前端地址，比如 https://webook.com
+	RPOrigins []string `validate:"min=1,dive,url"`
+}
+
+type SMSConfig struct {
+	// Provider 短信服务商：local 只打印日志，tencent 腾讯云
+	Provider string `validate:"oneof=local tencent"`
+	// CodeTemplate 验证码短信的默认模板
+	CodeTemplate string `validate:"required"`
+	// CodeTemplates 按语言区分的验证码短信模板，没有配置的语言用 CodeTemplate
+	CodeTemplates []SMSTemplateConfig `validate:"dive"`
+	Tencent       TencentSMSConfig
+	// Guards 验证码防刷规则，按 biz 配置，没有单独配置的 biz 使用 biz 为 "*" 的规则
+	Guards []SMSGuardConfig `validate:"dive"`
+}
+
+type SMSGuardConfig struct {
+	Biz   string         `validate:"required"`
+	Rules []SMSGuardRule `validate:"min=1,dive"`
+}
+
+type SMSGuardRule struct {
+	// Key 限流对象：phone、ip、device、global，global 是所有 biz 共用的预算，各个 biz 要配置成一样的
+	Key    string        `validate:"oneof=phone ip device global"`
+	Window time.Duration `validate:"min=1s"`
+	Rate   int           `validate:"min=1"`
+	// CaptchaAfter 窗口内发送超过这个次数之后要求人机验证，0 表示不需要
+	CaptchaAfter int `validate:"min=0,ltfield=Rate"`
+}
+
+type SMSTemplateConfig struct {
+	// Locale 语言，比如 en-US
+	Locale string `validate:"required"`
+	TplID  string `validate:"required"`
+}
+
+type TencentSMSConfig struct {
+	SecretID      string
+	SecretIDFile  string
+	SecretKey     string
+	SecretKeyFile string
+	Region        string
+	AppID         string
+	SignName      string
+}
+
+// CaptchaConfig 人机验证，验证码发送次数接近上限的时候要求先通过
+type CaptchaConfig struct {
+	// Provider none 一律不通过，local 只要提交了票据就通过（仅本地开发），
+	// turnstile、recaptcha、hcaptcha 调用对应的校验接口
+	Provider   string `validate:"oneof=none local turnstile recaptcha hcaptcha"`
+	Secret     string
+	SecretFile string
+	// VerifyURL 为空的时候使用提供方的默认地址
+	VerifyURL string `validate:"omitempty,url"`
+}
+
+// WeChatConfig 没有配置 AppID 的时候不开启微信登录
+type WeChatConfig struct {
+	AppID         string
+	AppSecret     string
+	AppSecretFile string
+	// EncryptKey 加密保存微信 token 用的 AES-256 密钥，32 字节，开启了微信登录或者小程序登录的时候必填
+	EncryptKey     string `validate:"omitempty,len=32"`
+	EncryptKeyFile string
+	// OpenURL 和 APIURL 为空的时候用微信的地址，联调的时候可以指向模拟服务
+	OpenURL string `validate:"omitempty,url"`
+	APIURL  string `validate:"omitempty,url"`
+	// MiniProgram 小程序登录，和网站应用共用 EncryptKey 加密 session_key
+	MiniProgram MiniProgramConfig
+}
+
+// MiniProgramConfig 没有配置 AppID 的时候不开启小程序登录
+type MiniProgramConfig struct {
+	AppID         string
+	AppSecret     string
+	AppSecretFile string
+}
+
+// OAuth2Config 第三方登录，回调地址是 RedirectBaseURL/oauth2/<提供方>/callback
+type OAuth2Config struct {
+	RedirectBaseURL string `validate:"required,url"`
+	// FrontendURL 回调结束之后跳转到前端的地址，结果放在 URL fragment 里面
+	// 为空的时候回调直接返回 JSON，token 在响应头里面
+	FrontendURL string `validate:"omitempty,url"`
+	GitHub      GitHubConfig
+	// OIDC 通用的 OIDC 提供方，比如 Google、企业的 Keycloak
+	OIDC []OIDCConfig `validate:"dive"`
+}
+
+// GitHubConfig 没有配置 ClientID 的时候不开启 GitHub 登录
+type GitHubConfig struct {
+	ClientID         string
+	ClientSecret     string
+	ClientSecretFile string
+}
+
+type OIDCConfig struct {
+	// Name 路由里面的名字，不能和 wechat、github 重复
+	Name             string `validate:"required,lowercase,alphanum,ne=wechat,ne=github"`
+	Issuer           string `validate:"required,url"`
+	ClientID         string `validate:"required"`
+	ClientSecret     string
+	ClientSecretFile string
+	// Scopes 默认 openid email profile
+	Scopes []string
+}
+
+// AccountConfig 账号注销
+type AccountConfig struct {
+	// DeleteGracePeriod 申请注销之后的冷静期，期间登录之后调用撤销接口才能撤销，过了之后匿名化
+	DeleteGracePeriod time.Duration `validate:"min=0"`
+	// PurgeInterval 后台检查冷静期已过的账号的间隔
+	PurgeInterval time.Duration `validate:"min=1m"`
+}
+
+// SecurityConfig 安全事件
+type SecurityConfig struct {
+	// GeoDBFile IP 地理位置库，IP2Location LITE DB3 格式的 CSV，为空的时候不查地理位置
+	GeoDBFile string
+	// NotifyWebhook 新设备登录的时候把事件 POST 到这个地址，为空的时候只打日志
+	NotifyWebhook string `validate:"omitempty,url"`
+	// NotifySecret 请求头 X-Webook-Signature 是请求体的 HMAC-SHA256，为空的时候不签名
+	NotifySecret     string
+	NotifySecretFile string
 }
@@ -1,14 +0,0 @@
-//go:build !k8s
-
-package config
-
-var Config = config{
-	DB: DBConfig{
-		DSN: "root:root@tcp(localhost:3306)/webook",
-	},
-	Redis: RedisConfig{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
-	},
-}
@@ -1,13 +0,0 @@
-//ago:build k8s
-
-package config
-
-//
-//var Config = config{
-//	DB: DBConfig{s
-//		DSN: "localhost:3306",
-//	},
-//	Redis: RedisConfig{
-//		Addr: "192.168.136.135:6379",
-//	},
-//}
@@ -0,0 +1,300 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"webook/pkg/logger"
+)
+
+// envPrefix 环境变量前缀，比如 WEBOOK_JWT_ACCESSKEY 覆盖 jwt.accessKey
+const envPrefix = "WEBOOK"
+
+// secretFileSuffix 敏感配置可以通过 xxxFile 指定从文件读取，比如 jwt.accessKeyFile
+// 方便挂载 k8s secret
+const secretFileSuffix = "File"
+
+var (
+	v        = viper.New()
+	validate = validator.New()
+
+	mu       sync.Mutex
+	watchers []watcher
+	current  atomic.Pointer[AppConfig]
+	// l 加载配置的时候日志还没有初始化，初始化之后通过 SetLogger 设置
+	log atomic.Pointer[logger.Logger]
+)
+
+type watcher struct {
+	key  string
+	last any
+	fn   func(cfg AppConfig)
+}
+
+// Load 加载配置，优先级从低到高：默认值、配置文件、环境变量、命令行参数
+// 加载完成之后会监听配置文件，变更的配置通过 OnChange 通知
+func Load(args []string) error {
+	flags := pflag.NewFlagSet("webook", pflag.ContinueOnError)
+	file := flags.String("config", "config/dev.yaml", "配置文件路径")
+	flags.String("server.addr", ":8080", "监听地址")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	v = viper.New()
+	setDefaults()
+	v.SetConfigFile(*file)
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	if err := v.BindPFlag("server.addr", flags.Lookup("server.addr")); err != nil {
+		return err
+	}
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("读取配置文件 %s 失败: %w", *file, err)
+	}
+	cfg, err := decode()
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	Config = cfg
+	current.Store(&cfg)
+	mu.Unlock()
+
+	v.OnConfigChange(func(in fsnotify.Event) {
+		reload()
+	})
+	v.WatchConfig()
+	return nil
+}
+
+// Get 最新的配置
+func Get() AppConfig {
+	return *current.Load()
+}
+
+// SetLogger 热更新的日志
+func SetLogger(l logger.Logger) {
+	log.Store(&l)
+}
+
+func getLogger() logger.Logger {
+	if l := log.Load(); l != nil {
+		return *l
+	}
+	return logger.NewNopLogger()
+}
+
+// OnChange 监听配置变更，key 对应的配置发生变化时调用 fn
+// 比如 OnChange("limiter.rules", ...)，fn 拿到的是变更之后的完整配置
+func OnChange(key string, fn func(cfg AppConfig)) {
+	mu.Lock()
+	defer mu.Unlock()
+	watchers = append(watchers, watcher{key: key, last: v.Get(key), fn: fn})
+}
+
+func reload() {
+	cfg, err := decode()
+	if err != nil {
+		// 配置不合法的时候继续使用原来的配置
+		getLogger().Error(context.Background(), "配置文件变更，但是加载失败", logger.Error(err))
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// 先替换全局的配置，watcher 里面读到的也是新的配置
+	Config = cfg
+	current.Store(&cfg)
+	for i := range watchers {
+		w := &watchers[i]
+		cur := v.Get(w.key)
+		if reflect.DeepEqual(cur, w.last) {
+			continue
+		}
+		w.last = cur
+		getLogger().Info(context.Background(), "配置变更", logger.String("key", w.key))
+		w.fn(cfg)
+	}
+}
+
+func decode() (AppConfig, error) {
+	var cfg AppConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		return AppConfig{}, err
+	}
+	if err := resolveSecretFiles(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return AppConfig{}, err
+	}
+	if err := validateConfig(cfg); err != nil {
+		return AppConfig{}, err
+	}
+	return cfg, nil
+}
+
+// setDefaults 环境变量只能覆盖 viper 知道的 key，所以敏感配置也要设置默认值
+func setDefaults() {
+	v.SetDefault("log.level", "info")
+	v.SetDefault("accessLog.enabled", true)
+	v.SetDefault("accessLog.maxBodySize", 1024)
+	v.SetDefault("accessLog.sampleRate", 1)
+	v.SetDefault("trace.exporter", "none")
+	v.SetDefault("trace.sampleRate", 1)
+	v.SetDefault("limiter.type", "sliding_window")
+	v.SetDefault("limiter.failPolicy", "local")
+	v.SetDefault("limiter.instances", 1)
+	v.SetDefault("password.minLength", 8)
+	v.SetDefault("password.maxLength", 64)
+	v.SetDefault("password.requireUpper", true)
+	v.SetDefault("password.requireLower", true)
+	v.SetDefault("password.requireDigit", true)
+	v.SetDefault("password.requireSpecial", true)
+	v.SetDefault("password.historySize", 5)
+	v.SetDefault("password.hash.algorithm", "bcrypt")
+	v.SetDefault("password.hash.bcryptCost", 10)
+	v.SetDefault("password.hash.argon2Memory", 64*1024)
+	v.SetDefault("password.hash.argon2Iterations", 3)
+	v.SetDefault("password.hash.argon2Parallelism", 2)
+	v.SetDefault("mfa.issuer", "webook")
+	v.SetDefault("webAuthn.rpId", "localhost")
+	v.SetDefault("webAuthn.rpDisplayName", "webook")
+	v.SetDefault("webAuthn.rpOrigins", []string{"http://localhost:3000"})
+	v.SetDefault("oauth2.redirectBaseURL", "http://localhost:8080")
+	v.SetDefault("sms.provider", "local")
+	v.SetDefault("sms.codeTemplate", "1877556")
+	v.SetDefault("sms.guards", []map[string]any{
+		{
+			"biz": "*",
+			"rules": []map[string]any{
+				{"key": "phone", "window": "24h", "rate": 10, "captchaAfter": 5},
+				{"key": "ip", "window": "1h", "rate": 30, "captchaAfter": 10},
+				{"key": "device", "window": "1h", "rate": 10, "captchaAfter": 5},
+				// 整体的短信预算，防止换号刷
+				{"key": "global", "window": "1h", "rate": 20000},
+			},
+		},
+	})
+	// 没有配置人机验证的时候拒绝，而不是放行
+	v.SetDefault("captcha.provider", "none")
+	v.SetDefault("account.deleteGracePeriod", "360h")
+	v.SetDefault("account.purgeInterval", "1h")
+	for _, key := range []string{
+		"db.dsn", "db.dsnFile",
+		"redis.password", "redis.passwordFile",
+		"jwt.accessKey", "jwt.accessKeyFile", "jwt.refreshKey", "jwt.refreshKeyFile",
+		"mfa.encryptKey", "mfa.encryptKeyFile",
+		"sms.tencent.secretId", "sms.tencent.secretIdFile",
+		"sms.tencent.secretKey", "sms.tencent.secretKeyFile",
+		"captcha.secret", "captcha.secretFile", "captcha.verifyURL",
+		"wechat.appId", "wechat.appSecret", "wechat.appSecretFile",
+		"wechat.encryptKey", "wechat.encryptKeyFile",
+		"wechat.miniProgram.appId", "wechat.miniProgram.appSecret", "wechat.miniProgram.appSecretFile",
+		"oauth2.github.clientId", "oauth2.github.clientSecret", "oauth2.github.clientSecretFile",
+		"security.notifyWebhook", "security.notifySecret", "security.notifySecretFile",
+	} {
+		v.SetDefault(key, "")
+	}
+}
+
+// resolveSecretFiles 字段 X 为空并且配置了 XFile 的时候，从文件中读取 X
+func resolveSecretFiles(val reflect.Value) error {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := val.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := resolveSecretFiles(field); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct {
+			for j := 0; j < field.Len(); j++ {
+				if err := resolveSecretFiles(field.Index(j)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		name := typ.Field(i).Name
+		fileField := val.FieldByName(name + secretFileSuffix)
+		if field.Kind() != reflect.String || !fileField.IsValid() ||
+			field.String() != "" || fileField.String() == "" {
+			continue
+		}
+		data, err := os.ReadFile(fileField.String())
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %w", name+secretFileSuffix, err)
+		}
+		field.SetString(strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+func validateConfig(cfg AppConfig) error {
+	if err := validate.Struct(cfg); err != nil {
+		return fmt.Errorf("配置不合法: %w", err)
+	}
+	if cfg.Trace.Exporter == "otlp" && cfg.Trace.Endpoint == "" {
+		return errors.New("配置不合法: 使用 otlp 上报需要配置 trace.endpoint")
+	}
+	if cfg.Password.Hash.Algorithm == "bcrypt" && cfg.Password.MaxLength > 72 {
+		return errors.New("配置不合法: bcrypt 最多支持 72 字节的密码，password.maxLength 不能超过 72")
+	}
+	if (cfg.WeChat.AppID != "" || cfg.WeChat.MiniProgram.AppID != "") && cfg.WeChat.EncryptKey == "" {
+		return errors.New("配置不合法: 开启微信登录或者小程序登录需要配置 wechat.encryptKey")
+	}
+	names := map[string]bool{}
+	for _, p := range cfg.OAuth2.OIDC {
+		if names[p.Name] {
+			return fmt.Errorf("配置不合法: oauth2.oidc 里面的 %s 重复了", p.Name)
+		}
+		names[p.Name] = true
+	}
+	if cfg.SMS.Provider == "tencent" {
+		t := cfg.SMS.Tencent
+		if t.SecretID == "" || t.SecretKey == "" || t.AppID == "" || t.SignName == "" {
+			return errors.New("配置不合法: 使用腾讯云短信需要配置 secretId、secretKey、appId、signName")
+		}
+	}
+	if err := validateSMSGuards(cfg.SMS.Guards); err != nil {
+		return err
+	}
+	switch cfg.Captcha.Provider {
+	case "turnstile", "recaptcha", "hcaptcha":
+		if cfg.Captcha.Secret == "" {
+			return fmt.Errorf("配置不合法: 使用 %s 人机验证需要配置 captcha.secret", cfg.Captcha.Provider)
+		}
+	}
+	return nil
+}
+
+// validateSMSGuards 必须有 "*" 的默认规则，同一个 biz 的限流对象不能重复，不然会共用一个计数
+func validateSMSGuards(guards []SMSGuardConfig) error {
+	bizs := map[string]bool{}
+	for _, g := range guards {
+		if bizs[g.Biz] {
+			return fmt.Errorf("配置不合法: sms.guards 里面的 %s 重复了", g.Biz)
+		}
+		bizs[g.Biz] = true
+		keys := map[string]bool{}
+		for _, r := range g.Rules {
+			if keys[r.Key] {
+				return fmt.Errorf("配置不合法: sms.guards 里面 %s 的 %s 规则重复了", g.Biz, r.Key)
+			}
+			keys[r.Key] = true
+		}
+	}
+	if !bizs["*"] {
+		return errors.New(`配置不合法: sms.guards 需要配置 biz 为 "*" 的默认规则`)
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testYAML = `
+db:
+  dsn: "root:root@tcp(localhost:3306)/webook"
+redis:
+  addr: "localhost:6379"
+limiter:
+  rules:
+    - path: "/users/login"
+      key: "ip"
+      window: "1m"
+      rate: 10
+jwt:
+  accessKey: "access-key-from-yaml"
+mfa:
+  encryptKey: "0123456789abcdef0123456789abcdef"
+`
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "refresh-key")
+	require.NoError(t, os.WriteFile(secret, []byte("refresh-key-from-file\n"), 0600))
+	t.Setenv("WEBOOK_JWT_REFRESHKEYFILE", secret)
+	t.Setenv("WEBOOK_REDIS_ADDR", "redis-from-env:6379")
+	oidcSecret := filepath.Join(dir, "oidc-secret")
+	require.NoError(t, os.WriteFile(oidcSecret, []byte("oidc-secret-from-file"), 0600))
+
+	file := filepath.Join(dir, "test.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(testYAML+`
+oauth2:
+  oidc:
+    - name: "keycloak"
+      issuer: "http://localhost:8180/realms/webook"
+      clientId: "webook"
+      clientSecretFile: "`+oidcSecret+`"
+`), 0600))
+	require.NoError(t, Load([]string{"--config=" + file, "--server.addr=:9090"}))
+
+	assert.Equal(t, ":9090", Config.Server.Addr)
+	assert.Equal(t, "root:root@tcp(localhost:3306)/webook", Config.DB.DSN)
+	assert.Equal(t, "redis-from-env:6379", Config.Redis.Addr)
+	assert.Equal(t, "access-key-from-yaml", Config.JWT.AccessKey)
+	assert.Equal(t, "refresh-key-from-file", Config.JWT.RefreshKey)
+	// 默认值
+	assert.Equal(t, "sliding_window", Config.Limiter.Type)
+	assert.Equal(t, "local", Config.SMS.Provider)
+	assert.Equal(t, "1877556", Config.SMS.CodeTemplate)
+	assert.Equal(t, "none", Config.Captcha.Provider)
+	require.Len(t, Config.SMS.Guards, 1)
+	assert.Equal(t, "*", Config.SMS.Guards[0].Biz)
+	assert.Equal(t, SMSGuardRule{Key: "phone", Window: time.Hour * 24, Rate: 10, CaptchaAfter: 5},
+		Config.SMS.Guards[0].Rules[0])
+	assert.Equal(t, "webook", Config.MFA.Issuer)
+	assert.Equal(t, []string{"http://localhost:3000"}, Config.WebAuthn.RPOrigins)
+	assert.Equal(t, "http://localhost:8080", Config.OAuth2.RedirectBaseURL)
+	// 切片里面的密钥文件
+	assert.Equal(t, "oidc-secret-from-file", Config.OAuth2.OIDC[0].ClientSecret)
+
+	// 热更新
+	changed := make(chan AppConfig, 1)
+	OnChange("limiter.rules", func(cfg AppConfig) {
+		// 通知之前已经替换了全局的配置
+		assert.Equal(t, cfg, Get())
+		select {
+		case changed <- cfg:
+		default:
+		}
+	})
+	updated := []byte(testYAML + `
+sms:
+  provider: "local"
+`)
+	// 只修改 sms，不会通知
+	require.NoError(t, os.WriteFile(file, updated, 0600))
+	select {
+	case <-changed:
+		t.Fatal("limiter.rules 没有变更")
+	case <-time.After(time.Millisecond * 300):
+	}
+	updated = []byte(strings.Replace(testYAML, "rate: 10", "rate: 10\n      method: \"POST\"", 1))
+	require.NoError(t, os.WriteFile(file, updated, 0600))
+	select {
+	case cfg := <-changed:
+		assert.Equal(t, "POST", cfg.Limiter.Rules[0].Method)
+		assert.Equal(t, "POST", Config.Limiter.Rules[0].Method)
+	case <-time.After(time.Second * 3):
+		t.Fatal("没有收到配置变更")
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		yaml string
+	}{
+		{
+			name: "缺少 DSN",
+			yaml: `
+redis:
+  addr: "localhost:6379"
+jwt:
+  accessKey: "access-key-from-yaml"
+  refreshKey: "refresh-key-from-yaml"
+`,
+		},
+		{
+			name: "密钥太短",
+			yaml: `
+db:
+  dsn: "root:root@tcp(localhost:3306)/webook"
+redis:
+  addr: "localhost:6379"
+jwt:
+  accessKey: "short"
+  refreshKey: "refresh-key-from-yaml"
+`,
+		},
+		{
+			name: "未知的限流算法",
+			yaml: `
+db:
+  dsn: "root:root@tcp(localhost:3306)/webook"
+redis:
+  addr: "localhost:6379"
+limiter:
+  type: "leaky_bucket"
+jwt:
+  accessKey: "access-key-from-yaml"
+  refreshKey: "refresh-key-from-yaml"
+`,
+		},
+		{
+			name: "otlp 缺少地址",
+			yaml: `
+db:
+  dsn: "root:root@tcp(localhost:3306)/webook"
+redis:
+  addr: "localhost:6379"
+jwt:
+  accessKey: "access-key-from-yaml"
+  refreshKey: "refresh-key-from-yaml"
+trace:
+  exporter: "otlp"
+`,
+		},
+		{
+			name: "腾讯云短信缺少密钥",
+			yaml: `
+db:
+  dsn: "root:root@tcp(localhost:3306)/webook"
+redis:
+  addr: "localhost:6379"
+jwt:
+  accessKey: "access-key-from-yaml"
+  refreshKey: "refresh-key-from-yaml"
+sms:
+  provider: "tencent"
+`,
+		},
+		{
+			name: "防刷规则缺少默认规则",
+			yaml: `
+db:
+  dsn: "root:root@tcp(localhost:3306)/webook"
+redis:
+  addr: "localhost:6379"
+jwt:
+  accessKey: "access-key-from-yaml"
+  refreshKey: "refresh-key-from-yaml"
+mfa:
+  encryptKey: "0123456789abcdef0123456789abcdef"
+sms:
+  guards:
+    - biz: "login"
+      rules:
+        - key: "phone"
+          window: "24h"
+          rate: 10
+`,
+		},
+		{
+			name: "防刷规则的人机验证阈值超过上限",
+			yaml: `
+db:
+  dsn: "root:root@tcp(localhost:3306)/webook"
+redis:
+  addr: "localhost:6379"
+jwt:
+  accessKey: "access-key-from-yaml"
+  refreshKey: "refresh-key-from-yaml"
+mfa:
+  encryptKey: "0123456789abcdef0123456789abcdef"
+sms:
+  guards:
+    - biz: "*"
+      rules:
+        - key: "phone"
+          window: "24h"
+          rate: 10
+          captchaAfter: 10
+`,
+		},
+		{
+			name: "人机验证缺少密钥",
+			yaml: `
+db:
+  dsn: "root:root@tcp(localhost:3306)/webook"
+redis:
+  addr: "localhost:6379"
+jwt:
+  accessKey: "access-key-from-yaml"
+  refreshKey: "refresh-key-from-yaml"
+mfa:
+  encryptKey: "0123456789abcdef0123456789abcdef"
+captcha:
+  provider: "turnstile"
+`,
+		},
+		{
+			name: "OIDC 提供方重名",
+			yaml: `
+db:
+  dsn: "root:root@tcp(localhost:3306)/webook"
+redis:
+  addr: "localhost:6379"
+jwt:
+  accessKey: "access-key-from-yaml"
+  refreshKey: "refresh-key-from-yaml"
+mfa:
+  encryptKey: "0123456789abcdef0123456789abcdef"
+oauth2:
+  oidc:
+    - name: "keycloak"
+      issuer: "http://localhost:8180/realms/a"
+      clientId: "webook"
+    - name: "keycloak"
+      issuer: "http://localhost:8180/realms/b"
+      clientId: "webook"
+`,
+		},
+		{
+			name: "微信登录缺少加密密钥",
+			yaml: `
+db:
+  dsn: "root:root@tcp(localhost:3306)/webook"
+redis:
+  addr: "localhost:6379"
+jwt:
+  accessKey: "access-key-from-yaml"
+  refreshKey: "refresh-key-from-yaml"
+mfa:
+  encryptKey: "0123456789abcdef0123456789abcdef"
+wechat:
+  appId: "wx-app-id"
+  appSecret: "wx-app-secret"
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "test.yaml")
+			require.NoError(t, os.WriteFile(file, []byte(tc.yaml), 0600))
+			assert.Error(t, Load([]string{"--config=" + file}))
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package domain
+
+// UserExport 导出给用户自己的数据
+// 不包含密码哈希、TOTP 密钥、恢复码、第三方 token 这些凭证
+type UserExport struct {
+	User       User
+	Identities []Identity
+	Passkeys   []Passkey
+	// TOTPEnabled 只导出有没有开启
+	TOTPEnabled bool
+	Roles       []string
+	// SecurityEvents 按时间倒序
+	SecurityEvents []SecurityEvent
+	ExportedAt     int64
+}
+
+// Reauth 敏感操作之前的重新验证
+type Reauth struct {
+	// Password 设置了密码的用户要输入密码
+	Password string
+	// TOTPCode 开启了二次验证的用户要输入验证码或者恢复码
+	TOTPCode string
+	// AuthenticatedAt 真正登录的时间，刷新 token 不会更新，没有密码的用户要求刚刚登录过
+	AuthenticatedAt int64
+}
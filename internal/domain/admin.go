@@ -0,0 +1,74 @@
+package domain
+
+import "slices"
+
+// 角色，存在 user_roles 表里面，一个用户可以有多个角色
+const (
+	RoleAdmin = "admin"
+	// RoleSupport 客服，只能查用户和解除短信限制
+	RoleSupport = "support"
+)
+
+// 权限，接口按权限校验，不直接判断角色
+const (
+	PermUserRead  = "user:read"
+	PermUserWrite = "user:write"
+	PermSMSReset  = "sms:reset"
+	PermRoleWrite = "role:write"
+)
+
+// RolePermissions 角色拥有的权限
+var RolePermissions = map[string][]string{
+	RoleAdmin:   {PermUserRead, PermUserWrite, PermSMSReset, PermRoleWrite},
+	RoleSupport: {PermUserRead, PermSMSReset},
+}
+
+// ValidRole 是不是已知的角色
+func ValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// HasPermission roles 里面有一个角色拥有 perm 就可以
+func HasPermission(roles []string, perm string) bool {
+	for _, role := range roles {
+		if slices.Contains(RolePermissions[role], perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Operator 执行管理操作的人
+type Operator struct {
+	UserID int64
+	IP     string
+}
+
+// 审计日志的操作类型
+const (
+	AuditSearchUser  = "user.search"
+	AuditViewUser    = "user.view"
+	AuditDisableUser = "user.disable"
+	AuditEnableUser  = "user.enable"
+	AuditBanUser     = "user.ban"
+	AuditForceLogout = "user.force_logout"
+	AuditSetRoles    = "user.set_roles"
+	AuditResetSMS    = "sms.reset"
+)
+
+// AuditLog 管理员操作记录，只增不改
+type AuditLog struct {
+	ID         int64
+	OperatorID int64
+	Action     string
+	// TargetID 被操作的用户，没有具体用户的操作为 0
+	TargetID int64
+	// Detail 操作参数，比如搜索的关键字、设置的角色
+	Detail string
+	IP     string
+	// Success 操作有没有成功，失败的时候 Error 是失败原因
+	Success   bool
+	Error     string
+	CreatedAt int64
+}
@@ -0,0 +1,9 @@
+package domain
+
+// ClientInfo 请求来源信息，由 web 层从请求中提取
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	// DeviceID 客户端上报的设备指纹，可能为空
+	DeviceID string
+}
@@ -0,0 +1,21 @@
+package domain
+
+// 第三方登录的提供方
+const (
+	ProviderWechat = "wechat"
+	ProviderGitHub = "github"
+)
+
+// Identity 第三方账号，通过 Provider + Subject 找到关联的用户
+type Identity struct {
+	// Provider 提供方的名字，比如 github，通用 OIDC 提供方用配置里面的名字
+	Provider string
+	// Subject 提供方内部的用户 ID，比如 OIDC 的 sub、微信的 openid
+	Subject string
+	// UnionID 微信开放平台下同一个用户的 ID，其他提供方为空
+	UnionID       string
+	Email         string
+	EmailVerified bool
+	Nickname      string
+	Avatar        string
+}
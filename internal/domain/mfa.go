@@ -0,0 +1,19 @@
+package domain
+
+// TOTP 用户的 TOTP 二次验证
+type TOTP struct {
+	UserID int64
+	// Secret 加密之后的密钥
+	Secret string
+	// Enabled 用第一个验证码确认之后才启用
+	Enabled bool
+	// LastStep 最后一次验证通过的时间周期，同一个周期的验证码不能再用
+	LastStep int64
+}
+
+// TOTPEnrollment 开启 TOTP 的时候返回给用户的信息
+type TOTPEnrollment struct {
+	Secret string
+	// URI otpauth:// 链接，前端转成二维码
+	URI string
+}
@@ -0,0 +1,21 @@
+package domain
+
+// Passkey 用户注册的通行密钥（WebAuthn 凭证），一个用户可以注册多个
+type Passkey struct {
+	ID     int64
+	UserID int64
+	// Name 用户起的名字，比如"我的 iPhone"
+	Name         string
+	CredentialID []byte
+	// PublicKey COSE 格式的公钥
+	PublicKey       []byte
+	AttestationType string
+	Transports      []string
+	AAGUID          []byte
+	SignCount       uint32
+	// BackupEligible 和 BackupState 表示密钥能不能、有没有同步到其他设备
+	BackupEligible bool
+	BackupState    bool
+	CreatedAt      int64
+	LastUsedAt     int64
+}
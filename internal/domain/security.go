@@ -0,0 +1,54 @@
+package domain
+
+// 安全事件类型
+const (
+	SecurityLoginSuccess   = "login.success"
+	SecurityLoginFailure   = "login.failure"
+	SecuritySMSSent        = "sms.sent"
+	SecuritySMSVerified    = "sms.verified"
+	SecurityTokenRefresh   = "token.refresh"
+	SecurityLogout         = "logout"
+	SecurityPasswordChange = "password.change"
+	SecurityTOTPEnable     = "2fa.enable"
+	SecurityTOTPDisable    = "2fa.disable"
+	// SecurityTOTPLocked 二次验证失败次数太多，锁定了一段时间
+	SecurityTOTPLocked    = "2fa.locked"
+	SecurityPasskeyAdd    = "passkey.add"
+	SecurityPasskeyDelete = "passkey.delete"
+)
+
+// 登录方式，第三方登录用提供方的名字，比如 github、wechat
+const (
+	LoginMethodPassword    = "password"
+	LoginMethodSMS         = "sms"
+	LoginMethodTOTP        = "2fa"
+	LoginMethodPasskey     = "passkey"
+	LoginMethodMiniProgram = "miniprogram"
+)
+
+// GeoLocation 根据 IP 查出来的大概位置，查不到的时候是零值
+type GeoLocation struct {
+	CountryCode string
+	Country     string
+	Region      string
+	City        string
+}
+
+// SecurityEvent 认证相关的安全事件，只增不改
+type SecurityEvent struct {
+	ID int64
+	// UserID 登录失败的时候可能不知道是哪个用户，为 0
+	UserID int64
+	Type   string
+	// Method 登录方式，登录相关的事件才有
+	Method string
+	// Subject 登录用的邮箱或者手机号，用户不存在的时候也能排查
+	Subject string
+	// Reason 失败的原因，错误码的文案 key，比如 user.invalid_user_or_password
+	Reason string
+	Client ClientInfo
+	Geo    GeoLocation
+	// NewDevice 登录成功，并且是这个用户第一次在这个设备上登录
+	NewDevice bool
+	CreatedAt int64
+}
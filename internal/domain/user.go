@@ -1,16 +1,48 @@
 package domain
 
+import "time"
+
 // User 领域对象
 type User struct {
 	WechatInfo
-	ID        int64
-	Email     string
-	Password  string
-	Phone     string
+	ID       int64
+	Email    string
+	Password string
+	Phone    string
+	// Nickname 和 Avatar 第三方首次登录的时候用第三方的资料填充
+	Nickname string
+	Avatar   string
+	// Locale 语言偏好，比如 en-US，为空表示跟随浏览器
+	Locale string
+	Status UserStatus
+	// BannedUntil 封禁截止时间，毫秒，只有 UserStatusBanned 的时候有意义
+	BannedUntil int64
+	// DeleteAt 申请注销之后，到这个时间匿名化，毫秒，0 表示没有申请
+	DeleteAt  int64
 	CreatedAt int64
 	UpdatedAt int64
 }
 
+// UserStatus 账号状态，管理员可以禁用或者封禁账号
+type UserStatus uint8
+
+const (
+	UserStatusActive UserStatus = iota
+	UserStatusDisabled
+	// UserStatusBanned 封禁到 BannedUntil，过期之后自动恢复正常
+	UserStatusBanned
+	// UserStatusDeleted 已经注销，个人信息都清空了
+	UserStatusDeleted
+)
+
+// StatusAt now 时刻实际的状态，封禁过期了就是正常
+func (u User) StatusAt(now time.Time) UserStatus {
+	if u.Status == UserStatusBanned && now.UnixMilli() >= u.BannedUntil {
+		return UserStatusActive
+	}
+	return u.Status
+}
+
 type Address struct {
 	Id     int64
 	UserId int64
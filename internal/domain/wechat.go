@@ -4,3 +4,40 @@ type WechatInfo struct {
 	UnionID string
 	OpenID  string
 }
+
+// WechatProfile 微信的昵称和头像，首次登录的时候填到用户资料里面
+type WechatProfile struct {
+	Nickname string
+	Avatar   string
+}
+
+// WechatToken 微信网页授权的 token，每个用户保存最新的一份
+// access_token 两个小时过期，refresh_token 30 天过期，时间都是毫秒
+type WechatToken struct {
+	UserID       int64
+	OpenID       string
+	AccessToken  string
+	RefreshToken string
+	Scope        string
+	// ExpiresAt access_token 的过期时间
+	ExpiresAt int64
+	// RefreshExpiresAt refresh_token 的过期时间，刷新 access_token 不会延长
+	RefreshExpiresAt int64
+}
+
+// WechatSession 小程序 wx.login 换到的会话，SessionKey 是加密之后的
+// 微信没有告诉 session_key 什么时候过期，解密失败的时候让小程序重新登录
+type WechatSession struct {
+	UserID     int64
+	OpenID     string
+	SessionKey string
+}
+
+// WechatEncryptedData 小程序 getUserProfile、getPhoneNumber 返回的加密数据
+type WechatEncryptedData struct {
+	// RawData 和 Signature 只有用户信息才有，用来校验数据没有被篡改
+	RawData       string
+	Signature     string
+	EncryptedData string
+	IV            string
+}
@@ -0,0 +1,57 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+	"webook/config"
+	"webook/internal/integration/startup"
+	"webook/internal/web"
+)
+
+// TestAccountHandler_Delete 导出数据，注销之后马上下线，冷静期过了匿名化，邮箱可以重新注册
+func TestAccountHandler_Delete(t *testing.T) {
+	old := config.Config
+	t.Cleanup(func() {
+		config.Config = old
+	})
+	// 不要冷静期，申请之后马上可以匿名化
+	config.Config.Account.DeleteGracePeriod = 0
+	server := startup.InitWebServer()
+	accountSvc := startup.InitAccountService()
+	email := fmt.Sprintf("deleted%d@qq.com", time.Now().UnixNano())
+
+	token, _ := signUpAndLogin(t, server, email)
+	uid := userIDFromToken(t, token)
+
+	recorder := doRequest(server, http.MethodGet, "/users/export", token, "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var exported struct {
+		Data web.ExportVO `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &exported))
+	assert.Equal(t, uid, exported.Data.Profile.ID)
+	assert.Equal(t, email, exported.Data.Profile.Email)
+
+	recorder = doRequest(server, http.MethodPost, "/users/delete", token, `{"password":"Hello#World"}`)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	recorder = doRequest(server, http.MethodPost, "/users/delete", token, `{"password":"Hello#World2024"}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	recorder = doRequest(server, http.MethodGet, "/users/profile", token, "")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	_, err := accountSvc.PurgeDue(context.Background(), 100)
+	require.NoError(t, err)
+	recorder = doRequest(server, http.MethodPost, "/users/login", "",
+		fmt.Sprintf(`{"email":%q,"password":"Hello#World2024"}`, email))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	// 邮箱的唯一索引已经释放了
+	token, _ = signUpAndLogin(t, server, email)
+	assert.NotEqual(t, uid, userIDFromToken(t, token))
+}
@@ -0,0 +1,128 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/integration/startup"
+	"webook/internal/repository/dao"
+	"webook/internal/web"
+	"webook/ioc"
+)
+
+// TestAdminHandler 管理员搜索用户并且强制下线，被下线的用户 access token 和 refresh token 都不能用了
+func TestAdminHandler(t *testing.T) {
+	server := startup.InitWebServer()
+	roleDAO := dao.NewRoleDAO(ioc.InitDB())
+	suffix := time.Now().UnixNano()
+	adminEmail := fmt.Sprintf("admin%d@qq.com", suffix)
+	targetEmail := fmt.Sprintf("target%d@qq.com", suffix)
+
+	adminToken, _ := signUpAndLogin(t, server, adminEmail)
+	adminID := userIDFromToken(t, adminToken)
+	targetToken, targetRefresh := signUpAndLogin(t, server, targetEmail)
+	targetID := userIDFromToken(t, targetToken)
+
+	// 普通用户不能访问管理接口
+	recorder := doRequest(server, http.MethodGet, "/admin/users/search?keyword="+targetEmail, adminToken, "")
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	// 角色在 token 里面，授权之后重新登录
+	require.NoError(t, roleDAO.Replace(context.Background(), adminID, []string{domain.RoleAdmin}, seedAudit(adminID)))
+	adminToken, _ = login(t, server, adminEmail)
+
+	recorder = doRequest(server, http.MethodGet, "/admin/users/search?keyword="+targetEmail, adminToken, "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var found struct {
+		Data []web.AdminUserVO `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &found))
+	require.Len(t, found.Data, 1)
+	assert.Equal(t, targetID, found.Data[0].ID)
+
+	recorder = doRequest(server, http.MethodPost, "/admin/users/logout", adminToken,
+		fmt.Sprintf(`{"id":%d}`, targetID))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = doRequest(server, http.MethodGet, "/users/profile", targetToken, "")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	recorder = doRequest(server, http.MethodPost, "/users/refresh_token", targetRefresh, "")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+// TestAdminHandler_Ban 封禁之后已经登录的 token 马上失效，封禁期内不能登录，解封之后可以
+func TestAdminHandler_Ban(t *testing.T) {
+	server := startup.InitWebServer()
+	roleDAO := dao.NewRoleDAO(ioc.InitDB())
+	suffix := time.Now().UnixNano()
+	adminEmail := fmt.Sprintf("admin%d@qq.com", suffix)
+	targetEmail := fmt.Sprintf("banned%d@qq.com", suffix)
+
+	adminToken, _ := signUpAndLogin(t, server, adminEmail)
+	require.NoError(t, roleDAO.Replace(context.Background(), userIDFromToken(t, adminToken), []string{domain.RoleAdmin},
+		seedAudit(userIDFromToken(t, adminToken))))
+	adminToken, _ = login(t, server, adminEmail)
+	targetToken, _ := signUpAndLogin(t, server, targetEmail)
+	targetID := userIDFromToken(t, targetToken)
+
+	recorder := doRequest(server, http.MethodPost, "/admin/users/ban", adminToken,
+		fmt.Sprintf(`{"id":%d,"until":%d}`, targetID, time.Now().Add(time.Hour).UnixMilli()))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = doRequest(server, http.MethodGet, "/users/profile", targetToken, "")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	recorder = doRequest(server, http.MethodPost, "/users/login", "",
+		fmt.Sprintf(`{"email":%q,"password":"Hello#World2024"}`, targetEmail))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.JSONEq(t, `{"code":200023,"msg":"账号已被封禁，请稍后再试","data":null}`, recorder.Body.String())
+
+	// 启用的时候解除封禁
+	recorder = doRequest(server, http.MethodPost, "/admin/users/enable", adminToken,
+		fmt.Sprintf(`{"id":%d}`, targetID))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	// 强制下线精确到秒，下一秒重新登录的 token 才有效
+	time.Sleep(time.Second)
+	targetToken, _ = login(t, server, targetEmail)
+	recorder = doRequest(server, http.MethodGet, "/users/profile", targetToken, "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func signUpAndLogin(t *testing.T, server *gin.Engine, email string) (string, string) {
+	recorder := doRequest(server, http.MethodPost, "/users/signup", "",
+		fmt.Sprintf(`{"email":%q,"password":"Hello#World2024","confirmPassword":"Hello#World2024"}`, email))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	return login(t, server, email)
+}
+
+// seedAudit 测试里面直接授权，和后台授权一样留一条审计日志
+func seedAudit(uid int64) dao.AdminAuditLog {
+	return dao.AdminAuditLog{Action: domain.AuditSetRoles, TargetID: uid, Detail: domain.RoleAdmin, Success: true}
+}
+
+// login 返回 access token 和 refresh token
+func login(t *testing.T, server *gin.Engine, email string) (string, string) {
+	recorder := doRequest(server, http.MethodPost, "/users/login", "",
+		fmt.Sprintf(`{"email":%q,"password":"Hello#World2024"}`, email))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	return recorder.Header().Get("x-jwt-token"), recorder.Header().Get("x-refresh-token")
+}
+
+func doRequest(server *gin.Engine, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	return recorder
+}
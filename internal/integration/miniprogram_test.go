@@ -0,0 +1,114 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webook/config"
+	"webook/internal/integration/startup"
+	"webook/internal/service/oauth2/oauth2test"
+	"webook/internal/web"
+)
+
+// TestMiniProgramHandler 小程序和网页用同一个开放平台，按 unionid 关联到同一个用户
+func TestMiniProgramHandler(t *testing.T) {
+	wechatServer := oauth2test.NewWechatServer("wx-integration", "wx-secret")
+	defer wechatServer.Close()
+	miniServer := oauth2test.NewMiniProgramServer("wx-mini", "mini-secret")
+	defer miniServer.Close()
+	// 网页和小程序共用 api.weixin.qq.com，按路径分给两个模拟服务
+	mux := http.NewServeMux()
+	mux.Handle("/sns/jscode2session", miniServer.Config.Handler)
+	mux.Handle("/", wechatServer.Config.Handler)
+	apiServer := httptest.NewServer(mux)
+	defer apiServer.Close()
+	old := config.Config
+	t.Cleanup(func() {
+		config.Config = old
+	})
+	config.Config.WeChat = config.WeChatConfig{
+		AppID:      "wx-integration",
+		AppSecret:  "wx-secret",
+		EncryptKey: "0123456789abcdef0123456789abcdef",
+		OpenURL:    wechatServer.URL,
+		APIURL:     apiServer.URL,
+		MiniProgram: config.MiniProgramConfig{
+			AppID:     "wx-mini",
+			AppSecret: "mini-secret",
+		},
+	}
+	config.Config.OAuth2.FrontendURL = "http://localhost:3000/oauth2/callback"
+	server := startup.InitWebServer()
+
+	now := time.Now().UnixNano()
+	unionID := fmt.Sprintf("unionid-%d", now)
+	wechatServer.User = oauth2test.WechatUser{
+		OpenID:  fmt.Sprintf("openid-%d", now),
+		UnionID: unionID,
+	}
+	miniServer.User = oauth2test.WechatUser{
+		OpenID:     fmt.Sprintf("mini-openid-%d", now),
+		UnionID:    unionID,
+		Nickname:   "小程序用户",
+		HeadImgURL: "https://thirdwx.qlogo.cn/mmopen/1002/132",
+	}
+	miniServer.Phone = fmt.Sprintf("139%08d", now%100000000)
+
+	// 1. 网页先登录
+	fragment := wechatLogin(t, server, wechatServer, nil)
+	require.Equal(t, "0", fragment.Get("code"))
+	uid := userIDFromToken(t, fragment.Get("access_token"))
+
+	// 2. 小程序登录，是同一个用户
+	rawData, signature, encryptedData, iv, err := miniServer.EncryptedUserInfo()
+	require.NoError(t, err)
+	recorder := miniProgramPost(t, server, "/oauth2/wechat/miniprogram/login", "", web.MiniProgramLoginReq{
+		Code:          miniServer.Login(),
+		RawData:       rawData,
+		Signature:     signature,
+		EncryptedData: encryptedData,
+		IV:            iv,
+	})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	token := recorder.Header().Get("x-jwt-token")
+	assert.Equal(t, uid, userIDFromToken(t, token))
+
+	// 3. 绑定手机号
+	encryptedPhone, phoneIV, err := miniServer.EncryptedPhone()
+	require.NoError(t, err)
+	recorder = miniProgramPost(t, server, "/oauth2/wechat/miniprogram/phone", token, web.MiniProgramPhoneReq{
+		EncryptedData: encryptedPhone,
+		IV:            phoneIV,
+	})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"code":0,"msg":"OK","data":null}`, recorder.Body.String())
+
+	// 4. 微信换了 session_key，旧的数据解不开
+	miniServer.RotateSessionKey()
+	encryptedPhone, phoneIV, err = miniServer.EncryptedPhone()
+	require.NoError(t, err)
+	recorder = miniProgramPost(t, server, "/oauth2/wechat/miniprogram/phone", token, web.MiniProgramPhoneReq{
+		EncryptedData: encryptedPhone,
+		IV:            phoneIV,
+	})
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func miniProgramPost(t *testing.T, server http.Handler, path, token string, body any) *httptest.ResponseRecorder {
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	return recorder
+}
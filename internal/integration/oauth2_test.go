@@ -0,0 +1,146 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+	"webook/config"
+	"webook/internal/integration/startup"
+	"webook/internal/service/oauth2/oauth2test"
+	"webook/internal/web"
+)
+
+// TestOAuth2Handler_WechatCallback 微信接口用本地的模拟服务，其余都是真实的依赖
+func TestOAuth2Handler_WechatCallback(t *testing.T) {
+	wechatServer := oauth2test.NewWechatServer("wx-integration", "wx-secret")
+	defer wechatServer.Close()
+	old := config.Config
+	t.Cleanup(func() {
+		config.Config = old
+	})
+	config.Config.WeChat = config.WeChatConfig{
+		AppID:      "wx-integration",
+		AppSecret:  "wx-secret",
+		EncryptKey: "0123456789abcdef0123456789abcdef",
+		OpenURL:    wechatServer.URL,
+		APIURL:     wechatServer.URL,
+	}
+	config.Config.OAuth2.FrontendURL = "http://localhost:3000/oauth2/callback"
+	server := startup.InitWebServer()
+
+	testCases := []struct {
+		name string
+		// callback 拿着授权地址走一遍回调，返回跳转回前端的 fragment
+		callback func(t *testing.T) url.Values
+
+		wantCode string
+	}{
+		{
+			name: "首次登录创建用户，再次登录还是同一个用户",
+			callback: func(t *testing.T) url.Values {
+				wechatServer.User = oauth2test.WechatUser{
+					OpenID:     fmt.Sprintf("openid-%d", time.Now().UnixNano()),
+					UnionID:    fmt.Sprintf("unionid-%d", time.Now().UnixNano()),
+					Nickname:   "微信用户",
+					HeadImgURL: "https://thirdwx.qlogo.cn/mmopen/1001/132",
+				}
+				first := wechatLogin(t, server, wechatServer, nil)
+				require.Equal(t, "0", first.Get("code"))
+				uid := userIDFromToken(t, first.Get("access_token"))
+
+				// 拿着 token 访问需要登录的接口
+				req := httptest.NewRequest(http.MethodGet, "/users/profile", nil)
+				req.Header.Set("Authorization", "Bearer "+first.Get("access_token"))
+				recorder := httptest.NewRecorder()
+				server.ServeHTTP(recorder, req)
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				// 昵称和头像用微信的资料填充
+				var profile struct {
+					Data web.ProfileVO `json:"data"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &profile))
+				assert.Equal(t, "微信用户", profile.Data.Nickname)
+				assert.Equal(t, "https://thirdwx.qlogo.cn/mmopen/1001/132", profile.Data.Avatar)
+
+				second := wechatLogin(t, server, wechatServer, nil)
+				assert.Equal(t, uid, userIDFromToken(t, second.Get("access_token")))
+				return second
+			},
+			wantCode: "0",
+		},
+		{
+			name: "state 不匹配",
+			callback: func(t *testing.T) url.Values {
+				return wechatLogin(t, server, wechatServer, func(q url.Values) {
+					q.Set("state", "other-state")
+				})
+			},
+			wantCode: "201001",
+		},
+		{
+			name: "授权码无效",
+			callback: func(t *testing.T) url.Values {
+				return wechatLogin(t, server, wechatServer, func(q url.Values) {
+					q.Set("code", "invalid-code")
+				})
+			},
+			wantCode: "201002",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fragment := tc.callback(t)
+			assert.Equal(t, tc.wantCode, fragment.Get("code"))
+		})
+	}
+}
+
+// wechatLogin 获取授权地址，在模拟服务上确认授权，然后回调
+// modify 修改回调的参数
+func wechatLogin(t *testing.T, server *gin.Engine, wechatServer *oauth2test.WechatServer,
+	modify func(q url.Values)) url.Values {
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/oauth2/wechat/authurl", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var res web.Result
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	code, state, err := wechatServer.Authorize(res.Data.(string))
+	require.NoError(t, err)
+	q := url.Values{"code": {code}, "state": {state}}
+	if modify != nil {
+		modify(q)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/oauth2/wechat/callback?"+q.Encode(), nil)
+	req.AddCookie(cookies[0])
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusFound, recorder.Code)
+
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:3000", location.Host)
+	fragment, err := url.ParseQuery(location.Fragment)
+	require.NoError(t, err)
+	return fragment
+}
+
+func userIDFromToken(t *testing.T, token string) int64 {
+	var claims web.UserClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.Config.JWT.AccessKey), nil
+	})
+	require.NoError(t, err)
+	return claims.UserID
+}
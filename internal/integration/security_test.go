@@ -0,0 +1,46 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/integration/startup"
+	"webook/internal/web"
+)
+
+// TestSecurityHandler_Events 登录成功和失败都记下来，用户按时间倒序查看
+func TestSecurityHandler_Events(t *testing.T) {
+	server := startup.InitWebServer()
+	email := fmt.Sprintf("security%d@qq.com", time.Now().UnixNano())
+
+	token, _ := signUpAndLogin(t, server, email)
+	recorder := doRequest(server, http.MethodPost, "/users/login", "",
+		fmt.Sprintf(`{"email":%q,"password":"Hello#World"}`, email))
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	recorder = doRequest(server, http.MethodGet, "/users/security/events?limit=10", token, "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var res struct {
+		Data []web.SecurityEventVO `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+	require.Len(t, res.Data, 2)
+	assert.Equal(t, domain.SecurityLoginFailure, res.Data[0].Type)
+	assert.Equal(t, "user.invalid_user_or_password", res.Data[0].Reason)
+	assert.Equal(t, domain.SecurityLoginSuccess, res.Data[1].Type)
+	assert.Equal(t, domain.LoginMethodPassword, res.Data[1].Method)
+	assert.False(t, res.Data[1].NewDevice)
+
+	// 翻页
+	recorder = doRequest(server, http.MethodGet,
+		fmt.Sprintf("/users/security/events?cursor=%d", res.Data[0].ID), token, "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+	require.Len(t, res.Data, 1)
+	assert.Equal(t, domain.SecurityLoginSuccess, res.Data[0].Type)
+}
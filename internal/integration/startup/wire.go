@@ -16,22 +16,52 @@ import (
 func InitWebServer() *gin.Engine {
 	wire.Build(
 		// 底层存储
-		ioc.InitDB, InitRedis,
+		ioc.InitLogger, ioc.InitDB, InitRedis, ioc.InitRedisHealthChecker, ioc.InitI18n,
 
 		// dao & cache
-		dao.NewUserDAO,
-		cache.NewUserCache, cache.NewCodeCache,
+		dao.NewUserDAO, dao.NewPasswordHistoryDAO, dao.NewMFADAO, dao.NewPasskeyDAO,
+		dao.NewWechatTokenDAO, dao.NewWechatSessionDAO, dao.NewRoleDAO, dao.NewAdminAuditDAO,
+		dao.NewSecurityEventDAO,
+		cache.NewUserCache, cache.NewCodeCache, cache.NewMFATicketCache, cache.NewMFAFailureCache,
+		cache.NewPasskeySessionCache, cache.NewSessionCache,
 
 		// repository
 		repository.NewCachedUserRepository, repository.NewCodeRepository,
+		repository.NewPasswordHistoryRepository, repository.NewMFARepository,
+		repository.NewPasskeyRepository, repository.NewWechatTokenRepository,
+		repository.NewWechatSessionRepository, repository.NewRoleRepository,
+		repository.NewAdminAuditRepository, repository.NewSessionRepository,
+		repository.NewSecurityEventRepository,
 
 		// service
-		ioc.InitSMSService,
+		ioc.InitSMSService, ioc.InitCodeTemplates,
+		ioc.InitPasswordPolicy, ioc.InitPasswordHasher,
 		service.NewUserService, service.NewCodeService,
+		ioc.InitCaptchaService, ioc.InitCodeGuard, ioc.InitMFAService,
+		ioc.InitWebAuthn, service.NewPasskeyService, ioc.InitWechatTokenService,
+		ioc.InitWechatMiniProgramService,
+		service.NewRoleService, service.NewSessionService, service.NewAdminService,
+		ioc.InitAccountService, ioc.InitSecurityEventService,
 
 		// handler
-		web.NewUserHandler, ioc.InitGinMiddlewares, ioc.InitWebServer,
-		web.NewOAuth2WechatHandler, ioc.InitWechatService,
+		ioc.InitJWTHandler, web.NewUserHandler, ioc.InitGinMiddlewares, ioc.InitWebServer,
+		ioc.InitOAuth2Handler, web.NewMiniProgramHandler, web.NewAdminHandler, ioc.InitOAuth2Providers,
+		web.NewAccountHandler, web.NewSecurityHandler,
 	)
 	return gin.Default()
 }
+
+// InitAccountService 测试里面直接调用 PurgeDue，不用等后台任务
+func InitAccountService() service.AccountService {
+	wire.Build(
+		ioc.InitLogger, ioc.InitDB, InitRedis, ioc.InitRedisHealthChecker,
+		dao.NewUserDAO, dao.NewPasskeyDAO, dao.NewMFADAO, dao.NewRoleDAO, dao.NewSecurityEventDAO,
+		cache.NewUserCache, cache.NewMFATicketCache, cache.NewMFAFailureCache, cache.NewPasskeySessionCache,
+		cache.NewSessionCache,
+		repository.NewCachedUserRepository, repository.NewPasskeyRepository, repository.NewMFARepository,
+		repository.NewRoleRepository, repository.NewSessionRepository, repository.NewSecurityEventRepository,
+		ioc.InitPasswordHasher, ioc.InitMFAService, service.NewSessionService,
+		ioc.InitAccountService,
+	)
+	return nil
+}
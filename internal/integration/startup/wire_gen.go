@@ -23,16 +23,89 @@ func InitWebServer() *gin.Engine {
 	userDAO := dao.NewUserDAO(db)
 	cmdable := InitRedis()
 	userCache := cache.NewUserCache(cmdable)
-	userRepository := repository.NewCachedUserRepository(userDAO, userCache)
-	userService := service.NewUserService(userRepository)
+	healthChecker := ioc.InitRedisHealthChecker(cmdable)
+	logger := ioc.InitLogger()
+	userRepository := repository.NewCachedUserRepository(userDAO, userCache, healthChecker, logger)
+	passwordHistoryDAO := dao.NewPasswordHistoryDAO(db)
+	passwordHistoryRepository := repository.NewPasswordHistoryRepository(passwordHistoryDAO)
+	policy := ioc.InitPasswordPolicy()
+	hasher := ioc.InitPasswordHasher()
+	userService := service.NewUserService(userRepository, passwordHistoryRepository, policy, hasher, logger)
 	codeCache := cache.NewCodeCache(cmdable)
 	codeRepository := repository.NewCodeRepository(codeCache)
-	smsService := ioc.InitSMSService()
-	codeService := service.NewCodeService(codeRepository, smsService)
-	userHandler := web.NewUserHandler(userService, codeService)
-	wechatService := ioc.InitWechatService()
-	oAuth2WechatHandler := web.NewOAuth2WechatHandler(wechatService)
-	v := ioc.InitGinMiddlewares(cmdable)
-	engine := ioc.InitWebServer(userHandler, oAuth2WechatHandler, v)
+	smsService := ioc.InitSMSService(logger)
+	codeTemplates := ioc.InitCodeTemplates()
+	codeService := service.NewCodeService(codeRepository, smsService, codeTemplates, logger)
+	captchaService := ioc.InitCaptchaService(logger)
+	codeGuard := ioc.InitCodeGuard(cmdable, captchaService)
+	mfadao := dao.NewMFADAO(db)
+	mfaTicketCache := cache.NewMFATicketCache(cmdable)
+	mfaFailureCache := cache.NewMFAFailureCache(cmdable)
+	mfaRepository := repository.NewMFARepository(mfadao, mfaTicketCache, mfaFailureCache)
+	mfaService := ioc.InitMFAService(mfaRepository, logger)
+	passkeyDAO := dao.NewPasskeyDAO(db)
+	passkeySessionCache := cache.NewPasskeySessionCache(cmdable)
+	passkeyRepository := repository.NewPasskeyRepository(passkeyDAO, passkeySessionCache)
+	webAuthn := ioc.InitWebAuthn()
+	passkeyService := service.NewPasskeyService(passkeyRepository, webAuthn, logger)
+	roleDAO := dao.NewRoleDAO(db)
+	roleRepository := repository.NewRoleRepository(roleDAO)
+	roleService := service.NewRoleService(roleRepository)
+	sessionCache := cache.NewSessionCache(cmdable)
+	sessionRepository := repository.NewSessionRepository(sessionCache)
+	sessionService := service.NewSessionService(sessionRepository, userRepository, logger)
+	securityEventDAO := dao.NewSecurityEventDAO(db)
+	securityEventRepository := repository.NewSecurityEventRepository(securityEventDAO)
+	securityEventService := ioc.InitSecurityEventService(securityEventRepository, userRepository, logger)
+	jwtHandler := ioc.InitJWTHandler(roleService, sessionService, securityEventService)
+	bundle := ioc.InitI18n()
+	userHandler := web.NewUserHandler(userService, codeService, codeGuard, mfaService, passkeyService, jwtHandler, bundle, logger)
+	registry := ioc.InitOAuth2Providers()
+	wechatTokenDAO := dao.NewWechatTokenDAO(db)
+	wechatTokenRepository := repository.NewWechatTokenRepository(wechatTokenDAO)
+	wechatTokenService := ioc.InitWechatTokenService(wechatTokenRepository, registry, logger)
+	oAuth2Handler := ioc.InitOAuth2Handler(registry, userService, mfaService, wechatTokenService, jwtHandler, logger)
+	wechatSessionDAO := dao.NewWechatSessionDAO(db)
+	wechatSessionRepository := repository.NewWechatSessionRepository(wechatSessionDAO)
+	wechatMiniProgramService := ioc.InitWechatMiniProgramService(wechatSessionRepository, userService, logger)
+	miniProgramHandler := web.NewMiniProgramHandler(wechatMiniProgramService, userService, mfaService, jwtHandler, logger)
+	adminAuditDAO := dao.NewAdminAuditDAO(db)
+	adminAuditRepository := repository.NewAdminAuditRepository(adminAuditDAO)
+	adminService := service.NewAdminService(userRepository, roleRepository, adminAuditRepository, sessionService, codeGuard, logger)
+	adminHandler := web.NewAdminHandler(adminService, logger)
+	accountService := ioc.InitAccountService(userRepository, passkeyRepository, roleRepository, securityEventRepository, mfaService, sessionService, hasher, logger)
+	accountHandler := web.NewAccountHandler(accountService, securityEventService, logger)
+	securityHandler := web.NewSecurityHandler(securityEventService, logger)
+	v := ioc.InitGinMiddlewares(cmdable, registry, sessionService, bundle, logger)
+	engine := ioc.InitWebServer(userHandler, oAuth2Handler, miniProgramHandler, adminHandler, accountHandler, securityHandler, v)
 	return engine
 }
+
+// InitAccountService 测试里面直接调用 PurgeDue，不用等后台任务
+func InitAccountService() service.AccountService {
+	db := ioc.InitDB()
+	userDAO := dao.NewUserDAO(db)
+	cmdable := InitRedis()
+	userCache := cache.NewUserCache(cmdable)
+	healthChecker := ioc.InitRedisHealthChecker(cmdable)
+	logger := ioc.InitLogger()
+	userRepository := repository.NewCachedUserRepository(userDAO, userCache, healthChecker, logger)
+	passkeyDAO := dao.NewPasskeyDAO(db)
+	passkeySessionCache := cache.NewPasskeySessionCache(cmdable)
+	passkeyRepository := repository.NewPasskeyRepository(passkeyDAO, passkeySessionCache)
+	roleDAO := dao.NewRoleDAO(db)
+	roleRepository := repository.NewRoleRepository(roleDAO)
+	securityEventDAO := dao.NewSecurityEventDAO(db)
+	securityEventRepository := repository.NewSecurityEventRepository(securityEventDAO)
+	mfadao := dao.NewMFADAO(db)
+	mfaTicketCache := cache.NewMFATicketCache(cmdable)
+	mfaFailureCache := cache.NewMFAFailureCache(cmdable)
+	mfaRepository := repository.NewMFARepository(mfadao, mfaTicketCache, mfaFailureCache)
+	mfaService := ioc.InitMFAService(mfaRepository, logger)
+	sessionCache := cache.NewSessionCache(cmdable)
+	sessionRepository := repository.NewSessionRepository(sessionCache)
+	sessionService := service.NewSessionService(sessionRepository, userRepository, logger)
+	hasher := ioc.InitPasswordHasher()
+	accountService := ioc.InitAccountService(userRepository, passkeyRepository, roleRepository, securityEventRepository, mfaService, sessionService, hasher, logger)
+	return accountService
+}
@@ -11,12 +11,16 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+	"webook/config"
 	"webook/internal/integration/startup"
 	"webook/internal/web"
 )
 
 func init() {
 	gin.SetMode(gin.ReleaseMode)
+	if err := config.Load([]string{"--config=../../config/dev.yaml"}); err != nil {
+		panic(err)
+	}
 }
 
 // todo: 测试有问题，以后在搞
@@ -42,7 +46,7 @@ func TestUserHandler_SendSmsCode(t *testing.T) {
 			after: func(t *testing.T) {
 				ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 				defer cancel()
-				key := "phone_code:login:15212345678"
+				key := "phone_code:login:+8615212345678"
 				code, err := rdb.Get(ctx, key).Result()
 				assert.NoError(t, err)
 				assert.True(t, len(code) > 0)
@@ -52,10 +56,11 @@ func TestUserHandler_SendSmsCode(t *testing.T) {
 				err = rdb.Del(ctx, key).Err()
 				assert.NoError(t, err)
 			},
-			phone:    "15212345678",
+			phone:    "+8615212345678",
 			wantCode: http.StatusOK,
 			wantBody: web.Result{
-				Msg: "发送成功",
+				// 默认语言是简体中文
+				Msg: "成功",
 			},
 		},
 		{
@@ -64,10 +69,13 @@ func TestUserHandler_SendSmsCode(t *testing.T) {
 
 			},
 			after:    func(t *testing.T) {},
-			wantCode: http.StatusOK,
+			wantCode: http.StatusBadRequest,
 			wantBody: web.Result{
-				Code: 4,
-				Msg:  "请输入手机号码",
+				Code: 100001,
+				Msg:  "参数错误",
+				Data: []any{
+					map[string]any{"field": "phone", "tag": "required", "msg": "phone 不能为空"},
+				},
 			},
 		},
 		{
@@ -75,22 +83,22 @@ func TestUserHandler_SendSmsCode(t *testing.T) {
 			before: func(t *testing.T) {
 				ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 				defer cancel()
-				key := "phone_code:login:15212345678"
+				key := "phone_code:login:+8615212345678"
 				err := rdb.Set(ctx, key, "123456", time.Minute*9+time.Second*50).Err()
 				assert.NoError(t, err)
 			},
 			after: func(t *testing.T) {
 				ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 				defer cancel()
-				key := "phone_code:login:15212345678"
+				key := "phone_code:login:+8615212345678"
 				code, err := rdb.GetDel(ctx, key).Result()
 				assert.NoError(t, err)
 				assert.Equal(t, "123456", code)
 			},
-			phone:    "15212345678",
-			wantCode: http.StatusOK,
+			phone:    "+8615212345678",
+			wantCode: http.StatusTooManyRequests,
 			wantBody: web.Result{
-				Code: 4,
+				Code: 200009,
 				Msg:  "短信发送太频繁，请稍后再试",
 			},
 		},
@@ -113,9 +121,6 @@ func TestUserHandler_SendSmsCode(t *testing.T) {
 			server.ServeHTTP(recorder, req)
 			// 断言结果
 			assert.Equal(t, tc.wantCode, recorder.Code)
-			if tc.wantCode != http.StatusOK {
-				return
-			}
 			var res web.Result
 			err = json.NewDecoder(recorder.Body).Decode(&res)
 			assert.NoError(t, err)
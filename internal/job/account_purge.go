@@ -0,0 +1,57 @@
+package job
+
+import (
+	"context"
+	"time"
+	"webook/internal/service"
+	"webook/pkg/logger"
+)
+
+// AccountPurgeJob 定时匿名化冷静期已经过了的账号
+// 多个实例同时跑也没关系，已经匿名化过的账号不会再处理
+type AccountPurgeJob struct {
+	svc      service.AccountService
+	interval time.Duration
+	batch    int
+	l        logger.Logger
+}
+
+func NewAccountPurgeJob(svc service.AccountService, interval time.Duration, l logger.Logger) *AccountPurgeJob {
+	return &AccountPurgeJob{
+		svc:      svc,
+		interval: interval,
+		batch:    100,
+		l:        l,
+	}
+}
+
+// Start 启动的时候先跑一次，之后每隔 interval 跑一次，阻塞到 ctx 取消
+func (j *AccountPurgeJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		j.Run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run 一批处理满了说明可能还有，接着处理，有失败的留到下一轮
+func (j *AccountPurgeJob) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := j.svc.PurgeDue(ctx, j.batch)
+		if err != nil {
+			j.l.Error(ctx, "查询待注销的账号失败", logger.Error(err))
+			return
+		}
+		if n > 0 {
+			j.l.Info(ctx, "匿名化注销的账号", logger.Int("count", n))
+		}
+		if n < j.batch {
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/service"
+	mocksvc "webook/internal/service/mock"
+	"webook/pkg/logger"
+)
+
+func TestAccountPurgeJob_Run(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.AccountService
+	}{
+		{
+			name: "一批满了接着处理",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := mocksvc.NewMockAccountService(ctrl)
+				svc.EXPECT().PurgeDue(gomock.Any(), 2).Return(2, nil).Times(2)
+				svc.EXPECT().PurgeDue(gomock.Any(), 2).Return(1, nil)
+				return svc
+			},
+		},
+		{
+			name: "没有要处理的",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := mocksvc.NewMockAccountService(ctrl)
+				svc.EXPECT().PurgeDue(gomock.Any(), 2).Return(0, nil)
+				return svc
+			},
+		},
+		{
+			name: "出错了留到下一轮",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := mocksvc.NewMockAccountService(ctrl)
+				svc.EXPECT().PurgeDue(gomock.Any(), 2).Return(0, errors.New("db 错误"))
+				return svc
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			j := NewAccountPurgeJob(tc.mock(ctrl), time.Hour, logger.NewNopLogger())
+			j.batch = 2
+			j.Run(context.Background())
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+type AdminAuditRepository interface {
+	Create(ctx context.Context, log domain.AuditLog) error
+}
+
+type adminAuditRepository struct {
+	dao dao.AdminAuditDAO
+}
+
+func NewAdminAuditRepository(dao dao.AdminAuditDAO) AdminAuditRepository {
+	return &adminAuditRepository{dao: dao}
+}
+
+func (repo *adminAuditRepository) Create(ctx context.Context, log domain.AuditLog) error {
+	ctx, span := tracer.Start(ctx, "AdminAuditRepository.Create")
+	defer span.End()
+	return repo.dao.Insert(ctx, auditToEntity(log))
+}
+
+func auditToEntity(log domain.AuditLog) dao.AdminAuditLog {
+	return dao.AdminAuditLog{
+		OperatorID: log.OperatorID,
+		Action:     log.Action,
+		TargetID:   log.TargetID,
+		Detail:     log.Detail,
+		IP:         log.IP,
+		Success:    log.Success,
+		Error:      log.Error,
+	}
+}
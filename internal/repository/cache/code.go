@@ -62,6 +62,8 @@ func (c *RedisCodeCache) Set(ctx context.Context, biz, phone, code string) error
 func (c *RedisCodeCache) Verify(ctx context.Context, biz, phone, code string) (bool, error) {
 	// 获取缓存中的验证码。
 	res, err := c.client.Eval(ctx, luaVerifyCode, []string{c.Key(biz, phone)}, code).Int()
+	// -2 表示验证码不存在或者已经过期，算作未命中
+	observeCache("code", res != -2, err)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,15 @@
+-- 记一次二次验证失败，窗口内失败次数到了上限就把过期时间延长成冷却时间
+-- 这一次刚好触发锁定返回 1，否则返回 0
+local key = KEYS[1]
+local maxFailures = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cooldown = tonumber(ARGV[3])
+local cnt = redis.call("incr", key)
+if cnt == 1 then
+    redis.call("expire", key, window)
+end
+if cnt == maxFailures then
+    redis.call("expire", key, cooldown)
+    return 1
+end
+return 0
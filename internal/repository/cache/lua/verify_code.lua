@@ -5,7 +5,10 @@ local expectedCode = ARGV[1]
 local cnt = tonumber(redis.call("get", cntKey))
 local code = redis.call("get", key)
 
-if cnt == nil or cnt <= 0 then
+if cnt == nil then
+    -- 验证码不存在或者已经过期
+    return -2
+elseif cnt <= 0 then
     return -1
 elseif code == expectedCode then
     redis.call("set", cntKey, 0)
@@ -0,0 +1,14 @@
+-- 校验二次验证的票据，每次校验都算一次尝试
+-- 票据不存在返回 -1，尝试次数太多返回 -2（同时删除票据），否则返回用户 ID
+local key = KEYS[1]
+local maxAttempts = tonumber(ARGV[1])
+local uid = redis.call("hget", key, "uid")
+if not uid then
+    return -1
+end
+local attempts = redis.call("hincrby", key, "attempts", 1)
+if attempts > maxAttempts then
+    redis.call("del", key)
+    return -2
+end
+return tonumber(uid)
@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// cacheResults 缓存命中情况，result 为 hit、miss、error
+var cacheResults = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "webook",
+	Subsystem: "cache",
+	Name:      "requests_total",
+	Help:      "缓存命中情况",
+}, []string{"cache", "result"})
+
+func observeCache(cache string, hit bool, err error) {
+	result := "miss"
+	switch {
+	case err != nil:
+		result = "error"
+	case hit:
+		result = "hit"
+	}
+	cacheResults.WithLabelValues(cache, result).Inc()
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed lua/incr_mfa_failure.lua
+var luaIncrMFAFailure string
+
+// MFAFailureCache 按用户统计二次验证失败的次数，不管是哪个票据、哪个接口
+// 次数到了上限之后锁定一段时间，换票据也不能接着猜
+type MFAFailureCache interface {
+	// Locked 还在冷却时间内返回 true
+	Locked(ctx context.Context, uid int64) (bool, error)
+	// Incr 记一次失败，这一次刚好触发锁定的时候返回 true
+	Incr(ctx context.Context, uid int64) (bool, error)
+	// Reset 验证通过之后清空失败次数
+	Reset(ctx context.Context, uid int64) error
+}
+
+type RedisMFAFailureCache struct {
+	client redis.Cmdable
+	// window 统计失败次数的时间窗口
+	window      time.Duration
+	cooldown    time.Duration
+	maxFailures int
+}
+
+func NewMFAFailureCache(client redis.Cmdable) MFAFailureCache {
+	return &RedisMFAFailureCache{
+		client:      client,
+		window:      time.Minute * 15,
+		cooldown:    time.Minute * 15,
+		maxFailures: 10,
+	}
+}
+
+func (c *RedisMFAFailureCache) Locked(ctx context.Context, uid int64) (bool, error) {
+	cnt, err := c.client.Get(ctx, c.Key(uid)).Int()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return cnt >= c.maxFailures, nil
+}
+
+func (c *RedisMFAFailureCache) Incr(ctx context.Context, uid int64) (bool, error) {
+	res, err := c.client.Eval(ctx, luaIncrMFAFailure, []string{c.Key(uid)},
+		c.maxFailures, int(c.window.Seconds()), int(c.cooldown.Seconds())).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+func (c *RedisMFAFailureCache) Reset(ctx context.Context, uid int64) error {
+	return c.client.Del(ctx, c.Key(uid)).Err()
+}
+
+func (c *RedisMFAFailureCache) Key(uid int64) string {
+	return fmt.Sprintf("mfa:failure:%d", uid)
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"webook/internal/repository/cache/redis_mock"
+)
+
+func TestRedisMFAFailureCache_Locked(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+
+		wantLocked bool
+	}{
+		{
+			name: "没有失败过",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				res := redis_mock.NewMockCmdable(ctrl)
+				cmd := redis.NewStringCmd(context.Background())
+				cmd.SetErr(redis.Nil)
+				res.EXPECT().Get(gomock.Any(), "mfa:failure:1").Return(cmd)
+				return res
+			},
+		},
+		{
+			name: "失败次数还没到上限",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				res := redis_mock.NewMockCmdable(ctrl)
+				cmd := redis.NewStringCmd(context.Background())
+				cmd.SetVal("9")
+				res.EXPECT().Get(gomock.Any(), "mfa:failure:1").Return(cmd)
+				return res
+			},
+		},
+		{
+			name: "到了上限，锁定",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				res := redis_mock.NewMockCmdable(ctrl)
+				cmd := redis.NewStringCmd(context.Background())
+				cmd.SetVal("10")
+				res.EXPECT().Get(gomock.Any(), "mfa:failure:1").Return(cmd)
+				return res
+			},
+			wantLocked: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			c := NewMFAFailureCache(tc.mock(ctrl))
+			locked, err := c.Locked(context.Background(), 1)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantLocked, locked)
+		})
+	}
+}
+
+func TestRedisMFAFailureCache_Incr(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+
+		wantTriggered bool
+	}{
+		{
+			name: "还没到上限",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				res := redis_mock.NewMockCmdable(ctrl)
+				cmd := redis.NewCmd(context.Background())
+				cmd.SetVal(int64(0))
+				res.EXPECT().Eval(gomock.Any(), luaIncrMFAFailure, []string{"mfa:failure:1"},
+					10, 900, 900).Return(cmd)
+				return res
+			},
+		},
+		{
+			name: "这一次触发锁定",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				res := redis_mock.NewMockCmdable(ctrl)
+				cmd := redis.NewCmd(context.Background())
+				cmd.SetVal(int64(1))
+				res.EXPECT().Eval(gomock.Any(), luaIncrMFAFailure, []string{"mfa:failure:1"},
+					10, 900, 900).Return(cmd)
+				return res
+			},
+			wantTriggered: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			c := NewMFAFailureCache(tc.mock(ctrl))
+			triggered, err := c.Incr(context.Background(), 1)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantTriggered, triggered)
+		})
+	}
+}
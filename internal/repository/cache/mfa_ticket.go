@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed lua/verify_mfa_ticket.lua
+var luaVerifyMFATicket string
+
+var (
+	ErrMFATicketNotFound    = errors.New("二次验证票据不存在或者已经过期")
+	ErrMFATicketTooManyTime = errors.New("二次验证尝试次数太多")
+)
+
+// MFATicketCache 密码校验通过之后、二次验证之前的票据
+type MFATicketCache interface {
+	Set(ctx context.Context, ticket string, uid int64) error
+	// Verify 每调用一次算一次尝试，超过次数之后票据作废
+	Verify(ctx context.Context, ticket string) (int64, error)
+	Del(ctx context.Context, ticket string) error
+}
+
+type RedisMFATicketCache struct {
+	client      redis.Cmdable
+	expiration  time.Duration
+	maxAttempts int
+}
+
+func NewMFATicketCache(client redis.Cmdable) MFATicketCache {
+	return &RedisMFATicketCache{
+		client:      client,
+		expiration:  time.Minute * 5,
+		maxAttempts: 5,
+	}
+}
+
+func (c *RedisMFATicketCache) Set(ctx context.Context, ticket string, uid int64) error {
+	key := c.Key(ticket)
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "uid", uid, "attempts", 0)
+		pipe.Expire(ctx, key, c.expiration)
+		return nil
+	})
+	return err
+}
+
+func (c *RedisMFATicketCache) Verify(ctx context.Context, ticket string) (int64, error) {
+	res, err := c.client.Eval(ctx, luaVerifyMFATicket, []string{c.Key(ticket)}, c.maxAttempts).Int64()
+	if err != nil {
+		return 0, err
+	}
+	switch res {
+	case -1:
+		return 0, ErrMFATicketNotFound
+	case -2:
+		return 0, ErrMFATicketTooManyTime
+	default:
+		return res, nil
+	}
+}
+
+func (c *RedisMFATicketCache) Del(ctx context.Context, ticket string) error {
+	return c.client.Del(ctx, c.Key(ticket)).Err()
+}
+
+func (c *RedisMFATicketCache) Key(ticket string) string {
+	return fmt.Sprintf("mfa:ticket:%s", ticket)
+}
@@ -0,0 +1,84 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/cache/mfa_failure.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/cache/mfa_failure.go -package=cache_mocksvc -destination=./internal/repository/cache/mock/mfa_failure.mock.go
+//
+
+// Package cache_mocksvc is a generated GoMock package.
+package cache_mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockMFAFailureCache is a mock of MFAFailureCache interface.
+type MockMFAFailureCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockMFAFailureCacheMockRecorder
+}
+
+// MockMFAFailureCacheMockRecorder is the mock recorder for MockMFAFailureCache.
+type MockMFAFailureCacheMockRecorder struct {
+	mock *MockMFAFailureCache
+}
+
+// NewMockMFAFailureCache creates a new mock instance.
+func NewMockMFAFailureCache(ctrl *gomock.Controller) *MockMFAFailureCache {
+	mock := &MockMFAFailureCache{ctrl: ctrl}
+	mock.recorder = &MockMFAFailureCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMFAFailureCache) EXPECT() *MockMFAFailureCacheMockRecorder {
+	return m.recorder
+}
+
+// Incr mocks base method.
+func (m *MockMFAFailureCache) Incr(ctx context.Context, uid int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Incr", ctx, uid)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Incr indicates an expected call of Incr.
+func (mr *MockMFAFailureCacheMockRecorder) Incr(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Incr", reflect.TypeOf((*MockMFAFailureCache)(nil).Incr), ctx, uid)
+}
+
+// Locked mocks base method.
+func (m *MockMFAFailureCache) Locked(ctx context.Context, uid int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Locked", ctx, uid)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Locked indicates an expected call of Locked.
+func (mr *MockMFAFailureCacheMockRecorder) Locked(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Locked", reflect.TypeOf((*MockMFAFailureCache)(nil).Locked), ctx, uid)
+}
+
+// Reset mocks base method.
+func (m *MockMFAFailureCache) Reset(ctx context.Context, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", ctx, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockMFAFailureCacheMockRecorder) Reset(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockMFAFailureCache)(nil).Reset), ctx, uid)
+}
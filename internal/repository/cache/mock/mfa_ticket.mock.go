@@ -0,0 +1,83 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/cache/mfa_ticket.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/cache/mfa_ticket.go -package=cache_mocksvc -destination=./internal/repository/cache/mock/mfa_ticket.mock.go
+//
+
+// Package cache_mocksvc is a generated GoMock package.
+package cache_mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockMFATicketCache is a mock of MFATicketCache interface.
+type MockMFATicketCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockMFATicketCacheMockRecorder
+}
+
+// MockMFATicketCacheMockRecorder is the mock recorder for MockMFATicketCache.
+type MockMFATicketCacheMockRecorder struct {
+	mock *MockMFATicketCache
+}
+
+// NewMockMFATicketCache creates a new mock instance.
+func NewMockMFATicketCache(ctrl *gomock.Controller) *MockMFATicketCache {
+	mock := &MockMFATicketCache{ctrl: ctrl}
+	mock.recorder = &MockMFATicketCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMFATicketCache) EXPECT() *MockMFATicketCacheMockRecorder {
+	return m.recorder
+}
+
+// Del mocks base method.
+func (m *MockMFATicketCache) Del(ctx context.Context, ticket string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Del", ctx, ticket)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Del indicates an expected call of Del.
+func (mr *MockMFATicketCacheMockRecorder) Del(ctx, ticket any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockMFATicketCache)(nil).Del), ctx, ticket)
+}
+
+// Set mocks base method.
+func (m *MockMFATicketCache) Set(ctx context.Context, ticket string, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", ctx, ticket, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set.
+func (mr *MockMFATicketCacheMockRecorder) Set(ctx, ticket, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockMFATicketCache)(nil).Set), ctx, ticket, uid)
+}
+
+// Verify mocks base method.
+func (m *MockMFATicketCache) Verify(ctx context.Context, ticket string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", ctx, ticket)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockMFATicketCacheMockRecorder) Verify(ctx, ticket any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockMFATicketCache)(nil).Verify), ctx, ticket)
+}
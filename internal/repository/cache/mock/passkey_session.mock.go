@@ -0,0 +1,70 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/cache/passkey_session.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/cache/passkey_session.go -package=cache_mocksvc -destination=./internal/repository/cache/mock/passkey_session.mock.go
+//
+
+// Package cache_mocksvc is a generated GoMock package.
+package cache_mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockPasskeySessionCache is a mock of PasskeySessionCache interface.
+type MockPasskeySessionCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockPasskeySessionCacheMockRecorder
+}
+
+// MockPasskeySessionCacheMockRecorder is the mock recorder for MockPasskeySessionCache.
+type MockPasskeySessionCacheMockRecorder struct {
+	mock *MockPasskeySessionCache
+}
+
+// NewMockPasskeySessionCache creates a new mock instance.
+func NewMockPasskeySessionCache(ctrl *gomock.Controller) *MockPasskeySessionCache {
+	mock := &MockPasskeySessionCache{ctrl: ctrl}
+	mock.recorder = &MockPasskeySessionCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPasskeySessionCache) EXPECT() *MockPasskeySessionCacheMockRecorder {
+	return m.recorder
+}
+
+// Set mocks base method.
+func (m *MockPasskeySessionCache) Set(ctx context.Context, id string, data []byte, expiration time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", ctx, id, data, expiration)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set.
+func (mr *MockPasskeySessionCacheMockRecorder) Set(ctx, id, data, expiration any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockPasskeySessionCache)(nil).Set), ctx, id, data, expiration)
+}
+
+// Take mocks base method.
+func (m *MockPasskeySessionCache) Take(ctx context.Context, id string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Take", ctx, id)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Take indicates an expected call of Take.
+func (mr *MockPasskeySessionCacheMockRecorder) Take(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Take", reflect.TypeOf((*MockPasskeySessionCache)(nil).Take), ctx, id)
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+var ErrPasskeySessionNotFound = errors.New("通行密钥会话不存在或者已经过期")
+
+// PasskeySessionCache WebAuthn 注册和登录过程中的 challenge
+type PasskeySessionCache interface {
+	Set(ctx context.Context, id string, data []byte, expiration time.Duration) error
+	// Take 取出来之后就删除，一个 challenge 只能用一次
+	Take(ctx context.Context, id string) ([]byte, error)
+}
+
+type RedisPasskeySessionCache struct {
+	client redis.Cmdable
+}
+
+func NewPasskeySessionCache(client redis.Cmdable) PasskeySessionCache {
+	return &RedisPasskeySessionCache{client: client}
+}
+
+func (c *RedisPasskeySessionCache) Set(ctx context.Context, id string, data []byte, expiration time.Duration) error {
+	return c.client.Set(ctx, c.Key(id), data, expiration).Err()
+}
+
+func (c *RedisPasskeySessionCache) Take(ctx context.Context, id string) ([]byte, error) {
+	data, err := c.client.GetDel(ctx, c.Key(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrPasskeySessionNotFound
+	}
+	return data, err
+}
+
+func (c *RedisPasskeySessionCache) Key(id string) string {
+	return fmt.Sprintf("passkey:session:%s", id)
+}
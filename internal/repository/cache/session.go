@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// SessionCache 记录用户的 token 从什么时候开始失效，强制下线用
+type SessionCache interface {
+	// Revoke at 之前签发的 token 都失效
+	Revoke(ctx context.Context, uid int64, at time.Time) error
+	// RevokedAt 没有强制下线过的时候返回零值
+	RevokedAt(ctx context.Context, uid int64) (time.Time, error)
+}
+
+// secondsBefore 毫秒时间戳都比这个大，比这个小的是以前按秒存的
+const secondsBefore = 1e12
+
+type RedisSessionCache struct {
+	client redis.Cmdable
+	// expiration 和 refresh token 的有效期一样，过了这个时间旧的 token 自己就过期了
+	expiration time.Duration
+}
+
+func NewSessionCache(client redis.Cmdable) SessionCache {
+	return &RedisSessionCache{
+		client:     client,
+		expiration: time.Hour * 24 * 7,
+	}
+}
+
+func (c *RedisSessionCache) Revoke(ctx context.Context, uid int64, at time.Time) error {
+	return c.client.Set(ctx, c.Key(uid), at.UnixMilli(), c.expiration).Err()
+}
+
+func (c *RedisSessionCache) RevokedAt(ctx context.Context, uid int64) (time.Time, error) {
+	ms, err := c.client.Get(ctx, c.Key(uid)).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	// 以前存的是秒，过期之前还要按秒解析，不然强制下线就失效了
+	if ms < secondsBefore {
+		return time.Unix(ms, 0), nil
+	}
+	return time.UnixMilli(ms), nil
+}
+
+func (c *RedisSessionCache) Key(uid int64) string {
+	return fmt.Sprintf("user:session:revoked:%d", uid)
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/repository/cache/redis_mock"
+)
+
+func TestRedisSessionCache_RevokedAt(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+
+		wantTime time.Time
+		wantErr  error
+	}{
+		{
+			name: "没有强制下线过",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				res := redis_mock.NewMockCmdable(ctrl)
+				cmd := redis.NewStringCmd(context.Background())
+				cmd.SetErr(redis.Nil)
+				res.EXPECT().Get(gomock.Any(), "user:session:revoked:1").Return(cmd)
+				return res
+			},
+		},
+		{
+			name: "按毫秒存的",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				res := redis_mock.NewMockCmdable(ctrl)
+				cmd := redis.NewStringCmd(context.Background())
+				cmd.SetVal("1700000000500")
+				res.EXPECT().Get(gomock.Any(), "user:session:revoked:1").Return(cmd)
+				return res
+			},
+			wantTime: time.UnixMilli(1700000000500),
+		},
+		{
+			name: "以前按秒存的",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				res := redis_mock.NewMockCmdable(ctrl)
+				cmd := redis.NewStringCmd(context.Background())
+				cmd.SetVal("1700000000")
+				res.EXPECT().Get(gomock.Any(), "user:session:revoked:1").Return(cmd)
+				return res
+			},
+			wantTime: time.Unix(1700000000, 0),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			c := NewSessionCache(tc.mock(ctrl))
+			at, err := c.RevokedAt(context.Background(), 1)
+			assert.Equal(t, tc.wantErr, err)
+			assert.True(t, tc.wantTime.Equal(at))
+		})
+	}
+}
@@ -7,25 +7,32 @@ import (
 	"github.com/redis/go-redis/v9"
 	"time"
 	"webook/internal/domain"
+	"webook/pkg/cachex"
 )
 
 var ErrKeyNotExist = redis.Nil
 
+// notFoundValue 缓存“用户不存在”，用户序列化之后不会是这个值
+const notFoundValue = "-"
+
+// UserCache 满足 cachex.Store，过期时间由调用方决定
 type UserCache interface {
-	Set(ctx context.Context, user domain.User) error
+	// Get 没有缓存的时候返回 ErrKeyNotExist，缓存了用户不存在的时候返回 cachex.ErrNegative
+	// 数据解析不了的时候返回 cachex.ErrCorrupted
 	Get(ctx context.Context, id int64) (domain.User, error)
+	Set(ctx context.Context, id int64, user domain.User, ttl time.Duration) error
+	SetNotFound(ctx context.Context, id int64, ttl time.Duration) error
+	Del(ctx context.Context, id int64) error
 }
 
 type RedisUserCache struct {
-	client     redis.Cmdable
-	expiration time.Duration
+	client redis.Cmdable
 }
 
 // NewUserCache 用什么就让他传什么
 func NewUserCache(client redis.Cmdable) UserCache {
 	return &RedisUserCache{
-		client:     client,
-		expiration: time.Minute * 15,
+		client: client,
 	}
 }
 
@@ -36,21 +43,38 @@ type Config struct {
 func (cache *RedisUserCache) Get(ctx context.Context, id int64) (domain.User, error) {
 	key := cache.Key(id)
 	val, err := cache.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		observeCache("user", false, nil)
+		return domain.User{}, err
+	}
+	observeCache("user", err == nil, err)
 	if err != nil {
 		return domain.User{}, err
 	}
+	if string(val) == notFoundValue {
+		return domain.User{}, cachex.ErrNegative
+	}
 	var user domain.User
-	err = json.Unmarshal(val, &user)
-	return user, err
+	if err = json.Unmarshal(val, &user); err != nil {
+		return domain.User{}, fmt.Errorf("%w: %w", cachex.ErrCorrupted, err)
+	}
+	return user, nil
 }
 
-func (cache *RedisUserCache) Set(ctx context.Context, user domain.User) error {
+func (cache *RedisUserCache) Set(ctx context.Context, id int64, user domain.User, ttl time.Duration) error {
 	val, err := json.Marshal(user)
 	if err != nil {
 		return err
 	}
-	key := cache.Key(user.ID)
-	return cache.client.Set(ctx, key, val, cache.expiration).Err()
+	return cache.client.Set(ctx, cache.Key(id), val, ttl).Err()
+}
+
+func (cache *RedisUserCache) SetNotFound(ctx context.Context, id int64, ttl time.Duration) error {
+	return cache.client.Set(ctx, cache.Key(id), notFoundValue, ttl).Err()
+}
+
+func (cache *RedisUserCache) Del(ctx context.Context, id int64) error {
+	return cache.client.Del(ctx, cache.Key(id)).Err()
 }
 
 func (cache *RedisUserCache) Key(id int64) string {
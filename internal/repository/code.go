@@ -26,9 +26,13 @@ func NewCodeRepository(c cache.CodeCache) CodeRepository {
 }
 
 func (repo *CachedCodeRepository) Set(ctx context.Context, biz, phone, code string) error {
+	ctx, span := tracer.Start(ctx, "CodeRepository.Set")
+	defer span.End()
 	return repo.cache.Set(ctx, biz, phone, code)
 }
 
 func (repo *CachedCodeRepository) Verify(ctx context.Context, biz, phone, code string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "CodeRepository.Verify")
+	defer span.End()
 	return repo.cache.Verify(ctx, biz, phone, code)
 }
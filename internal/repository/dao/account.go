@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"gorm.io/gorm"
+	"time"
+)
+
+// userStatusDeleted 和 domain.UserStatusDeleted 一致
+const userStatusDeleted uint8 = 3
+
+func (dao *GormUserDAO) FindIdentities(ctx context.Context, uid int64) ([]UserIdentity, error) {
+	var res []UserIdentity
+	err := dao.db.WithContext(ctx).Where("user_id = ?", uid).Order("id").Find(&res).Error
+	return res, err
+}
+
+func (dao *GormUserDAO) ScheduleDelete(ctx context.Context, id int64, deleteAt int64) error {
+	return dao.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND status <> ?", id, userStatusDeleted).
+		Updates(map[string]any{
+			"deleteAt":   deleteAt,
+			"updateTime": time.Now().UnixMilli(),
+		}).Error
+}
+
+func (dao *GormUserDAO) FindDeleteDue(ctx context.Context, now int64, limit int) ([]int64, error) {
+	var ids []int64
+	err := dao.db.WithContext(ctx).Model(&User{}).
+		Where("deleteAt > 0 AND deleteAt <= ? AND status <> ?", now, userStatusDeleted).
+		Order("deleteAt").Limit(limit).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// Anonymize 在一个事务里面清空用户的个人信息，删掉关联的数据
+// 邮箱、手机号和微信置为 NULL，唯一索引不再占用，可以重新注册
+// 用户这一行保留下来，其他业务按用户 ID 关联的数据不会断掉
+// 安全事件留着做风控和审计，只清掉能认出是谁的字段
+func (dao *GormUserDAO) Anonymize(ctx context.Context, id int64) error {
+	now := time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 账号不存在的登录失败没有用户 ID，只记了邮箱或者手机号，清空之前先查出来
+		var u User
+		if err := tx.Select("email", "phone").Where("id = ?", id).Limit(1).Find(&u).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&User{}).
+			Where("id = ? AND status <> ?", id, userStatusDeleted).
+			Updates(map[string]any{
+				"email":         nil,
+				"phone":         nil,
+				"wechatOpenID":  nil,
+				"wechatUnionID": nil,
+				"Password":      "",
+				"nickname":      "",
+				"avatar":        "",
+				"locale":        "",
+				"status":        userStatusDeleted,
+				"bannedUntil":   0,
+				"deleteAt":      0,
+				"updateTime":    now,
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			// 已经匿名化过了
+			return res.Error
+		}
+		for _, model := range []any{
+			&UserIdentity{}, &PasswordHistory{}, &UserTOTP{}, &UserRecoveryCode{},
+			&Passkey{}, &WechatToken{}, &WechatSession{}, &UserRole{},
+		} {
+			if err := tx.Where("user_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		events := tx.Model(&SecurityEvent{}).Where("user_id = ?", id)
+		var subjects []string
+		for _, subject := range []sql.NullString{u.Email, u.Phone} {
+			if subject.Valid {
+				subjects = append(subjects, subject.String)
+			}
+		}
+		if len(subjects) > 0 {
+			events = events.Or("user_id = 0 AND subject IN ?", subjects)
+		}
+		return events.Updates(map[string]any{
+			"subject":      "",
+			"ip":           "",
+			"user_agent":   "",
+			"device_hash":  "",
+			"country_code": "",
+			"country":      "",
+			"region":       "",
+			"city":         "",
+		}).Error
+	})
+}
@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type AdminAuditDAO interface {
+	Insert(ctx context.Context, log AdminAuditLog) error
+}
+
+// AdminAuditLog 管理员操作记录，只插入不修改
+type AdminAuditLog struct {
+	ID         int64  `gorm:"primaryKey,autoIncrement"`
+	OperatorID int64  `gorm:"index"`
+	Action     string `gorm:"type:varchar(64)"`
+	TargetID   int64  `gorm:"index"`
+	Detail     string `gorm:"type:varchar(1024)"`
+	IP         string `gorm:"type:varchar(64)"`
+	Success    bool
+	Error      string `gorm:"type:varchar(1024)"`
+	CreateTime int64  `gorm:"column:createTime;index"`
+}
+
+type GormAdminAuditDAO struct {
+	db *gorm.DB
+}
+
+func NewAdminAuditDAO(db *gorm.DB) AdminAuditDAO {
+	return &GormAdminAuditDAO{db: db}
+}
+
+func (dao *GormAdminAuditDAO) Insert(ctx context.Context, log AdminAuditLog) error {
+	return insertAudit(dao.db.WithContext(ctx), log)
+}
+
+// insertAudit 修改数据的管理操作在同一个事务里面记审计日志
+func insertAudit(tx *gorm.DB, log AdminAuditLog) error {
+	log.CreateTime = time.Now().UnixMilli()
+	return tx.Create(&log).Error
+}
@@ -0,0 +1,77 @@
+package dao
+
+import (
+	"context"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ErrIdentityDuplicated 这个第三方账号已经关联了用户
+var ErrIdentityDuplicated = gorm.ErrDuplicatedKey
+
+// UserIdentity 第三方账号，UserID 关联 User.ID
+// 微信的 openid 历史上存在 User 上面，不在这张表里
+type UserIdentity struct {
+	ID       int64  `gorm:"primaryKey,autoIncrement"`
+	UserID   int64  `gorm:"index"`
+	Provider string `gorm:"type:varchar(32);uniqueIndex:uk_provider_subject"`
+	Subject  string `gorm:"type:varchar(255);uniqueIndex:uk_provider_subject"`
+	// Email 第三方账号的邮箱，只做展示，不参与登录
+	Email      string `gorm:"type:varchar(255)"`
+	CreateTime int64  `gorm:"column:createTime"`
+	UpdateTime int64  `gorm:"column:updateTime"`
+}
+
+func (dao *GormUserDAO) FindByIdentity(ctx context.Context, provider, subject string) (User, error) {
+	var identity UserIdentity
+	err := dao.db.WithContext(ctx).
+		Where("provider = ? AND subject = ?", provider, subject).
+		First(&identity).Error
+	if err != nil {
+		return User{}, err
+	}
+	return dao.FindByID(ctx, identity.UserID)
+}
+
+func (dao *GormUserDAO) InsertWithIdentity(ctx context.Context, u User, identity UserIdentity) (int64, error) {
+	now := time.Now().UnixMilli()
+	u.CreateTime, u.UpdateTime = now, now
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&u).Error; err != nil {
+			return err
+		}
+		identity.UserID = u.ID
+		identity.CreateTime, identity.UpdateTime = now, now
+		return tx.Create(&identity).Error
+	})
+	if err != nil {
+		return 0, identityErr(err)
+	}
+	return u.ID, nil
+}
+
+func (dao *GormUserDAO) InsertIdentity(ctx context.Context, identity UserIdentity) error {
+	now := time.Now().UnixMilli()
+	identity.CreateTime, identity.UpdateTime = now, now
+	return identityErr(dao.db.WithContext(ctx).Create(&identity).Error)
+}
+
+func (dao *GormUserDAO) UpdateWechat(ctx context.Context, id int64, openID, unionID string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"wechatOpenID":  openID,
+			"wechatUnionID": unionID,
+			"updateTime":    time.Now().UnixMilli(),
+		}).Error
+}
+
+func identityErr(err error) error {
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		const uniqueConflictsErrNo uint16 = 1062
+		if mysqlErr.Number == uniqueConflictsErrNo {
+			return ErrIdentityDuplicated
+		}
+	}
+	return err
+}
@@ -5,5 +5,6 @@ import "gorm.io/gorm"
 // InitTable 建表
 func InitTable(db *gorm.DB) error {
 	// Gorm会默认给表名添加复数 user -> users
-	return db.AutoMigrate(&User{})
+	return db.AutoMigrate(&User{}, &PasswordHistory{}, &UserTOTP{}, &UserRecoveryCode{}, &Passkey{}, &UserIdentity{}, &WechatToken{},
+		&WechatSession{}, &UserRole{}, &AdminAuditLog{}, &SecurityEvent{})
 }
@@ -0,0 +1,121 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var ErrMFANotFound = gorm.ErrRecordNotFound
+
+type MFADAO interface {
+	FindTOTP(ctx context.Context, uid int64) (UserTOTP, error)
+	// UpsertTOTP 重新生成密钥，没确认之前是未启用的状态
+	UpsertTOTP(ctx context.Context, t UserTOTP) error
+	// EnableTOTP 启用 TOTP，同时替换掉所有的恢复码
+	EnableTOTP(ctx context.Context, uid int64, codeHashes []string) error
+	// UpdateLastStep 只有 step 比记录的大才会更新，返回是否更新成功
+	UpdateLastStep(ctx context.Context, uid int64, step int64) (bool, error)
+	// UseRecoveryCode 把恢复码标记为已使用，返回是否找到了没用过的恢复码
+	UseRecoveryCode(ctx context.Context, uid int64, codeHash string) (bool, error)
+	DeleteTOTP(ctx context.Context, uid int64) error
+}
+
+// UserTOTP 用户的 TOTP 密钥
+type UserTOTP struct {
+	ID         int64  `gorm:"primaryKey,autoIncrement"`
+	UserID     int64  `gorm:"uniqueIndex"`
+	Secret     string `gorm:"type:varchar(255)"`
+	Enabled    bool
+	LastStep   int64 `gorm:"column:lastStep"`
+	CreateTime int64 `gorm:"column:createTime"`
+	UpdateTime int64 `gorm:"column:updateTime"`
+}
+
+// UserRecoveryCode 恢复码，只存 SHA-256
+type UserRecoveryCode struct {
+	ID       int64  `gorm:"primaryKey,autoIncrement"`
+	UserID   int64  `gorm:"index"`
+	CodeHash string `gorm:"type:varchar(64)"`
+	// UsedTime 为 0 表示还没用过
+	UsedTime   int64 `gorm:"column:usedTime"`
+	CreateTime int64 `gorm:"column:createTime"`
+}
+
+type GormMFADAO struct {
+	db *gorm.DB
+}
+
+func NewMFADAO(db *gorm.DB) MFADAO {
+	return &GormMFADAO{db: db}
+}
+
+func (dao *GormMFADAO) FindTOTP(ctx context.Context, uid int64) (UserTOTP, error) {
+	var t UserTOTP
+	err := dao.db.WithContext(ctx).Where("user_id = ?", uid).First(&t).Error
+	return t, err
+}
+
+func (dao *GormMFADAO) UpsertTOTP(ctx context.Context, t UserTOTP) error {
+	now := time.Now().UnixMilli()
+	t.CreateTime = now
+	t.UpdateTime = now
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"secret":     t.Secret,
+			"enabled":    false,
+			"lastStep":   0,
+			"updateTime": now,
+		}),
+	}).Create(&t).Error
+}
+
+func (dao *GormMFADAO) EnableTOTP(ctx context.Context, uid int64, codeHashes []string) error {
+	now := time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&UserTOTP{}).Where("user_id = ?", uid).
+			Updates(map[string]any{
+				"enabled":    true,
+				"updateTime": now,
+			}).Error
+		if err != nil {
+			return err
+		}
+		if err = tx.Where("user_id = ?", uid).Delete(&UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]UserRecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, UserRecoveryCode{UserID: uid, CodeHash: hash, CreateTime: now})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (dao *GormMFADAO) UpdateLastStep(ctx context.Context, uid int64, step int64) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&UserTOTP{}).
+		Where("user_id = ? AND lastStep < ?", uid, step).
+		Updates(map[string]any{
+			"lastStep":   step,
+			"updateTime": time.Now().UnixMilli(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (dao *GormMFADAO) UseRecoveryCode(ctx context.Context, uid int64, codeHash string) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&UserRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND usedTime = 0", uid, codeHash).
+		Update("usedTime", time.Now().UnixMilli())
+	return res.RowsAffected > 0, res.Error
+}
+
+func (dao *GormMFADAO) DeleteTOTP(ctx context.Context, uid int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", uid).Delete(&UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", uid).Delete(&UserTOTP{}).Error
+	})
+}
@@ -0,0 +1,55 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/dao/admin_audit.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/dao/admin_audit.go -package=dao_mocksvc -destination=./internal/repository/dao/mock/admin_audit.mock.go
+//
+
+// Package dao_mocksvc is a generated GoMock package.
+package dao_mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webook/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAdminAuditDAO is a mock of AdminAuditDAO interface.
+type MockAdminAuditDAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdminAuditDAOMockRecorder
+}
+
+// MockAdminAuditDAOMockRecorder is the mock recorder for MockAdminAuditDAO.
+type MockAdminAuditDAOMockRecorder struct {
+	mock *MockAdminAuditDAO
+}
+
+// NewMockAdminAuditDAO creates a new mock instance.
+func NewMockAdminAuditDAO(ctrl *gomock.Controller) *MockAdminAuditDAO {
+	mock := &MockAdminAuditDAO{ctrl: ctrl}
+	mock.recorder = &MockAdminAuditDAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdminAuditDAO) EXPECT() *MockAdminAuditDAOMockRecorder {
+	return m.recorder
+}
+
+// Insert mocks base method.
+func (m *MockAdminAuditDAO) Insert(ctx context.Context, log dao.AdminAuditLog) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Insert", ctx, log)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Insert indicates an expected call of Insert.
+func (mr *MockAdminAuditDAOMockRecorder) Insert(ctx, log any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockAdminAuditDAO)(nil).Insert), ctx, log)
+}
@@ -0,0 +1,128 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/dao/mfa.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/dao/mfa.go -package=dao_mocksvc -destination=./internal/repository/dao/mock/mfa.mock.go
+//
+
+// Package dao_mocksvc is a generated GoMock package.
+package dao_mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webook/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockMFADAO is a mock of MFADAO interface.
+type MockMFADAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockMFADAOMockRecorder
+}
+
+// MockMFADAOMockRecorder is the mock recorder for MockMFADAO.
+type MockMFADAOMockRecorder struct {
+	mock *MockMFADAO
+}
+
+// NewMockMFADAO creates a new mock instance.
+func NewMockMFADAO(ctrl *gomock.Controller) *MockMFADAO {
+	mock := &MockMFADAO{ctrl: ctrl}
+	mock.recorder = &MockMFADAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMFADAO) EXPECT() *MockMFADAOMockRecorder {
+	return m.recorder
+}
+
+// DeleteTOTP mocks base method.
+func (m *MockMFADAO) DeleteTOTP(ctx context.Context, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTOTP", ctx, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTOTP indicates an expected call of DeleteTOTP.
+func (mr *MockMFADAOMockRecorder) DeleteTOTP(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTOTP", reflect.TypeOf((*MockMFADAO)(nil).DeleteTOTP), ctx, uid)
+}
+
+// EnableTOTP mocks base method.
+func (m *MockMFADAO) EnableTOTP(ctx context.Context, uid int64, codeHashes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTOTP", ctx, uid, codeHashes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnableTOTP indicates an expected call of EnableTOTP.
+func (mr *MockMFADAOMockRecorder) EnableTOTP(ctx, uid, codeHashes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTOTP", reflect.TypeOf((*MockMFADAO)(nil).EnableTOTP), ctx, uid, codeHashes)
+}
+
+// FindTOTP mocks base method.
+func (m *MockMFADAO) FindTOTP(ctx context.Context, uid int64) (dao.UserTOTP, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindTOTP", ctx, uid)
+	ret0, _ := ret[0].(dao.UserTOTP)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindTOTP indicates an expected call of FindTOTP.
+func (mr *MockMFADAOMockRecorder) FindTOTP(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTOTP", reflect.TypeOf((*MockMFADAO)(nil).FindTOTP), ctx, uid)
+}
+
+// UpdateLastStep mocks base method.
+func (m *MockMFADAO) UpdateLastStep(ctx context.Context, uid, step int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLastStep", ctx, uid, step)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateLastStep indicates an expected call of UpdateLastStep.
+func (mr *MockMFADAOMockRecorder) UpdateLastStep(ctx, uid, step any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLastStep", reflect.TypeOf((*MockMFADAO)(nil).UpdateLastStep), ctx, uid, step)
+}
+
+// UpsertTOTP mocks base method.
+func (m *MockMFADAO) UpsertTOTP(ctx context.Context, t dao.UserTOTP) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertTOTP", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertTOTP indicates an expected call of UpsertTOTP.
+func (mr *MockMFADAOMockRecorder) UpsertTOTP(ctx, t any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertTOTP", reflect.TypeOf((*MockMFADAO)(nil).UpsertTOTP), ctx, t)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockMFADAO) UseRecoveryCode(ctx context.Context, uid int64, codeHash string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, uid, codeHash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockMFADAOMockRecorder) UseRecoveryCode(ctx, uid, codeHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockMFADAO)(nil).UseRecoveryCode), ctx, uid, codeHash)
+}
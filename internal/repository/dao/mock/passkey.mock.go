@@ -0,0 +1,98 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/dao/passkey.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/dao/passkey.go -package=dao_mocksvc -destination=./internal/repository/dao/mock/passkey.mock.go
+//
+
+// Package dao_mocksvc is a generated GoMock package.
+package dao_mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webook/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockPasskeyDAO is a mock of PasskeyDAO interface.
+type MockPasskeyDAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockPasskeyDAOMockRecorder
+}
+
+// MockPasskeyDAOMockRecorder is the mock recorder for MockPasskeyDAO.
+type MockPasskeyDAOMockRecorder struct {
+	mock *MockPasskeyDAO
+}
+
+// NewMockPasskeyDAO creates a new mock instance.
+func NewMockPasskeyDAO(ctrl *gomock.Controller) *MockPasskeyDAO {
+	mock := &MockPasskeyDAO{ctrl: ctrl}
+	mock.recorder = &MockPasskeyDAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPasskeyDAO) EXPECT() *MockPasskeyDAOMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockPasskeyDAO) Delete(ctx context.Context, uid, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, uid, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockPasskeyDAOMockRecorder) Delete(ctx, uid, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockPasskeyDAO)(nil).Delete), ctx, uid, id)
+}
+
+// FindByUserID mocks base method.
+func (m *MockPasskeyDAO) FindByUserID(ctx context.Context, uid int64) ([]dao.Passkey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUserID", ctx, uid)
+	ret0, _ := ret[0].([]dao.Passkey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUserID indicates an expected call of FindByUserID.
+func (mr *MockPasskeyDAOMockRecorder) FindByUserID(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUserID", reflect.TypeOf((*MockPasskeyDAO)(nil).FindByUserID), ctx, uid)
+}
+
+// Insert mocks base method.
+func (m *MockPasskeyDAO) Insert(ctx context.Context, p dao.Passkey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Insert", ctx, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Insert indicates an expected call of Insert.
+func (mr *MockPasskeyDAOMockRecorder) Insert(ctx, p any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockPasskeyDAO)(nil).Insert), ctx, p)
+}
+
+// UpdateUsage mocks base method.
+func (m *MockPasskeyDAO) UpdateUsage(ctx context.Context, id int64, signCount uint32, backupState bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUsage", ctx, id, signCount, backupState)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUsage indicates an expected call of UpdateUsage.
+func (mr *MockPasskeyDAOMockRecorder) UpdateUsage(ctx, id, signCount, backupState any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUsage", reflect.TypeOf((*MockPasskeyDAO)(nil).UpdateUsage), ctx, id, signCount, backupState)
+}
@@ -0,0 +1,70 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/dao/password_history.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/dao/password_history.go -package=dao_mocksvc -destination=./internal/repository/dao/mock/password_history.mock.go
+//
+
+// Package dao_mocksvc is a generated GoMock package.
+package dao_mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webook/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockPasswordHistoryDAO is a mock of PasswordHistoryDAO interface.
+type MockPasswordHistoryDAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockPasswordHistoryDAOMockRecorder
+}
+
+// MockPasswordHistoryDAOMockRecorder is the mock recorder for MockPasswordHistoryDAO.
+type MockPasswordHistoryDAOMockRecorder struct {
+	mock *MockPasswordHistoryDAO
+}
+
+// NewMockPasswordHistoryDAO creates a new mock instance.
+func NewMockPasswordHistoryDAO(ctrl *gomock.Controller) *MockPasswordHistoryDAO {
+	mock := &MockPasswordHistoryDAO{ctrl: ctrl}
+	mock.recorder = &MockPasswordHistoryDAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPasswordHistoryDAO) EXPECT() *MockPasswordHistoryDAOMockRecorder {
+	return m.recorder
+}
+
+// FindRecent mocks base method.
+func (m *MockPasswordHistoryDAO) FindRecent(ctx context.Context, uid int64, limit int) ([]dao.PasswordHistory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindRecent", ctx, uid, limit)
+	ret0, _ := ret[0].([]dao.PasswordHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindRecent indicates an expected call of FindRecent.
+func (mr *MockPasswordHistoryDAOMockRecorder) FindRecent(ctx, uid, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindRecent", reflect.TypeOf((*MockPasswordHistoryDAO)(nil).FindRecent), ctx, uid, limit)
+}
+
+// Insert mocks base method.
+func (m *MockPasswordHistoryDAO) Insert(ctx context.Context, h dao.PasswordHistory) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Insert", ctx, h)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Insert indicates an expected call of Insert.
+func (mr *MockPasswordHistoryDAOMockRecorder) Insert(ctx, h any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockPasswordHistoryDAO)(nil).Insert), ctx, h)
+}
@@ -0,0 +1,70 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/dao/role.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/dao/role.go -package=dao_mocksvc -destination=./internal/repository/dao/mock/role.mock.go
+//
+
+// Package dao_mocksvc is a generated GoMock package.
+package dao_mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webook/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockRoleDAO is a mock of RoleDAO interface.
+type MockRoleDAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockRoleDAOMockRecorder
+}
+
+// MockRoleDAOMockRecorder is the mock recorder for MockRoleDAO.
+type MockRoleDAOMockRecorder struct {
+	mock *MockRoleDAO
+}
+
+// NewMockRoleDAO creates a new mock instance.
+func NewMockRoleDAO(ctrl *gomock.Controller) *MockRoleDAO {
+	mock := &MockRoleDAO{ctrl: ctrl}
+	mock.recorder = &MockRoleDAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRoleDAO) EXPECT() *MockRoleDAOMockRecorder {
+	return m.recorder
+}
+
+// FindByUserID mocks base method.
+func (m *MockRoleDAO) FindByUserID(ctx context.Context, uid int64) ([]dao.UserRole, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUserID", ctx, uid)
+	ret0, _ := ret[0].([]dao.UserRole)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUserID indicates an expected call of FindByUserID.
+func (mr *MockRoleDAOMockRecorder) FindByUserID(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUserID", reflect.TypeOf((*MockRoleDAO)(nil).FindByUserID), ctx, uid)
+}
+
+// Replace mocks base method.
+func (m *MockRoleDAO) Replace(ctx context.Context, uid int64, roles []string, audit dao.AdminAuditLog) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replace", ctx, uid, roles, audit)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Replace indicates an expected call of Replace.
+func (mr *MockRoleDAOMockRecorder) Replace(ctx, uid, roles, audit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replace", reflect.TypeOf((*MockRoleDAO)(nil).Replace), ctx, uid, roles, audit)
+}
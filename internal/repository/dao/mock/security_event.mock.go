@@ -0,0 +1,85 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/dao/security_event.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/dao/security_event.go -package=dao_mocksvc -destination=./internal/repository/dao/mock/security_event.mock.go
+//
+
+// Package dao_mocksvc is a generated GoMock package.
+package dao_mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webook/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockSecurityEventDAO is a mock of SecurityEventDAO interface.
+type MockSecurityEventDAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockSecurityEventDAOMockRecorder
+}
+
+// MockSecurityEventDAOMockRecorder is the mock recorder for MockSecurityEventDAO.
+type MockSecurityEventDAOMockRecorder struct {
+	mock *MockSecurityEventDAO
+}
+
+// NewMockSecurityEventDAO creates a new mock instance.
+func NewMockSecurityEventDAO(ctrl *gomock.Controller) *MockSecurityEventDAO {
+	mock := &MockSecurityEventDAO{ctrl: ctrl}
+	mock.recorder = &MockSecurityEventDAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSecurityEventDAO) EXPECT() *MockSecurityEventDAOMockRecorder {
+	return m.recorder
+}
+
+// FindByUserID mocks base method.
+func (m *MockSecurityEventDAO) FindByUserID(ctx context.Context, uid, cursor int64, limit int) ([]dao.SecurityEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUserID", ctx, uid, cursor, limit)
+	ret0, _ := ret[0].([]dao.SecurityEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUserID indicates an expected call of FindByUserID.
+func (mr *MockSecurityEventDAOMockRecorder) FindByUserID(ctx, uid, cursor, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUserID", reflect.TypeOf((*MockSecurityEventDAO)(nil).FindByUserID), ctx, uid, cursor, limit)
+}
+
+// HasLogin mocks base method.
+func (m *MockSecurityEventDAO) HasLogin(ctx context.Context, uid int64, deviceHash string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasLogin", ctx, uid, deviceHash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HasLogin indicates an expected call of HasLogin.
+func (mr *MockSecurityEventDAOMockRecorder) HasLogin(ctx, uid, deviceHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasLogin", reflect.TypeOf((*MockSecurityEventDAO)(nil).HasLogin), ctx, uid, deviceHash)
+}
+
+// Insert mocks base method.
+func (m *MockSecurityEventDAO) Insert(ctx context.Context, evt dao.SecurityEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Insert", ctx, evt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Insert indicates an expected call of Insert.
+func (mr *MockSecurityEventDAOMockRecorder) Insert(ctx, evt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockSecurityEventDAO)(nil).Insert), ctx, evt)
+}
@@ -0,0 +1,70 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/dao/wechat_session.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/dao/wechat_session.go -package=dao_mocksvc -destination=./internal/repository/dao/mock/wechat_session.mock.go
+//
+
+// Package dao_mocksvc is a generated GoMock package.
+package dao_mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webook/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockWechatSessionDAO is a mock of WechatSessionDAO interface.
+type MockWechatSessionDAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockWechatSessionDAOMockRecorder
+}
+
+// MockWechatSessionDAOMockRecorder is the mock recorder for MockWechatSessionDAO.
+type MockWechatSessionDAOMockRecorder struct {
+	mock *MockWechatSessionDAO
+}
+
+// NewMockWechatSessionDAO creates a new mock instance.
+func NewMockWechatSessionDAO(ctrl *gomock.Controller) *MockWechatSessionDAO {
+	mock := &MockWechatSessionDAO{ctrl: ctrl}
+	mock.recorder = &MockWechatSessionDAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWechatSessionDAO) EXPECT() *MockWechatSessionDAOMockRecorder {
+	return m.recorder
+}
+
+// FindByUserID mocks base method.
+func (m *MockWechatSessionDAO) FindByUserID(ctx context.Context, uid int64) (dao.WechatSession, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUserID", ctx, uid)
+	ret0, _ := ret[0].(dao.WechatSession)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUserID indicates an expected call of FindByUserID.
+func (mr *MockWechatSessionDAOMockRecorder) FindByUserID(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUserID", reflect.TypeOf((*MockWechatSessionDAO)(nil).FindByUserID), ctx, uid)
+}
+
+// Upsert mocks base method.
+func (m *MockWechatSessionDAO) Upsert(ctx context.Context, s dao.WechatSession) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upsert", ctx, s)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Upsert indicates an expected call of Upsert.
+func (mr *MockWechatSessionDAOMockRecorder) Upsert(ctx, s any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockWechatSessionDAO)(nil).Upsert), ctx, s)
+}
@@ -0,0 +1,91 @@
+package dao
+
+import (
+	"context"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	ErrPasskeyDuplicated = gorm.ErrDuplicatedKey
+	ErrPasskeyNotFound   = gorm.ErrRecordNotFound
+)
+
+type PasskeyDAO interface {
+	Insert(ctx context.Context, p Passkey) error
+	FindByUserID(ctx context.Context, uid int64) ([]Passkey, error)
+	// UpdateUsage 登录成功之后更新签名计数和最后使用时间
+	UpdateUsage(ctx context.Context, id int64, signCount uint32, backupState bool) error
+	// Delete 只能删除自己的通行密钥
+	Delete(ctx context.Context, uid int64, id int64) error
+}
+
+// Passkey WebAuthn 凭证，UserID 关联 User.ID
+type Passkey struct {
+	ID              int64  `gorm:"primaryKey,autoIncrement"`
+	UserID          int64  `gorm:"index"`
+	Name            string `gorm:"type:varchar(64)"`
+	CredentialID    []byte `gorm:"type:varbinary(1023);uniqueIndex"`
+	PublicKey       []byte `gorm:"type:blob"`
+	AttestationType string `gorm:"type:varchar(32)"`
+	// Transports 逗号分隔，比如 internal,hybrid
+	Transports     string `gorm:"type:varchar(128)"`
+	AAGUID         []byte `gorm:"column:aaguid;type:varbinary(16)"`
+	SignCount      uint32
+	BackupEligible bool
+	BackupState    bool
+	LastUsedTime   int64 `gorm:"column:lastUsedTime"`
+	CreateTime     int64 `gorm:"column:createTime"`
+	UpdateTime     int64 `gorm:"column:updateTime"`
+}
+
+type GormPasskeyDAO struct {
+	db *gorm.DB
+}
+
+func NewPasskeyDAO(db *gorm.DB) PasskeyDAO {
+	return &GormPasskeyDAO{db: db}
+}
+
+func (dao *GormPasskeyDAO) Insert(ctx context.Context, p Passkey) error {
+	now := time.Now().UnixMilli()
+	p.CreateTime = now
+	p.UpdateTime = now
+	err := dao.db.WithContext(ctx).Create(&p).Error
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		const uniqueConflictsErrNo uint16 = 1062
+		if mysqlErr.Number == uniqueConflictsErrNo {
+			return ErrPasskeyDuplicated
+		}
+	}
+	return err
+}
+
+func (dao *GormPasskeyDAO) FindByUserID(ctx context.Context, uid int64) ([]Passkey, error) {
+	var res []Passkey
+	err := dao.db.WithContext(ctx).Where("user_id = ?", uid).Order("id").Find(&res).Error
+	return res, err
+}
+
+func (dao *GormPasskeyDAO) UpdateUsage(ctx context.Context, id int64, signCount uint32, backupState bool) error {
+	now := time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Model(&Passkey{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"sign_count":   signCount,
+			"backup_state": backupState,
+			"lastUsedTime": now,
+			"updateTime":   now,
+		}).Error
+}
+
+func (dao *GormPasskeyDAO) Delete(ctx context.Context, uid int64, id int64) error {
+	res := dao.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, uid).Delete(&Passkey{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrPasskeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type PasswordHistoryDAO interface {
+	Insert(ctx context.Context, h PasswordHistory) error
+	// FindRecent 最近的 limit 个密码，按时间倒序
+	FindRecent(ctx context.Context, uid int64, limit int) ([]PasswordHistory, error)
+}
+
+// PasswordHistory 用过的密码，只存哈希
+type PasswordHistory struct {
+	ID         int64  `gorm:"primaryKey,autoIncrement"`
+	UserID     int64  `gorm:"index:idx_user_id_ctime"`
+	Password   string `gorm:"type:varchar(255)"`
+	CreateTime int64  `gorm:"column:createTime;index:idx_user_id_ctime"`
+}
+
+type GormPasswordHistoryDAO struct {
+	db *gorm.DB
+}
+
+func NewPasswordHistoryDAO(db *gorm.DB) PasswordHistoryDAO {
+	return &GormPasswordHistoryDAO{db: db}
+}
+
+func (dao *GormPasswordHistoryDAO) Insert(ctx context.Context, h PasswordHistory) error {
+	h.CreateTime = time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Create(&h).Error
+}
+
+func (dao *GormPasswordHistoryDAO) FindRecent(ctx context.Context, uid int64, limit int) ([]PasswordHistory, error) {
+	var res []PasswordHistory
+	err := dao.db.WithContext(ctx).Where("user_id = ?", uid).
+		Order("createTime DESC, id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
@@ -0,0 +1,54 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type RoleDAO interface {
+	FindByUserID(ctx context.Context, uid int64) ([]UserRole, error)
+	// Replace 在一个事务里面把用户的角色替换成 roles，同时写入 audit
+	Replace(ctx context.Context, uid int64, roles []string, audit AdminAuditLog) error
+}
+
+// UserRole 用户的角色，权限和角色的对应关系在代码里面
+type UserRole struct {
+	ID         int64  `gorm:"primaryKey,autoIncrement"`
+	UserID     int64  `gorm:"uniqueIndex:uid_role"`
+	Role       string `gorm:"type:varchar(32);uniqueIndex:uid_role"`
+	CreateTime int64  `gorm:"column:createTime"`
+}
+
+type GormRoleDAO struct {
+	db *gorm.DB
+}
+
+func NewRoleDAO(db *gorm.DB) RoleDAO {
+	return &GormRoleDAO{db: db}
+}
+
+func (dao *GormRoleDAO) FindByUserID(ctx context.Context, uid int64) ([]UserRole, error) {
+	var roles []UserRole
+	err := dao.db.WithContext(ctx).Where("user_id = ?", uid).Order("id").Find(&roles).Error
+	return roles, err
+}
+
+func (dao *GormRoleDAO) Replace(ctx context.Context, uid int64, roles []string, audit AdminAuditLog) error {
+	now := time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", uid).Delete(&UserRole{}).Error; err != nil {
+			return err
+		}
+		if len(roles) > 0 {
+			rows := make([]UserRole, 0, len(roles))
+			for _, role := range roles {
+				rows = append(rows, UserRole{UserID: uid, Role: role, CreateTime: now})
+			}
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+		return insertAudit(tx, audit)
+	})
+}
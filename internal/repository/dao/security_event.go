@@ -0,0 +1,69 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+type SecurityEventDAO interface {
+	Insert(ctx context.Context, evt SecurityEvent) error
+	// FindByUserID 按 ID 倒序，cursor 为 0 的时候从最新的开始，否则只查 ID 小于 cursor 的
+	FindByUserID(ctx context.Context, uid int64, cursor int64, limit int) ([]SecurityEvent, error)
+	// HasLogin 用户有没有登录成功过，deviceHash 为空的时候不限设备
+	HasLogin(ctx context.Context, uid int64, deviceHash string) (bool, error)
+}
+
+// SecurityEvent 认证相关的安全事件，只插入，注销账号的时候清掉能认出是谁的字段
+type SecurityEvent struct {
+	ID      int64  `gorm:"primaryKey,autoIncrement"`
+	UserID  int64  `gorm:"index:idx_user_type_device,priority:1"`
+	Type    string `gorm:"type:varchar(32);index:idx_user_type_device,priority:2"`
+	Method  string `gorm:"type:varchar(32)"`
+	Subject string `gorm:"type:varchar(128)"`
+	Reason  string `gorm:"type:varchar(64)"`
+	IP      string `gorm:"type:varchar(64)"`
+	// UserAgent 太长的截断
+	UserAgent string `gorm:"type:varchar(512)"`
+	// DeviceHash 设备指纹或者 User-Agent 的 SHA-256，判断是不是新设备
+	DeviceHash  string `gorm:"type:char(64);index:idx_user_type_device,priority:3"`
+	CountryCode string `gorm:"type:varchar(8)"`
+	Country     string `gorm:"type:varchar(64)"`
+	Region      string `gorm:"type:varchar(128)"`
+	City        string `gorm:"type:varchar(128)"`
+	NewDevice   bool
+	CreateTime  int64 `gorm:"column:createTime;index"`
+}
+
+type GormSecurityEventDAO struct {
+	db *gorm.DB
+}
+
+func NewSecurityEventDAO(db *gorm.DB) SecurityEventDAO {
+	return &GormSecurityEventDAO{db: db}
+}
+
+func (dao *GormSecurityEventDAO) Insert(ctx context.Context, evt SecurityEvent) error {
+	return dao.db.WithContext(ctx).Create(&evt).Error
+}
+
+func (dao *GormSecurityEventDAO) FindByUserID(ctx context.Context, uid int64, cursor int64, limit int) ([]SecurityEvent, error) {
+	query := dao.db.WithContext(ctx).Where("user_id = ?", uid)
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+	var res []SecurityEvent
+	err := query.Order("id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (dao *GormSecurityEventDAO) HasLogin(ctx context.Context, uid int64, deviceHash string) (bool, error) {
+	// 登录成功的类型和 domain.SecurityLoginSuccess 一致
+	query := dao.db.WithContext(ctx).Model(&SecurityEvent{}).
+		Where("user_id = ? AND type = ?", uid, "login.success")
+	if deviceHash != "" {
+		query = query.Where("device_hash = ?", deviceHash)
+	}
+	var ids []int64
+	err := query.Limit(1).Pluck("id", &ids).Error
+	return len(ids) > 0, err
+}
@@ -14,11 +14,34 @@ var (
 )
 
 type UserDAO interface {
-	Insert(ctx context.Context, u User) error
+	// Insert 返回新用户的 ID
+	Insert(ctx context.Context, u User) (int64, error)
 	FindByPhone(ctx context.Context, phone string) (User, error)
 	FindByEmail(ctx context.Context, email string) (User, error)
 	FindByID(ctx context.Context, id int64) (User, error)
 	FindByWechat(ctx context.Context, openID string) (User, error)
+	FindByWechatUnionID(ctx context.Context, unionID string) (User, error)
+	UpdateLocale(ctx context.Context, id int64, locale string) error
+	UpdatePassword(ctx context.Context, id int64, password string) error
+	// UpdatePhone 手机号已经被其他用户用了的时候返回 ErrUserDuplicated
+	UpdatePhone(ctx context.Context, id int64, phone string) error
+	// FindByIdentity 通过第三方账号查找用户，微信除外
+	FindByIdentity(ctx context.Context, provider, subject string) (User, error)
+	// InsertWithIdentity 在一个事务里面创建用户和第三方账号
+	InsertWithIdentity(ctx context.Context, u User, identity UserIdentity) (int64, error)
+	// InsertIdentity 给已有的用户关联第三方账号
+	InsertIdentity(ctx context.Context, identity UserIdentity) error
+	UpdateWechat(ctx context.Context, id int64, openID, unionID string) error
+	// UpdateStatus bannedUntil 只有封禁的时候有意义，其他状态传 0
+	// 在同一个事务里面写入 audit，两个要么一起成功，要么一起失败
+	UpdateStatus(ctx context.Context, id int64, status uint8, bannedUntil int64, audit AdminAuditLog) error
+	// FindIdentities 用户关联的所有第三方账号，微信除外
+	FindIdentities(ctx context.Context, uid int64) ([]UserIdentity, error)
+	// ScheduleDelete deleteAt 为 0 表示撤销注销
+	ScheduleDelete(ctx context.Context, id int64, deleteAt int64) error
+	// FindDeleteDue 冷静期已经过了、还没有匿名化的用户 ID
+	FindDeleteDue(ctx context.Context, now int64, limit int) ([]int64, error)
+	Anonymize(ctx context.Context, id int64) error
 }
 
 type GormUserDAO struct {
@@ -36,13 +59,22 @@ type User struct {
 	UpdateTime    int64          `gorm:"column:updateTime"`
 	WechatOpenID  sql.NullString `gorm:"column:wechatOpenID"`
 	WechatUnionID sql.NullString `gorm:"column:wechatUnionID"`
+	Nickname      string         `gorm:"type:varchar(128)"`
+	Avatar        string         `gorm:"type:varchar(512)"`
+	Locale        string         `gorm:"type:varchar(16)"`
+	// Status 0 正常，1 禁用，2 封禁，3 已注销
+	Status uint8 `gorm:"not null;default:0"`
+	// BannedUntil 封禁截止时间，毫秒
+	BannedUntil int64 `gorm:"column:bannedUntil;not null;default:0"`
+	// DeleteAt 申请注销之后，到这个时间匿名化，毫秒，0 表示没有申请
+	DeleteAt int64 `gorm:"column:deleteAt;not null;default:0;index"`
 }
 
 func NewUserDAO(db *gorm.DB) UserDAO {
 	return &GormUserDAO{db: db}
 }
 
-func (dao *GormUserDAO) Insert(ctx context.Context, u User) error {
+func (dao *GormUserDAO) Insert(ctx context.Context, u User) (int64, error) {
 	now := time.Now().UnixMilli()
 	u.CreateTime = now
 	u.UpdateTime = now
@@ -51,10 +83,10 @@ func (dao *GormUserDAO) Insert(ctx context.Context, u User) error {
 		const uniqueConflictsErrNo uint16 = 1062
 		if mysqlErr.Number == uniqueConflictsErrNo {
 			// 邮箱冲突（唯一键）
-			return ErrUserDuplicated
+			return 0, ErrUserDuplicated
 		}
 	}
-	return err
+	return u.ID, err
 }
 
 func (dao *GormUserDAO) FindByEmail(ctx context.Context, email string) (User, error) {
@@ -89,3 +121,59 @@ func (dao *GormUserDAO) FindByWechat(ctx context.Context, openID string) (User,
 	}
 	return user, err
 }
+
+func (dao *GormUserDAO) FindByWechatUnionID(ctx context.Context, unionID string) (User, error) {
+	var user User
+	err := dao.db.WithContext(ctx).Where("wechatUnionID = ?", unionID).First(&user).Error
+	if err == gorm.ErrRecordNotFound {
+		return user, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (dao *GormUserDAO) UpdateLocale(ctx context.Context, id int64, locale string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"locale":     locale,
+			"updateTime": time.Now().UnixMilli(),
+		}).Error
+}
+
+func (dao *GormUserDAO) UpdatePassword(ctx context.Context, id int64, password string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"Password":   password,
+			"updateTime": time.Now().UnixMilli(),
+		}).Error
+}
+
+func (dao *GormUserDAO) UpdatePhone(ctx context.Context, id int64, phone string) error {
+	err := dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"phone":      phone,
+			"updateTime": time.Now().UnixMilli(),
+		}).Error
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		const uniqueConflictsErrNo uint16 = 1062
+		if mysqlErr.Number == uniqueConflictsErrNo {
+			return ErrUserDuplicated
+		}
+	}
+	return err
+}
+
+func (dao *GormUserDAO) UpdateStatus(ctx context.Context, id int64, status uint8, bannedUntil int64,
+	audit AdminAuditLog) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&User{}).Where("id = ?", id).
+			Updates(map[string]any{
+				"status":      status,
+				"bannedUntil": bannedUntil,
+				"updateTime":  time.Now().UnixMilli(),
+			}).Error
+		if err != nil {
+			return err
+		}
+		return insertAudit(tx, audit)
+	})
+}
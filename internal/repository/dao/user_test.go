@@ -18,6 +18,7 @@ func TestGormUserDAO_Insert(t *testing.T) {
 		mock    func(t *testing.T) *sql.DB
 		ctx     context.Context
 		user    User
+		wantID  int64
 		wantErr error
 	}{
 		{
@@ -32,6 +33,7 @@ func TestGormUserDAO_Insert(t *testing.T) {
 			},
 			ctx:     context.Background(),
 			user:    User{},
+			wantID:  123,
 			wantErr: nil,
 		},
 		{
@@ -76,8 +78,40 @@ func TestGormUserDAO_Insert(t *testing.T) {
 			})
 			assert.NoError(t, err)
 			dao := NewUserDAO(db)
-			err = dao.Insert(tc.ctx, tc.user)
+			id, err := dao.Insert(tc.ctx, tc.user)
 			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantID, id)
 		})
 	}
 }
+
+// TestGormUserDAO_Anonymize 安全事件不删，只清掉能认出是谁的字段
+func TestGormUserDAO_Anonymize(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT `email`,`phone` FROM `users`").
+		WillReturnRows(sqlmock.NewRows([]string{"email", "phone"}).AddRow("tom@qq.com", nil))
+	mock.ExpectExec("UPDATE `users`").WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, table := range []string{"user_identities", "password_histories", "user_totps", "user_recovery_codes",
+		"passkeys", "wechat_tokens", "wechat_sessions", "user_roles"} {
+		mock.ExpectExec("DELETE FROM `" + table + "`").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec("UPDATE `security_events` SET .*`city`=.*`subject`=.* "+
+		"WHERE user_id = \\? OR \\(user_id = 0 AND subject IN \\(\\?\\)\\)").
+		WithArgs("", "", "", "", "", "", "", "", int64(1), "tom@qq.com").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sqlDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	assert.NoError(t, err)
+	err = NewUserDAO(db).Anonymize(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
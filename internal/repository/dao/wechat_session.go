@@ -0,0 +1,54 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var ErrWechatSessionNotFound = gorm.ErrRecordNotFound
+
+type WechatSessionDAO interface {
+	// Upsert 每个用户只保存最新的一份，小程序重新登录之后旧的 session_key 就不能用了
+	Upsert(ctx context.Context, s WechatSession) error
+	FindByUserID(ctx context.Context, uid int64) (WechatSession, error)
+}
+
+// WechatSession 小程序的会话，SessionKey 是加密之后的
+type WechatSession struct {
+	ID         int64  `gorm:"primaryKey,autoIncrement"`
+	UserID     int64  `gorm:"uniqueIndex"`
+	OpenID     string `gorm:"column:openID;type:varchar(128)"`
+	SessionKey string `gorm:"type:varchar(256)"`
+	CreateTime int64  `gorm:"column:createTime"`
+	UpdateTime int64  `gorm:"column:updateTime"`
+}
+
+type GormWechatSessionDAO struct {
+	db *gorm.DB
+}
+
+func NewWechatSessionDAO(db *gorm.DB) WechatSessionDAO {
+	return &GormWechatSessionDAO{db: db}
+}
+
+func (dao *GormWechatSessionDAO) Upsert(ctx context.Context, s WechatSession) error {
+	now := time.Now().UnixMilli()
+	s.CreateTime = now
+	s.UpdateTime = now
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"openID":      s.OpenID,
+			"session_key": s.SessionKey,
+			"updateTime":  now,
+		}),
+	}).Create(&s).Error
+}
+
+func (dao *GormWechatSessionDAO) FindByUserID(ctx context.Context, uid int64) (WechatSession, error) {
+	var s WechatSession
+	err := dao.db.WithContext(ctx).Where("user_id = ?", uid).First(&s).Error
+	return s, err
+}
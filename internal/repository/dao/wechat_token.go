@@ -0,0 +1,67 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var ErrWechatTokenNotFound = gorm.ErrRecordNotFound
+
+type WechatTokenDAO interface {
+	// Upsert 每个用户只保存最新的一份
+	Upsert(ctx context.Context, t WechatToken) error
+	FindByUserID(ctx context.Context, uid int64) (WechatToken, error)
+	Delete(ctx context.Context, uid int64) error
+}
+
+// WechatToken 微信网页授权的 token，AccessToken 和 RefreshToken 是加密之后的
+type WechatToken struct {
+	ID                int64  `gorm:"primaryKey,autoIncrement"`
+	UserID            int64  `gorm:"uniqueIndex"`
+	OpenID            string `gorm:"column:openID;type:varchar(128)"`
+	AccessToken       string `gorm:"type:varchar(1024)"`
+	RefreshToken      string `gorm:"type:varchar(1024)"`
+	Scope             string `gorm:"type:varchar(128)"`
+	ExpireTime        int64  `gorm:"column:expireTime"`
+	RefreshExpireTime int64  `gorm:"column:refreshExpireTime"`
+	CreateTime        int64  `gorm:"column:createTime"`
+	UpdateTime        int64  `gorm:"column:updateTime"`
+}
+
+type GormWechatTokenDAO struct {
+	db *gorm.DB
+}
+
+func NewWechatTokenDAO(db *gorm.DB) WechatTokenDAO {
+	return &GormWechatTokenDAO{db: db}
+}
+
+func (dao *GormWechatTokenDAO) Upsert(ctx context.Context, t WechatToken) error {
+	now := time.Now().UnixMilli()
+	t.CreateTime = now
+	t.UpdateTime = now
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"openID":            t.OpenID,
+			"access_token":      t.AccessToken,
+			"refresh_token":     t.RefreshToken,
+			"scope":             t.Scope,
+			"expireTime":        t.ExpireTime,
+			"refreshExpireTime": t.RefreshExpireTime,
+			"updateTime":        now,
+		}),
+	}).Create(&t).Error
+}
+
+func (dao *GormWechatTokenDAO) FindByUserID(ctx context.Context, uid int64) (WechatToken, error) {
+	var t WechatToken
+	err := dao.db.WithContext(ctx).Where("user_id = ?", uid).First(&t).Error
+	return t, err
+}
+
+func (dao *GormWechatTokenDAO) Delete(ctx context.Context, uid int64) error {
+	return dao.db.WithContext(ctx).Where("user_id = ?", uid).Delete(&WechatToken{}).Error
+}
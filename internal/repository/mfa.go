@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"webook/internal/domain"
+	"webook/internal/repository/cache"
+	"webook/internal/repository/dao"
+)
+
+var (
+	ErrTOTPNotFound         = dao.ErrMFANotFound
+	ErrMFATicketNotFound    = cache.ErrMFATicketNotFound
+	ErrMFATicketTooManyTime = cache.ErrMFATicketTooManyTime
+)
+
+// MFARepository 二次验证的密钥、恢复码和登录票据
+type MFARepository interface {
+	FindTOTP(ctx context.Context, uid int64) (domain.TOTP, error)
+	// SaveTOTP 保存新的密钥，确认之前不生效
+	SaveTOTP(ctx context.Context, uid int64, secret string) error
+	// EnableTOTP codeHashes 是恢复码的哈希，会替换掉原来的恢复码
+	EnableTOTP(ctx context.Context, uid int64, codeHashes []string) error
+	// UseStep 记录验证通过的时间周期，周期已经用过的时候返回 false
+	UseStep(ctx context.Context, uid int64, step int64) (bool, error)
+	// UseRecoveryCode 恢复码只能用一次，没有可用的恢复码时返回 false
+	UseRecoveryCode(ctx context.Context, uid int64, codeHash string) (bool, error)
+	DeleteTOTP(ctx context.Context, uid int64) error
+
+	SetTicket(ctx context.Context, ticket string, uid int64) error
+	VerifyTicket(ctx context.Context, ticket string) (int64, error)
+	DelTicket(ctx context.Context, ticket string) error
+
+	// Locked 二次验证失败次数太多，还在冷却时间内
+	Locked(ctx context.Context, uid int64) (bool, error)
+	// IncrFailure 记一次二次验证失败，这一次刚好触发锁定的时候返回 true
+	IncrFailure(ctx context.Context, uid int64) (bool, error)
+	ResetFailure(ctx context.Context, uid int64) error
+}
+
+type mfaRepository struct {
+	dao     dao.MFADAO
+	ticket  cache.MFATicketCache
+	failure cache.MFAFailureCache
+}
+
+func NewMFARepository(dao dao.MFADAO, ticket cache.MFATicketCache, failure cache.MFAFailureCache) MFARepository {
+	return &mfaRepository{dao: dao, ticket: ticket, failure: failure}
+}
+
+func (repo *mfaRepository) FindTOTP(ctx context.Context, uid int64) (domain.TOTP, error) {
+	ctx, span := tracer.Start(ctx, "MFARepository.FindTOTP")
+	defer span.End()
+	t, err := repo.dao.FindTOTP(ctx, uid)
+	if errors.Is(err, dao.ErrMFANotFound) {
+		return domain.TOTP{}, ErrTOTPNotFound
+	}
+	if err != nil {
+		return domain.TOTP{}, err
+	}
+	return domain.TOTP{
+		UserID:   t.UserID,
+		Secret:   t.Secret,
+		Enabled:  t.Enabled,
+		LastStep: t.LastStep,
+	}, nil
+}
+
+func (repo *mfaRepository) SaveTOTP(ctx context.Context, uid int64, secret string) error {
+	ctx, span := tracer.Start(ctx, "MFARepository.SaveTOTP")
+	defer span.End()
+	return repo.dao.UpsertTOTP(ctx, dao.UserTOTP{UserID: uid, Secret: secret})
+}
+
+func (repo *mfaRepository) EnableTOTP(ctx context.Context, uid int64, codeHashes []string) error {
+	ctx, span := tracer.Start(ctx, "MFARepository.EnableTOTP")
+	defer span.End()
+	return repo.dao.EnableTOTP(ctx, uid, codeHashes)
+}
+
+func (repo *mfaRepository) UseStep(ctx context.Context, uid int64, step int64) (bool, error) {
+	ctx, span := tracer.Start(ctx, "MFARepository.UseStep")
+	defer span.End()
+	return repo.dao.UpdateLastStep(ctx, uid, step)
+}
+
+func (repo *mfaRepository) UseRecoveryCode(ctx context.Context, uid int64, codeHash string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "MFARepository.UseRecoveryCode")
+	defer span.End()
+	return repo.dao.UseRecoveryCode(ctx, uid, codeHash)
+}
+
+func (repo *mfaRepository) DeleteTOTP(ctx context.Context, uid int64) error {
+	ctx, span := tracer.Start(ctx, "MFARepository.DeleteTOTP")
+	defer span.End()
+	return repo.dao.DeleteTOTP(ctx, uid)
+}
+
+func (repo *mfaRepository) SetTicket(ctx context.Context, ticket string, uid int64) error {
+	ctx, span := tracer.Start(ctx, "MFARepository.SetTicket")
+	defer span.End()
+	return repo.ticket.Set(ctx, ticket, uid)
+}
+
+func (repo *mfaRepository) VerifyTicket(ctx context.Context, ticket string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "MFARepository.VerifyTicket")
+	defer span.End()
+	return repo.ticket.Verify(ctx, ticket)
+}
+
+func (repo *mfaRepository) DelTicket(ctx context.Context, ticket string) error {
+	ctx, span := tracer.Start(ctx, "MFARepository.DelTicket")
+	defer span.End()
+	return repo.ticket.Del(ctx, ticket)
+}
+
+func (repo *mfaRepository) Locked(ctx context.Context, uid int64) (bool, error) {
+	ctx, span := tracer.Start(ctx, "MFARepository.Locked")
+	defer span.End()
+	return repo.failure.Locked(ctx, uid)
+}
+
+func (repo *mfaRepository) IncrFailure(ctx context.Context, uid int64) (bool, error) {
+	ctx, span := tracer.Start(ctx, "MFARepository.IncrFailure")
+	defer span.End()
+	return repo.failure.Incr(ctx, uid)
+}
+
+func (repo *mfaRepository) ResetFailure(ctx context.Context, uid int64) error {
+	ctx, span := tracer.Start(ctx, "MFARepository.ResetFailure")
+	defer span.End()
+	return repo.failure.Reset(ctx, uid)
+}
@@ -0,0 +1,55 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/admin_audit.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/admin_audit.go -package=mocksvc -destination=./internal/repository/mock/admin_audit.mock.go
+//
+
+// Package mocksvc is a generated GoMock package.
+package mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAdminAuditRepository is a mock of AdminAuditRepository interface.
+type MockAdminAuditRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdminAuditRepositoryMockRecorder
+}
+
+// MockAdminAuditRepositoryMockRecorder is the mock recorder for MockAdminAuditRepository.
+type MockAdminAuditRepositoryMockRecorder struct {
+	mock *MockAdminAuditRepository
+}
+
+// NewMockAdminAuditRepository creates a new mock instance.
+func NewMockAdminAuditRepository(ctrl *gomock.Controller) *MockAdminAuditRepository {
+	mock := &MockAdminAuditRepository{ctrl: ctrl}
+	mock.recorder = &MockAdminAuditRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdminAuditRepository) EXPECT() *MockAdminAuditRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockAdminAuditRepository) Create(ctx context.Context, log domain.AuditLog) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, log)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockAdminAuditRepositoryMockRecorder) Create(ctx, log any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAdminAuditRepository)(nil).Create), ctx, log)
+}
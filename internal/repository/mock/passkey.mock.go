@@ -0,0 +1,128 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/passkey.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/passkey.go -package=mocksvc -destination=./internal/repository/mock/passkey.mock.go
+//
+
+// Package mocksvc is a generated GoMock package.
+package mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockPasskeyRepository is a mock of PasskeyRepository interface.
+type MockPasskeyRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockPasskeyRepositoryMockRecorder
+}
+
+// MockPasskeyRepositoryMockRecorder is the mock recorder for MockPasskeyRepository.
+type MockPasskeyRepositoryMockRecorder struct {
+	mock *MockPasskeyRepository
+}
+
+// NewMockPasskeyRepository creates a new mock instance.
+func NewMockPasskeyRepository(ctrl *gomock.Controller) *MockPasskeyRepository {
+	mock := &MockPasskeyRepository{ctrl: ctrl}
+	mock.recorder = &MockPasskeyRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPasskeyRepository) EXPECT() *MockPasskeyRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockPasskeyRepository) Create(ctx context.Context, p domain.Passkey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockPasskeyRepositoryMockRecorder) Create(ctx, p any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockPasskeyRepository)(nil).Create), ctx, p)
+}
+
+// Delete mocks base method.
+func (m *MockPasskeyRepository) Delete(ctx context.Context, uid, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, uid, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockPasskeyRepositoryMockRecorder) Delete(ctx, uid, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockPasskeyRepository)(nil).Delete), ctx, uid, id)
+}
+
+// FindByUser mocks base method.
+func (m *MockPasskeyRepository) FindByUser(ctx context.Context, uid int64) ([]domain.Passkey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUser", ctx, uid)
+	ret0, _ := ret[0].([]domain.Passkey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUser indicates an expected call of FindByUser.
+func (mr *MockPasskeyRepositoryMockRecorder) FindByUser(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUser", reflect.TypeOf((*MockPasskeyRepository)(nil).FindByUser), ctx, uid)
+}
+
+// SetSession mocks base method.
+func (m *MockPasskeyRepository) SetSession(ctx context.Context, id string, data []byte, expiration time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSession", ctx, id, data, expiration)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSession indicates an expected call of SetSession.
+func (mr *MockPasskeyRepositoryMockRecorder) SetSession(ctx, id, data, expiration any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSession", reflect.TypeOf((*MockPasskeyRepository)(nil).SetSession), ctx, id, data, expiration)
+}
+
+// TakeSession mocks base method.
+func (m *MockPasskeyRepository) TakeSession(ctx context.Context, id string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeSession", ctx, id)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeSession indicates an expected call of TakeSession.
+func (mr *MockPasskeyRepositoryMockRecorder) TakeSession(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeSession", reflect.TypeOf((*MockPasskeyRepository)(nil).TakeSession), ctx, id)
+}
+
+// UpdateUsage mocks base method.
+func (m *MockPasskeyRepository) UpdateUsage(ctx context.Context, p domain.Passkey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUsage", ctx, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUsage indicates an expected call of UpdateUsage.
+func (mr *MockPasskeyRepositoryMockRecorder) UpdateUsage(ctx, p any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUsage", reflect.TypeOf((*MockPasskeyRepository)(nil).UpdateUsage), ctx, p)
+}
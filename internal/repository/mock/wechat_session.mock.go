@@ -0,0 +1,70 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/wechat_session.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/wechat_session.go -package=mocksvc -destination=./internal/repository/mock/wechat_session.mock.go
+//
+
+// Package mocksvc is a generated GoMock package.
+package mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockWechatSessionRepository is a mock of WechatSessionRepository interface.
+type MockWechatSessionRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockWechatSessionRepositoryMockRecorder
+}
+
+// MockWechatSessionRepositoryMockRecorder is the mock recorder for MockWechatSessionRepository.
+type MockWechatSessionRepositoryMockRecorder struct {
+	mock *MockWechatSessionRepository
+}
+
+// NewMockWechatSessionRepository creates a new mock instance.
+func NewMockWechatSessionRepository(ctrl *gomock.Controller) *MockWechatSessionRepository {
+	mock := &MockWechatSessionRepository{ctrl: ctrl}
+	mock.recorder = &MockWechatSessionRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWechatSessionRepository) EXPECT() *MockWechatSessionRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Find mocks base method.
+func (m *MockWechatSessionRepository) Find(ctx context.Context, uid int64) (domain.WechatSession, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Find", ctx, uid)
+	ret0, _ := ret[0].(domain.WechatSession)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Find indicates an expected call of Find.
+func (mr *MockWechatSessionRepositoryMockRecorder) Find(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockWechatSessionRepository)(nil).Find), ctx, uid)
+}
+
+// Save mocks base method.
+func (m *MockWechatSessionRepository) Save(ctx context.Context, s domain.WechatSession) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", ctx, s)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockWechatSessionRepositoryMockRecorder) Save(ctx, s any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockWechatSessionRepository)(nil).Save), ctx, s)
+}
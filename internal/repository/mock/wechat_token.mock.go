@@ -0,0 +1,84 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/repository/wechat_token.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/repository/wechat_token.go -package=mocksvc -destination=./internal/repository/mock/wechat_token.mock.go
+//
+
+// Package mocksvc is a generated GoMock package.
+package mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockWechatTokenRepository is a mock of WechatTokenRepository interface.
+type MockWechatTokenRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockWechatTokenRepositoryMockRecorder
+}
+
+// MockWechatTokenRepositoryMockRecorder is the mock recorder for MockWechatTokenRepository.
+type MockWechatTokenRepositoryMockRecorder struct {
+	mock *MockWechatTokenRepository
+}
+
+// NewMockWechatTokenRepository creates a new mock instance.
+func NewMockWechatTokenRepository(ctrl *gomock.Controller) *MockWechatTokenRepository {
+	mock := &MockWechatTokenRepository{ctrl: ctrl}
+	mock.recorder = &MockWechatTokenRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWechatTokenRepository) EXPECT() *MockWechatTokenRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockWechatTokenRepository) Delete(ctx context.Context, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockWechatTokenRepositoryMockRecorder) Delete(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockWechatTokenRepository)(nil).Delete), ctx, uid)
+}
+
+// Find mocks base method.
+func (m *MockWechatTokenRepository) Find(ctx context.Context, uid int64) (domain.WechatToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Find", ctx, uid)
+	ret0, _ := ret[0].(domain.WechatToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Find indicates an expected call of Find.
+func (mr *MockWechatTokenRepositoryMockRecorder) Find(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockWechatTokenRepository)(nil).Find), ctx, uid)
+}
+
+// Save mocks base method.
+func (m *MockWechatTokenRepository) Save(ctx context.Context, t domain.WechatToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockWechatTokenRepositoryMockRecorder) Save(ctx, t any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockWechatTokenRepository)(nil).Save), ctx, t)
+}
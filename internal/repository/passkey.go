@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/cache"
+	"webook/internal/repository/dao"
+)
+
+var (
+	ErrPasskeyDuplicated      = dao.ErrPasskeyDuplicated
+	ErrPasskeyNotFound        = dao.ErrPasskeyNotFound
+	ErrPasskeySessionNotFound = cache.ErrPasskeySessionNotFound
+)
+
+// PasskeyRepository 通行密钥和 WebAuthn 会话
+type PasskeyRepository interface {
+	Create(ctx context.Context, p domain.Passkey) error
+	FindByUser(ctx context.Context, uid int64) ([]domain.Passkey, error)
+	UpdateUsage(ctx context.Context, p domain.Passkey) error
+	Delete(ctx context.Context, uid int64, id int64) error
+
+	// SetSession data 是序列化之后的会话，repository 不关心格式
+	SetSession(ctx context.Context, id string, data []byte, expiration time.Duration) error
+	TakeSession(ctx context.Context, id string) ([]byte, error)
+}
+
+type passkeyRepository struct {
+	dao     dao.PasskeyDAO
+	session cache.PasskeySessionCache
+}
+
+func NewPasskeyRepository(dao dao.PasskeyDAO, session cache.PasskeySessionCache) PasskeyRepository {
+	return &passkeyRepository{dao: dao, session: session}
+}
+
+func (repo *passkeyRepository) Create(ctx context.Context, p domain.Passkey) error {
+	ctx, span := tracer.Start(ctx, "PasskeyRepository.Create")
+	defer span.End()
+	return repo.dao.Insert(ctx, repo.toEntity(p))
+}
+
+func (repo *passkeyRepository) FindByUser(ctx context.Context, uid int64) ([]domain.Passkey, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyRepository.FindByUser")
+	defer span.End()
+	ps, err := repo.dao.FindByUserID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Passkey, 0, len(ps))
+	for _, p := range ps {
+		res = append(res, repo.toDomain(p))
+	}
+	return res, nil
+}
+
+func (repo *passkeyRepository) UpdateUsage(ctx context.Context, p domain.Passkey) error {
+	ctx, span := tracer.Start(ctx, "PasskeyRepository.UpdateUsage")
+	defer span.End()
+	return repo.dao.UpdateUsage(ctx, p.ID, p.SignCount, p.BackupState)
+}
+
+func (repo *passkeyRepository) Delete(ctx context.Context, uid int64, id int64) error {
+	ctx, span := tracer.Start(ctx, "PasskeyRepository.Delete")
+	defer span.End()
+	return repo.dao.Delete(ctx, uid, id)
+}
+
+func (repo *passkeyRepository) SetSession(ctx context.Context, id string, data []byte, expiration time.Duration) error {
+	ctx, span := tracer.Start(ctx, "PasskeyRepository.SetSession")
+	defer span.End()
+	return repo.session.Set(ctx, id, data, expiration)
+}
+
+func (repo *passkeyRepository) TakeSession(ctx context.Context, id string) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyRepository.TakeSession")
+	defer span.End()
+	return repo.session.Take(ctx, id)
+}
+
+func (repo *passkeyRepository) toDomain(p dao.Passkey) domain.Passkey {
+	var transports []string
+	if p.Transports != "" {
+		transports = strings.Split(p.Transports, ",")
+	}
+	return domain.Passkey{
+		ID:              p.ID,
+		UserID:          p.UserID,
+		Name:            p.Name,
+		CredentialID:    p.CredentialID,
+		PublicKey:       p.PublicKey,
+		AttestationType: p.AttestationType,
+		Transports:      transports,
+		AAGUID:          p.AAGUID,
+		SignCount:       p.SignCount,
+		BackupEligible:  p.BackupEligible,
+		BackupState:     p.BackupState,
+		CreatedAt:       p.CreateTime,
+		LastUsedAt:      p.LastUsedTime,
+	}
+}
+
+func (repo *passkeyRepository) toEntity(p domain.Passkey) dao.Passkey {
+	return dao.Passkey{
+		ID:              p.ID,
+		UserID:          p.UserID,
+		Name:            p.Name,
+		CredentialID:    p.CredentialID,
+		PublicKey:       p.PublicKey,
+		AttestationType: p.AttestationType,
+		Transports:      strings.Join(p.Transports, ","),
+		AAGUID:          p.AAGUID,
+		SignCount:       p.SignCount,
+		BackupEligible:  p.BackupEligible,
+		BackupState:     p.BackupState,
+	}
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"webook/internal/repository/dao"
+)
+
+// PasswordHistoryRepository 用户用过的密码，用来防止重复使用最近的密码
+type PasswordHistoryRepository interface {
+	// Add hash 是哈希之后的密码
+	Add(ctx context.Context, uid int64, hash string) error
+	// Recent 最近的 limit 个密码哈希，按时间倒序
+	Recent(ctx context.Context, uid int64, limit int) ([]string, error)
+}
+
+type passwordHistoryRepository struct {
+	dao dao.PasswordHistoryDAO
+}
+
+func NewPasswordHistoryRepository(dao dao.PasswordHistoryDAO) PasswordHistoryRepository {
+	return &passwordHistoryRepository{dao: dao}
+}
+
+func (repo *passwordHistoryRepository) Add(ctx context.Context, uid int64, hash string) error {
+	ctx, span := tracer.Start(ctx, "PasswordHistoryRepository.Add")
+	defer span.End()
+	return repo.dao.Insert(ctx, dao.PasswordHistory{
+		UserID:   uid,
+		Password: hash,
+	})
+}
+
+func (repo *passwordHistoryRepository) Recent(ctx context.Context, uid int64, limit int) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "PasswordHistoryRepository.Recent")
+	defer span.End()
+	hs, err := repo.dao.FindRecent(ctx, uid, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(hs))
+	for _, h := range hs {
+		res = append(res, h.Password)
+	}
+	return res, nil
+}
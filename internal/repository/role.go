@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+type RoleRepository interface {
+	Roles(ctx context.Context, uid int64) ([]string, error)
+	// SetRoles 在同一个事务里面写入管理员的审计日志
+	SetRoles(ctx context.Context, uid int64, roles []string, audit domain.AuditLog) error
+}
+
+type roleRepository struct {
+	dao dao.RoleDAO
+}
+
+func NewRoleRepository(dao dao.RoleDAO) RoleRepository {
+	return &roleRepository{dao: dao}
+}
+
+func (repo *roleRepository) Roles(ctx context.Context, uid int64) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "RoleRepository.Roles")
+	defer span.End()
+	rows, err := repo.dao.FindByUserID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]string, 0, len(rows))
+	for _, r := range rows {
+		roles = append(roles, r.Role)
+	}
+	return roles, nil
+}
+
+func (repo *roleRepository) SetRoles(ctx context.Context, uid int64, roles []string, audit domain.AuditLog) error {
+	ctx, span := tracer.Start(ctx, "RoleRepository.SetRoles")
+	defer span.End()
+	return repo.dao.Replace(ctx, uid, roles, auditToEntity(audit))
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+// maxUserAgentLength 和表结构里面的长度一致
+const maxUserAgentLength = 512
+
+type SecurityEventRepository interface {
+	Create(ctx context.Context, evt domain.SecurityEvent) error
+	// FindByUser 按时间倒序，cursor 是上一页最后一个事件的 ID，第一页为 0
+	FindByUser(ctx context.Context, uid int64, cursor int64, limit int) ([]domain.SecurityEvent, error)
+	// KnownDevice 用户以前有没有在这个设备上登录成功过，没有设备信息的时候当作见过
+	KnownDevice(ctx context.Context, uid int64, client domain.ClientInfo) (bool, error)
+	// HasLogin 用户以前有没有登录成功过
+	HasLogin(ctx context.Context, uid int64) (bool, error)
+}
+
+type securityEventRepository struct {
+	dao dao.SecurityEventDAO
+}
+
+func NewSecurityEventRepository(dao dao.SecurityEventDAO) SecurityEventRepository {
+	return &securityEventRepository{dao: dao}
+}
+
+func (repo *securityEventRepository) Create(ctx context.Context, evt domain.SecurityEvent) error {
+	ctx, span := tracer.Start(ctx, "SecurityEventRepository.Create")
+	defer span.End()
+	return repo.dao.Insert(ctx, repo.toEntity(evt))
+}
+
+func (repo *securityEventRepository) FindByUser(ctx context.Context, uid int64, cursor int64,
+	limit int) ([]domain.SecurityEvent, error) {
+	ctx, span := tracer.Start(ctx, "SecurityEventRepository.FindByUser")
+	defer span.End()
+	rows, err := repo.dao.FindByUserID(ctx, uid, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.SecurityEvent, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, repo.toDomain(row))
+	}
+	return res, nil
+}
+
+func (repo *securityEventRepository) KnownDevice(ctx context.Context, uid int64, client domain.ClientInfo) (bool, error) {
+	ctx, span := tracer.Start(ctx, "SecurityEventRepository.KnownDevice")
+	defer span.End()
+	hash := deviceHash(client)
+	if hash == "" {
+		return true, nil
+	}
+	return repo.dao.HasLogin(ctx, uid, hash)
+}
+
+func (repo *securityEventRepository) HasLogin(ctx context.Context, uid int64) (bool, error) {
+	ctx, span := tracer.Start(ctx, "SecurityEventRepository.HasLogin")
+	defer span.End()
+	return repo.dao.HasLogin(ctx, uid, "")
+}
+
+// deviceHash 优先用前端上报的设备指纹，没有的时候用 User-Agent
+func deviceHash(client domain.ClientInfo) string {
+	device := client.DeviceID
+	if device == "" {
+		device = client.UserAgent
+	}
+	if device == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(device))
+	return hex.EncodeToString(sum[:])
+}
+
+func (repo *securityEventRepository) toEntity(evt domain.SecurityEvent) dao.SecurityEvent {
+	ua := evt.Client.UserAgent
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+	return dao.SecurityEvent{
+		UserID:      evt.UserID,
+		Type:        evt.Type,
+		Method:      evt.Method,
+		Subject:     evt.Subject,
+		Reason:      evt.Reason,
+		IP:          evt.Client.IP,
+		UserAgent:   ua,
+		DeviceHash:  deviceHash(evt.Client),
+		CountryCode: evt.Geo.CountryCode,
+		Country:     evt.Geo.Country,
+		Region:      evt.Geo.Region,
+		City:        evt.Geo.City,
+		NewDevice:   evt.NewDevice,
+		CreateTime:  evt.CreatedAt,
+	}
+}
+
+func (repo *securityEventRepository) toDomain(evt dao.SecurityEvent) domain.SecurityEvent {
+	return domain.SecurityEvent{
+		ID:      evt.ID,
+		UserID:  evt.UserID,
+		Type:    evt.Type,
+		Method:  evt.Method,
+		Subject: evt.Subject,
+		Reason:  evt.Reason,
+		Client: domain.ClientInfo{
+			IP:        evt.IP,
+			UserAgent: evt.UserAgent,
+		},
+		Geo: domain.GeoLocation{
+			CountryCode: evt.CountryCode,
+			Country:     evt.Country,
+			Region:      evt.Region,
+			City:        evt.City,
+		},
+		NewDevice: evt.NewDevice,
+		CreatedAt: evt.CreateTime,
+	}
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"webook/internal/repository/cache"
+)
+
+// SessionRepository 强制下线的记录，只放在 Redis 里面
+type SessionRepository interface {
+	Revoke(ctx context.Context, uid int64, at time.Time) error
+	RevokedAt(ctx context.Context, uid int64) (time.Time, error)
+}
+
+type sessionRepository struct {
+	cache cache.SessionCache
+}
+
+func NewSessionRepository(c cache.SessionCache) SessionRepository {
+	return &sessionRepository{cache: c}
+}
+
+func (repo *sessionRepository) Revoke(ctx context.Context, uid int64, at time.Time) error {
+	ctx, span := tracer.Start(ctx, "SessionRepository.Revoke")
+	defer span.End()
+	return repo.cache.Revoke(ctx, uid, at)
+}
+
+func (repo *sessionRepository) RevokedAt(ctx context.Context, uid int64) (time.Time, error) {
+	ctx, span := tracer.Start(ctx, "SessionRepository.RevokedAt")
+	defer span.End()
+	return repo.cache.RevokedAt(ctx, uid)
+}
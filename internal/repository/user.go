@@ -3,44 +3,101 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"go.opentelemetry.io/otel"
+	"time"
 	"webook/internal/domain"
 	"webook/internal/repository/cache"
 	"webook/internal/repository/dao"
+	"webook/pkg/cachex"
+	"webook/pkg/logger"
 )
 
 var (
-	ErrUserDuplicated = dao.ErrUserDuplicated
-	ErrUserNotFound   = dao.ErrUserNotFound
+	ErrUserDuplicated     = dao.ErrUserDuplicated
+	ErrUserNotFound       = dao.ErrUserNotFound
+	ErrIdentityDuplicated = dao.ErrIdentityDuplicated
 )
 
+var tracer = otel.Tracer("webook/internal/repository")
+
 type UserRepository interface {
 	Create(ctx context.Context, u domain.User) error
 	FindByEmail(ctx context.Context, email string) (domain.User, error)
 	FindByPhone(ctx context.Context, phone string) (domain.User, error)
 	FindByID(ctx context.Context, id int64) (domain.User, error)
 	FindByWechat(ctx context.Context, openID string) (domain.User, error)
+	// FindByWechatUnionID 网站应用和小程序的 openid 不一样，要按 unionid 找
+	FindByWechatUnionID(ctx context.Context, unionID string) (domain.User, error)
+	UpdateLocale(ctx context.Context, id int64, locale string) error
+	// UpdatePassword password 是哈希之后的密码
+	UpdatePassword(ctx context.Context, id int64, password string) error
+	UpdatePhone(ctx context.Context, id int64, phone string) error
+	// FindByIdentity 微信用 FindByWechat
+	FindByIdentity(ctx context.Context, identity domain.Identity) (domain.User, error)
+	// CreateWithIdentity 创建用户并且关联第三方账号
+	CreateWithIdentity(ctx context.Context, u domain.User, identity domain.Identity) error
+	// LinkIdentity 第三方账号已经关联了用户的时候返回 ErrIdentityDuplicated
+	LinkIdentity(ctx context.Context, uid int64, identity domain.Identity) error
+	BindWechat(ctx context.Context, uid int64, info domain.WechatInfo) error
+	// UpdateStatus 同时删除缓存，登录态校验马上就能看到新的状态
+	// 只有管理员会修改状态，在同一个事务里面写入 audit
+	UpdateStatus(ctx context.Context, id int64, status domain.UserStatus, bannedUntil int64,
+		audit domain.AuditLog) error
+	// FindIdentities 微信不在里面，在 User.WechatInfo 上
+	FindIdentities(ctx context.Context, uid int64) ([]domain.Identity, error)
+	// ScheduleDelete deleteAt 是毫秒，0 表示撤销注销
+	ScheduleDelete(ctx context.Context, id int64, deleteAt int64) error
+	FindDeleteDue(ctx context.Context, now time.Time, limit int) ([]int64, error)
+	// Anonymize 清空个人信息和关联的数据，同时删除缓存
+	Anonymize(ctx context.Context, id int64) error
+}
+
+// userCacheOptions 用户信息改得少，不存在的用户多半是伪造的 ID
+var userCacheOptions = cachex.Options{
+	TTL:         time.Minute * 15,
+	NegativeTTL: time.Minute,
+	Jitter:      0.1,
+	DeleteDelay: time.Second,
+	Timeout:     time.Second,
+	LoadTimeout: time.Second * 3,
+	NotFound:    ErrUserNotFound,
 }
 
 type CachedUserRepository struct {
 	dao   dao.UserDAO
-	cache cache.UserCache
+	cache *cachex.Aside[int64, domain.User]
+	l     logger.Logger
+}
+
+// NewCachedUserRepository health 和限流共用，Redis 不可用的时候直接查库
+func NewCachedUserRepository(dao dao.UserDAO, c cache.UserCache, health cachex.HealthChecker,
+	l logger.Logger) UserRepository {
+	return newCachedUserRepository(dao, c, health, userCacheOptions, l)
 }
 
-func NewCachedUserRepository(dao dao.UserDAO, c cache.UserCache) UserRepository {
+func newCachedUserRepository(dao dao.UserDAO, c cache.UserCache, health cachex.HealthChecker,
+	opts cachex.Options, l logger.Logger) *CachedUserRepository {
 	return &CachedUserRepository{
 		dao:   dao,
-		cache: c,
+		cache: cachex.NewAside[int64, domain.User](c, health, opts, l),
+		l:     l,
 	}
 }
 
 func (repo *CachedUserRepository) toDomain(u dao.User) domain.User {
 	return domain.User{
-		ID:        u.ID,
-		Email:     u.Email.String,
-		Phone:     u.Phone.String,
-		Password:  u.Password,
-		CreatedAt: u.CreateTime,
-		UpdatedAt: u.UpdateTime,
+		ID:          u.ID,
+		Email:       u.Email.String,
+		Phone:       u.Phone.String,
+		Password:    u.Password,
+		Nickname:    u.Nickname,
+		Avatar:      u.Avatar,
+		Locale:      u.Locale,
+		Status:      domain.UserStatus(u.Status),
+		BannedUntil: u.BannedUntil,
+		DeleteAt:    u.DeleteAt,
+		CreatedAt:   u.CreateTime,
+		UpdatedAt:   u.UpdateTime,
 		WechatInfo: domain.WechatInfo{
 			OpenID:  u.WechatOpenID.String,
 			UnionID: u.WechatUnionID.String,
@@ -55,7 +112,13 @@ func (r *CachedUserRepository) toEntity(u domain.User) dao.User {
 			String: u.Email,
 			Valid:  u.Email != "",
 		},
-		Password: u.Password,
+		Password:    u.Password,
+		Nickname:    u.Nickname,
+		Avatar:      u.Avatar,
+		Locale:      u.Locale,
+		Status:      uint8(u.Status),
+		BannedUntil: u.BannedUntil,
+		DeleteAt:    u.DeleteAt,
 		Phone: sql.NullString{
 			String: u.Phone,
 			Valid:  u.Phone != "",
@@ -72,10 +135,20 @@ func (r *CachedUserRepository) toEntity(u domain.User) dao.User {
 }
 
 func (r *CachedUserRepository) Create(ctx context.Context, u domain.User) error {
-	return r.dao.Insert(ctx, r.toEntity(u))
+	ctx, span := tracer.Start(ctx, "UserRepository.Create")
+	defer span.End()
+	id, err := r.dao.Insert(ctx, r.toEntity(u))
+	if err != nil {
+		return err
+	}
+	// 注册之前可能有人查过这个 ID，缓存了“用户不存在”
+	r.delCache(ctx, id)
+	return nil
 }
 
 func (r *CachedUserRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.FindByEmail")
+	defer span.End()
 	user, err := r.dao.FindByEmail(ctx, email)
 	if err != nil {
 		return domain.User{}, err
@@ -84,45 +157,191 @@ func (r *CachedUserRepository) FindByEmail(ctx context.Context, email string) (d
 		ID:       user.ID,
 		Email:    user.Email.String,
 		Password: user.Password,
+		Locale:   user.Locale,
+		// 登录的时候要检查账号状态
+		Status:      domain.UserStatus(user.Status),
+		BannedUntil: user.BannedUntil,
 	}, err
 }
 
 func (r *CachedUserRepository) FindByID(ctx context.Context, id int64) (domain.User, error) {
-	// 1.先查缓存
-	user, err := r.cache.Get(ctx, id)
-	if err == nil {
-		// 缓存命中
-		return user, nil
+	ctx, span := tracer.Start(ctx, "UserRepository.FindByID")
+	defer span.End()
+	// 缓存没有的时候查库，回写是异步的
+	return r.cache.Get(ctx, id, func(ctx context.Context) (domain.User, error) {
+		u, err := r.dao.FindByID(ctx, id)
+		if err != nil {
+			return domain.User{}, err
+		}
+		return r.toDomain(u), nil
+	})
+}
+
+func (repo *CachedUserRepository) FindByPhone(ctx context.Context, phone string) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.FindByPhone")
+	defer span.End()
+	u, err := repo.dao.FindByPhone(ctx, phone)
+	if err != nil {
+		return domain.User{}, err
 	}
-	// 2.再查DB
-	u, err := r.dao.FindByID(ctx, id)
-	if err == ErrUserNotFound {
-		return domain.User{}, ErrUserNotFound
+	return repo.toDomain(u), nil
+}
+
+func (repo *CachedUserRepository) FindByWechat(ctx context.Context, openID string) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.FindByWechat")
+	defer span.End()
+	du, err := repo.dao.FindByWechat(ctx, openID)
+	if err != nil {
+		return domain.User{}, err
 	}
+	return repo.toDomain(du), nil
+}
+
+func (repo *CachedUserRepository) FindByWechatUnionID(ctx context.Context, unionID string) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.FindByWechatUnionID")
+	defer span.End()
+	du, err := repo.dao.FindByWechatUnionID(ctx, unionID)
 	if err != nil {
 		return domain.User{}, err
 	}
+	return repo.toDomain(du), nil
+}
+
+func (repo *CachedUserRepository) UpdateLocale(ctx context.Context, id int64, locale string) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.UpdateLocale")
+	defer span.End()
+	if err := repo.dao.UpdateLocale(ctx, id, locale); err != nil {
+		return err
+	}
+	repo.delCache(ctx, id)
+	return nil
+}
 
-	user = r.toDomain(u)
-	//go func() {
-	if err := r.cache.Set(ctx, user); err != nil {
-		//日志，监控
+func (repo *CachedUserRepository) UpdatePassword(ctx context.Context, id int64, password string) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.UpdatePassword")
+	defer span.End()
+	if err := repo.dao.UpdatePassword(ctx, id, password); err != nil {
+		return err
 	}
-	return user, err
+	repo.delCache(ctx, id)
+	return nil
 }
 
-func (repo *CachedUserRepository) FindByPhone(ctx context.Context, phone string) (domain.User, error) {
-	u, err := repo.dao.FindByPhone(ctx, phone)
+func (repo *CachedUserRepository) UpdatePhone(ctx context.Context, id int64, phone string) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.UpdatePhone")
+	defer span.End()
+	if err := repo.dao.UpdatePhone(ctx, id, phone); err != nil {
+		return err
+	}
+	repo.delCache(ctx, id)
+	return nil
+}
+
+func (repo *CachedUserRepository) FindByIdentity(ctx context.Context, identity domain.Identity) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.FindByIdentity")
+	defer span.End()
+	u, err := repo.dao.FindByIdentity(ctx, identity.Provider, identity.Subject)
 	if err != nil {
 		return domain.User{}, err
 	}
 	return repo.toDomain(u), nil
 }
 
-func (repo *CachedUserRepository) FindByWechat(ctx context.Context, openID string) (domain.User, error) {
-	du, err := repo.dao.FindByWechat(ctx, openID)
+func (repo *CachedUserRepository) CreateWithIdentity(ctx context.Context, u domain.User, identity domain.Identity) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.CreateWithIdentity")
+	defer span.End()
+	id, err := repo.dao.InsertWithIdentity(ctx, repo.toEntity(u), repo.identityToEntity(0, identity))
 	if err != nil {
-		return domain.User{}, err
+		return err
+	}
+	repo.delCache(ctx, id)
+	return nil
+}
+
+func (repo *CachedUserRepository) LinkIdentity(ctx context.Context, uid int64, identity domain.Identity) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.LinkIdentity")
+	defer span.End()
+	return repo.dao.InsertIdentity(ctx, repo.identityToEntity(uid, identity))
+}
+
+func (repo *CachedUserRepository) BindWechat(ctx context.Context, uid int64, info domain.WechatInfo) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.BindWechat")
+	defer span.End()
+	if err := repo.dao.UpdateWechat(ctx, uid, info.OpenID, info.UnionID); err != nil {
+		return err
+	}
+	repo.delCache(ctx, uid)
+	return nil
+}
+
+func (repo *CachedUserRepository) UpdateStatus(ctx context.Context, id int64, status domain.UserStatus,
+	bannedUntil int64, audit domain.AuditLog) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.UpdateStatus")
+	defer span.End()
+	if err := repo.dao.UpdateStatus(ctx, id, uint8(status), bannedUntil, auditToEntity(audit)); err != nil {
+		return err
+	}
+	repo.delCache(ctx, id)
+	return nil
+}
+
+func (repo *CachedUserRepository) FindIdentities(ctx context.Context, uid int64) ([]domain.Identity, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.FindIdentities")
+	defer span.End()
+	identities, err := repo.dao.FindIdentities(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Identity, 0, len(identities))
+	for _, identity := range identities {
+		res = append(res, domain.Identity{
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		})
+	}
+	return res, nil
+}
+
+func (repo *CachedUserRepository) ScheduleDelete(ctx context.Context, id int64, deleteAt int64) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.ScheduleDelete")
+	defer span.End()
+	if err := repo.dao.ScheduleDelete(ctx, id, deleteAt); err != nil {
+		return err
+	}
+	repo.delCache(ctx, id)
+	return nil
+}
+
+func (repo *CachedUserRepository) FindDeleteDue(ctx context.Context, now time.Time, limit int) ([]int64, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.FindDeleteDue")
+	defer span.End()
+	return repo.dao.FindDeleteDue(ctx, now.UnixMilli(), limit)
+}
+
+func (repo *CachedUserRepository) Anonymize(ctx context.Context, id int64) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.Anonymize")
+	defer span.End()
+	if err := repo.dao.Anonymize(ctx, id); err != nil {
+		return err
+	}
+	// 删不掉的话缓存里的个人信息要等过期才没有
+	repo.delCache(ctx, id)
+	return nil
+}
+
+func (repo *CachedUserRepository) identityToEntity(uid int64, identity domain.Identity) dao.UserIdentity {
+	return dao.UserIdentity{
+		UserID:   uid,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+}
+
+// delCache 延迟双删，删除失败只能等缓存过期
+func (repo *CachedUserRepository) delCache(ctx context.Context, id int64) {
+	if err := repo.cache.Invalidate(ctx, id); err != nil {
+		repo.l.Warn(ctx, "删除用户缓存失败", logger.Int64("uid", id), logger.Error(err))
 	}
-	return repo.toDomain(du), nil
 }
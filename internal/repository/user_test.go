@@ -3,32 +3,42 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"errors"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	"testing"
+	"time"
 	"webook/internal/domain"
 	"webook/internal/repository/cache"
 	cache_mocksvc "webook/internal/repository/cache/mock"
 	"webook/internal/repository/dao"
 	dao_mocksvc "webook/internal/repository/dao/mock"
+	"webook/pkg/cachex"
+	cachex_mocksvc "webook/pkg/cachex/mock"
+	"webook/pkg/logger"
 )
 
 func TestGormUserDAO_FindByID(t *testing.T) {
 	testCases := []struct {
 		name string
 
-		mock     func(ctrl *gomock.Controller) (dao.UserDAO, cache.UserCache)
-		ctx      context.Context
-		id       int64
-		wantUser domain.User
-		wantErr  error
+		// done 异步回写完成的时候关闭
+		mock func(ctrl *gomock.Controller, done chan struct{}) (dao.UserDAO, cache.UserCache,
+			cachex.HealthChecker)
+		ctx       context.Context
+		id        int64
+		wantAsync bool
+		wantUser  domain.User
+		wantErr   error
 	}{
 		{
 			name: "查询成功，缓存未命中",
-			mock: func(ctrl *gomock.Controller) (dao.UserDAO, cache.UserCache) {
+			ctx:  context.Background(),
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (dao.UserDAO, cache.UserCache,
+				cachex.HealthChecker) {
 				ud := dao_mocksvc.NewMockUserDAO(ctrl)
 				uc := cache_mocksvc.NewMockUserCache(ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
 				userID := int64(1)
 				uc.EXPECT().Get(gomock.Any(), userID).
 					Return(domain.User{}, cache.ErrKeyNotExist)
@@ -43,14 +53,18 @@ func TestGormUserDAO_FindByID(t *testing.T) {
 						CreateTime: 1715593591685,
 						UpdateTime: 1715593591685,
 					}, nil)
-				uc.EXPECT().Set(gomock.Any(), domain.User{
+				uc.EXPECT().Set(gomock.Any(), userID, domain.User{
 					ID:        1,
 					Email:     "666@qq.com",
 					Password:  "QQqq11!!",
 					CreatedAt: 1715593591685,
 					UpdatedAt: 1715593591685,
-				}).Return(nil)
-				return ud, uc
+				}, time.Minute*15).DoAndReturn(func(ctx context.Context, id int64, u domain.User,
+					ttl time.Duration) error {
+					close(done)
+					return nil
+				})
+				return ud, uc, health
 			},
 			id: 1,
 			wantUser: domain.User{
@@ -60,13 +74,18 @@ func TestGormUserDAO_FindByID(t *testing.T) {
 				CreatedAt: 1715593591685,
 				UpdatedAt: 1715593591685,
 			},
-			wantErr: nil,
+			wantAsync: true,
+			wantErr:   nil,
 		},
 		{
 			name: "缓存命中",
-			mock: func(ctrl *gomock.Controller) (dao.UserDAO, cache.UserCache) {
+			ctx:  context.Background(),
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (dao.UserDAO, cache.UserCache,
+				cachex.HealthChecker) {
 				ud := dao_mocksvc.NewMockUserDAO(ctrl)
 				uc := cache_mocksvc.NewMockUserCache(ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
 				userID := int64(1)
 				uc.EXPECT().Get(gomock.Any(), userID).
 					Return(domain.User{
@@ -76,7 +95,7 @@ func TestGormUserDAO_FindByID(t *testing.T) {
 						CreatedAt: 1715593591685,
 						UpdatedAt: 1715593591685,
 					}, nil)
-				return ud, uc
+				return ud, uc, health
 			},
 			id: 1,
 			wantUser: domain.User{
@@ -90,25 +109,73 @@ func TestGormUserDAO_FindByID(t *testing.T) {
 		},
 		{
 			name: "未查询到用户",
-			mock: func(ctrl *gomock.Controller) (dao.UserDAO, cache.UserCache) {
+			ctx:  context.Background(),
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (dao.UserDAO, cache.UserCache,
+				cachex.HealthChecker) {
 				ud := dao_mocksvc.NewMockUserDAO(ctrl)
 				uc := cache_mocksvc.NewMockUserCache(ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
 				userID := int64(121)
 				uc.EXPECT().Get(gomock.Any(), userID).
 					Return(domain.User{}, cache.ErrKeyNotExist)
 				ud.EXPECT().FindByID(gomock.Any(), userID).
 					Return(dao.User{}, ErrUserNotFound)
-				return ud, uc
+				// 防止缓存穿透
+				uc.EXPECT().SetNotFound(gomock.Any(), userID, time.Minute).
+					DoAndReturn(func(ctx context.Context, id int64, ttl time.Duration) error {
+						close(done)
+						return nil
+					})
+				return ud, uc, health
+			},
+			id:        121,
+			wantAsync: true,
+			wantUser:  domain.User{},
+			wantErr:   ErrUserNotFound,
+		},
+		{
+			name: "缓存了用户不存在",
+			ctx:  context.Background(),
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (dao.UserDAO, cache.UserCache,
+				cachex.HealthChecker) {
+				ud := dao_mocksvc.NewMockUserDAO(ctrl)
+				uc := cache_mocksvc.NewMockUserCache(ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
+				uc.EXPECT().Get(gomock.Any(), int64(121)).
+					Return(domain.User{}, cachex.ErrNegative)
+				return ud, uc, health
 			},
 			id:       121,
 			wantUser: domain.User{},
 			wantErr:  ErrUserNotFound,
 		},
+		{
+			name: "redis不可用，直接查库",
+			ctx:  context.Background(),
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (dao.UserDAO, cache.UserCache,
+				cachex.HealthChecker) {
+				ud := dao_mocksvc.NewMockUserDAO(ctrl)
+				uc := cache_mocksvc.NewMockUserCache(ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(false)
+				ud.EXPECT().FindByID(gomock.Any(), int64(1)).
+					Return(dao.User{ID: 1, CreateTime: 1715593591685}, nil)
+				return ud, uc, health
+			},
+			id:       1,
+			wantUser: domain.User{ID: 1, CreatedAt: 1715593591685},
+		},
 		{
 			name: "redis缓存失败",
-			mock: func(ctrl *gomock.Controller) (dao.UserDAO, cache.UserCache) {
+			ctx:  context.Background(),
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (dao.UserDAO, cache.UserCache,
+				cachex.HealthChecker) {
 				ud := dao_mocksvc.NewMockUserDAO(ctrl)
 				uc := cache_mocksvc.NewMockUserCache(ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
 				userID := int64(1)
 				uc.EXPECT().Get(gomock.Any(), userID).
 					Return(domain.User{}, cache.ErrKeyNotExist)
@@ -123,14 +190,18 @@ func TestGormUserDAO_FindByID(t *testing.T) {
 						CreateTime: 1715593591685,
 						UpdateTime: 1715593591685,
 					}, nil)
-				uc.EXPECT().Set(gomock.Any(), domain.User{
+				uc.EXPECT().Set(gomock.Any(), userID, domain.User{
 					ID:        1,
 					Email:     "666@qq.com",
 					Password:  "QQqq11!!",
 					CreatedAt: 1715593591685,
 					UpdatedAt: 1715593591685,
-				}).Return(errors.New("redis err"))
-				return ud, uc
+				}, time.Minute*15).Return(context.DeadlineExceeded)
+				// 回写失败之后暂时不用缓存
+				health.EXPECT().MarkFailed().Do(func() {
+					close(done)
+				})
+				return ud, uc, health
 			},
 			id: 1,
 			wantUser: domain.User{
@@ -140,7 +211,8 @@ func TestGormUserDAO_FindByID(t *testing.T) {
 				CreatedAt: 1715593591685,
 				UpdatedAt: 1715593591685,
 			},
-			wantErr: nil,
+			wantAsync: true,
+			wantErr:   nil,
 		},
 	}
 
@@ -149,11 +221,66 @@ func TestGormUserDAO_FindByID(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 
-			ud, uc := tc.mock(ctrl)
-			ur := NewCachedUserRepository(ud, uc)
+			done := make(chan struct{})
+			ud, uc, health := tc.mock(ctrl, done)
+			opts := userCacheOptions
+			// 过期时间固定下来方便断言
+			opts.Jitter = 0
+			ur := newCachedUserRepository(ud, uc, health, opts, logger.NewNopLogger())
 			user, err := ur.FindByID(tc.ctx, tc.id)
 			assert.Equal(t, tc.wantErr, err)
 			assert.Equal(t, tc.wantUser, user)
+			if tc.wantAsync {
+				select {
+				case <-done:
+				case <-time.After(time.Second):
+					t.Fatal("没有回写缓存")
+				}
+			}
+		})
+	}
+}
+
+func TestCachedUserRepository_Create(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (dao.UserDAO, cache.UserCache)
+
+		wantErr error
+	}{
+		{
+			name: "注册成功，删掉“用户不存在”的缓存",
+			mock: func(ctrl *gomock.Controller) (dao.UserDAO, cache.UserCache) {
+				ud := dao_mocksvc.NewMockUserDAO(ctrl)
+				uc := cache_mocksvc.NewMockUserCache(ctrl)
+				ud.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(int64(5), nil)
+				uc.EXPECT().Del(gomock.Any(), int64(5)).Return(nil)
+				return ud, uc
+			},
+		},
+		{
+			name: "邮箱冲突，不动缓存",
+			mock: func(ctrl *gomock.Controller) (dao.UserDAO, cache.UserCache) {
+				ud := dao_mocksvc.NewMockUserDAO(ctrl)
+				ud.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(int64(0), dao.ErrUserDuplicated)
+				return ud, cache_mocksvc.NewMockUserCache(ctrl)
+			},
+			wantErr: ErrUserDuplicated,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ud, uc := tc.mock(ctrl)
+			opts := userCacheOptions
+			// 不延迟双删，方便断言
+			opts.DeleteDelay = 0
+			ur := newCachedUserRepository(ud, uc, cachex_mocksvc.NewMockHealthChecker(ctrl), opts,
+				logger.NewNopLogger())
+			err := ur.Create(context.Background(), domain.User{Email: "tom@qq.com"})
+			assert.Equal(t, tc.wantErr, err)
 		})
 	}
 }
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+var ErrWechatSessionNotFound = dao.ErrWechatSessionNotFound
+
+// WechatSessionRepository 小程序的 session_key，加解密由调用方负责
+type WechatSessionRepository interface {
+	Save(ctx context.Context, s domain.WechatSession) error
+	Find(ctx context.Context, uid int64) (domain.WechatSession, error)
+}
+
+type wechatSessionRepository struct {
+	dao dao.WechatSessionDAO
+}
+
+func NewWechatSessionRepository(dao dao.WechatSessionDAO) WechatSessionRepository {
+	return &wechatSessionRepository{dao: dao}
+}
+
+func (repo *wechatSessionRepository) Save(ctx context.Context, s domain.WechatSession) error {
+	ctx, span := tracer.Start(ctx, "WechatSessionRepository.Save")
+	defer span.End()
+	return repo.dao.Upsert(ctx, dao.WechatSession{
+		UserID:     s.UserID,
+		OpenID:     s.OpenID,
+		SessionKey: s.SessionKey,
+	})
+}
+
+func (repo *wechatSessionRepository) Find(ctx context.Context, uid int64) (domain.WechatSession, error) {
+	ctx, span := tracer.Start(ctx, "WechatSessionRepository.Find")
+	defer span.End()
+	s, err := repo.dao.FindByUserID(ctx, uid)
+	if err != nil {
+		return domain.WechatSession{}, err
+	}
+	return domain.WechatSession{
+		UserID:     s.UserID,
+		OpenID:     s.OpenID,
+		SessionKey: s.SessionKey,
+	}, nil
+}
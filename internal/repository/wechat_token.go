@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+var ErrWechatTokenNotFound = dao.ErrWechatTokenNotFound
+
+// WechatTokenRepository 微信网页授权的 token，加解密由调用方负责
+type WechatTokenRepository interface {
+	Save(ctx context.Context, t domain.WechatToken) error
+	Find(ctx context.Context, uid int64) (domain.WechatToken, error)
+	Delete(ctx context.Context, uid int64) error
+}
+
+type wechatTokenRepository struct {
+	dao dao.WechatTokenDAO
+}
+
+func NewWechatTokenRepository(dao dao.WechatTokenDAO) WechatTokenRepository {
+	return &wechatTokenRepository{dao: dao}
+}
+
+func (repo *wechatTokenRepository) Save(ctx context.Context, t domain.WechatToken) error {
+	ctx, span := tracer.Start(ctx, "WechatTokenRepository.Save")
+	defer span.End()
+	return repo.dao.Upsert(ctx, dao.WechatToken{
+		UserID:            t.UserID,
+		OpenID:            t.OpenID,
+		AccessToken:       t.AccessToken,
+		RefreshToken:      t.RefreshToken,
+		Scope:             t.Scope,
+		ExpireTime:        t.ExpiresAt,
+		RefreshExpireTime: t.RefreshExpiresAt,
+	})
+}
+
+func (repo *wechatTokenRepository) Find(ctx context.Context, uid int64) (domain.WechatToken, error) {
+	ctx, span := tracer.Start(ctx, "WechatTokenRepository.Find")
+	defer span.End()
+	t, err := repo.dao.FindByUserID(ctx, uid)
+	if err != nil {
+		return domain.WechatToken{}, err
+	}
+	return domain.WechatToken{
+		UserID:           t.UserID,
+		OpenID:           t.OpenID,
+		AccessToken:      t.AccessToken,
+		RefreshToken:     t.RefreshToken,
+		Scope:            t.Scope,
+		ExpiresAt:        t.ExpireTime,
+		RefreshExpiresAt: t.RefreshExpireTime,
+	}, nil
+}
+
+func (repo *wechatTokenRepository) Delete(ctx context.Context, uid int64) error {
+	ctx, span := tracer.Start(ctx, "WechatTokenRepository.Delete")
+	defer span.End()
+	return repo.dao.Delete(ctx, uid)
+}
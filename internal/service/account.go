@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/pkg/logger"
+	"webook/pkg/password"
+)
+
+// exportEventBatch 导出安全事件的时候一次查这么多
+const exportEventBatch = 500
+
+// reauthWindow 没有密码的用户，要求这么久之内真正登录过，刷新 token 不算
+const reauthWindow = time.Minute * 5
+
+var (
+	ErrReauthFailed   = errors.New("密码错误")
+	ErrReauthRequired = errors.New("请重新登录之后再操作")
+)
+
+// AccountService 导出数据和注销账号
+type AccountService interface {
+	Export(ctx context.Context, uid int64) (domain.UserExport, error)
+	// ScheduleDelete 重新验证通过之后申请注销，冷静期过了才匿名化，返回匿名化的时间
+	// 同时强制下线，冷静期内重新登录之后调用 CancelDelete 撤销，登录本身不会撤销
+	ScheduleDelete(ctx context.Context, uid int64, reauth domain.Reauth) (time.Time, error)
+	CancelDelete(ctx context.Context, uid int64) error
+	// PurgeDue 匿名化最多 limit 个冷静期已经过了的账号，返回成功的个数
+	PurgeDue(ctx context.Context, limit int) (int, error)
+}
+
+type accountService struct {
+	userRepo    repository.UserRepository
+	passkeyRepo repository.PasskeyRepository
+	roleRepo    repository.RoleRepository
+	eventRepo   repository.SecurityEventRepository
+	mfaSvc      MFAService
+	sessions    SessionService
+	hasher      password.Hasher
+	gracePeriod time.Duration
+	l           logger.Logger
+	now         func() time.Time
+}
+
+func NewAccountService(userRepo repository.UserRepository, passkeyRepo repository.PasskeyRepository,
+	roleRepo repository.RoleRepository, eventRepo repository.SecurityEventRepository, mfaSvc MFAService,
+	sessions SessionService, hasher password.Hasher, gracePeriod time.Duration, l logger.Logger) AccountService {
+	return &accountService{
+		userRepo:    userRepo,
+		passkeyRepo: passkeyRepo,
+		roleRepo:    roleRepo,
+		eventRepo:   eventRepo,
+		mfaSvc:      mfaSvc,
+		sessions:    sessions,
+		hasher:      hasher,
+		gracePeriod: gracePeriod,
+		l:           l,
+		now:         time.Now,
+	}
+}
+
+func (svc *accountService) Export(ctx context.Context, uid int64) (domain.UserExport, error) {
+	ctx, span := tracer.Start(ctx, "AccountService.Export")
+	defer span.End()
+	u, err := svc.userRepo.FindByID(ctx, uid)
+	if err != nil {
+		return domain.UserExport{}, err
+	}
+	identities, err := svc.userRepo.FindIdentities(ctx, uid)
+	if err != nil {
+		return domain.UserExport{}, err
+	}
+	passkeys, err := svc.passkeyRepo.FindByUser(ctx, uid)
+	if err != nil {
+		return domain.UserExport{}, err
+	}
+	totpEnabled, err := svc.mfaSvc.Enabled(ctx, uid)
+	if err != nil {
+		return domain.UserExport{}, err
+	}
+	roles, err := svc.roleRepo.Roles(ctx, uid)
+	if err != nil {
+		return domain.UserExport{}, err
+	}
+	events, err := svc.allEvents(ctx, uid)
+	if err != nil {
+		return domain.UserExport{}, err
+	}
+	return domain.UserExport{
+		User:           u,
+		Identities:     identities,
+		Passkeys:       passkeys,
+		TOTPEnabled:    totpEnabled,
+		Roles:          roles,
+		SecurityEvents: events,
+		ExportedAt:     svc.now().UnixMilli(),
+	}, nil
+}
+
+func (svc *accountService) allEvents(ctx context.Context, uid int64) ([]domain.SecurityEvent, error) {
+	var (
+		res    []domain.SecurityEvent
+		cursor int64
+	)
+	for {
+		events, err := svc.eventRepo.FindByUser(ctx, uid, cursor, exportEventBatch)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, events...)
+		if len(events) < exportEventBatch {
+			return res, nil
+		}
+		cursor = events[len(events)-1].ID
+	}
+}
+
+func (svc *accountService) ScheduleDelete(ctx context.Context, uid int64, reauth domain.Reauth) (time.Time, error) {
+	ctx, span := tracer.Start(ctx, "AccountService.ScheduleDelete")
+	defer span.End()
+	u, err := svc.userRepo.FindByID(ctx, uid)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err = svc.reauthenticate(ctx, u, reauth); err != nil {
+		return time.Time{}, err
+	}
+	deleteAt := svc.now().Add(svc.gracePeriod)
+	if err = svc.userRepo.ScheduleDelete(ctx, uid, deleteAt.UnixMilli()); err != nil {
+		return time.Time{}, err
+	}
+	if err = svc.sessions.Revoke(ctx, uid); err != nil {
+		return time.Time{}, err
+	}
+	return deleteAt, nil
+}
+
+// reauthenticate 有密码的用户验证密码，没有密码的用户要求刚刚登录过，开启了二次验证的还要验证码
+func (svc *accountService) reauthenticate(ctx context.Context, u domain.User, reauth domain.Reauth) error {
+	if u.Password != "" {
+		if ok, _ := svc.hasher.Verify(u.Password, reauth.Password); !ok {
+			return ErrReauthFailed
+		}
+	} else if svc.now().Sub(time.UnixMilli(reauth.AuthenticatedAt)) > reauthWindow {
+		return ErrReauthRequired
+	}
+	enabled, err := svc.mfaSvc.Enabled(ctx, u.ID)
+	if err != nil || !enabled {
+		return err
+	}
+	return svc.mfaSvc.Verify(ctx, u.ID, reauth.TOTPCode)
+}
+
+func (svc *accountService) CancelDelete(ctx context.Context, uid int64) error {
+	ctx, span := tracer.Start(ctx, "AccountService.CancelDelete")
+	defer span.End()
+	return svc.userRepo.ScheduleDelete(ctx, uid, 0)
+}
+
+func (svc *accountService) PurgeDue(ctx context.Context, limit int) (int, error) {
+	ctx, span := tracer.Start(ctx, "AccountService.PurgeDue")
+	defer span.End()
+	ids, err := svc.userRepo.FindDeleteDue(ctx, svc.now(), limit)
+	if err != nil {
+		return 0, err
+	}
+	cnt := 0
+	for _, id := range ids {
+		if err = svc.userRepo.Anonymize(ctx, id); err != nil {
+			// 下一轮再试
+			svc.l.Error(ctx, "匿名化账号失败", logger.Int64("uid", id), logger.Error(err))
+			continue
+		}
+		cnt++
+		// 冷静期内可能重新登录过，账号状态已经是注销了，失败的话 token 也不能用了
+		if err = svc.sessions.Revoke(ctx, id); err != nil {
+			svc.l.Warn(ctx, "注销之后强制下线失败", logger.Int64("uid", id), logger.Error(err))
+		}
+	}
+	return cnt, nil
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	mocksvc "webook/internal/repository/mock"
+	svcmocks "webook/internal/service/mock"
+	"webook/pkg/logger"
+	"webook/pkg/password"
+)
+
+type accountMocks struct {
+	userRepo    *mocksvc.MockUserRepository
+	passkeyRepo *mocksvc.MockPasskeyRepository
+	roleRepo    *mocksvc.MockRoleRepository
+	eventRepo   *mocksvc.MockSecurityEventRepository
+	mfaSvc      *svcmocks.MockMFAService
+	sessions    *svcmocks.MockSessionService
+}
+
+func newAccountMocks(ctrl *gomock.Controller) accountMocks {
+	return accountMocks{
+		userRepo:    mocksvc.NewMockUserRepository(ctrl),
+		passkeyRepo: mocksvc.NewMockPasskeyRepository(ctrl),
+		roleRepo:    mocksvc.NewMockRoleRepository(ctrl),
+		eventRepo:   mocksvc.NewMockSecurityEventRepository(ctrl),
+		mfaSvc:      svcmocks.NewMockMFAService(ctrl),
+		sessions:    svcmocks.NewMockSessionService(ctrl),
+	}
+}
+
+func (m accountMocks) svc(now time.Time) AccountService {
+	svc := NewAccountService(m.userRepo, m.passkeyRepo, m.roleRepo, m.eventRepo, m.mfaSvc, m.sessions,
+		password.NewBcryptHasher(10), time.Hour*24*15, logger.NewNopLogger())
+	svc.(*accountService).now = func() time.Time { return now }
+	return svc
+}
+
+func Test_accountService_ScheduleDelete(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	deleteAt := now.Add(time.Hour * 24 * 15)
+	// 密码是 QQqq11!!
+	const hash = "$2a$10$EHqoKRCV1mAPyeUebxNUeeOK2lAGvpsxT1pUZFgvw9TuKA9EVNLvS"
+	testCases := []struct {
+		name   string
+		mock   func(m accountMocks)
+		reauth domain.Reauth
+
+		wantDeleteAt time.Time
+		wantErr      error
+	}{
+		{
+			name: "密码正确，冷静期之后匿名化",
+			mock: func(m accountMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1, Password: hash}, nil)
+				m.mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(false, nil)
+				m.userRepo.EXPECT().ScheduleDelete(gomock.Any(), int64(1), deleteAt.UnixMilli()).Return(nil)
+				m.sessions.EXPECT().Revoke(gomock.Any(), int64(1)).Return(nil)
+			},
+			reauth:       domain.Reauth{Password: "QQqq11!!"},
+			wantDeleteAt: deleteAt,
+		},
+		{
+			name: "密码错误",
+			mock: func(m accountMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1, Password: hash}, nil)
+			},
+			reauth:  domain.Reauth{Password: "QQqq11!"},
+			wantErr: ErrReauthFailed,
+		},
+		{
+			name: "有密码的用户，刚登录过也要输密码",
+			mock: func(m accountMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1, Password: hash}, nil)
+			},
+			reauth:  domain.Reauth{AuthenticatedAt: now.UnixMilli()},
+			wantErr: ErrReauthFailed,
+		},
+		{
+			name: "没有密码，刚刚登录过",
+			mock: func(m accountMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				m.mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(false, nil)
+				m.userRepo.EXPECT().ScheduleDelete(gomock.Any(), int64(1), deleteAt.UnixMilli()).Return(nil)
+				m.sessions.EXPECT().Revoke(gomock.Any(), int64(1)).Return(nil)
+			},
+			reauth:       domain.Reauth{AuthenticatedAt: now.Add(-time.Minute).UnixMilli()},
+			wantDeleteAt: deleteAt,
+		},
+		{
+			name: "没有密码，登录太久了",
+			mock: func(m accountMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+			},
+			reauth:  domain.Reauth{AuthenticatedAt: now.Add(-time.Hour).UnixMilli()},
+			wantErr: ErrReauthRequired,
+		},
+		{
+			name: "没有密码，刷新出来的 token 没有登录时间",
+			mock: func(m accountMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+			},
+			reauth:  domain.Reauth{},
+			wantErr: ErrReauthRequired,
+		},
+		{
+			name: "开启了二次验证，验证码错误",
+			mock: func(m accountMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1, Password: hash}, nil)
+				m.mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(true, nil)
+				m.mfaSvc.EXPECT().Verify(gomock.Any(), int64(1), "123456").Return(ErrMFACodeInvalid)
+			},
+			reauth:  domain.Reauth{Password: "QQqq11!!", TOTPCode: "123456"},
+			wantErr: ErrMFACodeInvalid,
+		},
+		{
+			name: "用户不存在",
+			mock: func(m accountMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{}, ErrUserNotFound)
+			},
+			wantErr: ErrUserNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := newAccountMocks(ctrl)
+			tc.mock(m)
+			deleteAt, err := m.svc(now).ScheduleDelete(context.Background(), 1, tc.reauth)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantDeleteAt, deleteAt)
+		})
+	}
+}
+
+func Test_accountService_PurgeDue(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name string
+		mock func(m accountMocks)
+
+		wantCnt int
+		wantErr error
+	}{
+		{
+			name: "一个失败，其他的继续",
+			mock: func(m accountMocks) {
+				m.userRepo.EXPECT().FindDeleteDue(gomock.Any(), now, 10).Return([]int64{1, 2, 3}, nil)
+				m.userRepo.EXPECT().Anonymize(gomock.Any(), int64(1)).Return(nil)
+				m.userRepo.EXPECT().Anonymize(gomock.Any(), int64(2)).Return(errors.New("db 错误"))
+				m.userRepo.EXPECT().Anonymize(gomock.Any(), int64(3)).Return(nil)
+				m.sessions.EXPECT().Revoke(gomock.Any(), int64(1)).Return(nil)
+				// 强制下线失败不影响结果
+				m.sessions.EXPECT().Revoke(gomock.Any(), int64(3)).Return(errors.New("redis 错误"))
+			},
+			wantCnt: 2,
+		},
+		{
+			name: "查询失败",
+			mock: func(m accountMocks) {
+				m.userRepo.EXPECT().FindDeleteDue(gomock.Any(), now, 10).Return(nil, errors.New("db 错误"))
+			},
+			wantErr: errors.New("db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := newAccountMocks(ctrl)
+			tc.mock(m)
+			cnt, err := m.svc(now).PurgeDue(context.Background(), 10)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantCnt, cnt)
+		})
+	}
+}
+
+func Test_accountService_Export(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := newAccountMocks(ctrl)
+	user := domain.User{ID: 1, Email: "tom@qq.com"}
+	identities := []domain.Identity{{Provider: domain.ProviderGitHub, Subject: "1001"}}
+	passkeys := []domain.Passkey{{ID: 2, UserID: 1, Name: "我的 iPhone"}}
+	events := []domain.SecurityEvent{{ID: 3, UserID: 1, Type: domain.SecurityLoginSuccess}}
+	m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(user, nil)
+	m.userRepo.EXPECT().FindIdentities(gomock.Any(), int64(1)).Return(identities, nil)
+	m.passkeyRepo.EXPECT().FindByUser(gomock.Any(), int64(1)).Return(passkeys, nil)
+	m.mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(true, nil)
+	m.roleRepo.EXPECT().Roles(gomock.Any(), int64(1)).Return([]string{domain.RoleSupport}, nil)
+	m.eventRepo.EXPECT().FindByUser(gomock.Any(), int64(1), int64(0), exportEventBatch).Return(events, nil)
+
+	export, err := m.svc(now).Export(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.UserExport{
+		User:           user,
+		Identities:     identities,
+		Passkeys:       passkeys,
+		TOTPEnabled:    true,
+		Roles:          []string{domain.RoleSupport},
+		SecurityEvents: events,
+		ExportedAt:     now.UnixMilli(),
+	}, export)
+}
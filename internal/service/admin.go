@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/pkg/logger"
+)
+
+var (
+	ErrInvalidRole       = errors.New("未知的角色")
+	ErrInvalidUserStatus = errors.New("未知的账号状态")
+	ErrInvalidBanUntil   = errors.New("封禁截止时间必须晚于当前时间")
+)
+
+// AdminService 运营后台的用户管理，每个操作做完之后按结果记审计日志，失败的操作也记
+// 修改数据库的操作和审计日志在同一个事务里面，查询的审计日志记不下来就不返回数据
+type AdminService interface {
+	// SearchUsers keyword 是用户 ID、邮箱或者手机号，精确匹配，以后支持模糊搜索再返回多个
+	SearchUsers(ctx context.Context, op domain.Operator, keyword string) ([]domain.User, error)
+	// UserDetail 返回用户信息和角色
+	UserDetail(ctx context.Context, op domain.Operator, uid int64) (domain.User, []string, error)
+	// SetStatus 禁用的时候同时强制下线，启用的时候也会解除封禁
+	SetStatus(ctx context.Context, op domain.Operator, uid int64, status domain.UserStatus) error
+	// Ban 封禁到 until，同时强制下线，到期之后不用解封也能登录
+	Ban(ctx context.Context, op domain.Operator, uid int64, until time.Time) error
+	ForceLogout(ctx context.Context, op domain.Operator, uid int64) error
+	// SetRoles 覆盖用户的角色，角色在 token 里面，所以要强制下线重新登录
+	SetRoles(ctx context.Context, op domain.Operator, uid int64, roles []string) error
+	// ResetSMSLimit 只清空 CodeGuard 里面按手机号计数的规则，手机号的硬限制和人机验证一起解除
+	// 按 IP、设备和全局的计数不动，已经发出去的验证码和它的重发间隔也不动
+	ResetSMSLimit(ctx context.Context, op domain.Operator, biz, phone string) error
+}
+
+type adminService struct {
+	userRepo  repository.UserRepository
+	roleRepo  repository.RoleRepository
+	auditRepo repository.AdminAuditRepository
+	sessions  SessionService
+	codeGuard CodeGuard
+	l         logger.Logger
+	now       func() time.Time
+}
+
+func NewAdminService(userRepo repository.UserRepository, roleRepo repository.RoleRepository,
+	auditRepo repository.AdminAuditRepository, sessions SessionService, codeGuard CodeGuard,
+	l logger.Logger) AdminService {
+	return &adminService{
+		userRepo:  userRepo,
+		roleRepo:  roleRepo,
+		auditRepo: auditRepo,
+		sessions:  sessions,
+		codeGuard: codeGuard,
+		l:         l,
+		now:       time.Now,
+	}
+}
+
+func (svc *adminService) SearchUsers(ctx context.Context, op domain.Operator, keyword string) ([]domain.User, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.SearchUsers")
+	defer span.End()
+	users, err := svc.searchUsers(ctx, keyword)
+	if aerr := svc.audit(ctx, op, domain.AuditSearchUser, 0, keyword, err); aerr != nil {
+		return nil, aerr
+	}
+	return users, err
+}
+
+func (svc *adminService) searchUsers(ctx context.Context, keyword string) ([]domain.User, error) {
+	var (
+		u   domain.User
+		err error
+	)
+	switch {
+	case strings.Contains(keyword, "@"):
+		u, err = svc.userRepo.FindByEmail(ctx, keyword)
+		if err == nil {
+			// 按邮箱查出来的字段不全
+			u, err = svc.userRepo.FindByID(ctx, u.ID)
+		}
+	case strings.HasPrefix(keyword, "+"):
+		// 手机号都是 E.164 格式
+		u, err = svc.userRepo.FindByPhone(ctx, keyword)
+	default:
+		id, perr := strconv.ParseInt(keyword, 10, 64)
+		if perr != nil {
+			return []domain.User{}, nil
+		}
+		u, err = svc.userRepo.FindByID(ctx, id)
+	}
+	if err == repository.ErrUserNotFound {
+		return []domain.User{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []domain.User{u}, nil
+}
+
+func (svc *adminService) UserDetail(ctx context.Context, op domain.Operator, uid int64) (domain.User, []string, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.UserDetail")
+	defer span.End()
+	u, err := svc.userRepo.FindByID(ctx, uid)
+	var roles []string
+	if err == nil {
+		roles, err = svc.roleRepo.Roles(ctx, uid)
+	}
+	if aerr := svc.audit(ctx, op, domain.AuditViewUser, uid, "", err); aerr != nil {
+		return domain.User{}, nil, aerr
+	}
+	if err != nil {
+		return domain.User{}, nil, err
+	}
+	return u, roles, nil
+}
+
+func (svc *adminService) SetStatus(ctx context.Context, op domain.Operator, uid int64, status domain.UserStatus) error {
+	ctx, span := tracer.Start(ctx, "AdminService.SetStatus")
+	defer span.End()
+	var action string
+	switch status {
+	case domain.UserStatusActive:
+		action = domain.AuditEnableUser
+	case domain.UserStatusDisabled:
+		action = domain.AuditDisableUser
+	default:
+		return ErrInvalidUserStatus
+	}
+	err := svc.findUser(ctx, uid)
+	if err == nil {
+		err = svc.userRepo.UpdateStatus(ctx, uid, status, 0, newAuditLog(op, action, uid, "", nil))
+	}
+	if err != nil {
+		// 事务回滚了，失败的操作单独记一条
+		svc.audit(ctx, op, action, uid, "", err)
+		return err
+	}
+	if status == domain.UserStatusDisabled {
+		return svc.revoke(ctx, op, uid)
+	}
+	return nil
+}
+
+func (svc *adminService) Ban(ctx context.Context, op domain.Operator, uid int64, until time.Time) error {
+	ctx, span := tracer.Start(ctx, "AdminService.Ban")
+	defer span.End()
+	if !until.After(svc.now()) {
+		return ErrInvalidBanUntil
+	}
+	detail := until.Format(time.RFC3339)
+	err := svc.findUser(ctx, uid)
+	if err == nil {
+		err = svc.userRepo.UpdateStatus(ctx, uid, domain.UserStatusBanned, until.UnixMilli(),
+			newAuditLog(op, domain.AuditBanUser, uid, detail, nil))
+	}
+	if err != nil {
+		svc.audit(ctx, op, domain.AuditBanUser, uid, detail, err)
+		return err
+	}
+	// 解封之后旧的 token 也不能再用
+	return svc.revoke(ctx, op, uid)
+}
+
+func (svc *adminService) ForceLogout(ctx context.Context, op domain.Operator, uid int64) error {
+	ctx, span := tracer.Start(ctx, "AdminService.ForceLogout")
+	defer span.End()
+	err := svc.findUser(ctx, uid)
+	if err == nil {
+		err = svc.sessions.Revoke(ctx, uid)
+	}
+	svc.audit(ctx, op, domain.AuditForceLogout, uid, "", err)
+	return err
+}
+
+func (svc *adminService) SetRoles(ctx context.Context, op domain.Operator, uid int64, roles []string) error {
+	ctx, span := tracer.Start(ctx, "AdminService.SetRoles")
+	defer span.End()
+	roles = slices.Clone(roles)
+	slices.Sort(roles)
+	roles = slices.Compact(roles)
+	for _, role := range roles {
+		if !domain.ValidRole(role) {
+			return fmt.Errorf("%w: %s", ErrInvalidRole, role)
+		}
+	}
+	detail := strings.Join(roles, ",")
+	err := svc.findUser(ctx, uid)
+	if err == nil {
+		err = svc.roleRepo.SetRoles(ctx, uid, roles, newAuditLog(op, domain.AuditSetRoles, uid, detail, nil))
+	}
+	if err != nil {
+		svc.audit(ctx, op, domain.AuditSetRoles, uid, detail, err)
+		return err
+	}
+	return svc.revoke(ctx, op, uid)
+}
+
+func (svc *adminService) ResetSMSLimit(ctx context.Context, op domain.Operator, biz, phone string) error {
+	ctx, span := tracer.Start(ctx, "AdminService.ResetSMSLimit")
+	defer span.End()
+	err := svc.codeGuard.Reset(ctx, biz, phone)
+	svc.audit(ctx, op, domain.AuditResetSMS, 0, biz+":"+phone, err)
+	return err
+}
+
+// findUser 已经注销的用户当作不存在，不能再启用或者授权
+func (svc *adminService) findUser(ctx context.Context, uid int64) error {
+	u, err := svc.userRepo.FindByID(ctx, uid)
+	if err == nil && u.Status == domain.UserStatusDeleted {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// revoke 状态或者角色已经改好了，强制下线失败的时候单独记一条
+func (svc *adminService) revoke(ctx context.Context, op domain.Operator, uid int64) error {
+	err := svc.sessions.Revoke(ctx, uid)
+	if err != nil {
+		svc.audit(ctx, op, domain.AuditForceLogout, uid, "", err)
+	}
+	return err
+}
+
+// audit 记录操作的结果，opErr 是操作本身的错误
+// 记不下来的时候打日志，修改类的操作已经做完了，只有查询类的操作用得上返回的错误
+func (svc *adminService) audit(ctx context.Context, op domain.Operator, action string, target int64,
+	detail string, opErr error) error {
+	err := svc.auditRepo.Create(ctx, newAuditLog(op, action, target, detail, opErr))
+	if err != nil {
+		svc.l.Error(ctx, "记录审计日志失败", logger.Int64("operator", op.UserID),
+			logger.String("action", action), logger.Int64("target", target),
+			logger.Bool("success", opErr == nil), logger.Error(err))
+	}
+	return err
+}
+
+func newAuditLog(op domain.Operator, action string, target int64, detail string, opErr error) domain.AuditLog {
+	log := domain.AuditLog{
+		OperatorID: op.UserID,
+		Action:     action,
+		TargetID:   target,
+		Detail:     detail,
+		IP:         op.IP,
+		Success:    opErr == nil,
+	}
+	if opErr != nil {
+		log.Error = opErr.Error()
+	}
+	return log
+}
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	mocksvc "webook/internal/repository/mock"
+	svcmocks "webook/internal/service/mock"
+	"webook/pkg/logger"
+)
+
+type adminMocks struct {
+	userRepo  *mocksvc.MockUserRepository
+	roleRepo  *mocksvc.MockRoleRepository
+	auditRepo *mocksvc.MockAdminAuditRepository
+	sessions  *svcmocks.MockSessionService
+	codeGuard *svcmocks.MockCodeGuard
+}
+
+func newAdminMocks(ctrl *gomock.Controller) adminMocks {
+	return adminMocks{
+		userRepo:  mocksvc.NewMockUserRepository(ctrl),
+		roleRepo:  mocksvc.NewMockRoleRepository(ctrl),
+		auditRepo: mocksvc.NewMockAdminAuditRepository(ctrl),
+		sessions:  svcmocks.NewMockSessionService(ctrl),
+		codeGuard: svcmocks.NewMockCodeGuard(ctrl),
+	}
+}
+
+func (m adminMocks) svc() AdminService {
+	return NewAdminService(m.userRepo, m.roleRepo, m.auditRepo, m.sessions, m.codeGuard, logger.NewNopLogger())
+}
+
+func Test_adminService_SearchUsers(t *testing.T) {
+	op := domain.Operator{UserID: 100, IP: "127.0.0.1"}
+	testCases := []struct {
+		name    string
+		mock    func(m adminMocks)
+		keyword string
+
+		wantUsers []domain.User
+		wantErr   error
+	}{
+		{
+			name: "按邮箱搜索",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByEmail(gomock.Any(), "tom@qq.com").Return(domain.User{ID: 1}, nil)
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).
+					Return(domain.User{ID: 1, Email: "tom@qq.com", Nickname: "tom"}, nil)
+				m.auditRepo.EXPECT().Create(gomock.Any(), domain.AuditLog{
+					OperatorID: 100, Action: domain.AuditSearchUser, Detail: "tom@qq.com", IP: "127.0.0.1",
+					Success: true,
+				}).Return(nil)
+			},
+			keyword:   "tom@qq.com",
+			wantUsers: []domain.User{{ID: 1, Email: "tom@qq.com", Nickname: "tom"}},
+		},
+		{
+			name: "按手机号搜索",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByPhone(gomock.Any(), "+8613800138000").
+					Return(domain.User{ID: 2, Phone: "+8613800138000"}, nil)
+				m.auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			keyword:   "+8613800138000",
+			wantUsers: []domain.User{{ID: 2, Phone: "+8613800138000"}},
+		},
+		{
+			name: "按 ID 搜索，用户不存在",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(3)).
+					Return(domain.User{}, repository.ErrUserNotFound)
+				m.auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			keyword:   "3",
+			wantUsers: []domain.User{},
+		},
+		{
+			name: "关键字不是 ID、邮箱或者手机号",
+			mock: func(m adminMocks) {
+				m.auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			keyword:   "tom",
+			wantUsers: []domain.User{},
+		},
+		{
+			name: "查询失败也要记录",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByPhone(gomock.Any(), "+8613800138000").
+					Return(domain.User{}, errors.New("db 错误"))
+				m.auditRepo.EXPECT().Create(gomock.Any(), domain.AuditLog{
+					OperatorID: 100, Action: domain.AuditSearchUser, Detail: "+8613800138000", IP: "127.0.0.1",
+					Error: "db 错误",
+				}).Return(nil)
+			},
+			keyword: "+8613800138000",
+			wantErr: errors.New("db 错误"),
+		},
+		{
+			name: "审计日志记录失败，不返回数据",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByEmail(gomock.Any(), "tom@qq.com").Return(domain.User{ID: 1}, nil)
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				m.auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(errors.New("db 错误"))
+			},
+			keyword: "tom@qq.com",
+			wantErr: errors.New("db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := newAdminMocks(ctrl)
+			tc.mock(m)
+			users, err := m.svc().SearchUsers(context.Background(), op, tc.keyword)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantUsers, users)
+		})
+	}
+}
+
+func Test_adminService_SetStatus(t *testing.T) {
+	op := domain.Operator{UserID: 100, IP: "127.0.0.1"}
+	testCases := []struct {
+		name   string
+		mock   func(m adminMocks)
+		status domain.UserStatus
+
+		wantErr error
+	}{
+		{
+			name: "禁用并且强制下线",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				// 审计日志和状态在同一个事务里面
+				m.userRepo.EXPECT().UpdateStatus(gomock.Any(), int64(1), domain.UserStatusDisabled, int64(0),
+					domain.AuditLog{
+						OperatorID: 100, Action: domain.AuditDisableUser, TargetID: 1, IP: "127.0.0.1", Success: true,
+					}).Return(nil)
+				m.sessions.EXPECT().Revoke(gomock.Any(), int64(1)).Return(nil)
+			},
+			status: domain.UserStatusDisabled,
+		},
+		{
+			name: "启用不用下线",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				m.userRepo.EXPECT().UpdateStatus(gomock.Any(), int64(1), domain.UserStatusActive, int64(0),
+					domain.AuditLog{
+						OperatorID: 100, Action: domain.AuditEnableUser, TargetID: 1, IP: "127.0.0.1", Success: true,
+					}).Return(nil)
+			},
+			status: domain.UserStatusActive,
+		},
+		{
+			name: "用户不存在",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).
+					Return(domain.User{}, repository.ErrUserNotFound)
+				m.auditRepo.EXPECT().Create(gomock.Any(), domain.AuditLog{
+					OperatorID: 100, Action: domain.AuditDisableUser, TargetID: 1, IP: "127.0.0.1",
+					Error: ErrUserNotFound.Error(),
+				}).Return(nil)
+			},
+			status:  domain.UserStatusDisabled,
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "修改失败，事务回滚之后单独记录",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				m.userRepo.EXPECT().UpdateStatus(gomock.Any(), int64(1), domain.UserStatusDisabled, int64(0),
+					gomock.Any()).Return(errors.New("db 错误"))
+				m.auditRepo.EXPECT().Create(gomock.Any(), domain.AuditLog{
+					OperatorID: 100, Action: domain.AuditDisableUser, TargetID: 1, IP: "127.0.0.1",
+					Error: "db 错误",
+				}).Return(nil)
+			},
+			status:  domain.UserStatusDisabled,
+			wantErr: errors.New("db 错误"),
+		},
+		{
+			name: "已经禁用了，强制下线失败单独记录",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				m.userRepo.EXPECT().UpdateStatus(gomock.Any(), int64(1), domain.UserStatusDisabled, int64(0),
+					gomock.Any()).Return(nil)
+				m.sessions.EXPECT().Revoke(gomock.Any(), int64(1)).Return(errors.New("redis 错误"))
+				m.auditRepo.EXPECT().Create(gomock.Any(), domain.AuditLog{
+					OperatorID: 100, Action: domain.AuditForceLogout, TargetID: 1, IP: "127.0.0.1",
+					Error: "redis 错误",
+				}).Return(nil)
+			},
+			status:  domain.UserStatusDisabled,
+			wantErr: errors.New("redis 错误"),
+		},
+		{
+			name:    "未知的状态",
+			mock:    func(m adminMocks) {},
+			status:  domain.UserStatus(9),
+			wantErr: ErrInvalidUserStatus,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := newAdminMocks(ctrl)
+			tc.mock(m)
+			err := m.svc().SetStatus(context.Background(), op, 1, tc.status)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_adminService_Ban(t *testing.T) {
+	op := domain.Operator{UserID: 100, IP: "127.0.0.1"}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name  string
+		mock  func(m adminMocks)
+		until time.Time
+
+		wantErr error
+	}{
+		{
+			name: "封禁并且强制下线",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				m.userRepo.EXPECT().UpdateStatus(gomock.Any(), int64(1), domain.UserStatusBanned,
+					now.Add(7*24*time.Hour).UnixMilli(), domain.AuditLog{
+						OperatorID: 100, Action: domain.AuditBanUser, TargetID: 1,
+						Detail: "2024-01-08T00:00:00Z", IP: "127.0.0.1", Success: true,
+					}).Return(nil)
+				m.sessions.EXPECT().Revoke(gomock.Any(), int64(1)).Return(nil)
+			},
+			until: now.Add(7 * 24 * time.Hour),
+		},
+		{
+			name:    "截止时间已经过了",
+			mock:    func(m adminMocks) {},
+			until:   now,
+			wantErr: ErrInvalidBanUntil,
+		},
+		{
+			name: "用户不存在",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).
+					Return(domain.User{}, repository.ErrUserNotFound)
+				m.auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			until:   now.Add(time.Hour),
+			wantErr: ErrUserNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := newAdminMocks(ctrl)
+			tc.mock(m)
+			svc := m.svc()
+			svc.(*adminService).now = func() time.Time { return now }
+			err := svc.Ban(context.Background(), op, 1, tc.until)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_adminService_SetRoles(t *testing.T) {
+	op := domain.Operator{UserID: 100, IP: "127.0.0.1"}
+	testCases := []struct {
+		name  string
+		mock  func(m adminMocks)
+		roles []string
+
+		wantErr error
+	}{
+		{
+			name: "去重之后保存，强制下线",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				m.roleRepo.EXPECT().SetRoles(gomock.Any(), int64(1), []string{"admin", "support"}, domain.AuditLog{
+					OperatorID: 100, Action: domain.AuditSetRoles, TargetID: 1, Detail: "admin,support", IP: "127.0.0.1",
+					Success: true,
+				}).Return(nil)
+				m.sessions.EXPECT().Revoke(gomock.Any(), int64(1)).Return(nil)
+			},
+			roles: []string{"support", "admin", "support"},
+		},
+		{
+			name: "去掉所有角色",
+			mock: func(m adminMocks) {
+				m.userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				m.roleRepo.EXPECT().SetRoles(gomock.Any(), int64(1), []string{}, gomock.Any()).Return(nil)
+				m.sessions.EXPECT().Revoke(gomock.Any(), int64(1)).Return(nil)
+			},
+			roles: []string{},
+		},
+		{
+			name:    "未知的角色",
+			mock:    func(m adminMocks) {},
+			roles:   []string{"admin", "root"},
+			wantErr: ErrInvalidRole,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := newAdminMocks(ctrl)
+			tc.mock(m)
+			err := m.svc().SetRoles(context.Background(), op, 1, tc.roles)
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func Test_adminService_ResetSMSLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := newAdminMocks(ctrl)
+	m.codeGuard.EXPECT().Reset(gomock.Any(), "Login", "+8613800138000").Return(nil)
+	m.auditRepo.EXPECT().Create(gomock.Any(), domain.AuditLog{
+		OperatorID: 100, Action: domain.AuditResetSMS, Detail: "Login:+8613800138000", IP: "127.0.0.1",
+		Success: true,
+	}).Return(nil)
+	err := m.svc().ResetSMSLimit(context.Background(), domain.Operator{UserID: 100, IP: "127.0.0.1"},
+		"Login", "+8613800138000")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,16 @@
+package nocaptcha
+
+import "context"
+
+// Service 没有配置人机验证的时候使用，一律不通过
+// 触发人机验证的请求都会被拒绝，不能因为少了配置就放开防刷
+type Service struct {
+}
+
+func NewService() *Service {
+	return &Service{}
+}
+
+func (s *Service) Verify(ctx context.Context, ticket string, ip string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,82 @@
+package siteverify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"webook/pkg/httpx"
+)
+
+// 各家的默认校验地址
+const (
+	TurnstileURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+	RecaptchaURL = "https://www.google.com/recaptcha/api/siteverify"
+	HCaptchaURL  = "https://api.hcaptcha.com/siteverify"
+)
+
+// Service Cloudflare Turnstile、Google reCAPTCHA 和 hCaptcha 的校验接口是一样的：
+// 表单提交 secret、response、remoteip，返回 {"success": true, "error-codes": []}
+type Service struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewService(url, secret string) *Service {
+	return &Service{
+		url:    url,
+		secret: secret,
+		client: &http.Client{
+			Transport: httpx.NewTracingTransport(http.DefaultTransport),
+			Timeout:   time.Second * 5,
+		},
+	}
+}
+
+type response struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+func (s *Service) Verify(ctx context.Context, ticket string, ip string) (bool, error) {
+	if ticket == "" {
+		return false, nil
+	}
+	form := url.Values{}
+	form.Set("secret", s.secret)
+	form.Set("response", ticket)
+	if ip != "" {
+		form.Set("remoteip", ip)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("人机验证接口返回 %d", resp.StatusCode)
+	}
+	var res response
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return false, err
+	}
+	if res.Success {
+		return true, nil
+	}
+	for _, code := range res.ErrorCodes {
+		// 密钥配置错了是我们的问题，不能当作用户没有通过验证
+		if code == "missing-input-secret" || code == "invalid-input-secret" {
+			return false, fmt.Errorf("人机验证密钥不对: %s", code)
+		}
+	}
+	return false, nil
+}
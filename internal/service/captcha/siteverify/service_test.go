@@ -0,0 +1,72 @@
+package siteverify
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestService_Verify(t *testing.T) {
+	testCases := []struct {
+		name   string
+		ticket string
+		status int
+		body   string
+
+		wantOK  bool
+		wantErr bool
+	}{
+		{
+			name:   "验证通过",
+			ticket: "ticket",
+			status: http.StatusOK,
+			body:   `{"success":true,"error-codes":[]}`,
+			wantOK: true,
+		},
+		{
+			name:   "票据不对",
+			ticket: "ticket",
+			status: http.StatusOK,
+			body:   `{"success":false,"error-codes":["invalid-input-response"]}`,
+		},
+		{
+			name:    "密钥配置错了",
+			ticket:  "ticket",
+			status:  http.StatusOK,
+			body:    `{"success":false,"error-codes":["invalid-input-secret"]}`,
+			wantErr: true,
+		},
+		{
+			name:    "接口出错",
+			ticket:  "ticket",
+			status:  http.StatusInternalServerError,
+			wantErr: true,
+		},
+		{
+			name: "没有票据，不调用接口",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				require.NoError(t, r.ParseForm())
+				assert.Equal(t, "secret", r.PostForm.Get("secret"))
+				assert.Equal(t, tc.ticket, r.PostForm.Get("response"))
+				assert.Equal(t, "192.0.2.1", r.PostForm.Get("remoteip"))
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			ok, err := NewService(server.URL, "secret").Verify(context.Background(), tc.ticket, "192.0.2.1")
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.ticket != "", called)
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package captcha
+
+import "context"
+
+// Service 人机验证（图形验证码/滑块等）的抽象
+type Service interface {
+	// Verify 校验前端提交的验证票据
+	Verify(ctx context.Context, ticket string, ip string) (bool, error)
+}
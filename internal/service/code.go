@@ -6,6 +6,8 @@ import (
 	"math/rand"
 	"webook/internal/repository"
 	"webook/internal/service/sms"
+	"webook/pkg/i18n"
+	"webook/pkg/logger"
 )
 
 var ErrCodeSendTooMany = repository.ErrCodeSendTooMany
@@ -16,33 +18,65 @@ type CodeService interface {
 	Verify(ctx context.Context, biz, phone, inputCode string) (bool, error)
 }
 
+// CodeTemplates 验证码短信模板，按语言区分
+type CodeTemplates struct {
+	// Default 找不到对应语言的时候使用
+	Default string
+	// Locales 语言到模板 ID 的映射，语言是 en-US 这种写法
+	Locales map[string]string
+}
+
+// For 返回语言对应的模板 ID
+func (t CodeTemplates) For(locale string) string {
+	if tpl, ok := t.Locales[locale]; ok {
+		return tpl
+	}
+	return t.Default
+}
+
 type codeService struct {
 	repo repository.CodeRepository
 	sms  sms.Service
+	tpls CodeTemplates
+	l    logger.Logger
 }
 
-func NewCodeService(repo repository.CodeRepository, smsSvc sms.Service) CodeService {
+func NewCodeService(repo repository.CodeRepository, smsSvc sms.Service,
+	tpls CodeTemplates, l logger.Logger) CodeService {
 	return &codeService{
 		repo: repo,
 		sms:  smsSvc,
+		tpls: tpls,
+		l:    l,
 	}
 }
 
 func (svc *codeService) Send(ctx context.Context, biz, phone string) error {
+	ctx, span := tracer.Start(ctx, "CodeService.Send")
+	defer span.End()
 	code := svc.generate()
 	err := svc.repo.Set(ctx, biz, phone, code)
 	// 你在这儿，是不是要开始发送验证码了？
 	if err != nil {
 		return err
 	}
-	const codeTplId = "1877556"
-	return svc.sms.Send(ctx, codeTplId, []string{code}, phone)
+	// 按请求的语言选择模板
+	err = svc.sms.Send(ctx, svc.tpls.For(i18n.Locale(ctx)), []string{code}, phone)
+	if err != nil {
+		// 验证码已经存进去了，但是用户收不到，只能等过期之后重发
+		svc.l.Error(ctx, "短信发送失败", logger.String("biz", biz),
+			logger.String("phone", phone), logger.Error(err))
+	}
+	return err
 }
 
 func (svc *codeService) Verify(ctx context.Context,
 	biz, phone, inputCode string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "CodeService.Verify")
+	defer span.End()
 	ok, err := svc.repo.Verify(ctx, biz, phone, inputCode)
 	if err == ErrCodeSendTooMany {
+		svc.l.Warn(ctx, "验证码校验次数过多", logger.String("biz", biz), logger.String("phone", phone))
 		// 相当于，我们对外面屏蔽了验证次数过多的错误，我们就是告诉调用者，你这个不对
 		return false, nil
 	}
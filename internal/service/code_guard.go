@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"webook/internal/domain"
+	"webook/internal/service/captcha"
+	"webook/pkg/limiter"
+)
+
+var (
+	ErrCodeSendLimited = errors.New("验证码发送次数超出限制")
+	ErrCaptchaRequired = errors.New("需要进行人机验证")
+)
+
+// CodeGuardDefaultBiz 没有单独配置规则的 biz 使用这一组规则
+const CodeGuardDefaultBiz = "*"
+
+// CodeGuard 发送验证码之前的防刷检查
+// 被拒绝的请求、人机验证没通过的请求和发送冷却期内的请求都不占用配额，
+// 所以 Check 只检查不计数，验证码真正发出去之后再调用 Record 计数
+type CodeGuard interface {
+	Check(ctx context.Context, biz, phone string, client domain.ClientInfo, captchaTicket string) error
+	// Record 验证码发送成功之后调用
+	Record(ctx context.Context, biz, phone string, client domain.ClientInfo) error
+	// Reset 清空手机号相关的发送计数，按 IP、设备和全局的计数不动
+	Reset(ctx context.Context, biz, phone string) error
+}
+
+// CodeGuardKeyFunc 计算限流对象，返回空字符串表示这条规则不适用
+type CodeGuardKeyFunc func(biz, phone string, client domain.ClientInfo) string
+
+// CodeGuardRule 一条防刷规则
+type CodeGuardRule struct {
+	Name string
+	Key  CodeGuardKeyFunc
+	// Limiter 硬限制，触发之后直接拒绝
+	// 检查和计数分开，并发的请求可能一起通过检查，所以会稍微超出一点
+	Limiter limiter.Limiter
+	// CaptchaAfter 窗口内发送超过这个次数之后要求人机验证，0 表示不需要
+	CaptchaAfter int
+}
+
+func GuardByPhone(biz, phone string, client domain.ClientInfo) string {
+	return biz + ":" + phone
+}
+
+func GuardByIP(biz, phone string, client domain.ClientInfo) string {
+	return biz + ":" + client.IP
+}
+
+func GuardByDevice(biz, phone string, client domain.ClientInfo) string {
+	if client.DeviceID == "" {
+		return ""
+	}
+	return biz + ":" + client.DeviceID
+}
+
+// GuardGlobal 所有 biz 共用一个预算
+func GuardGlobal(biz, phone string, client domain.ClientInfo) string {
+	return "all"
+}
+
+type codeGuard struct {
+	rules   map[string][]CodeGuardRule
+	captcha captcha.Service
+}
+
+// NewCodeGuard rules 按 biz 配置，找不到的 biz 使用 CodeGuardDefaultBiz 的规则
+func NewCodeGuard(rules map[string][]CodeGuardRule, captchaSvc captcha.Service) CodeGuard {
+	return &codeGuard{
+		rules:   rules,
+		captcha: captchaSvc,
+	}
+}
+
+func (g *codeGuard) Check(ctx context.Context, biz, phone string,
+	client domain.ClientInfo, captchaTicket string) error {
+	needCaptcha := false
+	for _, rule := range g.rulesFor(biz) {
+		key := rule.Key(biz, phone, client)
+		if key == "" {
+			continue
+		}
+		res, err := rule.Limiter.Peek(ctx, guardKey(rule, key))
+		if err != nil {
+			return err
+		}
+		if res.Limited {
+			return ErrCodeSendLimited
+		}
+		// 算上这一次，窗口内发送的次数
+		sent := res.Limit - res.Remaining
+		if rule.CaptchaAfter > 0 && sent > rule.CaptchaAfter {
+			needCaptcha = true
+		}
+	}
+	if !needCaptcha {
+		return nil
+	}
+	if captchaTicket == "" {
+		return ErrCaptchaRequired
+	}
+	ok, err := g.captcha.Verify(ctx, captchaTicket, client.IP)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCaptchaRequired
+	}
+	return nil
+}
+
+func (g *codeGuard) Record(ctx context.Context, biz, phone string, client domain.ClientInfo) error {
+	for _, rule := range g.rulesFor(biz) {
+		key := rule.Key(biz, phone, client)
+		if key == "" {
+			continue
+		}
+		// 已经发出去了，超出限制也要记上
+		if _, err := rule.Limiter.Limit(ctx, guardKey(rule, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *codeGuard) Reset(ctx context.Context, biz, phone string) error {
+	for _, rule := range g.rulesFor(biz) {
+		key := rule.Key(biz, phone, domain.ClientInfo{})
+		// 和手机号无关的规则，换个手机号算出来的 key 是一样的
+		if key == "" || key == rule.Key(biz, "", domain.ClientInfo{}) {
+			continue
+		}
+		if err := rule.Limiter.Reset(ctx, guardKey(rule, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *codeGuard) rulesFor(biz string) []CodeGuardRule {
+	rules, ok := g.rules[biz]
+	if !ok {
+		return g.rules[CodeGuardDefaultBiz]
+	}
+	return rules
+}
+
+func guardKey(rule CodeGuardRule, key string) string {
+	return fmt.Sprintf("sms:guard:%s:%s", rule.Name, key)
+}
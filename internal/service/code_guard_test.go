@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/service/captcha"
+	"webook/internal/service/captcha/captcha_mocksvc"
+	"webook/pkg/limiter"
+	limiter_mocksvc "webook/pkg/limiter/mock"
+)
+
+func Test_codeGuard_Check(t *testing.T) {
+	client := domain.ClientInfo{IP: "127.0.0.1"}
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) (map[string][]CodeGuardRule, captcha.Service)
+		biz     string
+		ticket  string
+		wantErr error
+	}{
+		{
+			name: "没有触发限制",
+			mock: func(ctrl *gomock.Controller) (map[string][]CodeGuardRule, captcha.Service) {
+				hard := limiter_mocksvc.NewMockLimiter(ctrl)
+				hard.EXPECT().Peek(gomock.Any(), "sms:guard:phone:login:15212345678").
+					Return(limiter.Result{Limit: 10, Remaining: 5}, nil)
+				return map[string][]CodeGuardRule{
+					"login": {{Name: "phone", Key: GuardByPhone, Limiter: hard, CaptchaAfter: 5}},
+				}, captcha_mocksvc.NewMockService(ctrl)
+			},
+			biz: "login",
+		},
+		{
+			name: "使用默认规则，触发硬限制",
+			mock: func(ctrl *gomock.Controller) (map[string][]CodeGuardRule, captcha.Service) {
+				hard := limiter_mocksvc.NewMockLimiter(ctrl)
+				hard.EXPECT().Peek(gomock.Any(), "sms:guard:ip:register:127.0.0.1").Return(limiter.Result{Limited: true}, nil)
+				return map[string][]CodeGuardRule{
+					CodeGuardDefaultBiz: {{Name: "ip", Key: GuardByIP, Limiter: hard}},
+				}, captcha_mocksvc.NewMockService(ctrl)
+			},
+			biz:     "register",
+			wantErr: ErrCodeSendLimited,
+		},
+		{
+			name: "没有设备指纹，跳过规则",
+			mock: func(ctrl *gomock.Controller) (map[string][]CodeGuardRule, captcha.Service) {
+				hard := limiter_mocksvc.NewMockLimiter(ctrl)
+				return map[string][]CodeGuardRule{
+					"login": {{Name: "device", Key: GuardByDevice, Limiter: hard}},
+				}, captcha_mocksvc.NewMockService(ctrl)
+			},
+			biz: "login",
+		},
+		{
+			name: "接近阈值，没有人机验证票据",
+			mock: func(ctrl *gomock.Controller) (map[string][]CodeGuardRule, captcha.Service) {
+				hard := limiter_mocksvc.NewMockLimiter(ctrl)
+				hard.EXPECT().Peek(gomock.Any(), gomock.Any()).
+					Return(limiter.Result{Limit: 10, Remaining: 4}, nil)
+				return map[string][]CodeGuardRule{
+					"login": {{Name: "phone", Key: GuardByPhone, Limiter: hard, CaptchaAfter: 5}},
+				}, captcha_mocksvc.NewMockService(ctrl)
+			},
+			biz:     "login",
+			wantErr: ErrCaptchaRequired,
+		},
+		{
+			name: "接近阈值，人机验证通过",
+			mock: func(ctrl *gomock.Controller) (map[string][]CodeGuardRule, captcha.Service) {
+				hard := limiter_mocksvc.NewMockLimiter(ctrl)
+				hard.EXPECT().Peek(gomock.Any(), gomock.Any()).
+					Return(limiter.Result{Limit: 10, Remaining: 4}, nil)
+				svc := captcha_mocksvc.NewMockService(ctrl)
+				svc.EXPECT().Verify(gomock.Any(), "ticket", "127.0.0.1").Return(true, nil)
+				return map[string][]CodeGuardRule{
+					"login": {{Name: "phone", Key: GuardByPhone, Limiter: hard, CaptchaAfter: 5}},
+				}, svc
+			},
+			biz:    "login",
+			ticket: "ticket",
+		},
+		{
+			name: "接近阈值，人机验证失败",
+			mock: func(ctrl *gomock.Controller) (map[string][]CodeGuardRule, captcha.Service) {
+				hard := limiter_mocksvc.NewMockLimiter(ctrl)
+				hard.EXPECT().Peek(gomock.Any(), gomock.Any()).
+					Return(limiter.Result{Limit: 10, Remaining: 4}, nil)
+				svc := captcha_mocksvc.NewMockService(ctrl)
+				svc.EXPECT().Verify(gomock.Any(), "ticket", "127.0.0.1").Return(false, nil)
+				return map[string][]CodeGuardRule{
+					"login": {{Name: "phone", Key: GuardByPhone, Limiter: hard, CaptchaAfter: 5}},
+				}, svc
+			},
+			biz:     "login",
+			ticket:  "ticket",
+			wantErr: ErrCaptchaRequired,
+		},
+		{
+			name: "限流器出错",
+			mock: func(ctrl *gomock.Controller) (map[string][]CodeGuardRule, captcha.Service) {
+				hard := limiter_mocksvc.NewMockLimiter(ctrl)
+				hard.EXPECT().Peek(gomock.Any(), gomock.Any()).Return(limiter.Result{}, errors.New("redis err"))
+				return map[string][]CodeGuardRule{
+					"login": {{Name: "global", Key: GuardGlobal, Limiter: hard}},
+				}, captcha_mocksvc.NewMockService(ctrl)
+			},
+			biz:     "login",
+			wantErr: errors.New("redis err"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			guard := NewCodeGuard(tc.mock(ctrl))
+			err := guard.Check(context.Background(), tc.biz, "15212345678", client, tc.ticket)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_codeGuard_Reset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	byPhone := limiter_mocksvc.NewMockLimiter(ctrl)
+	byPhone.EXPECT().Reset(gomock.Any(), "sms:guard:phone:login:15212345678").Return(nil)
+	// 按 IP、设备和全局的计数不动
+	others := limiter_mocksvc.NewMockLimiter(ctrl)
+	guard := NewCodeGuard(map[string][]CodeGuardRule{
+		CodeGuardDefaultBiz: {
+			{Name: "phone", Key: GuardByPhone, Limiter: byPhone},
+			{Name: "ip", Key: GuardByIP, Limiter: others},
+			{Name: "device", Key: GuardByDevice, Limiter: others},
+			{Name: "global", Key: GuardGlobal, Limiter: others},
+		},
+	}, nil)
+	assert.NoError(t, guard.Reset(context.Background(), "login", "15212345678"))
+}
+
+func Test_codeGuard_Record(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	byPhone := limiter_mocksvc.NewMockLimiter(ctrl)
+	byPhone.EXPECT().Limit(gomock.Any(), "sms:guard:phone:login:15212345678").
+		Return(limiter.Result{Limit: 10, Remaining: 3}, nil)
+	byIP := limiter_mocksvc.NewMockLimiter(ctrl)
+	// 已经发出去了，超出限制也不算错误
+	byIP.EXPECT().Limit(gomock.Any(), "sms:guard:ip:login:127.0.0.1").
+		Return(limiter.Result{Limited: true}, nil)
+	// 没有设备指纹，不计数
+	byDevice := limiter_mocksvc.NewMockLimiter(ctrl)
+	guard := NewCodeGuard(map[string][]CodeGuardRule{
+		"login": {
+			{Name: "phone", Key: GuardByPhone, Limiter: byPhone},
+			{Name: "ip", Key: GuardByIP, Limiter: byIP},
+			{Name: "device", Key: GuardByDevice, Limiter: byDevice},
+		},
+	}, nil)
+	err := guard.Record(context.Background(), "login", "15212345678", domain.ClientInfo{IP: "127.0.0.1"})
+	assert.NoError(t, err)
+}
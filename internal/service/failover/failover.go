@@ -3,19 +3,21 @@ package failover
 import (
 	"context"
 	"errors"
-	"log"
 	"sync/atomic"
 	"webook/internal/service/sms"
+	"webook/pkg/logger"
 )
 
 type FailOverSMSService struct {
 	svcs []sms.Service
 	idx  uint64
+	l    logger.Logger
 }
 
-func NewFailOverSMSService(svcs []sms.Service) *FailOverSMSService {
+func NewFailOverSMSService(svcs []sms.Service, l logger.Logger) *FailOverSMSService {
 	return &FailOverSMSService{
 		svcs: svcs,
+		l:    l,
 	}
 }
 
@@ -43,7 +45,7 @@ func (f *FailOverSMSService) SendV1(ctx context.Context, tplID string, args []st
 		case context.Canceled, context.DeadlineExceeded:
 			return err
 		}
-		log.Println(err)
+		f.l.Warn(ctx, "短信服务商发送失败，尝试下一个", logger.Error(err))
 	}
 	return errors.New("所有服务商都发送失败")
 }
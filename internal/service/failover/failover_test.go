@@ -8,6 +8,7 @@ import (
 	"testing"
 	"webook/internal/service/sms"
 	"webook/internal/service/sms/sms_mocksvc"
+	"webook/pkg/logger"
 )
 
 func TestFailOverSMSService_Send(t *testing.T) {
@@ -69,7 +70,7 @@ func TestFailOverSMSService_Send(t *testing.T) {
 			defer ctrl.Finish()
 			sms_arr := tc.mock(ctrl)
 
-			svc := NewFailOverSMSService(sms_arr)
+			svc := NewFailOverSMSService(sms_arr, logger.NewNopLogger())
 			err := svc.Send(tc.ctx, tc.tplID, tc.args, tc.numbers...)
 			assert.Equal(t, tc.wantErr, err)
 		})
@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/pkg/cryptox"
+	"webook/pkg/logger"
+	"webook/pkg/totp"
+)
+
+var (
+	ErrTOTPNotEnabled     = errors.New("没有开启二次验证")
+	ErrTOTPAlreadyEnabled = errors.New("已经开启了二次验证")
+	ErrMFACodeInvalid     = errors.New("二次验证码错误")
+	// ErrMFATicketInvalid 票据不存在、过期或者尝试次数太多，需要重新用密码登录
+	ErrMFATicketInvalid = errors.New("二次验证票据无效")
+	// ErrMFALocked 二次验证失败次数太多，冷却时间内不再校验验证码
+	ErrMFALocked = errors.New("二次验证失败次数太多")
+	// ErrMFALockTriggered 这一次失败触发了锁定，调用方记录安全事件，errors.Is(err, ErrMFALocked) 也成立
+	ErrMFALockTriggered = fmt.Errorf("%w，触发锁定", ErrMFALocked)
+)
+
+const (
+	// recoveryCodeCount 开启二次验证的时候生成的恢复码数量
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet 去掉了容易看错的 0、1、i、l、o
+	recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+	// totpSkew 允许前后一个周期的时钟误差
+	totpSkew = 1
+)
+
+type MFAService interface {
+	Enabled(ctx context.Context, uid int64) (bool, error)
+	// Enroll 生成新的密钥，account 显示在身份验证器 App 里面，一般是邮箱
+	// 需要调用 Confirm 确认之后才会开启
+	Enroll(ctx context.Context, uid int64, account string) (domain.TOTPEnrollment, error)
+	// Confirm 用第一个验证码确认绑定，返回恢复码明文，只会返回这一次
+	Confirm(ctx context.Context, uid int64, code string) ([]string, error)
+	// Verify 校验 TOTP 验证码或者恢复码
+	// 不管从哪个接口校验，失败次数太多之后都会锁定一段时间，返回 ErrMFALocked
+	Verify(ctx context.Context, uid int64, code string) error
+	// Disable 关闭二次验证，需要一个有效的验证码
+	Disable(ctx context.Context, uid int64, code string) error
+	// CreateTicket 密码校验通过之后，生成二次验证的票据
+	CreateTicket(ctx context.Context, uid int64) (string, error)
+	// VerifyTicket 校验票据和验证码，通过之后票据作废，返回用户 ID
+	// 验证码错误的时候也返回用户 ID，方便记录安全事件
+	VerifyTicket(ctx context.Context, ticket, code string) (int64, error)
+}
+
+type mfaService struct {
+	repo   repository.MFARepository
+	cipher *cryptox.Cipher
+	issuer string
+	l      logger.Logger
+	now    func() time.Time
+}
+
+func NewMFAService(repo repository.MFARepository, cipher *cryptox.Cipher,
+	issuer string, l logger.Logger) MFAService {
+	return &mfaService{
+		repo:   repo,
+		cipher: cipher,
+		issuer: issuer,
+		l:      l,
+		now:    time.Now,
+	}
+}
+
+func (svc *mfaService) Enabled(ctx context.Context, uid int64) (bool, error) {
+	ctx, span := tracer.Start(ctx, "MFAService.Enabled")
+	defer span.End()
+	t, err := svc.repo.FindTOTP(ctx, uid)
+	if err == repository.ErrTOTPNotFound {
+		return false, nil
+	}
+	return t.Enabled, err
+}
+
+func (svc *mfaService) Enroll(ctx context.Context, uid int64, account string) (domain.TOTPEnrollment, error) {
+	ctx, span := tracer.Start(ctx, "MFAService.Enroll")
+	defer span.End()
+	enabled, err := svc.Enabled(ctx, uid)
+	if err != nil {
+		return domain.TOTPEnrollment{}, err
+	}
+	// 要换密钥的话先关闭，避免不小心覆盖掉正在用的密钥
+	if enabled {
+		return domain.TOTPEnrollment{}, ErrTOTPAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return domain.TOTPEnrollment{}, err
+	}
+	encrypted, err := svc.cipher.Encrypt(secret)
+	if err != nil {
+		return domain.TOTPEnrollment{}, err
+	}
+	if err = svc.repo.SaveTOTP(ctx, uid, encrypted); err != nil {
+		return domain.TOTPEnrollment{}, err
+	}
+	return domain.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(svc.issuer, account, secret),
+	}, nil
+}
+
+func (svc *mfaService) Confirm(ctx context.Context, uid int64, code string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "MFAService.Confirm")
+	defer span.End()
+	t, err := svc.repo.FindTOTP(ctx, uid)
+	if err == repository.ErrTOTPNotFound {
+		return nil, ErrTOTPNotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Enabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if err = svc.verifyTOTP(ctx, t, code); err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		c, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, c)
+		hashes = append(hashes, hashRecoveryCode(c))
+	}
+	if err = svc.repo.EnableTOTP(ctx, uid, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (svc *mfaService) Verify(ctx context.Context, uid int64, code string) error {
+	ctx, span := tracer.Start(ctx, "MFAService.Verify")
+	defer span.End()
+	t, err := svc.repo.FindTOTP(ctx, uid)
+	if err == repository.ErrTOTPNotFound {
+		return ErrTOTPNotEnabled
+	}
+	if err != nil {
+		return err
+	}
+	if !t.Enabled {
+		return ErrTOTPNotEnabled
+	}
+	// 锁定状态查不到的时候不校验，不然 Redis 出问题的时候可以无限次猜
+	locked, err := svc.repo.Locked(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return ErrMFALocked
+	}
+	err = svc.verifyCode(ctx, t, strings.TrimSpace(code))
+	switch err {
+	case nil:
+		if err = svc.repo.ResetFailure(ctx, uid); err != nil {
+			// 窗口过了失败次数自己会清掉
+			svc.l.Warn(ctx, "清空二次验证失败次数失败", logger.Int64("uid", uid), logger.Error(err))
+		}
+		return nil
+	case ErrMFACodeInvalid:
+		return svc.incrFailure(ctx, uid)
+	default:
+		return err
+	}
+}
+
+// verifyCode 6 位的是 TOTP 验证码，其他的当成恢复码
+func (svc *mfaService) verifyCode(ctx context.Context, t domain.TOTP, code string) error {
+	if len(code) == totp.Digits {
+		return svc.verifyTOTP(ctx, t, code)
+	}
+	ok, err := svc.repo.UseRecoveryCode(ctx, t.UserID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrMFACodeInvalid
+	}
+	svc.l.Info(ctx, "使用恢复码通过二次验证", logger.Int64("uid", t.UserID))
+	return nil
+}
+
+// incrFailure 验证码错误的时候调用，这一次触发了锁定的时候返回 ErrMFALockTriggered
+func (svc *mfaService) incrFailure(ctx context.Context, uid int64) error {
+	triggered, err := svc.repo.IncrFailure(ctx, uid)
+	if err != nil {
+		svc.l.Error(ctx, "记录二次验证失败次数失败", logger.Int64("uid", uid), logger.Error(err))
+		return ErrMFACodeInvalid
+	}
+	if triggered {
+		svc.l.Warn(ctx, "二次验证失败次数太多，锁定", logger.Int64("uid", uid))
+		return ErrMFALockTriggered
+	}
+	return ErrMFACodeInvalid
+}
+
+// verifyTOTP 校验通过之后记录周期，同一个验证码不能用两次
+func (svc *mfaService) verifyTOTP(ctx context.Context, t domain.TOTP, code string) error {
+	secret, err := svc.cipher.Decrypt(t.Secret)
+	if err != nil {
+		return err
+	}
+	step, ok := totp.Validate(secret, code, svc.now(), totpSkew)
+	if !ok || step <= t.LastStep {
+		return ErrMFACodeInvalid
+	}
+	// 并发请求用同一个验证码的时候，只有一个能更新成功
+	ok, err = svc.repo.UseStep(ctx, t.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrMFACodeInvalid
+	}
+	return nil
+}
+
+func (svc *mfaService) Disable(ctx context.Context, uid int64, code string) error {
+	ctx, span := tracer.Start(ctx, "MFAService.Disable")
+	defer span.End()
+	if err := svc.Verify(ctx, uid, code); err != nil {
+		return err
+	}
+	return svc.repo.DeleteTOTP(ctx, uid)
+}
+
+func (svc *mfaService) CreateTicket(ctx context.Context, uid int64) (string, error) {
+	ctx, span := tracer.Start(ctx, "MFAService.CreateTicket")
+	defer span.End()
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(buf)
+	return ticket, svc.repo.SetTicket(ctx, ticket, uid)
+}
+
+func (svc *mfaService) VerifyTicket(ctx context.Context, ticket, code string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "MFAService.VerifyTicket")
+	defer span.End()
+	uid, err := svc.repo.VerifyTicket(ctx, ticket)
+	switch err {
+	case nil:
+	case repository.ErrMFATicketNotFound, repository.ErrMFATicketTooManyTime:
+		return 0, ErrMFATicketInvalid
+	default:
+		return 0, err
+	}
+	if err = svc.Verify(ctx, uid, code); err != nil {
+		return uid, err
+	}
+	if err = svc.repo.DelTicket(ctx, ticket); err != nil {
+		// 票据过一会儿就过期了，而且验证码不能重复使用
+		svc.l.Warn(ctx, "删除二次验证票据失败", logger.Int64("uid", uid), logger.Error(err))
+	}
+	return uid, nil
+}
+
+// generateRecoveryCode 格式是 xxxxx-xxxxx，方便抄写
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for i, b := range buf {
+		if i == 5 {
+			sb.WriteByte('-')
+		}
+		sb.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+	}
+	return sb.String(), nil
+}
+
+// hashRecoveryCode 恢复码是随机生成的，熵足够，用 SHA-256 就行
+// 忽略大小写和分隔符，用户抄写的时候容易写错
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	mocksvc "webook/internal/repository/mock"
+	"webook/pkg/cryptox"
+	"webook/pkg/logger"
+	"webook/pkg/totp"
+)
+
+func Test_mfaService_Verify(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	cipher, err := cryptox.NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	encrypted, err := cipher.Encrypt(secret)
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	step := totp.Step(now)
+	code, err := totp.Code(secret, step)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.MFARepository
+		code string
+
+		wantErr error
+	}{
+		{
+			name: "验证码正确",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := mocksvc.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(1)).
+					Return(domain.TOTP{UserID: 1, Secret: encrypted, Enabled: true}, nil)
+				repo.EXPECT().Locked(gomock.Any(), int64(1)).Return(false, nil)
+				repo.EXPECT().UseStep(gomock.Any(), int64(1), step).Return(true, nil)
+				repo.EXPECT().ResetFailure(gomock.Any(), int64(1)).Return(nil)
+				return repo
+			},
+			code: code,
+		},
+		{
+			name: "验证码已经用过",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := mocksvc.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(1)).
+					Return(domain.TOTP{UserID: 1, Secret: encrypted, Enabled: true, LastStep: step}, nil)
+				repo.EXPECT().Locked(gomock.Any(), int64(1)).Return(false, nil)
+				repo.EXPECT().IncrFailure(gomock.Any(), int64(1)).Return(false, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrMFACodeInvalid,
+		},
+		{
+			name: "并发使用同一个验证码",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := mocksvc.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(1)).
+					Return(domain.TOTP{UserID: 1, Secret: encrypted, Enabled: true}, nil)
+				repo.EXPECT().Locked(gomock.Any(), int64(1)).Return(false, nil)
+				repo.EXPECT().UseStep(gomock.Any(), int64(1), step).Return(false, nil)
+				repo.EXPECT().IncrFailure(gomock.Any(), int64(1)).Return(false, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrMFACodeInvalid,
+		},
+		{
+			name: "使用恢复码，忽略大小写和分隔符",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := mocksvc.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(1)).
+					Return(domain.TOTP{UserID: 1, Secret: encrypted, Enabled: true}, nil)
+				repo.EXPECT().Locked(gomock.Any(), int64(1)).Return(false, nil)
+				repo.EXPECT().UseRecoveryCode(gomock.Any(), int64(1), hashRecoveryCode("abcde-fghjk")).
+					Return(true, nil)
+				repo.EXPECT().ResetFailure(gomock.Any(), int64(1)).Return(nil)
+				return repo
+			},
+			code: "ABCDE FGHJK",
+		},
+		{
+			name: "恢复码已经用过",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := mocksvc.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(1)).
+					Return(domain.TOTP{UserID: 1, Secret: encrypted, Enabled: true}, nil)
+				repo.EXPECT().Locked(gomock.Any(), int64(1)).Return(false, nil)
+				repo.EXPECT().UseRecoveryCode(gomock.Any(), int64(1), gomock.Any()).Return(false, nil)
+				repo.EXPECT().IncrFailure(gomock.Any(), int64(1)).Return(false, nil)
+				return repo
+			},
+			code:    "abcde-fghjk",
+			wantErr: ErrMFACodeInvalid,
+		},
+		{
+			name: "失败次数太多，已经锁定",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := mocksvc.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(1)).
+					Return(domain.TOTP{UserID: 1, Secret: encrypted, Enabled: true}, nil)
+				repo.EXPECT().Locked(gomock.Any(), int64(1)).Return(true, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrMFALocked,
+		},
+		{
+			name: "这一次失败触发锁定",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := mocksvc.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(1)).
+					Return(domain.TOTP{UserID: 1, Secret: encrypted, Enabled: true}, nil)
+				repo.EXPECT().Locked(gomock.Any(), int64(1)).Return(false, nil)
+				repo.EXPECT().UseRecoveryCode(gomock.Any(), int64(1), gomock.Any()).Return(false, nil)
+				repo.EXPECT().IncrFailure(gomock.Any(), int64(1)).Return(true, nil)
+				return repo
+			},
+			code:    "abcde-fghjk",
+			wantErr: ErrMFALockTriggered,
+		},
+		{
+			name: "查不到锁定状态",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := mocksvc.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(1)).
+					Return(domain.TOTP{UserID: 1, Secret: encrypted, Enabled: true}, nil)
+				repo.EXPECT().Locked(gomock.Any(), int64(1)).Return(false, errors.New("redis 错误"))
+				return repo
+			},
+			code:    code,
+			wantErr: errors.New("redis 错误"),
+		},
+		{
+			name: "还没有确认绑定",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := mocksvc.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(1)).
+					Return(domain.TOTP{UserID: 1, Secret: encrypted}, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrTOTPNotEnabled,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			svc := NewMFAService(tc.mock(ctrl), cipher, "webook", logger.NewNopLogger()).(*mfaService)
+			svc.now = func() time.Time { return now }
+			err := svc.Verify(context.Background(), 1, tc.code)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_mfaService_Confirm(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cipher, err := cryptox.NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	repo := mocksvc.NewMockMFARepository(ctrl)
+	svc := NewMFAService(repo, cipher, "webook", logger.NewNopLogger()).(*mfaService)
+
+	var saved string
+	repo.EXPECT().FindTOTP(gomock.Any(), int64(1)).Return(domain.TOTP{}, repository.ErrTOTPNotFound)
+	repo.EXPECT().SaveTOTP(gomock.Any(), int64(1), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, uid int64, secret string) error {
+			saved = secret
+			return nil
+		})
+	enrollment, err := svc.Enroll(context.Background(), 1, "tom@qq.com")
+	require.NoError(t, err)
+	// 存储的是密文
+	assert.NotEqual(t, enrollment.Secret, saved)
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+
+	code, err := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	repo.EXPECT().FindTOTP(gomock.Any(), int64(1)).Return(domain.TOTP{UserID: 1, Secret: saved}, nil)
+	repo.EXPECT().UseStep(gomock.Any(), int64(1), gomock.Any()).Return(true, nil)
+	var hashes []string
+	repo.EXPECT().EnableTOTP(gomock.Any(), int64(1), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, uid int64, codeHashes []string) error {
+			hashes = codeHashes
+			return nil
+		})
+	codes, err := svc.Confirm(context.Background(), 1, code)
+	require.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	// 只存哈希
+	for i, c := range codes {
+		assert.Equal(t, hashRecoveryCode(c), hashes[i])
+		assert.NotEqual(t, c, hashes[i])
+	}
+}
@@ -0,0 +1,143 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/service/admin.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/service/admin.go -package=mocksvc -destination=./internal/service/mock/admin.mock.go
+//
+
+// Package mocksvc is a generated GoMock package.
+package mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAdminService is a mock of AdminService interface.
+type MockAdminService struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdminServiceMockRecorder
+}
+
+// MockAdminServiceMockRecorder is the mock recorder for MockAdminService.
+type MockAdminServiceMockRecorder struct {
+	mock *MockAdminService
+}
+
+// NewMockAdminService creates a new mock instance.
+func NewMockAdminService(ctrl *gomock.Controller) *MockAdminService {
+	mock := &MockAdminService{ctrl: ctrl}
+	mock.recorder = &MockAdminServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdminService) EXPECT() *MockAdminServiceMockRecorder {
+	return m.recorder
+}
+
+// Ban mocks base method.
+func (m *MockAdminService) Ban(ctx context.Context, op domain.Operator, uid int64, until time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ban", ctx, op, uid, until)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ban indicates an expected call of Ban.
+func (mr *MockAdminServiceMockRecorder) Ban(ctx, op, uid, until any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ban", reflect.TypeOf((*MockAdminService)(nil).Ban), ctx, op, uid, until)
+}
+
+// ForceLogout mocks base method.
+func (m *MockAdminService) ForceLogout(ctx context.Context, op domain.Operator, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForceLogout", ctx, op, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForceLogout indicates an expected call of ForceLogout.
+func (mr *MockAdminServiceMockRecorder) ForceLogout(ctx, op, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForceLogout", reflect.TypeOf((*MockAdminService)(nil).ForceLogout), ctx, op, uid)
+}
+
+// ResetSMSLimit mocks base method.
+func (m *MockAdminService) ResetSMSLimit(ctx context.Context, op domain.Operator, biz, phone string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetSMSLimit", ctx, op, biz, phone)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetSMSLimit indicates an expected call of ResetSMSLimit.
+func (mr *MockAdminServiceMockRecorder) ResetSMSLimit(ctx, op, biz, phone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetSMSLimit", reflect.TypeOf((*MockAdminService)(nil).ResetSMSLimit), ctx, op, biz, phone)
+}
+
+// SearchUsers mocks base method.
+func (m *MockAdminService) SearchUsers(ctx context.Context, op domain.Operator, keyword string) ([]domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchUsers", ctx, op, keyword)
+	ret0, _ := ret[0].([]domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchUsers indicates an expected call of SearchUsers.
+func (mr *MockAdminServiceMockRecorder) SearchUsers(ctx, op, keyword any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsers", reflect.TypeOf((*MockAdminService)(nil).SearchUsers), ctx, op, keyword)
+}
+
+// SetRoles mocks base method.
+func (m *MockAdminService) SetRoles(ctx context.Context, op domain.Operator, uid int64, roles []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRoles", ctx, op, uid, roles)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRoles indicates an expected call of SetRoles.
+func (mr *MockAdminServiceMockRecorder) SetRoles(ctx, op, uid, roles any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRoles", reflect.TypeOf((*MockAdminService)(nil).SetRoles), ctx, op, uid, roles)
+}
+
+// SetStatus mocks base method.
+func (m *MockAdminService) SetStatus(ctx context.Context, op domain.Operator, uid int64, status domain.UserStatus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetStatus", ctx, op, uid, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetStatus indicates an expected call of SetStatus.
+func (mr *MockAdminServiceMockRecorder) SetStatus(ctx, op, uid, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStatus", reflect.TypeOf((*MockAdminService)(nil).SetStatus), ctx, op, uid, status)
+}
+
+// UserDetail mocks base method.
+func (m *MockAdminService) UserDetail(ctx context.Context, op domain.Operator, uid int64) (domain.User, []string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserDetail", ctx, op, uid)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].([]string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UserDetail indicates an expected call of UserDetail.
+func (mr *MockAdminServiceMockRecorder) UserDetail(ctx, op, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserDetail", reflect.TypeOf((*MockAdminService)(nil).UserDetail), ctx, op, uid)
+}
@@ -0,0 +1,83 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/service/code_guard.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/service/code_guard.go -package=mocksvc -destination=./internal/service/mock/code_guard.mock.go
+//
+
+// Package mocksvc is a generated GoMock package.
+package mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCodeGuard is a mock of CodeGuard interface.
+type MockCodeGuard struct {
+	ctrl     *gomock.Controller
+	recorder *MockCodeGuardMockRecorder
+}
+
+// MockCodeGuardMockRecorder is the mock recorder for MockCodeGuard.
+type MockCodeGuardMockRecorder struct {
+	mock *MockCodeGuard
+}
+
+// NewMockCodeGuard creates a new mock instance.
+func NewMockCodeGuard(ctrl *gomock.Controller) *MockCodeGuard {
+	mock := &MockCodeGuard{ctrl: ctrl}
+	mock.recorder = &MockCodeGuardMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCodeGuard) EXPECT() *MockCodeGuardMockRecorder {
+	return m.recorder
+}
+
+// Check mocks base method.
+func (m *MockCodeGuard) Check(ctx context.Context, biz, phone string, client domain.ClientInfo, captchaTicket string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", ctx, biz, phone, client, captchaTicket)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockCodeGuardMockRecorder) Check(ctx, biz, phone, client, captchaTicket any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockCodeGuard)(nil).Check), ctx, biz, phone, client, captchaTicket)
+}
+
+// Record mocks base method.
+func (m *MockCodeGuard) Record(ctx context.Context, biz, phone string, client domain.ClientInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Record", ctx, biz, phone, client)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Record indicates an expected call of Record.
+func (mr *MockCodeGuardMockRecorder) Record(ctx, biz, phone, client any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Record", reflect.TypeOf((*MockCodeGuard)(nil).Record), ctx, biz, phone, client)
+}
+
+// Reset mocks base method.
+func (m *MockCodeGuard) Reset(ctx context.Context, biz, phone string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", ctx, biz, phone)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockCodeGuardMockRecorder) Reset(ctx, biz, phone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockCodeGuard)(nil).Reset), ctx, biz, phone)
+}
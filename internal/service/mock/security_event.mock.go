@@ -0,0 +1,68 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/service/security_event.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/service/security_event.go -package=mocksvc -destination=./internal/service/mock/security_event.mock.go
+//
+
+// Package mocksvc is a generated GoMock package.
+package mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockSecurityEventService is a mock of SecurityEventService interface.
+type MockSecurityEventService struct {
+	ctrl     *gomock.Controller
+	recorder *MockSecurityEventServiceMockRecorder
+}
+
+// MockSecurityEventServiceMockRecorder is the mock recorder for MockSecurityEventService.
+type MockSecurityEventServiceMockRecorder struct {
+	mock *MockSecurityEventService
+}
+
+// NewMockSecurityEventService creates a new mock instance.
+func NewMockSecurityEventService(ctrl *gomock.Controller) *MockSecurityEventService {
+	mock := &MockSecurityEventService{ctrl: ctrl}
+	mock.recorder = &MockSecurityEventServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSecurityEventService) EXPECT() *MockSecurityEventServiceMockRecorder {
+	return m.recorder
+}
+
+// List mocks base method.
+func (m *MockSecurityEventService) List(ctx context.Context, uid, cursor int64, limit int) ([]domain.SecurityEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, uid, cursor, limit)
+	ret0, _ := ret[0].([]domain.SecurityEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockSecurityEventServiceMockRecorder) List(ctx, uid, cursor, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSecurityEventService)(nil).List), ctx, uid, cursor, limit)
+}
+
+// Record mocks base method.
+func (m *MockSecurityEventService) Record(ctx context.Context, evt domain.SecurityEvent) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Record", ctx, evt)
+}
+
+// Record indicates an expected call of Record.
+func (mr *MockSecurityEventServiceMockRecorder) Record(ctx, evt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Record", reflect.TypeOf((*MockSecurityEventService)(nil).Record), ctx, evt)
+}
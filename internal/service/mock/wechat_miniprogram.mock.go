@@ -0,0 +1,71 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/service/wechat_miniprogram.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/service/wechat_miniprogram.go -package=mocksvc -destination=./internal/service/mock/wechat_miniprogram.mock.go
+//
+
+// Package mocksvc is a generated GoMock package.
+package mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockWechatMiniProgramService is a mock of WechatMiniProgramService interface.
+type MockWechatMiniProgramService struct {
+	ctrl     *gomock.Controller
+	recorder *MockWechatMiniProgramServiceMockRecorder
+}
+
+// MockWechatMiniProgramServiceMockRecorder is the mock recorder for MockWechatMiniProgramService.
+type MockWechatMiniProgramServiceMockRecorder struct {
+	mock *MockWechatMiniProgramService
+}
+
+// NewMockWechatMiniProgramService creates a new mock instance.
+func NewMockWechatMiniProgramService(ctrl *gomock.Controller) *MockWechatMiniProgramService {
+	mock := &MockWechatMiniProgramService{ctrl: ctrl}
+	mock.recorder = &MockWechatMiniProgramServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWechatMiniProgramService) EXPECT() *MockWechatMiniProgramServiceMockRecorder {
+	return m.recorder
+}
+
+// DecryptPhone mocks base method.
+func (m *MockWechatMiniProgramService) DecryptPhone(ctx context.Context, uid int64, data domain.WechatEncryptedData) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecryptPhone", ctx, uid, data)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DecryptPhone indicates an expected call of DecryptPhone.
+func (mr *MockWechatMiniProgramServiceMockRecorder) DecryptPhone(ctx, uid, data any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecryptPhone", reflect.TypeOf((*MockWechatMiniProgramService)(nil).DecryptPhone), ctx, uid, data)
+}
+
+// Login mocks base method.
+func (m *MockWechatMiniProgramService) Login(ctx context.Context, code string, userInfo domain.WechatEncryptedData) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Login", ctx, code, userInfo)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Login indicates an expected call of Login.
+func (mr *MockWechatMiniProgramServiceMockRecorder) Login(ctx, code, userInfo any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockWechatMiniProgramService)(nil).Login), ctx, code, userInfo)
+}
@@ -0,0 +1,70 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/service/wechat_token.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/service/wechat_token.go -package=mocksvc -destination=./internal/service/mock/wechat_token.mock.go
+//
+
+// Package mocksvc is a generated GoMock package.
+package mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	oauth2 "webook/internal/service/oauth2"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockWechatTokenService is a mock of WechatTokenService interface.
+type MockWechatTokenService struct {
+	ctrl     *gomock.Controller
+	recorder *MockWechatTokenServiceMockRecorder
+}
+
+// MockWechatTokenServiceMockRecorder is the mock recorder for MockWechatTokenService.
+type MockWechatTokenServiceMockRecorder struct {
+	mock *MockWechatTokenService
+}
+
+// NewMockWechatTokenService creates a new mock instance.
+func NewMockWechatTokenService(ctrl *gomock.Controller) *MockWechatTokenService {
+	mock := &MockWechatTokenService{ctrl: ctrl}
+	mock.recorder = &MockWechatTokenServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWechatTokenService) EXPECT() *MockWechatTokenServiceMockRecorder {
+	return m.recorder
+}
+
+// AccessToken mocks base method.
+func (m *MockWechatTokenService) AccessToken(ctx context.Context, uid int64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AccessToken", ctx, uid)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AccessToken indicates an expected call of AccessToken.
+func (mr *MockWechatTokenServiceMockRecorder) AccessToken(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccessToken", reflect.TypeOf((*MockWechatTokenService)(nil).AccessToken), ctx, uid)
+}
+
+// Save mocks base method.
+func (m *MockWechatTokenService) Save(ctx context.Context, uid int64, openID string, token oauth2.Token) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", ctx, uid, openID, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockWechatTokenServiceMockRecorder) Save(ctx, uid, openID, token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockWechatTokenService)(nil).Save), ctx, uid, openID, token)
+}
@@ -0,0 +1,25 @@
+package lognotify
+
+import (
+	"context"
+	"webook/internal/domain"
+	"webook/pkg/logger"
+)
+
+// Service 没有配置通知渠道的时候用，只打印日志
+type Service struct {
+	l logger.Logger
+}
+
+func NewService(l logger.Logger) *Service {
+	return &Service{
+		l: l,
+	}
+}
+
+func (s *Service) NewDeviceLogin(ctx context.Context, evt domain.SecurityEvent) error {
+	s.l.Info(ctx, "新设备登录", logger.Int64("uid", evt.UserID), logger.String("method", evt.Method),
+		logger.String("ip", evt.Client.IP), logger.String("country", evt.Geo.Country),
+		logger.String("city", evt.Geo.City))
+	return nil
+}
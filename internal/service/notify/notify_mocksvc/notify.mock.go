@@ -0,0 +1,55 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/service/notify/types.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/service/notify/types.go -package=notify_mocksvc -destination=./internal/service/notify/notify_mocksvc/notify.mock.go
+//
+
+// Package notify_mocksvc is a generated GoMock package.
+package notify_mocksvc
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockService is a mock of Service interface.
+type MockService struct {
+	ctrl     *gomock.Controller
+	recorder *MockServiceMockRecorder
+}
+
+// MockServiceMockRecorder is the mock recorder for MockService.
+type MockServiceMockRecorder struct {
+	mock *MockService
+}
+
+// NewMockService creates a new mock instance.
+func NewMockService(ctrl *gomock.Controller) *MockService {
+	mock := &MockService{ctrl: ctrl}
+	mock.recorder = &MockServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockService) EXPECT() *MockServiceMockRecorder {
+	return m.recorder
+}
+
+// NewDeviceLogin mocks base method.
+func (m *MockService) NewDeviceLogin(ctx context.Context, evt domain.SecurityEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewDeviceLogin", ctx, evt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NewDeviceLogin indicates an expected call of NewDeviceLogin.
+func (mr *MockServiceMockRecorder) NewDeviceLogin(ctx, evt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewDeviceLogin", reflect.TypeOf((*MockService)(nil).NewDeviceLogin), ctx, evt)
+}
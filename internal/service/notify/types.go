@@ -0,0 +1,12 @@
+package notify
+
+import (
+	"context"
+	"webook/internal/domain"
+)
+
+// Service 给用户发安全提醒的抽象，实现可以是短信、邮件、站内信或者转给其他系统
+type Service interface {
+	// NewDeviceLogin 用户在没有用过的设备上登录成功
+	NewDeviceLogin(ctx context.Context, evt domain.SecurityEvent) error
+}
@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"webook/internal/domain"
+	"webook/pkg/httpx"
+)
+
+// SignatureHeader 请求体的 HMAC-SHA256，接收方用同一个密钥校验，格式是 sha256=<hex>
+const SignatureHeader = "X-Webook-Signature"
+
+// Event 发给接收方的请求体
+type Event struct {
+	Type      string `json:"type"`
+	UserID    int64  `json:"userId"`
+	Method    string `json:"method"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"userAgent"`
+	Country   string `json:"country"`
+	Region    string `json:"region"`
+	City      string `json:"city"`
+	Time      int64  `json:"time"`
+}
+
+// Service 把安全提醒 POST 到配置的地址，由接收方决定怎么通知用户
+type Service struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewService secret 为空的时候不签名
+func NewService(url, secret string) *Service {
+	return &Service{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{
+			Transport: httpx.NewTracingTransport(http.DefaultTransport),
+			Timeout:   time.Second * 5,
+		},
+	}
+}
+
+func (s *Service) NewDeviceLogin(ctx context.Context, evt domain.SecurityEvent) error {
+	return s.post(ctx, Event{
+		Type:      "login.new_device",
+		UserID:    evt.UserID,
+		Method:    evt.Method,
+		IP:        evt.Client.IP,
+		UserAgent: evt.Client.UserAgent,
+		Country:   evt.Geo.Country,
+		Region:    evt.Geo.Region,
+		City:      evt.Geo.City,
+		Time:      evt.CreatedAt,
+	})
+}
+
+func (s *Service) post(ctx context.Context, evt Event) error {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(s.secret, body))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook 返回 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign 接收方校验签名的时候也可以用
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
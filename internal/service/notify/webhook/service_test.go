@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/internal/domain"
+)
+
+func TestService_NewDeviceLogin(t *testing.T) {
+	testCases := []struct {
+		name   string
+		secret string
+		status int
+
+		wantErr bool
+	}{
+		{
+			name:   "带签名",
+			secret: "webhook-secret",
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "不签名",
+			status: http.StatusOK,
+		},
+		{
+			name:    "接收方出错",
+			status:  http.StatusInternalServerError,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				got       Event
+				signature string
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.NoError(t, json.Unmarshal(body, &got))
+				signature = r.Header.Get(SignatureHeader)
+				if tc.secret != "" {
+					assert.Equal(t, "sha256="+Sign([]byte(tc.secret), body), signature)
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			err := NewService(server.URL, tc.secret).NewDeviceLogin(context.Background(), domain.SecurityEvent{
+				UserID:    1,
+				Method:    domain.LoginMethodPassword,
+				Client:    domain.ClientInfo{IP: "1.0.1.1", UserAgent: "Mozilla/5.0"},
+				Geo:       domain.GeoLocation{Country: "China", Region: "Fujian", City: "Fuzhou"},
+				CreatedAt: 1700000000000,
+			})
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, Event{
+				Type:      "login.new_device",
+				UserID:    1,
+				Method:    "password",
+				IP:        "1.0.1.1",
+				UserAgent: "Mozilla/5.0",
+				Country:   "China",
+				Region:    "Fujian",
+				City:      "Fuzhou",
+				Time:      1700000000000,
+			}, got)
+			if tc.secret == "" {
+				assert.Empty(t, signature)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/service/oauth2"
+	"webook/pkg/httpx"
+
+	xoauth2 "golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+const defaultAPIURL = "https://api.github.com"
+
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// AuthURL、TokenURL、APIURL 为空的时候用 GitHub 的地址，测试的时候指向本地的模拟服务
+	AuthURL  string
+	TokenURL string
+	APIURL   string
+}
+
+type provider struct {
+	oauth  xoauth2.Config
+	apiURL string
+	client *http.Client
+}
+
+func NewProvider(cfg Config) oauth2.Provider {
+	endpoint := endpoints.GitHub
+	if cfg.AuthURL != "" {
+		endpoint.AuthURL = cfg.AuthURL
+	}
+	if cfg.TokenURL != "" {
+		endpoint.TokenURL = cfg.TokenURL
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = defaultAPIURL
+	}
+	return &provider{
+		oauth: xoauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     endpoint,
+			// 没有公开邮箱的用户要通过 /user/emails 获取
+			Scopes: []string{"read:user", "user:email"},
+		},
+		apiURL: cfg.APIURL,
+		client: &http.Client{
+			Transport: httpx.NewTracingTransport(http.DefaultTransport),
+			Timeout:   time.Second * 10,
+		},
+	}
+}
+
+func (p *provider) Name() string {
+	return domain.ProviderGitHub
+}
+
+func (p *provider) AuthURL(ctx context.Context, state string, opts oauth2.AuthOptions) (string, error) {
+	return p.oauth.AuthCodeURL(state, xoauth2.S256ChallengeOption(opts.CodeVerifier)), nil
+}
+
+func (p *provider) Exchange(ctx context.Context, code string, opts oauth2.AuthOptions) (oauth2.Token, error) {
+	ctx = context.WithValue(ctx, xoauth2.HTTPClient, p.client)
+	token, err := p.oauth.Exchange(ctx, code, xoauth2.VerifierOption(opts.CodeVerifier))
+	if err != nil {
+		return oauth2.Token{}, err
+	}
+	return oauth2.Token{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		Expiry:       token.Expiry,
+	}, nil
+}
+
+type user struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type email struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func (p *provider) UserInfo(ctx context.Context, token oauth2.Token) (domain.Identity, error) {
+	var u user
+	if err := p.get(ctx, token, "/user", &u); err != nil {
+		return domain.Identity{}, err
+	}
+	if u.ID == 0 {
+		return domain.Identity{}, errors.New("GitHub 没有返回用户 ID")
+	}
+	nickname := u.Name
+	if nickname == "" {
+		nickname = u.Login
+	}
+	identity := domain.Identity{
+		Provider: domain.ProviderGitHub,
+		Subject:  strconv.FormatInt(u.ID, 10),
+		Nickname: nickname,
+		Avatar:   u.AvatarURL,
+	}
+	// /user 里面的是公开邮箱，不一定验证过
+	var emails []email
+	if err := p.get(ctx, token, "/user/emails", &emails); err != nil {
+		return domain.Identity{}, err
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			identity.Email = e.Email
+			identity.EmailVerified = true
+			break
+		}
+	}
+	return identity, nil
+}
+
+func (p *provider) get(ctx context.Context, token oauth2.Token, path string, val any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub %s 返回 HTTP 状态码：%d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(val)
+}
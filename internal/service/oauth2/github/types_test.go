@@ -0,0 +1,93 @@
+package github
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/service/oauth2"
+	"webook/internal/service/oauth2/oauth2test"
+)
+
+func TestProvider(t *testing.T) {
+	server := oauth2test.NewServer("webook", "webook-secret")
+	defer server.Close()
+	testCases := []struct {
+		name   string
+		user   oauth2test.User
+		secret string
+
+		wantErr      bool
+		wantIdentity domain.Identity
+	}{
+		{
+			name:   "登录成功",
+			user:   server.User,
+			secret: "webook-secret",
+			wantIdentity: domain.Identity{
+				Provider:      domain.ProviderGitHub,
+				Subject:       "1001",
+				Email:         "tom@example.com",
+				EmailVerified: true,
+				Nickname:      "Tom",
+				Avatar:        "https://example.com/tom.png",
+			},
+		},
+		{
+			name: "主邮箱没有验证",
+			user: oauth2test.User{
+				ID:    1002,
+				Login: "jerry",
+				Email: "jerry@example.com",
+			},
+			secret: "webook-secret",
+			// 没有名字用登录名
+			wantIdentity: domain.Identity{
+				Provider: domain.ProviderGitHub,
+				Subject:  "1002",
+				Nickname: "jerry",
+			},
+		},
+		{
+			name:    "client_secret 不对",
+			user:    server.User,
+			secret:  "wrong-secret",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server.User = tc.user
+			p := NewProvider(Config{
+				ClientID:     "webook",
+				ClientSecret: tc.secret,
+				RedirectURL:  "http://localhost:8080/oauth2/github/callback",
+				AuthURL:      server.URL + "/authorize",
+				TokenURL:     server.URL + "/token",
+				APIURL:       server.URL,
+			})
+			ctx := context.Background()
+			opts, err := oauth2.NewAuthOptions()
+			require.NoError(t, err)
+			authURL, err := p.AuthURL(ctx, "state-1", opts)
+			require.NoError(t, err)
+			u, err := url.Parse(authURL)
+			require.NoError(t, err)
+			assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+
+			code, _, err := server.Authorize(authURL)
+			require.NoError(t, err)
+			token, err := p.Exchange(ctx, code, opts)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			identity, err := p.UserInfo(ctx, token)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantIdentity, identity)
+		})
+	}
+}
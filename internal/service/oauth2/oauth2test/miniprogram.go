@@ -0,0 +1,160 @@
+package oauth2test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// MiniProgramServer 模拟微信小程序的 jscode2session，是 wechat.MiniProgramConfig 的 APIURL
+type MiniProgramServer struct {
+	*httptest.Server
+	AppID     string
+	AppSecret string
+	// User 修改之后对新的 Login 生效
+	User WechatUser
+	// Phone getPhoneNumber 返回的手机号
+	Phone string
+
+	mu    sync.Mutex
+	codes map[string]WechatUser
+	// sessionKeys openid 到 session_key，RotateSessionKey 之后旧的就不能用了
+	sessionKeys map[string][]byte
+}
+
+// NewMiniProgramServer 用完之后调用 Close
+func NewMiniProgramServer(appID, appSecret string) *MiniProgramServer {
+	s := &MiniProgramServer{
+		AppID:     appID,
+		AppSecret: appSecret,
+		User: WechatUser{
+			OpenID:     "mini-openid-1001",
+			UnionID:    "unionid-1001",
+			Nickname:   "微信用户",
+			HeadImgURL: "https://thirdwx.qlogo.cn/mmopen/1001/132",
+		},
+		Phone:       "13800138000",
+		codes:       map[string]WechatUser{},
+		sessionKeys: map[string][]byte{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sns/jscode2session", s.code2session)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Login 模拟小程序调用 wx.login，返回 code
+func (s *MiniProgramServer) Login() string {
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = s.User
+	s.mu.Unlock()
+	return code
+}
+
+// RotateSessionKey 模拟微信更新当前用户的 session_key
+func (s *MiniProgramServer) RotateSessionKey() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessionKeys, s.User.OpenID)
+}
+
+// EncryptedUserInfo 模拟 getUserProfile，用当前用户的 session_key 加密
+func (s *MiniProgramServer) EncryptedUserInfo() (rawData, signature, encryptedData, iv string, err error) {
+	key := s.sessionKey(s.User.OpenID)
+	raw, err := json.Marshal(map[string]any{
+		"nickName":  s.User.Nickname,
+		"avatarUrl": s.User.HeadImgURL,
+	})
+	if err != nil {
+		return "", "", "", "", err
+	}
+	sum := sha1.Sum(append(raw, base64.StdEncoding.EncodeToString(key)...))
+	encryptedData, iv, err = s.encrypt(key, map[string]any{
+		"openId":    s.User.OpenID,
+		"unionId":   s.User.UnionID,
+		"nickName":  s.User.Nickname,
+		"avatarUrl": s.User.HeadImgURL,
+	})
+	return string(raw), hex.EncodeToString(sum[:]), encryptedData, iv, err
+}
+
+// EncryptedPhone 模拟 getPhoneNumber
+func (s *MiniProgramServer) EncryptedPhone() (encryptedData, iv string, err error) {
+	return s.encrypt(s.sessionKey(s.User.OpenID), map[string]any{
+		"phoneNumber":     s.Phone,
+		"purePhoneNumber": s.Phone,
+		"countryCode":     "86",
+	})
+}
+
+// sessionKey 没有的话生成一个
+func (s *MiniProgramServer) sessionKey(openID string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.sessionKeys[openID]
+	if !ok {
+		key = make([]byte, aes.BlockSize)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+		s.sessionKeys[openID] = key
+	}
+	return key
+}
+
+// encrypt 和微信一样用 AES-128-CBC 加密，带上 watermark
+func (s *MiniProgramServer) encrypt(key []byte, data map[string]any) (string, string, error) {
+	data["watermark"] = map[string]any{"appid": s.AppID, "timestamp": time.Now().Unix()}
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return "", "", err
+	}
+	n := aes.BlockSize - len(plaintext)%aes.BlockSize
+	plaintext = append(plaintext, bytes.Repeat([]byte{byte(n)}, n)...)
+	iv := make([]byte, aes.BlockSize)
+	if _, err = rand.Read(iv); err != nil {
+		return "", "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", "", err
+	}
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+	return base64.StdEncoding.EncodeToString(ciphertext), base64.StdEncoding.EncodeToString(iv), nil
+}
+
+func (s *MiniProgramServer) code2session(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("appid") != s.AppID || q.Get("secret") != s.AppSecret {
+		wechatError(w, wechatErrInvalidAppSecret, "invalid appsecret")
+		return
+	}
+	code := q.Get("js_code")
+	s.mu.Lock()
+	u, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+	if !ok || q.Get("grant_type") != "authorization_code" {
+		wechatError(w, wechatErrInvalidCode, "invalid code")
+		return
+	}
+	res := map[string]any{
+		"openid":      u.OpenID,
+		"session_key": base64.StdEncoding.EncodeToString(s.sessionKey(u.OpenID)),
+	}
+	if u.UnionID != "" {
+		res["unionid"] = u.UnionID
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,161 @@
+package oauth2test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// 微信接口的错误码
+const (
+	wechatErrInvalidAppSecret    = 40125
+	wechatErrInvalidCode         = 40029
+	wechatErrInvalidAccessToken  = 40001
+	wechatErrInvalidRefreshToken = 40030
+)
+
+type WechatUser struct {
+	OpenID     string
+	UnionID    string
+	Nickname   string
+	HeadImgURL string
+}
+
+// WechatServer 同时是 wechat.Config 的 OpenURL 和 APIURL
+type WechatServer struct {
+	*httptest.Server
+	AppID     string
+	AppSecret string
+	// User 修改之后对新的授权生效
+	User WechatUser
+
+	mu            sync.Mutex
+	codes         map[string]WechatUser
+	accessTokens  map[string]WechatUser
+	refreshTokens map[string]WechatUser
+}
+
+// NewWechatServer 用完之后调用 Close
+func NewWechatServer(appID, appSecret string) *WechatServer {
+	s := &WechatServer{
+		AppID:     appID,
+		AppSecret: appSecret,
+		User: WechatUser{
+			OpenID:     "openid-1001",
+			UnionID:    "unionid-1001",
+			Nickname:   "微信用户",
+			HeadImgURL: "https://thirdwx.qlogo.cn/mmopen/1001/132",
+		},
+		codes:         map[string]WechatUser{},
+		accessTokens:  map[string]WechatUser{},
+		refreshTokens: map[string]WechatUser{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/connect/qrconnect", s.qrconnect)
+	mux.HandleFunc("/sns/oauth2/access_token", s.accessToken)
+	mux.HandleFunc("/sns/oauth2/refresh_token", s.refreshToken)
+	mux.HandleFunc("/sns/userinfo", s.userInfo)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// ExpireAccessTokens 让已经发出去的 access_token 全部过期，refresh_token 还能用
+func (s *WechatServer) ExpireAccessTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessTokens = map[string]WechatUser{}
+}
+
+// Authorize 模拟用户扫码确认，返回回调地址里面的 code 和 state
+func (s *WechatServer) Authorize(authURL string) (code string, state string, err error) {
+	return authorize(authURL)
+}
+
+func (s *WechatServer) qrconnect(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("appid") != s.AppID || q.Get("response_type") != "code" || q.Get("scope") != "snsapi_login" {
+		http.Error(w, "参数错误", http.StatusBadRequest)
+		return
+	}
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = s.User
+	s.mu.Unlock()
+	redirectWithCode(w, r, q.Get("redirect_uri"), code, q.Get("state"))
+}
+
+// accessToken 微信出错的时候 HTTP 状态码也是 200，错误放在 errcode 里面
+func (s *WechatServer) accessToken(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("appid") != s.AppID || q.Get("secret") != s.AppSecret {
+		wechatError(w, wechatErrInvalidAppSecret, "invalid appsecret")
+		return
+	}
+	s.mu.Lock()
+	code := q.Get("code")
+	u, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+	if !ok || q.Get("grant_type") != "authorization_code" {
+		wechatError(w, wechatErrInvalidCode, "invalid code")
+		return
+	}
+	s.writeToken(w, u, randomString())
+}
+
+// refreshToken refresh_token 不变，只换 access_token
+func (s *WechatServer) refreshToken(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("appid") != s.AppID {
+		wechatError(w, wechatErrInvalidAppSecret, "invalid appid")
+		return
+	}
+	refresh := q.Get("refresh_token")
+	s.mu.Lock()
+	u, ok := s.refreshTokens[refresh]
+	s.mu.Unlock()
+	if !ok || q.Get("grant_type") != "refresh_token" {
+		wechatError(w, wechatErrInvalidRefreshToken, "invalid refresh_token")
+		return
+	}
+	s.writeToken(w, u, refresh)
+}
+
+func (s *WechatServer) writeToken(w http.ResponseWriter, u WechatUser, refresh string) {
+	access := randomString()
+	s.mu.Lock()
+	s.accessTokens[access] = u
+	s.refreshTokens[refresh] = u
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token":  access,
+		"expires_in":    7200,
+		"refresh_token": refresh,
+		"openid":        u.OpenID,
+		"scope":         "snsapi_login",
+		"unionid":       u.UnionID,
+	})
+}
+
+func (s *WechatServer) userInfo(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	u, ok := s.accessTokens[q.Get("access_token")]
+	s.mu.Unlock()
+	if !ok || u.OpenID != q.Get("openid") {
+		wechatError(w, wechatErrInvalidAccessToken, "invalid credential, access_token is invalid or not latest")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"openid":     u.OpenID,
+		"nickname":   u.Nickname,
+		"sex":        0,
+		"headimgurl": u.HeadImgURL,
+		"privilege":  []string{},
+		"unionid":    u.UnionID,
+	})
+}
+
+func wechatError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, http.StatusOK, map[string]any{"errcode": code, "errmsg": msg})
+}
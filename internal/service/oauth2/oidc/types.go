@@ -0,0 +1,147 @@
+// Package oidc 通用的 OpenID Connect 提供方，端点通过 Issuer 的 /.well-known/openid-configuration 发现
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/service/oauth2"
+	"webook/pkg/httpx"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	xoauth2 "golang.org/x/oauth2"
+)
+
+var ErrNonceMismatch = errors.New("oidc: nonce 不匹配")
+
+type Config struct {
+	// Name 路由和身份关联里面用的名字，不能和其他提供方重复
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes 为空的时候是 openid email profile
+	Scopes []string
+}
+
+type provider struct {
+	cfg    Config
+	client *http.Client
+
+	// 第一次用到的时候再发现端点，启动的时候提供方不可用也不影响其他功能
+	mu       sync.Mutex
+	oauth    *xoauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func NewProvider(cfg Config) oauth2.Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	return &provider{
+		cfg: cfg,
+		client: &http.Client{
+			Transport: httpx.NewTracingTransport(http.DefaultTransport),
+			Timeout:   time.Second * 10,
+		},
+	}
+}
+
+func (p *provider) Name() string {
+	return p.cfg.Name
+}
+
+// discover 失败的时候下次再试
+func (p *provider) discover() (*xoauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+	// 公钥是后台按需拉取的，不能用请求的 ctx
+	ctx := oidc.ClientContext(context.Background(), p.client)
+	op, err := oidc.NewProvider(ctx, p.cfg.Issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.oauth = &xoauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     op.Endpoint(),
+		Scopes:       p.cfg.Scopes,
+	}
+	p.verifier = op.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+	return p.oauth, p.verifier, nil
+}
+
+func (p *provider) AuthURL(ctx context.Context, state string, opts oauth2.AuthOptions) (string, error) {
+	cfg, _, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	return cfg.AuthCodeURL(state, xoauth2.S256ChallengeOption(opts.CodeVerifier), oidc.Nonce(opts.Nonce)), nil
+}
+
+func (p *provider) Exchange(ctx context.Context, code string, opts oauth2.AuthOptions) (oauth2.Token, error) {
+	cfg, verifier, err := p.discover()
+	if err != nil {
+		return oauth2.Token{}, err
+	}
+	token, err := cfg.Exchange(oidc.ClientContext(ctx, p.client), code, xoauth2.VerifierOption(opts.CodeVerifier))
+	if err != nil {
+		return oauth2.Token{}, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return oauth2.Token{}, errors.New("oidc: 没有返回 id_token")
+	}
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return oauth2.Token{}, err
+	}
+	if idToken.Nonce != opts.Nonce {
+		return oauth2.Token{}, ErrNonceMismatch
+	}
+	return oauth2.Token{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		IDToken:      rawIDToken,
+		Expiry:       token.Expiry,
+	}, nil
+}
+
+type claims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+}
+
+// UserInfo 直接用 ID Token 里面的信息，不再调用 userinfo 端点
+func (p *provider) UserInfo(ctx context.Context, token oauth2.Token) (domain.Identity, error) {
+	_, verifier, err := p.discover()
+	if err != nil {
+		return domain.Identity{}, err
+	}
+	idToken, err := verifier.Verify(ctx, token.IDToken)
+	if err != nil {
+		return domain.Identity{}, err
+	}
+	var c claims
+	if err = idToken.Claims(&c); err != nil {
+		return domain.Identity{}, err
+	}
+	return domain.Identity{
+		Provider:      p.cfg.Name,
+		Subject:       idToken.Subject,
+		Email:         c.Email,
+		EmailVerified: c.EmailVerified,
+		Nickname:      c.Name,
+		Avatar:        c.Picture,
+	}, nil
+}
@@ -0,0 +1,107 @@
+package oidc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/service/oauth2"
+	"webook/internal/service/oauth2/oauth2test"
+)
+
+func TestProvider(t *testing.T) {
+	server := oauth2test.NewServer("webook", "webook-secret")
+	defer server.Close()
+	p := NewProvider(Config{
+		Name:         "keycloak",
+		Issuer:       server.URL,
+		ClientID:     "webook",
+		ClientSecret: "webook-secret",
+		RedirectURL:  "http://localhost:8080/oauth2/keycloak/callback",
+	})
+
+	testCases := []struct {
+		name string
+		// opts 修改 Exchange 用的参数
+		opts func(opts oauth2.AuthOptions) oauth2.AuthOptions
+
+		wantErr      bool
+		wantIdentity domain.Identity
+	}{
+		{
+			name: "登录成功",
+			opts: func(opts oauth2.AuthOptions) oauth2.AuthOptions {
+				return opts
+			},
+			wantIdentity: domain.Identity{
+				Provider:      "keycloak",
+				Subject:       "user-1001",
+				Email:         "tom@example.com",
+				EmailVerified: true,
+				Nickname:      "Tom",
+				Avatar:        "https://example.com/tom.png",
+			},
+		},
+		{
+			name: "code_verifier 不对",
+			opts: func(opts oauth2.AuthOptions) oauth2.AuthOptions {
+				other, err := oauth2.NewAuthOptions()
+				require.NoError(t, err)
+				opts.CodeVerifier = other.CodeVerifier
+				return opts
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			opts, err := oauth2.NewAuthOptions()
+			require.NoError(t, err)
+			authURL, err := p.AuthURL(ctx, "state-1", opts)
+			require.NoError(t, err)
+			u, err := url.Parse(authURL)
+			require.NoError(t, err)
+			assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+			assert.Equal(t, opts.Nonce, u.Query().Get("nonce"))
+
+			code, state, err := server.Authorize(authURL)
+			require.NoError(t, err)
+			assert.Equal(t, "state-1", state)
+
+			token, err := p.Exchange(ctx, code, tc.opts(opts))
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			identity, err := p.UserInfo(ctx, token)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantIdentity, identity)
+		})
+	}
+}
+
+func TestProvider_NonceMismatch(t *testing.T) {
+	server := oauth2test.NewServer("webook", "webook-secret")
+	defer server.Close()
+	p := NewProvider(Config{
+		Name:         "keycloak",
+		Issuer:       server.URL,
+		ClientID:     "webook",
+		ClientSecret: "webook-secret",
+		RedirectURL:  "http://localhost:8080/oauth2/keycloak/callback",
+	})
+	ctx := context.Background()
+	opts, err := oauth2.NewAuthOptions()
+	require.NoError(t, err)
+	authURL, err := p.AuthURL(ctx, "state-1", opts)
+	require.NoError(t, err)
+	code, _, err := server.Authorize(authURL)
+	require.NoError(t, err)
+	opts.Nonce = "other-nonce"
+	_, err = p.Exchange(ctx, code, opts)
+	assert.ErrorIs(t, err, ErrNonceMismatch)
+}
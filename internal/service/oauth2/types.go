@@ -0,0 +1,88 @@
+// Package oauth2 第三方登录，每个提供方实现 Provider，具体实现见子包
+package oauth2
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"sort"
+	"time"
+	"webook/internal/domain"
+
+	xoauth2 "golang.org/x/oauth2"
+)
+
+// Provider OAuth2 授权码模式的第三方登录
+type Provider interface {
+	// Name 路由里面的名字，比如 /oauth2/github/callback
+	Name() string
+	// AuthURL 跳转到第三方授权页面的地址
+	AuthURL(ctx context.Context, state string, opts AuthOptions) (string, error)
+	// Exchange 用回调里面的授权码换 token，opts 和 AuthURL 的一样
+	Exchange(ctx context.Context, code string, opts AuthOptions) (Token, error)
+	// UserInfo 获取第三方账号的信息
+	UserInfo(ctx context.Context, token Token) (domain.Identity, error)
+}
+
+// Refresher 支持用 refresh token 换新 token 的提供方
+type Refresher interface {
+	Refresh(ctx context.Context, refreshToken string) (Token, error)
+}
+
+// AuthOptions 一次授权过程中 AuthURL 和 Exchange 共用的参数，不支持的提供方忽略
+type AuthOptions struct {
+	// CodeVerifier PKCE，AuthURL 里面带上 S256 之后的 code_challenge
+	CodeVerifier string
+	// Nonce OIDC 写进 ID Token 里面，防止重放
+	Nonce string
+}
+
+// NewAuthOptions 随机生成 PKCE 和 nonce
+func NewAuthOptions() (AuthOptions, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return AuthOptions{}, err
+	}
+	return AuthOptions{
+		CodeVerifier: xoauth2.GenerateVerifier(),
+		Nonce:        base64.RawURLEncoding.EncodeToString(buf),
+	}, nil
+}
+
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	// IDToken 只有 OIDC 才有
+	IDToken string
+	Expiry  time.Time
+	// Extra 提供方特有的字段，比如微信的 openid
+	Extra map[string]string
+}
+
+// Registry 按名字查找提供方
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider, len(providers))}
+	for _, p := range providers {
+		r.providers[p.Name()] = p
+	}
+	return r
+}
+
+func (r *Registry) Get(name string) (Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// Names 按字母排序
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
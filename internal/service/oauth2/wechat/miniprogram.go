@@ -0,0 +1,201 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+	"webook/internal/domain"
+	"webook/pkg/httpx"
+)
+
+// ErrInvalidData 签名不对、解密失败或者不是这个小程序的数据
+// session_key 换了之后用旧的解密也是这个错误
+var ErrInvalidData = errors.New("微信数据校验失败")
+
+// MiniProgramConfig 小程序登录，AppID 和网站应用的不是同一个
+type MiniProgramConfig struct {
+	AppID     string
+	AppSecret string
+	// APIURL 为空的时候用微信的地址，测试的时候指向本地的模拟服务
+	APIURL string
+}
+
+// Session jscode2session 的结果，同一个开放平台下的小程序和网站应用 unionid 相同
+type Session struct {
+	OpenID     string
+	UnionID    string
+	SessionKey string
+}
+
+// UserInfo 小程序 getUserProfile 解密之后的用户信息
+type UserInfo struct {
+	OpenID    string    `json:"openId"`
+	UnionID   string    `json:"unionId"`
+	NickName  string    `json:"nickName"`
+	AvatarURL string    `json:"avatarUrl"`
+	Watermark Watermark `json:"watermark"`
+}
+
+// PhoneInfo 小程序 getPhoneNumber 解密之后的手机号
+type PhoneInfo struct {
+	// PhoneNumber 国外的手机号会带区号
+	PhoneNumber     string    `json:"phoneNumber"`
+	PurePhoneNumber string    `json:"purePhoneNumber"`
+	CountryCode     string    `json:"countryCode"`
+	Watermark       Watermark `json:"watermark"`
+}
+
+// Watermark 数据是哪个小程序、什么时候获取的
+type Watermark struct {
+	AppID     string `json:"appid"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// MiniProgram 小程序登录，和扫码登录不一样，不需要跳转
+type MiniProgram interface {
+	// Code2Session 用 wx.login 拿到的 code 换 session_key
+	Code2Session(ctx context.Context, code string) (Session, error)
+	// DecryptUserInfo 校验签名并解密用户信息
+	DecryptUserInfo(sessionKey string, data domain.WechatEncryptedData) (UserInfo, error)
+	// DecryptPhone 解密手机号
+	DecryptPhone(sessionKey string, data domain.WechatEncryptedData) (PhoneInfo, error)
+}
+
+type miniProgram struct {
+	cfg    MiniProgramConfig
+	client *http.Client
+}
+
+func NewMiniProgram(cfg MiniProgramConfig) MiniProgram {
+	if cfg.APIURL == "" {
+		cfg.APIURL = defaultAPIURL
+	}
+	return &miniProgram{
+		cfg: cfg,
+		client: &http.Client{
+			Transport: httpx.NewTracingTransport(http.DefaultTransport),
+			Timeout:   time.Second * 10,
+		},
+	}
+}
+
+// SessionResult 微信 jscode2session 的响应
+type SessionResult struct {
+	OpenID     string `json:"openid"`
+	SessionKey string `json:"session_key"`
+	UnionID    string `json:"unionid"`
+	ErrCode    int64  `json:"errcode"`
+	ErrMsg     string `json:"errmsg"`
+}
+
+func (m *miniProgram) Code2Session(ctx context.Context, code string) (Session, error) {
+	q := url.Values{}
+	q.Set("appid", m.cfg.AppID)
+	q.Set("secret", m.cfg.AppSecret)
+	q.Set("js_code", code)
+	q.Set("grant_type", "authorization_code")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.cfg.APIURL+"/sns/jscode2session?"+q.Encode(), nil)
+	if err != nil {
+		return Session{}, err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return Session{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Session{}, fmt.Errorf("微信返回 HTTP 状态码：%d", resp.StatusCode)
+	}
+	var res SessionResult
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return Session{}, err
+	}
+	if res.ErrCode != 0 {
+		return Session{}, fmt.Errorf("微信返回错误码：%d, 错误信息：%s", res.ErrCode, res.ErrMsg)
+	}
+	if res.OpenID == "" || res.SessionKey == "" {
+		return Session{}, errors.New("微信没有返回 openid 或者 session_key")
+	}
+	return Session{
+		OpenID:     res.OpenID,
+		UnionID:    res.UnionID,
+		SessionKey: res.SessionKey,
+	}, nil
+}
+
+func (m *miniProgram) DecryptUserInfo(sessionKey string, data domain.WechatEncryptedData) (UserInfo, error) {
+	// 签名是 sha1(rawData + session_key)
+	sum := sha1.Sum([]byte(data.RawData + sessionKey))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(data.Signature)) != 1 {
+		return UserInfo{}, fmt.Errorf("%w: 签名不对", ErrInvalidData)
+	}
+	var info UserInfo
+	if err := m.decrypt(sessionKey, data, &info); err != nil {
+		return UserInfo{}, err
+	}
+	if info.Watermark.AppID != m.cfg.AppID {
+		return UserInfo{}, fmt.Errorf("%w: 不是这个小程序的数据", ErrInvalidData)
+	}
+	return info, nil
+}
+
+func (m *miniProgram) DecryptPhone(sessionKey string, data domain.WechatEncryptedData) (PhoneInfo, error) {
+	var info PhoneInfo
+	if err := m.decrypt(sessionKey, data, &info); err != nil {
+		return PhoneInfo{}, err
+	}
+	if info.Watermark.AppID != m.cfg.AppID {
+		return PhoneInfo{}, fmt.Errorf("%w: 不是这个小程序的数据", ErrInvalidData)
+	}
+	return info, nil
+}
+
+// decrypt AES-128-CBC，密钥是 session_key，数据、密钥和 IV 都是 base64 编码，PKCS#7 填充
+func (m *miniProgram) decrypt(sessionKey string, data domain.WechatEncryptedData, val any) error {
+	key, err := base64.StdEncoding.DecodeString(sessionKey)
+	if err != nil || len(key) != aes.BlockSize {
+		return fmt.Errorf("%w: session_key 格式不对", ErrInvalidData)
+	}
+	iv, err := base64.StdEncoding.DecodeString(data.IV)
+	if err != nil || len(iv) != aes.BlockSize {
+		return fmt.Errorf("%w: iv 格式不对", ErrInvalidData)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(data.EncryptedData)
+	if err != nil || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return fmt.Errorf("%w: 密文格式不对", ErrInvalidData)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	plaintext, err = pkcs7Unpad(plaintext)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(plaintext, val); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidData, err)
+	}
+	return nil
+}
+
+func pkcs7Unpad(data []byte) ([]byte, error) {
+	n := int(data[len(data)-1])
+	if n == 0 || n > aes.BlockSize || n > len(data) ||
+		!bytes.Equal(data[len(data)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, fmt.Errorf("%w: 填充不对", ErrInvalidData)
+	}
+	return data[:len(data)-n], nil
+}
@@ -0,0 +1,140 @@
+package wechat
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/service/oauth2/oauth2test"
+)
+
+func TestMiniProgram_Code2Session(t *testing.T) {
+	server := oauth2test.NewMiniProgramServer("wx-mini", "secret")
+	defer server.Close()
+	testCases := []struct {
+		name   string
+		secret string
+		// code 修改 wx.login 的 code
+		code func(code string) string
+
+		wantErr     bool
+		wantSession Session
+	}{
+		{
+			name:   "登录成功",
+			secret: "secret",
+			code: func(code string) string {
+				return code
+			},
+			wantSession: Session{OpenID: "mini-openid-1001", UnionID: "unionid-1001"},
+		},
+		{
+			name:   "code 无效",
+			secret: "secret",
+			code: func(code string) string {
+				return "invalid-code"
+			},
+			wantErr: true,
+		},
+		{
+			name:   "AppSecret 不对",
+			secret: "wrong-secret",
+			code: func(code string) string {
+				return code
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMiniProgram(MiniProgramConfig{AppID: "wx-mini", AppSecret: tc.secret, APIURL: server.URL})
+			session, err := m.Code2Session(context.Background(), tc.code(server.Login()))
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, session.SessionKey)
+			session.SessionKey = ""
+			assert.Equal(t, tc.wantSession, session)
+		})
+	}
+}
+
+func TestMiniProgram_Decrypt(t *testing.T) {
+	server := oauth2test.NewMiniProgramServer("wx-mini", "secret")
+	defer server.Close()
+	m := NewMiniProgram(MiniProgramConfig{AppID: "wx-mini", AppSecret: "secret", APIURL: server.URL})
+	session, err := m.Code2Session(context.Background(), server.Login())
+	require.NoError(t, err)
+	rawData, signature, encryptedData, iv, err := server.EncryptedUserInfo()
+	require.NoError(t, err)
+	userInfo := domain.WechatEncryptedData{
+		RawData:       rawData,
+		Signature:     signature,
+		EncryptedData: encryptedData,
+		IV:            iv,
+	}
+	encryptedPhone, phoneIV, err := server.EncryptedPhone()
+	require.NoError(t, err)
+	phone := domain.WechatEncryptedData{EncryptedData: encryptedPhone, IV: phoneIV}
+
+	info, err := m.DecryptUserInfo(session.SessionKey, userInfo)
+	require.NoError(t, err)
+	assert.Equal(t, "mini-openid-1001", info.OpenID)
+	assert.Equal(t, "unionid-1001", info.UnionID)
+	assert.Equal(t, "微信用户", info.NickName)
+	assert.Equal(t, "https://thirdwx.qlogo.cn/mmopen/1001/132", info.AvatarURL)
+	phoneInfo, err := m.DecryptPhone(session.SessionKey, phone)
+	require.NoError(t, err)
+	assert.Equal(t, "13800138000", phoneInfo.PhoneNumber)
+
+	testCases := []struct {
+		name    string
+		decrypt func() error
+	}{
+		{
+			name: "rawData 被篡改",
+			decrypt: func() error {
+				data := userInfo
+				data.RawData = `{"nickName":"admin"}`
+				_, err := m.DecryptUserInfo(session.SessionKey, data)
+				return err
+			},
+		},
+		{
+			name: "session_key 已经换了",
+			decrypt: func() error {
+				server.RotateSessionKey()
+				encrypted, iv, err := server.EncryptedPhone()
+				require.NoError(t, err)
+				_, err = m.DecryptPhone(session.SessionKey, domain.WechatEncryptedData{EncryptedData: encrypted, IV: iv})
+				return err
+			},
+		},
+		{
+			name: "其他小程序的数据",
+			decrypt: func() error {
+				other := NewMiniProgram(MiniProgramConfig{AppID: "wx-other"})
+				_, err := other.DecryptPhone(session.SessionKey, phone)
+				return err
+			},
+		},
+		{
+			name: "密文格式不对",
+			decrypt: func() error {
+				_, err := m.DecryptPhone(session.SessionKey, domain.WechatEncryptedData{
+					EncryptedData: "not-base64",
+					IV:            phoneIV,
+				})
+				return err
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorIs(t, tc.decrypt(), ErrInvalidData)
+		})
+	}
+}
@@ -6,32 +6,62 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 	"webook/internal/domain"
+	"webook/internal/service/oauth2"
+	"webook/pkg/httpx"
 )
 
-var (
-	redirectURL = url.PathEscape(`https://meoying.com/oauth2/wechat/callback`)
+const (
+	defaultOpenURL = "https://open.weixin.qq.com"
+	defaultAPIURL  = "https://api.weixin.qq.com"
 )
 
-type Service interface {
-	AuthURL(ctx context.Context, state string) (string, error)
-	VerifyCode(ctx context.Context, code string) (domain.WechatInfo, error)
+// Config 网站应用的微信登录
+type Config struct {
+	AppID     string
+	AppSecret string
+	// RedirectURL 回调地址，域名要和微信开放平台上配置的一致
+	RedirectURL string
+	// OpenURL 和 APIURL 为空的时候用微信的地址，测试的时候指向本地的模拟服务
+	OpenURL string
+	APIURL  string
 }
 
-type service struct {
-	appID     string
-	appSecret string
-	client    *http.Client
+type provider struct {
+	cfg    Config
+	client *http.Client
 }
 
-func NewService(appID string, appSecret string) Service {
-	return &service{
-		appID:     appID,
-		appSecret: appSecret,
-		client:    http.DefaultClient,
+// Provider 微信登录，除了授权码登录还可以刷新 access_token
+type Provider interface {
+	oauth2.Provider
+	oauth2.Refresher
+}
+
+// NewProvider 微信不支持 PKCE，AuthOptions 会被忽略
+func NewProvider(cfg Config) Provider {
+	if cfg.OpenURL == "" {
+		cfg.OpenURL = defaultOpenURL
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = defaultAPIURL
+	}
+	return &provider{
+		cfg: cfg,
+		// 调用微信接口的时候带上链路信息，查询参数里面有 secret，不能记到 trace 里面
+		client: &http.Client{
+			Transport: httpx.NewTracingTransport(http.DefaultTransport),
+			Timeout:   time.Second * 10,
+		},
 	}
 }
 
+func (p *provider) Name() string {
+	return domain.ProviderWechat
+}
+
+// Result 微信用授权码换 access_token 的响应
 type Result struct {
 	AccessToken  string `json:"access_token"`
 	ExpiresIn    int64  `json:"expires_in"`
@@ -43,32 +73,106 @@ type Result struct {
 	ErrMsg       string `json:"errmsg"`
 }
 
-func (s *service) VerifyCode(ctx context.Context, code string) (domain.WechatInfo, error) {
-	accessTokenURL := fmt.Sprintf(`https://api.weixin.qq.com/sns/oauth2/access_token?appid=%s&secret=%s&code=%s&grant_type=authorization_code`,
-		s.appSecret, code)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, accessTokenURL, nil)
-	if err != nil {
-		return domain.WechatInfo{}, err
+func (p *provider) AuthURL(ctx context.Context, state string, opts oauth2.AuthOptions) (string, error) {
+	// Encode 按照 key 排序，刚好是微信文档里面的顺序
+	q := url.Values{}
+	q.Set("appid", p.cfg.AppID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("response_type", "code")
+	q.Set("scope", "snsapi_login")
+	q.Set("state", state)
+	return p.cfg.OpenURL + "/connect/qrconnect?" + q.Encode() + "#wechat_redirect", nil
+}
+
+func (p *provider) Exchange(ctx context.Context, code string, opts oauth2.AuthOptions) (oauth2.Token, error) {
+	q := url.Values{}
+	q.Set("appid", p.cfg.AppID)
+	q.Set("secret", p.cfg.AppSecret)
+	q.Set("code", code)
+	q.Set("grant_type", "authorization_code")
+	return p.token(ctx, "/sns/oauth2/access_token?"+q.Encode())
+}
+
+// Refresh access_token 两个小时过期，refresh_token 30 天过期，过期之后只能重新授权
+func (p *provider) Refresh(ctx context.Context, refreshToken string) (oauth2.Token, error) {
+	q := url.Values{}
+	q.Set("appid", p.cfg.AppID)
+	q.Set("grant_type", "refresh_token")
+	q.Set("refresh_token", refreshToken)
+	return p.token(ctx, "/sns/oauth2/refresh_token?"+q.Encode())
+}
+
+func (p *provider) token(ctx context.Context, path string) (oauth2.Token, error) {
+	var res Result
+	if err := p.get(ctx, path, &res); err != nil {
+		return oauth2.Token{}, err
 	}
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return domain.WechatInfo{}, err
+	if res.ErrCode != 0 {
+		return oauth2.Token{}, fmt.Errorf("微信返回错误码：%d, 错误信息：%s", res.ErrCode, res.ErrMsg)
 	}
-	var res Result
-	err = json.NewDecoder(resp.Body).Decode(&res)
-	if err != nil {
-		return domain.WechatInfo{}, err
+	return oauth2.Token{
+		AccessToken:  res.AccessToken,
+		RefreshToken: res.RefreshToken,
+		Expiry:       time.Now().Add(time.Duration(res.ExpiresIn) * time.Second),
+		Extra: map[string]string{
+			"openid":  res.OpenID,
+			"unionid": res.UnionID,
+			"scope":   res.Scope,
+		},
+	}, nil
+}
+
+// UserInfoResult 微信 /sns/userinfo 的响应
+type UserInfoResult struct {
+	OpenID     string `json:"openid"`
+	UnionID    string `json:"unionid"`
+	Nickname   string `json:"nickname"`
+	HeadImgURL string `json:"headimgurl"`
+	ErrCode    int64  `json:"errcode"`
+	ErrMsg     string `json:"errmsg"`
+}
+
+// UserInfo 用 access_token 获取昵称和头像，openid 和 unionid 在换 token 的时候就拿到了
+func (p *provider) UserInfo(ctx context.Context, token oauth2.Token) (domain.Identity, error) {
+	openID := token.Extra["openid"]
+	if openID == "" {
+		return domain.Identity{}, fmt.Errorf("微信没有返回 openid")
+	}
+	q := url.Values{}
+	q.Set("access_token", token.AccessToken)
+	q.Set("openid", openID)
+	var res UserInfoResult
+	if err := p.get(ctx, "/sns/userinfo?"+q.Encode(), &res); err != nil {
+		return domain.Identity{}, err
 	}
 	if res.ErrCode != 0 {
-		return domain.WechatInfo{}, fmt.Errorf("微信返回错误码：%d, 错误信息：%s", res.ErrCode, res.ErrMsg)
+		return domain.Identity{}, fmt.Errorf("微信返回错误码：%d, 错误信息：%s", res.ErrCode, res.ErrMsg)
+	}
+	unionID := token.Extra["unionid"]
+	if unionID == "" {
+		unionID = res.UnionID
 	}
-	return domain.WechatInfo{
-		UnionID: res.UnionID,
-		OpenID:  res.OpenID,
+	return domain.Identity{
+		Provider: domain.ProviderWechat,
+		Subject:  openID,
+		UnionID:  unionID,
+		Nickname: res.Nickname,
+		Avatar:   res.HeadImgURL,
 	}, nil
 }
 
-func (s *service) AuthURL(ctx context.Context, state string) (string, error) {
-	const authURLPattern = `https://open.weixin.qq.com/connect/qrconnect?appid=%s&redirect_uri=%s&response_type=code&scope=snsapi_login&state=%s#wechat_redirect`
-	return fmt.Sprintf(authURLPattern, s.appID, redirectURL, state), nil
+func (p *provider) get(ctx context.Context, path string, val any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.APIURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("微信返回 HTTP 状态码：%d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(val)
 }
@@ -0,0 +1,143 @@
+package wechat
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/service/oauth2"
+	"webook/internal/service/oauth2/oauth2test"
+)
+
+func TestProvider_AuthURL(t *testing.T) {
+	p := NewProvider(Config{
+		AppID:       "wx123",
+		RedirectURL: "https://webook.com/oauth2/wechat/callback",
+	})
+	url, err := p.AuthURL(context.Background(), "state-1", oauth2.AuthOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://open.weixin.qq.com/connect/qrconnect?appid=wx123"+
+		"&redirect_uri=https%3A%2F%2Fwebook.com%2Foauth2%2Fwechat%2Fcallback"+
+		"&response_type=code&scope=snsapi_login&state=state-1#wechat_redirect", url)
+}
+
+func TestProvider_Login(t *testing.T) {
+	server := oauth2test.NewWechatServer("wx123", "secret")
+	defer server.Close()
+	testCases := []struct {
+		name   string
+		secret string
+		// code 修改授权码
+		code func(code string) string
+
+		wantErr      bool
+		wantIdentity domain.Identity
+	}{
+		{
+			name:   "登录成功",
+			secret: "secret",
+			code: func(code string) string {
+				return code
+			},
+			wantIdentity: domain.Identity{
+				Provider: domain.ProviderWechat,
+				Subject:  "openid-1001",
+				UnionID:  "unionid-1001",
+				Nickname: "微信用户",
+				Avatar:   "https://thirdwx.qlogo.cn/mmopen/1001/132",
+			},
+		},
+		{
+			name:   "授权码无效",
+			secret: "secret",
+			code: func(code string) string {
+				return "invalid-code"
+			},
+			wantErr: true,
+		},
+		{
+			name:   "AppSecret 不对",
+			secret: "wrong-secret",
+			code: func(code string) string {
+				return code
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewProvider(Config{
+				AppID:       "wx123",
+				AppSecret:   tc.secret,
+				RedirectURL: "https://webook.com/oauth2/wechat/callback",
+				OpenURL:     server.URL,
+				APIURL:      server.URL,
+			})
+			ctx := context.Background()
+			authURL, err := p.AuthURL(ctx, "state-1", oauth2.AuthOptions{})
+			require.NoError(t, err)
+			code, state, err := server.Authorize(authURL)
+			require.NoError(t, err)
+			assert.Equal(t, "state-1", state)
+
+			token, err := p.Exchange(ctx, tc.code(code), oauth2.AuthOptions{})
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, token.AccessToken)
+			assert.NotEmpty(t, token.RefreshToken)
+			identity, err := p.UserInfo(ctx, token)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantIdentity, identity)
+		})
+	}
+}
+
+func TestProvider_Refresh(t *testing.T) {
+	server := oauth2test.NewWechatServer("wx123", "secret")
+	defer server.Close()
+	p := NewProvider(Config{
+		AppID:     "wx123",
+		AppSecret: "secret",
+		OpenURL:   server.URL,
+		APIURL:    server.URL,
+	})
+	ctx := context.Background()
+	authURL, err := p.AuthURL(ctx, "state-1", oauth2.AuthOptions{})
+	require.NoError(t, err)
+	code, _, err := server.Authorize(authURL)
+	require.NoError(t, err)
+	token, err := p.Exchange(ctx, code, oauth2.AuthOptions{})
+	require.NoError(t, err)
+
+	// access_token 过期之后不能再获取用户信息
+	server.ExpireAccessTokens()
+	_, err = p.UserInfo(ctx, token)
+	assert.Error(t, err)
+
+	refreshed, err := p.Refresh(ctx, token.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, token.AccessToken, refreshed.AccessToken)
+	assert.Equal(t, token.RefreshToken, refreshed.RefreshToken)
+	identity, err := p.UserInfo(ctx, refreshed)
+	require.NoError(t, err)
+	assert.Equal(t, "openid-1001", identity.Subject)
+
+	_, err = p.Refresh(ctx, "invalid-refresh-token")
+	assert.Error(t, err)
+}
+
+func TestProvider_Unavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	p := NewProvider(Config{AppID: "wx123", AppSecret: "secret", APIURL: server.URL})
+	_, err := p.Exchange(context.Background(), "code-1", oauth2.AuthOptions{})
+	assert.Error(t, err)
+}
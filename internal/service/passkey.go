@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/pkg/logger"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+var (
+	ErrPasskeyDuplicated = repository.ErrPasskeyDuplicated
+	ErrPasskeyNotFound   = repository.ErrPasskeyNotFound
+	// ErrPasskeySessionInvalid challenge 不存在或者过期了，需要重新开始
+	ErrPasskeySessionInvalid = errors.New("通行密钥会话无效")
+	// ErrPasskeyVerifyFailed 浏览器返回的数据校验不通过，具体原因见 Unwrap
+	ErrPasskeyVerifyFailed = errors.New("通行密钥校验失败")
+)
+
+// passkeySessionExpiration 和 WebAuthn 默认的超时时间一致
+const passkeySessionExpiration = time.Minute * 5
+
+// PasskeyService 通行密钥的注册和登录
+// 注册和登录都分成两步：Begin 返回给浏览器的参数和会话 ID，Finish 带上会话 ID 和浏览器的响应
+type PasskeyService interface {
+	BeginRegistration(ctx context.Context, user domain.User) (*protocol.CredentialCreation, string, error)
+	FinishRegistration(ctx context.Context, user domain.User, sessionID, name string,
+		resp *protocol.ParsedCredentialCreationData) (domain.Passkey, error)
+	// BeginLogin 不需要知道是哪个用户，浏览器列出这个网站的通行密钥让用户选
+	BeginLogin(ctx context.Context) (*protocol.CredentialAssertion, string, error)
+	// FinishLogin 校验通过之后返回用户 ID
+	FinishLogin(ctx context.Context, sessionID string, resp *protocol.ParsedCredentialAssertionData) (int64, error)
+	List(ctx context.Context, uid int64) ([]domain.Passkey, error)
+	Delete(ctx context.Context, uid int64, id int64) error
+}
+
+type passkeyService struct {
+	repo repository.PasskeyRepository
+	wa   *webauthn.WebAuthn
+	l    logger.Logger
+}
+
+func NewPasskeyService(repo repository.PasskeyRepository, wa *webauthn.WebAuthn, l logger.Logger) PasskeyService {
+	return &passkeyService{repo: repo, wa: wa, l: l}
+}
+
+func (svc *passkeyService) BeginRegistration(ctx context.Context,
+	user domain.User) (*protocol.CredentialCreation, string, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.BeginRegistration")
+	defer span.End()
+	wu, err := svc.webauthnUser(ctx, user)
+	if err != nil {
+		return nil, "", err
+	}
+	// 已经注册过的不让浏览器重复注册
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(wu.credentials))
+	for _, c := range wu.credentials {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+	// 登录的时候不知道用户是谁，必须是可发现凭证
+	creation, session, err := svc.wa.BeginRegistration(wu,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired))
+	if err != nil {
+		return nil, "", err
+	}
+	id, err := svc.saveSession(ctx, session)
+	return creation, id, err
+}
+
+func (svc *passkeyService) FinishRegistration(ctx context.Context, user domain.User, sessionID, name string,
+	resp *protocol.ParsedCredentialCreationData) (domain.Passkey, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.FinishRegistration")
+	defer span.End()
+	session, err := svc.takeSession(ctx, sessionID)
+	if err != nil {
+		return domain.Passkey{}, err
+	}
+	wu, err := svc.webauthnUser(ctx, user)
+	if err != nil {
+		return domain.Passkey{}, err
+	}
+	cred, err := svc.wa.CreateCredential(wu, session, resp)
+	if err != nil {
+		return domain.Passkey{}, verifyError(err)
+	}
+	transports := make([]string, 0, len(cred.Transport))
+	for _, t := range cred.Transport {
+		transports = append(transports, string(t))
+	}
+	p := domain.Passkey{
+		UserID:          user.ID,
+		Name:            name,
+		CredentialID:    cred.ID,
+		PublicKey:       cred.PublicKey,
+		AttestationType: cred.AttestationType,
+		Transports:      transports,
+		AAGUID:          cred.Authenticator.AAGUID,
+		SignCount:       cred.Authenticator.SignCount,
+		BackupEligible:  cred.Flags.BackupEligible,
+		BackupState:     cred.Flags.BackupState,
+	}
+	if err = svc.repo.Create(ctx, p); err != nil {
+		return domain.Passkey{}, err
+	}
+	return p, nil
+}
+
+func (svc *passkeyService) BeginLogin(ctx context.Context) (*protocol.CredentialAssertion, string, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.BeginLogin")
+	defer span.End()
+	assertion, session, err := svc.wa.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, "", err
+	}
+	id, err := svc.saveSession(ctx, session)
+	return assertion, id, err
+}
+
+func (svc *passkeyService) FinishLogin(ctx context.Context, sessionID string,
+	resp *protocol.ParsedCredentialAssertionData) (int64, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.FinishLogin")
+	defer span.End()
+	session, err := svc.takeSession(ctx, sessionID)
+	if err != nil {
+		return 0, err
+	}
+	// 浏览器返回的 userHandle 就是注册时候的 WebAuthnID
+	var wu *webauthnUser
+	cred, err := svc.wa.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		uid, err := strconv.ParseInt(string(userHandle), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		wu, err = svc.webauthnUser(ctx, domain.User{ID: uid})
+		return wu, err
+	}, session, resp)
+	if err != nil {
+		return 0, verifyError(err)
+	}
+	// 签名计数没有增加，说明密钥可能被复制了
+	if cred.Authenticator.CloneWarning {
+		svc.l.Warn(ctx, "通行密钥签名计数异常", logger.Int64("uid", wu.user.ID))
+		return 0, ErrPasskeyVerifyFailed
+	}
+	for _, p := range wu.passkeys {
+		if string(p.CredentialID) != string(cred.ID) {
+			continue
+		}
+		p.SignCount = cred.Authenticator.SignCount
+		p.BackupState = cred.Flags.BackupState
+		if err = svc.repo.UpdateUsage(ctx, p); err != nil {
+			// 只影响克隆检测的准确性，不影响这次登录
+			svc.l.Warn(ctx, "更新通行密钥使用记录失败", logger.Int64("uid", p.UserID), logger.Error(err))
+		}
+		break
+	}
+	return wu.user.ID, nil
+}
+
+func (svc *passkeyService) List(ctx context.Context, uid int64) ([]domain.Passkey, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.List")
+	defer span.End()
+	return svc.repo.FindByUser(ctx, uid)
+}
+
+func (svc *passkeyService) Delete(ctx context.Context, uid int64, id int64) error {
+	ctx, span := tracer.Start(ctx, "PasskeyService.Delete")
+	defer span.End()
+	return svc.repo.Delete(ctx, uid, id)
+}
+
+func (svc *passkeyService) saveSession(ctx context.Context, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(buf)
+	return id, svc.repo.SetSession(ctx, id, data, passkeySessionExpiration)
+}
+
+// takeSession 会话只能用一次，校验失败也要重新开始
+func (svc *passkeyService) takeSession(ctx context.Context, id string) (webauthn.SessionData, error) {
+	var session webauthn.SessionData
+	data, err := svc.repo.TakeSession(ctx, id)
+	if err == repository.ErrPasskeySessionNotFound {
+		return session, ErrPasskeySessionInvalid
+	}
+	if err != nil {
+		return session, err
+	}
+	err = json.Unmarshal(data, &session)
+	return session, err
+}
+
+func (svc *passkeyService) webauthnUser(ctx context.Context, user domain.User) (*webauthnUser, error) {
+	passkeys, err := svc.repo.FindByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	wu := &webauthnUser{user: user, passkeys: passkeys}
+	for _, p := range passkeys {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(p.Transports))
+		for _, t := range p.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+		wu.credentials = append(wu.credentials, webauthn.Credential{
+			ID:              p.CredentialID,
+			PublicKey:       p.PublicKey,
+			AttestationType: p.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: p.BackupEligible,
+				BackupState:    p.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    p.AAGUID,
+				SignCount: p.SignCount,
+			},
+		})
+	}
+	return wu, nil
+}
+
+// verifyError 校验失败的原因记录在错误链里面，方便排查
+func verifyError(err error) error {
+	return fmt.Errorf("%w: %w", ErrPasskeyVerifyFailed, err)
+}
+
+// webauthnUser 适配 webauthn.User
+type webauthnUser struct {
+	user        domain.User
+	passkeys    []domain.Passkey
+	credentials []webauthn.Credential
+}
+
+// WebAuthnID 用户 ID 的十进制字符串，登录的时候从 userHandle 解析出来
+func (u *webauthnUser) WebAuthnID() []byte {
+	return []byte(strconv.FormatInt(u.user.ID, 10))
+}
+
+// WebAuthnName 显示在浏览器的通行密钥列表里面
+func (u *webauthnUser) WebAuthnName() string {
+	switch {
+	case u.user.Email != "":
+		return u.user.Email
+	case u.user.Phone != "":
+		return u.user.Phone
+	default:
+		return fmt.Sprintf("user-%d", u.user.ID)
+	}
+}
+
+func (u *webauthnUser) WebAuthnDisplayName() string {
+	return u.WebAuthnName()
+}
+
+func (u *webauthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	mocksvc "webook/internal/repository/mock"
+	"webook/pkg/logger"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:3000"
+)
+
+// softAuthenticator 软件实现的验证器，代替浏览器和安全密钥
+// 只支持 ES256 和 none 格式的证明，足够跑通注册和登录
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+	// origin 为空的时候用 testOrigin
+	origin string
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	id := make([]byte, 16)
+	_, err = rand.Read(id)
+	require.NoError(t, err)
+	return &softAuthenticator{key: key, credentialID: id}
+}
+
+// Create 模拟 navigator.credentials.create，返回浏览器提交给服务端的 JSON
+func (a *softAuthenticator) Create(t *testing.T, creation *protocol.CredentialCreation) []byte {
+	opts := creation.Response
+	a.userHandle = opts.User.ID.(protocol.URLEncodedBase64)
+	clientData := a.clientData(t, protocol.CreateCeremony, opts.Challenge)
+
+	cose, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1,
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	require.NoError(t, err)
+	// attested credential data: aaguid(16) + 长度(2) + credential id + 公钥
+	attested := make([]byte, 16, 16+2+len(a.credentialID)+len(cose))
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, cose...)
+	authData := a.authData(0x40, attested)
+
+	attObj, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	require.NoError(t, err)
+	return a.credential(t, map[string]any{
+		"clientDataJSON":    b64(clientData),
+		"attestationObject": b64(attObj),
+		"transports":        []string{"internal"},
+	})
+}
+
+// Get 模拟 navigator.credentials.get
+func (a *softAuthenticator) Get(t *testing.T, assertion *protocol.CredentialAssertion) []byte {
+	clientData := a.clientData(t, protocol.AssertCeremony, assertion.Response.Challenge)
+	a.signCount++
+	authData := a.authData(0, nil)
+	hash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, hash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+	return a.credential(t, map[string]any{
+		"clientDataJSON":    b64(clientData),
+		"authenticatorData": b64(authData),
+		"signature":         b64(sig),
+		"userHandle":        b64(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, typ protocol.CeremonyType, challenge protocol.URLEncodedBase64) []byte {
+	origin := a.origin
+	if origin == "" {
+		origin = testOrigin
+	}
+	data, err := json.Marshal(map[string]any{
+		"type":      typ,
+		"challenge": challenge.String(),
+		"origin":    origin,
+	})
+	require.NoError(t, err)
+	return data
+}
+
+// authData rpIdHash(32) + flags(1) + signCount(4) + extra，默认带上 UP 和 UV
+func (a *softAuthenticator) authData(flags byte, extra []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpIDHash[:], flags|0x01|0x04)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, extra...)
+}
+
+func (a *softAuthenticator) credential(t *testing.T, response map[string]any) []byte {
+	data, err := json.Marshal(map[string]any{
+		"id":       b64(a.credentialID),
+		"rawId":    b64(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	require.NoError(t, err)
+	return data
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func newTestWebAuthn(t *testing.T) *webauthn.WebAuthn {
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "webook",
+		RPOrigins:     []string{testOrigin},
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			UserVerification:   protocol.VerificationRequired,
+		},
+	})
+	require.NoError(t, err)
+	return wa
+}
+
+// fakeSessions 代替 Redis 保存会话
+func fakeSessions(repo *mocksvc.MockPasskeyRepository) {
+	sessions := map[string][]byte{}
+	repo.EXPECT().SetSession(gomock.Any(), gomock.Any(), gomock.Any(), passkeySessionExpiration).
+		DoAndReturn(func(ctx context.Context, id string, data []byte, exp time.Duration) error {
+			sessions[id] = data
+			return nil
+		}).AnyTimes()
+	repo.EXPECT().TakeSession(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, id string) ([]byte, error) {
+			data, ok := sessions[id]
+			if !ok {
+				return nil, repository.ErrPasskeySessionNotFound
+			}
+			delete(sessions, id)
+			return data, nil
+		}).AnyTimes()
+}
+
+// register 走一遍注册流程，返回保存下来的通行密钥
+func register(t *testing.T, svc PasskeyService, repo *mocksvc.MockPasskeyRepository,
+	auth *softAuthenticator, user domain.User) domain.Passkey {
+	var saved domain.Passkey
+	repo.EXPECT().FindByUser(gomock.Any(), user.ID).Return(nil, nil).Times(2)
+	repo.EXPECT().Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, p domain.Passkey) error {
+			saved = p
+			saved.ID = 1
+			return nil
+		})
+	creation, session, err := svc.BeginRegistration(context.Background(), user)
+	require.NoError(t, err)
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(auth.Create(t, creation)))
+	require.NoError(t, err)
+	_, err = svc.FinishRegistration(context.Background(), user, session, "测试密钥", parsed)
+	require.NoError(t, err)
+	return saved
+}
+
+func Test_passkeyService_Register(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocksvc.NewMockPasskeyRepository(ctrl)
+	fakeSessions(repo)
+	svc := NewPasskeyService(repo, newTestWebAuthn(t), logger.NewNopLogger())
+	auth := newSoftAuthenticator(t)
+
+	p := register(t, svc, repo, auth, domain.User{ID: 123, Email: "tom@qq.com"})
+	assert.Equal(t, int64(123), p.UserID)
+	assert.Equal(t, "测试密钥", p.Name)
+	assert.Equal(t, auth.credentialID, p.CredentialID)
+	assert.Equal(t, "none", p.AttestationType)
+	assert.Equal(t, []string{"internal"}, p.Transports)
+	// userHandle 是用户 ID
+	assert.Equal(t, []byte("123"), auth.userHandle)
+}
+
+func Test_passkeyService_Login(t *testing.T) {
+	testCases := []struct {
+		name string
+		// before 在调用 Get 之前修改验证器
+		before func(auth *softAuthenticator)
+		// replay 用同一个会话登录两次
+		replay bool
+
+		wantUID int64
+		wantErr error
+	}{
+		{
+			name:    "登录成功",
+			wantUID: 123,
+		},
+		{
+			name: "来源不对",
+			before: func(auth *softAuthenticator) {
+				auth.origin = "https://evil.com"
+			},
+			wantErr: ErrPasskeyVerifyFailed,
+		},
+		{
+			name: "签名计数回退",
+			before: func(auth *softAuthenticator) {
+				// 复制出来的密钥计数和原来的一样
+				auth.signCount = 4
+			},
+			wantErr: ErrPasskeyVerifyFailed,
+		},
+		{
+			name:    "会话不能重复使用",
+			replay:  true,
+			wantErr: ErrPasskeySessionInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mocksvc.NewMockPasskeyRepository(ctrl)
+			fakeSessions(repo)
+			svc := NewPasskeyService(repo, newTestWebAuthn(t), logger.NewNopLogger())
+			auth := newSoftAuthenticator(t)
+			p := register(t, svc, repo, auth, domain.User{ID: 123, Email: "tom@qq.com"})
+			// 之前已经登录过 5 次
+			p.SignCount = 5
+			auth.signCount = 5
+
+			repo.EXPECT().FindByUser(gomock.Any(), int64(123)).Return([]domain.Passkey{p}, nil).AnyTimes()
+			repo.EXPECT().UpdateUsage(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, updated domain.Passkey) error {
+					assert.Equal(t, p.ID, updated.ID)
+					assert.Equal(t, uint32(6), updated.SignCount)
+					return nil
+				}).AnyTimes()
+
+			assertion, session, err := svc.BeginLogin(context.Background())
+			require.NoError(t, err)
+			if tc.before != nil {
+				tc.before(auth)
+			}
+			parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(auth.Get(t, assertion)))
+			require.NoError(t, err)
+			if tc.replay {
+				_, err = svc.FinishLogin(context.Background(), session, parsed)
+				require.NoError(t, err)
+			}
+			uid, err := svc.FinishLogin(context.Background(), session, parsed)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantUID, uid)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+	"webook/internal/repository"
+)
+
+// RoleService 签发 token 的时候查询用户的角色，放到 claims 里面
+type RoleService interface {
+	Roles(ctx context.Context, uid int64) ([]string, error)
+}
+
+type roleService struct {
+	repo repository.RoleRepository
+}
+
+func NewRoleService(repo repository.RoleRepository) RoleService {
+	return &roleService{repo: repo}
+}
+
+func (svc *roleService) Roles(ctx context.Context, uid int64) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "RoleService.Roles")
+	defer span.End()
+	return svc.repo.Roles(ctx, uid)
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/service/notify"
+	"webook/pkg/geoip"
+	"webook/pkg/logger"
+)
+
+// notifyTimeout 通知是异步发的，不能无限等下去
+const notifyTimeout = time.Second * 10
+
+// SecurityEventService 认证相关的安全事件，回答“谁在什么地方登录了这个账号”
+type SecurityEventService interface {
+	// Record 补上地理位置，判断是不是新设备，新设备登录的时候异步通知用户
+	// 登录失败的事件没有用户 ID 的时候，按照登录用的邮箱或者手机号找用户
+	// 记录失败不影响业务，只打日志
+	Record(ctx context.Context, evt domain.SecurityEvent)
+	// List 用户自己的安全事件，按时间倒序，cursor 是上一页最后一个事件的 ID，第一页为 0
+	List(ctx context.Context, uid int64, cursor int64, limit int) ([]domain.SecurityEvent, error)
+}
+
+type securityEventService struct {
+	repo     repository.SecurityEventRepository
+	userRepo repository.UserRepository
+	// geo 为 nil 的时候不查地理位置
+	geo      *geoip.DB
+	notifier notify.Service
+	l        logger.Logger
+	now      func() time.Time
+}
+
+func NewSecurityEventService(repo repository.SecurityEventRepository, userRepo repository.UserRepository,
+	geo *geoip.DB, notifier notify.Service, l logger.Logger) SecurityEventService {
+	return &securityEventService{
+		repo:     repo,
+		userRepo: userRepo,
+		geo:      geo,
+		notifier: notifier,
+		l:        l,
+		now:      time.Now,
+	}
+}
+
+func (svc *securityEventService) Record(ctx context.Context, evt domain.SecurityEvent) {
+	ctx, span := tracer.Start(ctx, "SecurityEventService.Record")
+	defer span.End()
+	if evt.UserID == 0 {
+		evt.UserID = svc.resolveUser(ctx, evt)
+	}
+	loc := svc.geo.Lookup(evt.Client.IP)
+	evt.Geo = domain.GeoLocation{
+		CountryCode: loc.CountryCode,
+		Country:     loc.Country,
+		Region:      loc.Region,
+		City:        loc.City,
+	}
+	evt.CreatedAt = svc.now().UnixMilli()
+	if evt.Type == domain.SecurityLoginSuccess && evt.UserID != 0 {
+		newDevice, err := svc.newDevice(ctx, evt)
+		if err != nil {
+			// 判断不了就不通知，事件还是要记下来
+			svc.l.Warn(ctx, "判断新设备失败", logger.Int64("uid", evt.UserID), logger.Error(err))
+		}
+		evt.NewDevice = newDevice
+	}
+	if err := svc.repo.Create(ctx, evt); err != nil {
+		svc.l.Error(ctx, "记录安全事件失败", logger.Int64("uid", evt.UserID),
+			logger.String("type", evt.Type), logger.Error(err))
+		return
+	}
+	if evt.NewDevice {
+		svc.notifyNewDevice(ctx, evt)
+	}
+}
+
+// resolveUser 用户不存在或者查询失败的时候返回 0
+func (svc *securityEventService) resolveUser(ctx context.Context, evt domain.SecurityEvent) int64 {
+	if evt.Subject == "" {
+		return 0
+	}
+	var (
+		u   domain.User
+		err error
+	)
+	switch evt.Method {
+	case domain.LoginMethodPassword:
+		u, err = svc.userRepo.FindByEmail(ctx, evt.Subject)
+	case domain.LoginMethodSMS:
+		u, err = svc.userRepo.FindByPhone(ctx, evt.Subject)
+	default:
+		return 0
+	}
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		svc.l.Warn(ctx, "安全事件查询用户失败", logger.String("method", evt.Method), logger.Error(err))
+	}
+	return u.ID
+}
+
+// newDevice 没见过的设备，并且以前登录成功过，第一次登录不算
+func (svc *securityEventService) newDevice(ctx context.Context, evt domain.SecurityEvent) (bool, error) {
+	known, err := svc.repo.KnownDevice(ctx, evt.UserID, evt.Client)
+	if err != nil || known {
+		return false, err
+	}
+	return svc.repo.HasLogin(ctx, evt.UserID)
+}
+
+// notifyNewDevice 不阻塞登录，请求结束之后 gin.Context 会被复用，所以只保留链路信息
+func (svc *securityEventService) notifyNewDevice(ctx context.Context, evt domain.SecurityEvent) {
+	nctx := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+	go func() {
+		nctx, cancel := context.WithTimeout(nctx, notifyTimeout)
+		defer cancel()
+		if err := svc.notifier.NewDeviceLogin(nctx, evt); err != nil {
+			svc.l.Error(nctx, "新设备登录通知失败", logger.Int64("uid", evt.UserID), logger.Error(err))
+		}
+	}()
+}
+
+func (svc *securityEventService) List(ctx context.Context, uid int64, cursor int64,
+	limit int) ([]domain.SecurityEvent, error) {
+	ctx, span := tracer.Start(ctx, "SecurityEventService.List")
+	defer span.End()
+	return svc.repo.FindByUser(ctx, uid, cursor, limit)
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	mocksvc "webook/internal/repository/mock"
+	"webook/internal/service/notify/notify_mocksvc"
+	"webook/pkg/geoip"
+	"webook/pkg/logger"
+)
+
+func Test_securityEventService_Record(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	geo, err := geoip.Load(strings.NewReader(`1.0.0.0,1.0.0.255,CN,China,Beijing,Beijing`))
+	assert.NoError(t, err)
+	client := domain.ClientInfo{IP: "1.0.0.1", UserAgent: "Chrome", DeviceID: "device-1"}
+	location := domain.GeoLocation{CountryCode: "CN", Country: "China", Region: "Beijing", City: "Beijing"}
+	// 通知是异步的，发完之后关闭
+	notified := make(chan struct{})
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (repository.SecurityEventRepository,
+			repository.UserRepository, *notify_mocksvc.MockService)
+		evt domain.SecurityEvent
+
+		wantNotify bool
+	}{
+		{
+			name: "登录失败，按邮箱找到用户",
+			mock: func(ctrl *gomock.Controller) (repository.SecurityEventRepository,
+				repository.UserRepository, *notify_mocksvc.MockService) {
+				repo := mocksvc.NewMockSecurityEventRepository(ctrl)
+				userRepo := mocksvc.NewMockUserRepository(ctrl)
+				userRepo.EXPECT().FindByEmail(gomock.Any(), "tom@qq.com").Return(domain.User{ID: 1}, nil)
+				repo.EXPECT().Create(gomock.Any(), domain.SecurityEvent{
+					UserID:    1,
+					Type:      domain.SecurityLoginFailure,
+					Method:    domain.LoginMethodPassword,
+					Subject:   "tom@qq.com",
+					Reason:    "user.invalid_user_or_password",
+					Client:    client,
+					Geo:       location,
+					CreatedAt: now.UnixMilli(),
+				}).Return(nil)
+				return repo, userRepo, notify_mocksvc.NewMockService(ctrl)
+			},
+			evt: domain.SecurityEvent{
+				Type:    domain.SecurityLoginFailure,
+				Method:  domain.LoginMethodPassword,
+				Subject: "tom@qq.com",
+				Reason:  "user.invalid_user_or_password",
+				Client:  client,
+			},
+		},
+		{
+			name: "登录失败，用户不存在",
+			mock: func(ctrl *gomock.Controller) (repository.SecurityEventRepository,
+				repository.UserRepository, *notify_mocksvc.MockService) {
+				repo := mocksvc.NewMockSecurityEventRepository(ctrl)
+				userRepo := mocksvc.NewMockUserRepository(ctrl)
+				userRepo.EXPECT().FindByPhone(gomock.Any(), "13800000000").
+					Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().Create(gomock.Any(), domain.SecurityEvent{
+					Type:      domain.SecurityLoginFailure,
+					Method:    domain.LoginMethodSMS,
+					Subject:   "13800000000",
+					Client:    client,
+					Geo:       location,
+					CreatedAt: now.UnixMilli(),
+				}).Return(nil)
+				return repo, userRepo, notify_mocksvc.NewMockService(ctrl)
+			},
+			evt: domain.SecurityEvent{
+				Type:    domain.SecurityLoginFailure,
+				Method:  domain.LoginMethodSMS,
+				Subject: "13800000000",
+				Client:  client,
+			},
+		},
+		{
+			name: "新设备登录，通知用户",
+			mock: func(ctrl *gomock.Controller) (repository.SecurityEventRepository,
+				repository.UserRepository, *notify_mocksvc.MockService) {
+				repo := mocksvc.NewMockSecurityEventRepository(ctrl)
+				notifier := notify_mocksvc.NewMockService(ctrl)
+				repo.EXPECT().KnownDevice(gomock.Any(), int64(1), client).Return(false, nil)
+				repo.EXPECT().HasLogin(gomock.Any(), int64(1)).Return(true, nil)
+				evt := domain.SecurityEvent{
+					UserID:    1,
+					Type:      domain.SecurityLoginSuccess,
+					Method:    domain.LoginMethodPassword,
+					Client:    client,
+					Geo:       location,
+					NewDevice: true,
+					CreatedAt: now.UnixMilli(),
+				}
+				repo.EXPECT().Create(gomock.Any(), evt).Return(nil)
+				notifier.EXPECT().NewDeviceLogin(gomock.Any(), evt).
+					DoAndReturn(func(ctx context.Context, evt domain.SecurityEvent) error {
+						close(notified)
+						return nil
+					})
+				return repo, mocksvc.NewMockUserRepository(ctrl), notifier
+			},
+			evt: domain.SecurityEvent{
+				UserID: 1,
+				Type:   domain.SecurityLoginSuccess,
+				Method: domain.LoginMethodPassword,
+				Client: client,
+			},
+			wantNotify: true,
+		},
+		{
+			name: "登录过的设备",
+			mock: func(ctrl *gomock.Controller) (repository.SecurityEventRepository,
+				repository.UserRepository, *notify_mocksvc.MockService) {
+				repo := mocksvc.NewMockSecurityEventRepository(ctrl)
+				repo.EXPECT().KnownDevice(gomock.Any(), int64(1), client).Return(true, nil)
+				repo.EXPECT().Create(gomock.Any(), domain.SecurityEvent{
+					UserID:    1,
+					Type:      domain.SecurityLoginSuccess,
+					Method:    domain.LoginMethodPassword,
+					Client:    client,
+					Geo:       location,
+					CreatedAt: now.UnixMilli(),
+				}).Return(nil)
+				return repo, mocksvc.NewMockUserRepository(ctrl), notify_mocksvc.NewMockService(ctrl)
+			},
+			evt: domain.SecurityEvent{
+				UserID: 1,
+				Type:   domain.SecurityLoginSuccess,
+				Method: domain.LoginMethodPassword,
+				Client: client,
+			},
+		},
+		{
+			name: "第一次登录不算新设备",
+			mock: func(ctrl *gomock.Controller) (repository.SecurityEventRepository,
+				repository.UserRepository, *notify_mocksvc.MockService) {
+				repo := mocksvc.NewMockSecurityEventRepository(ctrl)
+				repo.EXPECT().KnownDevice(gomock.Any(), int64(1), client).Return(false, nil)
+				repo.EXPECT().HasLogin(gomock.Any(), int64(1)).Return(false, nil)
+				repo.EXPECT().Create(gomock.Any(), domain.SecurityEvent{
+					UserID:    1,
+					Type:      domain.SecurityLoginSuccess,
+					Method:    domain.LoginMethodPasskey,
+					Client:    client,
+					Geo:       location,
+					CreatedAt: now.UnixMilli(),
+				}).Return(nil)
+				return repo, mocksvc.NewMockUserRepository(ctrl), notify_mocksvc.NewMockService(ctrl)
+			},
+			evt: domain.SecurityEvent{
+				UserID: 1,
+				Type:   domain.SecurityLoginSuccess,
+				Method: domain.LoginMethodPasskey,
+				Client: client,
+			},
+		},
+		{
+			name: "判断新设备失败，照样记录",
+			mock: func(ctrl *gomock.Controller) (repository.SecurityEventRepository,
+				repository.UserRepository, *notify_mocksvc.MockService) {
+				repo := mocksvc.NewMockSecurityEventRepository(ctrl)
+				repo.EXPECT().KnownDevice(gomock.Any(), int64(1), client).Return(false, errors.New("mock db 错误"))
+				repo.EXPECT().Create(gomock.Any(), domain.SecurityEvent{
+					UserID:    1,
+					Type:      domain.SecurityLoginSuccess,
+					Method:    domain.LoginMethodPassword,
+					Client:    client,
+					Geo:       location,
+					CreatedAt: now.UnixMilli(),
+				}).Return(nil)
+				return repo, mocksvc.NewMockUserRepository(ctrl), notify_mocksvc.NewMockService(ctrl)
+			},
+			evt: domain.SecurityEvent{
+				UserID: 1,
+				Type:   domain.SecurityLoginSuccess,
+				Method: domain.LoginMethodPassword,
+				Client: client,
+			},
+		},
+		{
+			name: "保存失败，不通知",
+			mock: func(ctrl *gomock.Controller) (repository.SecurityEventRepository,
+				repository.UserRepository, *notify_mocksvc.MockService) {
+				repo := mocksvc.NewMockSecurityEventRepository(ctrl)
+				repo.EXPECT().KnownDevice(gomock.Any(), int64(1), client).Return(false, nil)
+				repo.EXPECT().HasLogin(gomock.Any(), int64(1)).Return(true, nil)
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(errors.New("mock db 错误"))
+				return repo, mocksvc.NewMockUserRepository(ctrl), notify_mocksvc.NewMockService(ctrl)
+			},
+			evt: domain.SecurityEvent{
+				UserID: 1,
+				Type:   domain.SecurityLoginSuccess,
+				Method: domain.LoginMethodPassword,
+				Client: client,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo, userRepo, notifier := tc.mock(ctrl)
+			svc := NewSecurityEventService(repo, userRepo, geo, notifier, logger.NewNopLogger())
+			svc.(*securityEventService).now = func() time.Time { return now }
+			svc.Record(context.Background(), tc.evt)
+			if tc.wantNotify {
+				select {
+				case <-notified:
+				case <-time.After(time.Second):
+					t.Fatal("没有发送新设备登录通知")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"time"
+	"webook/internal/repository"
+	"webook/pkg/logger"
+)
+
+// SessionService 强制下线，已经签发的 token 不等过期就作废
+type SessionService interface {
+	// Revoke 用户现有的 access token 和 refresh token 都失效
+	Revoke(ctx context.Context, uid int64) error
+	// Valid issuedAt 是 token 的签发时间
+	// 没有被强制下线，账号也没有被禁用或者封禁才有效
+	Valid(ctx context.Context, uid int64, issuedAt time.Time) bool
+}
+
+type sessionService struct {
+	repo     repository.SessionRepository
+	userRepo repository.UserRepository
+	l        logger.Logger
+	now      func() time.Time
+}
+
+func NewSessionService(repo repository.SessionRepository, userRepo repository.UserRepository,
+	l logger.Logger) SessionService {
+	return &sessionService{
+		repo:     repo,
+		userRepo: userRepo,
+		l:        l,
+		now:      time.Now,
+	}
+}
+
+func (svc *sessionService) Revoke(ctx context.Context, uid int64) error {
+	ctx, span := tracer.Start(ctx, "SessionService.Revoke")
+	defer span.End()
+	return svc.repo.Revoke(ctx, uid, svc.now())
+}
+
+func (svc *sessionService) Valid(ctx context.Context, uid int64, issuedAt time.Time) bool {
+	ctx, span := tracer.Start(ctx, "SessionService.Valid")
+	defer span.End()
+	revokedAt, err := svc.repo.RevokedAt(ctx, uid)
+	if err != nil {
+		// Redis 出问题的时候不管强制下线，不能让所有人都掉线，账号状态还是要查
+		svc.l.Warn(ctx, "查询强制下线记录失败", logger.Int64("uid", uid), logger.Error(err))
+		return svc.active(ctx, uid)
+	}
+	// 签发时间和强制下线时间都精确到毫秒，强制下线之后马上重新登录拿到的 token 不受影响
+	if !revokedAt.IsZero() && issuedAt.Before(revokedAt) {
+		return false
+	}
+	return svc.active(ctx, uid)
+}
+
+// active 每个请求都要查，走用户缓存，修改状态的时候会删缓存
+// 缓存不可用的时候会查库，查不到状态说明数据库也出问题了，不能放行被禁用或者封禁的账号
+func (svc *sessionService) active(ctx context.Context, uid int64) bool {
+	u, err := svc.userRepo.FindByID(ctx, uid)
+	if err == repository.ErrUserNotFound {
+		return false
+	}
+	if err != nil {
+		svc.l.Error(ctx, "查询账号状态失败", logger.Int64("uid", uid), logger.Error(err))
+		return false
+	}
+	return CheckStatus(u, svc.now()) == nil
+}
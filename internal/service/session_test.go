@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	mocksvc "webook/internal/repository/mock"
+	"webook/pkg/logger"
+)
+
+func Test_sessionService_Valid(t *testing.T) {
+	revokedAt := time.Unix(1700000000, 500_000_000)
+	now := revokedAt.Add(time.Hour)
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) (repository.SessionRepository, repository.UserRepository)
+		issuedAt time.Time
+
+		wantValid bool
+	}{
+		{
+			name: "没有强制下线过",
+			mock: func(ctrl *gomock.Controller) (repository.SessionRepository, repository.UserRepository) {
+				repo := mocksvc.NewMockSessionRepository(ctrl)
+				userRepo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().RevokedAt(gomock.Any(), int64(1)).Return(time.Time{}, nil)
+				userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				return repo, userRepo
+			},
+			issuedAt:  time.Unix(1600000000, 0),
+			wantValid: true,
+		},
+		{
+			name: "强制下线之前签发的",
+			mock: func(ctrl *gomock.Controller) (repository.SessionRepository, repository.UserRepository) {
+				repo := mocksvc.NewMockSessionRepository(ctrl)
+				userRepo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().RevokedAt(gomock.Any(), int64(1)).Return(revokedAt, nil)
+				return repo, userRepo
+			},
+			issuedAt: revokedAt.Add(-time.Minute),
+		},
+		{
+			name: "同一秒内强制下线之前签发的",
+			mock: func(ctrl *gomock.Controller) (repository.SessionRepository, repository.UserRepository) {
+				repo := mocksvc.NewMockSessionRepository(ctrl)
+				userRepo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().RevokedAt(gomock.Any(), int64(1)).Return(revokedAt, nil)
+				return repo, userRepo
+			},
+			issuedAt: revokedAt.Add(-time.Millisecond * 200),
+		},
+		{
+			name: "强制下线之后同一秒内重新登录的",
+			mock: func(ctrl *gomock.Controller) (repository.SessionRepository, repository.UserRepository) {
+				repo := mocksvc.NewMockSessionRepository(ctrl)
+				userRepo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().RevokedAt(gomock.Any(), int64(1)).Return(revokedAt, nil)
+				userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				return repo, userRepo
+			},
+			issuedAt:  revokedAt.Add(time.Millisecond * 200),
+			wantValid: true,
+		},
+		{
+			name: "强制下线之后重新登录的",
+			mock: func(ctrl *gomock.Controller) (repository.SessionRepository, repository.UserRepository) {
+				repo := mocksvc.NewMockSessionRepository(ctrl)
+				userRepo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().RevokedAt(gomock.Any(), int64(1)).Return(revokedAt, nil)
+				userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				return repo, userRepo
+			},
+			issuedAt:  revokedAt.Add(time.Second),
+			wantValid: true,
+		},
+		{
+			name: "Redis 出错的时候放行",
+			mock: func(ctrl *gomock.Controller) (repository.SessionRepository, repository.UserRepository) {
+				repo := mocksvc.NewMockSessionRepository(ctrl)
+				userRepo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().RevokedAt(gomock.Any(), int64(1)).Return(time.Time{}, errors.New("redis 错误"))
+				userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				return repo, userRepo
+			},
+			issuedAt:  revokedAt.Add(-time.Minute),
+			wantValid: true,
+		},
+		{
+			name: "账号被禁用",
+			mock: func(ctrl *gomock.Controller) (repository.SessionRepository, repository.UserRepository) {
+				repo := mocksvc.NewMockSessionRepository(ctrl)
+				userRepo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().RevokedAt(gomock.Any(), int64(1)).Return(time.Time{}, nil)
+				userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).
+					Return(domain.User{ID: 1, Status: domain.UserStatusDisabled}, nil)
+				return repo, userRepo
+			},
+			issuedAt: revokedAt,
+		},
+		{
+			name: "封禁期内",
+			mock: func(ctrl *gomock.Controller) (repository.SessionRepository, repository.UserRepository) {
+				repo := mocksvc.NewMockSessionRepository(ctrl)
+				userRepo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().RevokedAt(gomock.Any(), int64(1)).Return(time.Time{}, nil)
+				userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{
+					ID: 1, Status: domain.UserStatusBanned, BannedUntil: now.Add(time.Hour).UnixMilli(),
+				}, nil)
+				return repo, userRepo
+			},
+			issuedAt: revokedAt,
+		},
+		{
+			name: "封禁已经过期",
+			mock: func(ctrl *gomock.Controller) (repository.SessionRepository, repository.UserRepository) {
+				repo := mocksvc.NewMockSessionRepository(ctrl)
+				userRepo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().RevokedAt(gomock.Any(), int64(1)).Return(time.Time{}, nil)
+				userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{
+					ID: 1, Status: domain.UserStatusBanned, BannedUntil: now.Add(-time.Hour).UnixMilli(),
+				}, nil)
+				return repo, userRepo
+			},
+			issuedAt:  revokedAt,
+			wantValid: true,
+		},
+		{
+			name: "用户已经不存在",
+			mock: func(ctrl *gomock.Controller) (repository.SessionRepository, repository.UserRepository) {
+				repo := mocksvc.NewMockSessionRepository(ctrl)
+				userRepo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().RevokedAt(gomock.Any(), int64(1)).Return(time.Time{}, nil)
+				userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{}, repository.ErrUserNotFound)
+				return repo, userRepo
+			},
+			issuedAt: revokedAt,
+		},
+		{
+			name: "查询账号状态出错的时候拒绝",
+			mock: func(ctrl *gomock.Controller) (repository.SessionRepository, repository.UserRepository) {
+				repo := mocksvc.NewMockSessionRepository(ctrl)
+				userRepo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().RevokedAt(gomock.Any(), int64(1)).Return(time.Time{}, nil)
+				userRepo.EXPECT().FindByID(gomock.Any(), int64(1)).Return(domain.User{}, errors.New("db 错误"))
+				return repo, userRepo
+			},
+			issuedAt: revokedAt,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo, userRepo := tc.mock(ctrl)
+			svc := NewSessionService(repo, userRepo, logger.NewNopLogger())
+			svc.(*sessionService).now = func() time.Time { return now }
+			assert.Equal(t, tc.wantValid, svc.Valid(context.Background(), 1, tc.issuedAt))
+		})
+	}
+}
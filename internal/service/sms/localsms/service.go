@@ -2,17 +2,24 @@ package localsms
 
 import (
 	"context"
-	"log"
+	"strings"
+	"webook/pkg/logger"
 )
 
+// Service 本地开发用，不真的发短信，只打印日志
 type Service struct {
+	l logger.Logger
 }
 
-func NewService() *Service {
-	return &Service{}
+func NewService(l logger.Logger) *Service {
+	return &Service{
+		l: l,
+	}
 }
 
 func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
-	log.Println("验证码是", args)
+	// 本地开发需要从日志里拿到验证码，所以 args 不脱敏
+	s.l.Info(ctx, "发送短信", logger.String("tpl", tplId),
+		logger.Any("args", args), logger.String("phone", strings.Join(numbers, ",")))
 	return nil
 }
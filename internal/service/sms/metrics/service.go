@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"webook/internal/service/sms"
+)
+
+// PrometheusSMSService 按服务商、模板和结果统计短信发送次数
+type PrometheusSMSService struct {
+	svc      sms.Service
+	provider string
+	vector   *prometheus.CounterVec
+}
+
+// NewPrometheusSMSService vector 的 label 必须是 provider、tpl、result，见 NewSendCounter
+func NewPrometheusSMSService(svc sms.Service, provider string, vector *prometheus.CounterVec) *PrometheusSMSService {
+	return &PrometheusSMSService{
+		svc:      svc,
+		provider: provider,
+		vector:   vector,
+	}
+}
+
+// NewSendCounter 多个服务商共用一个 CounterVec，只需要注册一次
+func NewSendCounter(namespace, subsystem string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "sends_total",
+		Help:      "短信发送次数",
+	}, []string{"provider", "tpl", "result"})
+}
+
+func (p *PrometheusSMSService) Send(ctx context.Context, tplID string, args []string, numbers ...string) error {
+	err := p.svc.Send(ctx, tplID, args, numbers...)
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	p.vector.WithLabelValues(p.provider, tplID, result).Inc()
+	return err
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"webook/internal/service/sms"
+	"webook/internal/service/sms/sms_mocksvc"
+)
+
+func TestPrometheusSMSService_Send(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) sms.Service
+		wantErr error
+
+		wantResult string
+	}{
+		{
+			name: "发送成功",
+			mock: func(ctrl *gomock.Controller) sms.Service {
+				svc := sms_mocksvc.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), "tpl-1", []string{"123456"}, "15212345678").
+					Return(nil)
+				return svc
+			},
+			wantResult: "ok",
+		},
+		{
+			name: "发送失败",
+			mock: func(ctrl *gomock.Controller) sms.Service {
+				svc := sms_mocksvc.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), "tpl-1", []string{"123456"}, "15212345678").
+					Return(errors.New("provider err"))
+				return svc
+			},
+			wantErr:    errors.New("provider err"),
+			wantResult: "error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			vector := NewSendCounter("webook", "sms")
+			svc := NewPrometheusSMSService(tc.mock(ctrl), "tencent", vector)
+			err := svc.Send(context.Background(), "tpl-1", []string{"123456"}, "15212345678")
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, float64(1), testutil.ToFloat64(vector.WithLabelValues("tencent", "tpl-1", tc.wantResult)))
+			assert.Equal(t, 1, testutil.CollectAndCount(vector))
+		})
+	}
+}
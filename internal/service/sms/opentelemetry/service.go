@@ -0,0 +1,42 @@
+package opentelemetry
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"webook/internal/service/sms"
+)
+
+// Service 给每次发送短信创建一个 span，不记录参数和手机号
+type Service struct {
+	svc      sms.Service
+	provider string
+	tracer   trace.Tracer
+}
+
+func NewService(svc sms.Service, provider string) *Service {
+	return &Service{
+		svc:      svc,
+		provider: provider,
+		tracer:   otel.Tracer("webook/internal/service/sms/opentelemetry"),
+	}
+}
+
+func (s *Service) Send(ctx context.Context, tplID string, args []string, numbers ...string) error {
+	ctx, span := s.tracer.Start(ctx, "sms.Send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("sms.provider", s.provider),
+			attribute.String("sms.tpl", tplID),
+			attribute.Int("sms.numbers", len(numbers)),
+		))
+	defer span.End()
+	err := s.svc.Send(ctx, tplID, args, numbers...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"webook/internal/service/sms"
+	"webook/internal/service/sms/sms_mocksvc"
+)
+
+func TestService_Send(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) sms.Service
+		wantErr  error
+		wantCode codes.Code
+	}{
+		{
+			name: "发送成功",
+			mock: func(ctrl *gomock.Controller) sms.Service {
+				svc := sms_mocksvc.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), "tpl-1", []string{"123456"}, "15212345678").
+					Return(nil)
+				return svc
+			},
+			wantCode: codes.Unset,
+		},
+		{
+			name: "发送失败",
+			mock: func(ctrl *gomock.Controller) sms.Service {
+				svc := sms_mocksvc.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), "tpl-1", []string{"123456"}, "15212345678").
+					Return(errors.New("provider err"))
+				return svc
+			},
+			wantErr:  errors.New("provider err"),
+			wantCode: codes.Error,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			svc := NewService(tc.mock(ctrl), "tencent")
+			err := svc.Send(context.Background(), "tpl-1", []string{"123456"}, "15212345678")
+			assert.Equal(t, tc.wantErr, err)
+
+			spans := recorder.Ended()
+			require.NotEmpty(t, spans)
+			span := spans[len(spans)-1]
+			assert.Equal(t, "sms.Send", span.Name())
+			assert.Equal(t, tc.wantCode, span.Status().Code)
+		})
+	}
+}
@@ -16,11 +16,11 @@ type RateLimitSMSService struct {
 }
 
 func (r RateLimitSMSService) Send(ctx context.Context, tplID string, args []string, numbers ...string) error {
-	limited, err := r.limiter.Limit(ctx, r.key)
+	res, err := r.limiter.Limit(ctx, r.key)
 	if err != nil {
 		return err
 	}
-	if limited {
+	if res.Limited {
 		return ErrRateLimit
 	}
 	return r.svc.Send(ctx, tplID, args, numbers...)
@@ -23,7 +23,7 @@ func TestRateLimitSMSService_Send(t *testing.T) {
 			mock: func(ctrl *gomock.Controller) (sms.Service, limiter.Limiter) {
 				svc := sms_mocksvc.NewMockService(ctrl)
 				l := limiter_mocksvc.NewMockLimiter(ctrl)
-				l.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(false, nil)
+				l.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(limiter.Result{}, nil)
 				svc.EXPECT().
 					Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 					Return(nil)
@@ -36,7 +36,7 @@ func TestRateLimitSMSService_Send(t *testing.T) {
 			mock: func(ctrl *gomock.Controller) (sms.Service, limiter.Limiter) {
 				svc := sms_mocksvc.NewMockService(ctrl)
 				l := limiter_mocksvc.NewMockLimiter(ctrl)
-				l.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(true, nil)
+				l.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(limiter.Result{Limited: true}, nil)
 				return svc, l
 			},
 			wantErr: ErrRateLimit,
@@ -46,7 +46,7 @@ func TestRateLimitSMSService_Send(t *testing.T) {
 			mock: func(ctrl *gomock.Controller) (sms.Service, limiter.Limiter) {
 				svc := sms_mocksvc.NewMockService(ctrl)
 				l := limiter_mocksvc.NewMockLimiter(ctrl)
-				l.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(false, errors.New("limiter err"))
+				l.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(limiter.Result{}, errors.New("limiter err"))
 				return svc, l
 			},
 			wantErr: errors.New("limiter err"),
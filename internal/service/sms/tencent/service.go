@@ -2,29 +2,30 @@ package tencent
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
 	sms "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms/v20210111"
+	"webook/pkg/logger"
 )
 
 type Service struct {
 	client    *sms.Client
 	appID     *string
 	signature *string
+	l         logger.Logger
 }
 
 func (s *Service) Send(ctx context.Context, tplID string, args []string, numbers ...string) error {
 	request := sms.NewSendSmsRequest()
-	request.SmsSdkAppId = common.StringPtr("1400787878")
-	request.SignName = common.StringPtr("腾讯云")
+	request.SmsSdkAppId = s.appID
+	request.SignName = s.signature
 	request.TemplateId = common.StringPtr(tplID)
 	request.TemplateParamSet = common.StringPtrs(args)
 	request.PhoneNumberSet = common.StringPtrs(numbers)
 	response, err := s.client.SendSms(request)
 
 	if err != nil {
-		fmt.Printf("%s", err.Error())
+		s.l.Error(ctx, "调用腾讯云短信接口失败", logger.String("tpl", tplID), logger.Error(err))
 		return err
 	}
 	for _, status := range response.Response.SendStatusSet {
@@ -32,16 +33,16 @@ func (s *Service) Send(ctx context.Context, tplID string, args []string, numbers
 			return fmt.Errorf("send sms failed, code=%s, message=%s", *status.Code, *status.Message)
 		}
 	}
-	b, _ := json.Marshal(response.Response)
-	// 打印返回的json字符串
-	fmt.Printf("%s", b)
+	s.l.Debug(ctx, "腾讯云短信发送成功", logger.String("tpl", tplID),
+		logger.Any("sms_request_id", response.Response.RequestId))
 	return nil
 }
 
-func NewService(client *sms.Client, appID string, signName string) *Service {
+func NewService(client *sms.Client, appID string, signName string, l logger.Logger) *Service {
 	return &Service{
 		client:    client,
 		appID:     &appID,
 		signature: &signName,
+		l:         l,
 	}
 }
@@ -3,37 +3,80 @@ package service
 import (
 	"context"
 	"errors"
+	"strconv"
+	"time"
 	"webook/internal/domain"
 	"webook/internal/repository"
+	"webook/pkg/logger"
+	"webook/pkg/password"
 
-	"golang.org/x/crypto/bcrypt"
+	"go.opentelemetry.io/otel"
 )
 
 // var ErrUserDuplicateEmail = repository.ErrUserDuplicateEmail
 var ErrUserDuplicated = repository.ErrUserDuplicated
+var ErrUserNotFound = repository.ErrUserNotFound
 var ErrInvalidUserOrPassword = errors.New("邮箱或密码错误")
+var ErrPasswordIncorrect = errors.New("原密码错误")
+
+var (
+	ErrUserDisabled = errors.New("账号已被禁用")
+	ErrUserBanned   = errors.New("账号已被封禁")
+)
+
+// ErrIdentityLinked 第三方账号已经关联了其他用户
+var ErrIdentityLinked = errors.New("第三方账号已经关联了其他用户")
+
+// tracer service 层的 span，调用方传进来的 ctx 里有 span 就挂在它下面
+var tracer = otel.Tracer("webook/internal/service")
 
 type UserService interface {
 	SignUp(ctx context.Context, u domain.User) error
 	Login(ctx context.Context, email, password string) (domain.User, error)
 	Profile(ctx context.Context, id int64) (domain.User, error)
 	FindOrCreate(ctx context.Context, phone string) (domain.User, error)
-	FindOrCreateByWechat(context.Context, domain.WechatInfo) (domain.User, error)
+	// FindOrCreateByWechat 创建用户的时候用 profile 填充昵称和头像，已有的用户不覆盖
+	FindOrCreateByWechat(ctx context.Context, info domain.WechatInfo, profile domain.WechatProfile) (domain.User, error)
+	// FindOrCreateByIdentity 第三方登录，没有关联用户的话创建一个
+	FindOrCreateByIdentity(ctx context.Context, identity domain.Identity) (domain.User, error)
+	// LinkIdentity 已登录的用户绑定第三方账号，重复绑定同一个账号不报错
+	LinkIdentity(ctx context.Context, uid int64, identity domain.Identity) error
+	// UpdateLocale 修改语言偏好，locale 由调用方校验
+	UpdateLocale(ctx context.Context, id int64, locale string) error
+	// ChangePassword 修改密码，新密码不满足策略的时候返回 *password.PolicyError
+	ChangePassword(ctx context.Context, id int64, oldPassword, newPassword string) error
+	// BindPhone 绑定已经验证过的手机号，手机号是其他用户的时候返回 ErrUserDuplicated
+	BindPhone(ctx context.Context, id int64, phone string) error
 }
 
 type userService struct {
-	repo repository.UserRepository
+	repo    repository.UserRepository
+	history repository.PasswordHistoryRepository
+	policy  *password.Policy
+	hasher  password.Hasher
+	l       logger.Logger
 }
 
-func NewUserService(repo repository.UserRepository) UserService {
+func NewUserService(repo repository.UserRepository, history repository.PasswordHistoryRepository,
+	policy *password.Policy, hasher password.Hasher, l logger.Logger) UserService {
 	return &userService{
-		repo: repo,
+		repo:    repo,
+		history: history,
+		policy:  policy,
+		hasher:  hasher,
+		l:       l,
 	}
 }
 
+// SignUp 密码不满足策略的时候返回 *password.PolicyError
 func (svc *userService) SignUp(ctx context.Context, u domain.User) error {
+	ctx, span := tracer.Start(ctx, "UserService.SignUp")
+	defer span.End()
+	if err := svc.policy.Check(u.Password, u.Email); err != nil {
+		return err
+	}
 	// 加密
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+	hashedPassword, err := svc.hasher.Hash(u.Password)
 	if err != nil {
 		return err
 	}
@@ -43,37 +86,114 @@ func (svc *userService) SignUp(ctx context.Context, u domain.User) error {
 }
 
 func (svc *userService) Login(ctx context.Context, email, password string) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.Login")
+	defer span.End()
 	// 先查询有没有这个用户
 	user, err := svc.repo.FindByEmail(ctx, email)
 	if err == repository.ErrUserNotFound {
-		// todo
+		svc.l.Debug(ctx, "登录失败，用户不存在")
 		return domain.User{}, ErrInvalidUserOrPassword
 	}
 	if err != nil {
 		return domain.User{}, err
 	}
-	// 比较密码
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
-	if err != nil {
-		// todo 打印日志
+	// 比较密码，手机号注册的用户没有密码，哈希格式不对也当成密码错误
+	ok, err := svc.hasher.Verify(user.Password, password)
+	if !ok {
+		svc.l.Debug(ctx, "登录失败，密码错误", logger.Int64("uid", user.ID), logger.Error(err))
 		return domain.User{}, ErrInvalidUserOrPassword
 	}
-	// 没问题
+	// 密码对了才告诉调用方账号被禁用，免得用来探测账号
+	if err = CheckStatus(user, time.Now()); err != nil {
+		svc.l.Debug(ctx, "登录失败，账号不可用", logger.Int64("uid", user.ID), logger.Error(err))
+		return domain.User{}, err
+	}
+	// 哈希算法或者参数调整过，趁着有明文重新哈希
+	if svc.hasher.NeedsRehash(user.Password) {
+		svc.rehash(ctx, user.ID, password)
+	}
 	return user, nil
 }
 
+// rehash 失败不影响登录，下次登录再试
+func (svc *userService) rehash(ctx context.Context, id int64, pwd string) {
+	hash, err := svc.hasher.Hash(pwd)
+	if err == nil {
+		err = svc.repo.UpdatePassword(ctx, id, hash)
+	}
+	if err != nil {
+		svc.l.Warn(ctx, "重新哈希密码失败", logger.Int64("uid", id), logger.Error(err))
+	}
+}
+
+func (svc *userService) ChangePassword(ctx context.Context, id int64, oldPassword, newPassword string) error {
+	ctx, span := tracer.Start(ctx, "UserService.ChangePassword")
+	defer span.End()
+	user, err := svc.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if ok, _ := svc.hasher.Verify(user.Password, oldPassword); !ok {
+		return ErrPasswordIncorrect
+	}
+	if err = svc.policy.Check(newPassword, user.Email); err != nil {
+		return err
+	}
+	if err = svc.checkReused(ctx, user, newPassword); err != nil {
+		return err
+	}
+	hash, err := svc.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	if err = svc.repo.UpdatePassword(ctx, id, hash); err != nil {
+		return err
+	}
+	// 当前密码进入历史记录，失败的话只是少检查一个历史密码
+	if err = svc.history.Add(ctx, id, user.Password); err != nil {
+		svc.l.Warn(ctx, "记录历史密码失败", logger.Int64("uid", id), logger.Error(err))
+	}
+	return nil
+}
+
+// checkReused 和当前密码以及最近 HistorySize-1 个历史密码比较
+func (svc *userService) checkReused(ctx context.Context, user domain.User, pwd string) error {
+	size := svc.policy.HistorySize
+	if size <= 0 {
+		return nil
+	}
+	hashes := []string{user.Password}
+	if size > 1 {
+		recent, err := svc.history.Recent(ctx, user.ID, size-1)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, recent...)
+	}
+	for _, hash := range hashes {
+		if ok, _ := svc.hasher.Verify(hash, pwd); ok {
+			return &password.PolicyError{Rule: password.RuleReused, Param: strconv.Itoa(size)}
+		}
+	}
+	return nil
+}
+
 func (svc *userService) Profile(ctx context.Context, id int64) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.Profile")
+	defer span.End()
 	user, err := svc.repo.FindByID(ctx, id)
 	return user, err
 }
 
 func (svc *userService) FindOrCreate(ctx context.Context, phone string) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.FindOrCreate")
+	defer span.End()
 	// 先找一下，大部分用户是已经存在的用户
 	u, err := svc.repo.FindByPhone(ctx, phone)
 	if err != repository.ErrUserNotFound {
-		// err == nil, 找到User，直接返回
+		// err == nil, 找到User，检查状态之后返回
 		// err != nil，系统错误，直接返回
-		return u, err
+		return activeUser(u, err)
 	}
 	// 用户没找到，注册
 	err = svc.repo.Create(ctx, domain.User{
@@ -86,19 +206,135 @@ func (svc *userService) FindOrCreate(ctx context.Context, phone string) (domain.
 	}
 	// 要么 err ==nil，要么ErrDuplicateUser，也代表用户存在
 	// 主从延迟，理论上来讲，强制走主库
-	return svc.repo.FindByPhone(ctx, phone)
+	return activeUser(svc.repo.FindByPhone(ctx, phone))
 }
 
-func (svc *userService) FindOrCreateByWechat(ctx context.Context, wechatInfo domain.WechatInfo) (domain.User, error) {
-	u, err := svc.repo.FindByWechat(ctx, wechatInfo.OpenID)
+func (svc *userService) FindOrCreateByWechat(ctx context.Context, wechatInfo domain.WechatInfo,
+	profile domain.WechatProfile) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.FindOrCreateByWechat")
+	defer span.End()
+	u, err := svc.findByWechat(ctx, wechatInfo)
 	if err != repository.ErrUserNotFound {
-		return u, err
+		return activeUser(u, err)
 	}
 	err = svc.repo.Create(ctx, domain.User{
+		Nickname:   profile.Nickname,
+		Avatar:     profile.Avatar,
 		WechatInfo: wechatInfo,
 	})
 	if err != nil && err != ErrUserDuplicated {
 		return domain.User{}, err
 	}
-	return svc.repo.FindByWechat(ctx, wechatInfo.OpenID)
+	return activeUser(svc.findByWechat(ctx, wechatInfo))
+}
+
+// findByWechat 先按 unionid 找，网站应用和小程序登录的是同一个用户
+func (svc *userService) findByWechat(ctx context.Context, info domain.WechatInfo) (domain.User, error) {
+	if info.UnionID != "" {
+		u, err := svc.repo.FindByWechatUnionID(ctx, info.UnionID)
+		if err != repository.ErrUserNotFound {
+			return u, err
+		}
+	}
+	return svc.repo.FindByWechat(ctx, info.OpenID)
+}
+
+func (svc *userService) FindOrCreateByIdentity(ctx context.Context, identity domain.Identity) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.FindOrCreateByIdentity")
+	defer span.End()
+	// 微信的 openid 和 unionid 一直存在用户表上，小程序登录也要按 unionid 找到同一个用户
+	if identity.Provider == domain.ProviderWechat {
+		return svc.FindOrCreateByWechat(ctx, toWechatInfo(identity), domain.WechatProfile{
+			Nickname: identity.Nickname,
+			Avatar:   identity.Avatar,
+		})
+	}
+	u, err := svc.repo.FindByIdentity(ctx, identity)
+	if err != repository.ErrUserNotFound {
+		return activeUser(u, err)
+	}
+	// 不按邮箱自动关联已有用户，也不把邮箱填到新用户上
+	// 第三方的邮箱未必验证过，关联了就能登录别人的账号
+	err = svc.repo.CreateWithIdentity(ctx, domain.User{}, identity)
+	// 并发登录的时候另一个请求已经创建了
+	if err != nil && err != repository.ErrIdentityDuplicated {
+		return domain.User{}, err
+	}
+	return activeUser(svc.repo.FindByIdentity(ctx, identity))
+}
+
+// CheckStatus 账号被禁用或者还在封禁期内的时候不能登录，已经注销的当作不存在
+func CheckStatus(u domain.User, now time.Time) error {
+	switch u.StatusAt(now) {
+	case domain.UserStatusDisabled:
+		return ErrUserDisabled
+	case domain.UserStatusBanned:
+		return ErrUserBanned
+	case domain.UserStatusDeleted:
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// activeUser 包装查询结果，查到了但是账号不可用的时候返回错误
+func activeUser(u domain.User, err error) (domain.User, error) {
+	if err != nil {
+		return domain.User{}, err
+	}
+	if err = CheckStatus(u, time.Now()); err != nil {
+		return domain.User{}, err
+	}
+	return u, nil
+}
+
+func (svc *userService) LinkIdentity(ctx context.Context, uid int64, identity domain.Identity) error {
+	ctx, span := tracer.Start(ctx, "UserService.LinkIdentity")
+	defer span.End()
+	if identity.Provider == domain.ProviderWechat {
+		return svc.linkWechat(ctx, uid, toWechatInfo(identity))
+	}
+	err := svc.repo.LinkIdentity(ctx, uid, identity)
+	if err != repository.ErrIdentityDuplicated {
+		return err
+	}
+	u, err := svc.repo.FindByIdentity(ctx, identity)
+	if err != nil {
+		return err
+	}
+	if u.ID != uid {
+		return ErrIdentityLinked
+	}
+	return nil
+}
+
+func (svc *userService) linkWechat(ctx context.Context, uid int64, info domain.WechatInfo) error {
+	u, err := svc.findByWechat(ctx, info)
+	switch {
+	case err == nil && u.ID == uid:
+		return nil
+	case err == nil:
+		return ErrIdentityLinked
+	case err != repository.ErrUserNotFound:
+		return err
+	}
+	return svc.repo.BindWechat(ctx, uid, info)
+}
+
+func toWechatInfo(identity domain.Identity) domain.WechatInfo {
+	return domain.WechatInfo{
+		OpenID:  identity.Subject,
+		UnionID: identity.UnionID,
+	}
+}
+
+func (svc *userService) BindPhone(ctx context.Context, id int64, phone string) error {
+	ctx, span := tracer.Start(ctx, "UserService.BindPhone")
+	defer span.End()
+	return svc.repo.UpdatePhone(ctx, id, phone)
+}
+
+func (svc *userService) UpdateLocale(ctx context.Context, id int64, locale string) error {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateLocale")
+	defer span.End()
+	return svc.repo.UpdateLocale(ctx, id, locale)
 }
@@ -4,17 +4,29 @@ import (
 	"context"
 	"errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"strings"
 	"testing"
+	"time"
 	"webook/internal/domain"
 	"webook/internal/repository"
 	mocksvc "webook/internal/repository/mock"
+	"webook/pkg/logger"
+	"webook/pkg/password"
 )
 
+// testArgon2Params 测试用的参数，内存小一点跑得快
+var testArgon2Params = password.Argon2idParams{
+	Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32,
+}
+
 func Test_userService_Login(t *testing.T) {
 	testCases := []struct {
-		name     string
-		mock     func(controller *gomock.Controller) repository.UserRepository
+		name string
+		mock func(controller *gomock.Controller) repository.UserRepository
+		// 为空的时候用 bcrypt，cost 和测试数据一样是 10
+		hasher   password.Hasher
 		ctx      context.Context
 		email    string
 		password string
@@ -45,6 +57,38 @@ func Test_userService_Login(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "登录成功，换了哈希算法",
+			ctx:  context.Background(),
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				userRepository := mocksvc.NewMockUserRepository(ctrl)
+				userRepository.
+					EXPECT().FindByEmail(gomock.Any(), "666@qq.com").
+					Return(domain.User{
+						ID:       1,
+						Email:    "666@qq.com",
+						Password: "$2a$10$EHqoKRCV1mAPyeUebxNUeeOK2lAGvpsxT1pUZFgvw9TuKA9EVNLvS",
+					}, nil)
+				userRepository.EXPECT().
+					UpdatePassword(gomock.Any(), int64(1), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, id int64, hash string) error {
+						assert.True(t, strings.HasPrefix(hash, "$argon2id$"))
+						ok, err := password.Verify(hash, "QQqq11!!")
+						assert.NoError(t, err)
+						assert.True(t, ok)
+						return nil
+					})
+				return userRepository
+			},
+			hasher:   password.NewArgon2idHasher(testArgon2Params),
+			email:    "666@qq.com",
+			password: "QQqq11!!",
+			wantUser: domain.User{
+				ID:       1,
+				Email:    "666@qq.com",
+				Password: "$2a$10$EHqoKRCV1mAPyeUebxNUeeOK2lAGvpsxT1pUZFgvw9TuKA9EVNLvS",
+			},
+		},
 		{
 			name: "查询不到该用户",
 			ctx:  context.Background(),
@@ -92,6 +136,42 @@ func Test_userService_Login(t *testing.T) {
 			password: "QQqq11!",
 			wantErr:  ErrInvalidUserOrPassword,
 		},
+		{
+			name: "账号被禁用",
+			ctx:  context.Background(),
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				userRepository := mocksvc.NewMockUserRepository(ctrl)
+				userRepository.
+					EXPECT().FindByEmail(gomock.Any(), "666@qq.com").
+					Return(domain.User{
+						Email:    "666@qq.com",
+						Password: "$2a$10$EHqoKRCV1mAPyeUebxNUeeOK2lAGvpsxT1pUZFgvw9TuKA9EVNLvS",
+						Status:   domain.UserStatusDisabled,
+					}, nil)
+				return userRepository
+			},
+			email:    "666@qq.com",
+			password: "QQqq11!!",
+			wantErr:  ErrUserDisabled,
+		},
+		{
+			name: "账号被禁用，密码错误的时候不提示",
+			ctx:  context.Background(),
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				userRepository := mocksvc.NewMockUserRepository(ctrl)
+				userRepository.
+					EXPECT().FindByEmail(gomock.Any(), "666@qq.com").
+					Return(domain.User{
+						Email:    "666@qq.com",
+						Password: "$2a$10$EHqoKRCV1mAPyeUebxNUeeOK2lAGvpsxT1pUZFgvw9TuKA9EVNLvS",
+						Status:   domain.UserStatusDisabled,
+					}, nil)
+				return userRepository
+			},
+			email:    "666@qq.com",
+			password: "QQqq11!",
+			wantErr:  ErrInvalidUserOrPassword,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -100,7 +180,11 @@ func Test_userService_Login(t *testing.T) {
 			defer ctrl.Finish()
 
 			userRepository := tc.mock(ctrl)
-			userSvc := NewUserService(userRepository)
+			hasher := tc.hasher
+			if hasher == nil {
+				hasher = password.NewBcryptHasher(10)
+			}
+			userSvc := NewUserService(userRepository, nil, &password.Policy{}, hasher, logger.NewNopLogger())
 
 			u, err := userSvc.Login(tc.ctx, tc.email, tc.password)
 
@@ -109,3 +193,405 @@ func Test_userService_Login(t *testing.T) {
 		})
 	}
 }
+
+func Test_userService_ChangePassword(t *testing.T) {
+	// 当前密码 QQqq11!!
+	const current = "$2a$10$EHqoKRCV1mAPyeUebxNUeeOK2lAGvpsxT1pUZFgvw9TuKA9EVNLvS"
+	hasher := password.NewArgon2idHasher(testArgon2Params)
+	oldHash, err := hasher.Hash("Old-pass1!")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name   string
+		mock   func(ctrl *gomock.Controller) (repository.UserRepository, repository.PasswordHistoryRepository)
+		oldPwd string
+		newPwd string
+
+		wantErr error
+	}{
+		{
+			name: "修改成功",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.PasswordHistoryRepository) {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				history := mocksvc.NewMockPasswordHistoryRepository(ctrl)
+				repo.EXPECT().FindByID(gomock.Any(), int64(1)).
+					Return(domain.User{ID: 1, Email: "tom@qq.com", Password: current}, nil)
+				history.EXPECT().Recent(gomock.Any(), int64(1), 2).Return([]string{oldHash}, nil)
+				repo.EXPECT().UpdatePassword(gomock.Any(), int64(1), gomock.Any()).Return(nil)
+				// 当前密码进入历史记录
+				history.EXPECT().Add(gomock.Any(), int64(1), current).Return(nil)
+				return repo, history
+			},
+			oldPwd: "QQqq11!!",
+			newPwd: "New-pass1!",
+		},
+		{
+			name: "原密码错误",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.PasswordHistoryRepository) {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByID(gomock.Any(), int64(1)).
+					Return(domain.User{ID: 1, Email: "tom@qq.com", Password: current}, nil)
+				return repo, nil
+			},
+			oldPwd:  "QQqq11!",
+			newPwd:  "New-pass1!",
+			wantErr: ErrPasswordIncorrect,
+		},
+		{
+			name: "包含邮箱前缀",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.PasswordHistoryRepository) {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByID(gomock.Any(), int64(1)).
+					Return(domain.User{ID: 1, Email: "tom@qq.com", Password: current}, nil)
+				return repo, nil
+			},
+			oldPwd:  "QQqq11!!",
+			newPwd:  "Tom-pass1!",
+			wantErr: &password.PolicyError{Rule: password.RuleEmail},
+		},
+		{
+			name: "和当前密码相同",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.PasswordHistoryRepository) {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				history := mocksvc.NewMockPasswordHistoryRepository(ctrl)
+				repo.EXPECT().FindByID(gomock.Any(), int64(1)).
+					Return(domain.User{ID: 1, Email: "tom@qq.com", Password: current}, nil)
+				history.EXPECT().Recent(gomock.Any(), int64(1), 2).Return(nil, nil)
+				return repo, history
+			},
+			oldPwd:  "QQqq11!!",
+			newPwd:  "QQqq11!!",
+			wantErr: &password.PolicyError{Rule: password.RuleReused, Param: "3"},
+		},
+		{
+			name: "和历史密码相同",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.PasswordHistoryRepository) {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				history := mocksvc.NewMockPasswordHistoryRepository(ctrl)
+				repo.EXPECT().FindByID(gomock.Any(), int64(1)).
+					Return(domain.User{ID: 1, Email: "tom@qq.com", Password: current}, nil)
+				history.EXPECT().Recent(gomock.Any(), int64(1), 2).Return([]string{oldHash}, nil)
+				return repo, history
+			},
+			oldPwd:  "QQqq11!!",
+			newPwd:  "Old-pass1!",
+			wantErr: &password.PolicyError{Rule: password.RuleReused, Param: "3"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo, history := tc.mock(ctrl)
+			policy := &password.Policy{
+				MinLength:   8,
+				MaxLength:   64,
+				HistorySize: 3,
+			}
+			svc := NewUserService(repo, history, policy, hasher, logger.NewNopLogger())
+			err := svc.ChangePassword(context.Background(), 1, tc.oldPwd, tc.newPwd)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_userService_FindOrCreate(t *testing.T) {
+	phone := "+8613800138000"
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.UserRepository
+
+		wantUser domain.User
+		wantErr  error
+	}{
+		{
+			name: "已有用户",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByPhone(gomock.Any(), phone).Return(domain.User{ID: 1, Phone: phone}, nil)
+				return repo
+			},
+			wantUser: domain.User{ID: 1, Phone: phone},
+		},
+		{
+			name: "新用户",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				gomock.InOrder(
+					repo.EXPECT().FindByPhone(gomock.Any(), phone).Return(domain.User{}, repository.ErrUserNotFound),
+					repo.EXPECT().Create(gomock.Any(), domain.User{Phone: phone}).Return(nil),
+					repo.EXPECT().FindByPhone(gomock.Any(), phone).Return(domain.User{ID: 2, Phone: phone}, nil),
+				)
+				return repo
+			},
+			wantUser: domain.User{ID: 2, Phone: phone},
+		},
+		{
+			name: "封禁期内",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByPhone(gomock.Any(), phone).Return(domain.User{
+					ID: 1, Phone: phone, Status: domain.UserStatusBanned,
+					BannedUntil: time.Now().Add(time.Hour).UnixMilli(),
+				}, nil)
+				return repo
+			},
+			wantErr: ErrUserBanned,
+		},
+		{
+			name: "封禁已经过期",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByPhone(gomock.Any(), phone).Return(domain.User{
+					ID: 1, Phone: phone, Status: domain.UserStatusBanned, BannedUntil: 1700000000000,
+				}, nil)
+				return repo
+			},
+			wantUser: domain.User{ID: 1, Phone: phone, Status: domain.UserStatusBanned, BannedUntil: 1700000000000},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			svc := NewUserService(tc.mock(ctrl), nil, &password.Policy{}, nil, logger.NewNopLogger())
+			u, err := svc.FindOrCreate(context.Background(), phone)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantUser, u)
+		})
+	}
+}
+
+func Test_userService_FindOrCreateByIdentity(t *testing.T) {
+	github := domain.Identity{
+		Provider: domain.ProviderGitHub,
+		Subject:  "1001",
+		Email:    "tom@qq.com",
+	}
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) repository.UserRepository
+		identity domain.Identity
+
+		wantUser domain.User
+		wantErr  error
+	}{
+		{
+			name: "已经关联了用户",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByIdentity(gomock.Any(), github).Return(domain.User{ID: 1}, nil)
+				return repo
+			},
+			identity: github,
+			wantUser: domain.User{ID: 1},
+		},
+		{
+			name: "关联的用户被禁用",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByIdentity(gomock.Any(), github).
+					Return(domain.User{ID: 1, Status: domain.UserStatusDisabled}, nil)
+				return repo
+			},
+			identity: github,
+			wantErr:  ErrUserDisabled,
+		},
+		{
+			name: "新用户，不填邮箱",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				gomock.InOrder(
+					repo.EXPECT().FindByIdentity(gomock.Any(), github).Return(domain.User{}, repository.ErrUserNotFound),
+					repo.EXPECT().CreateWithIdentity(gomock.Any(), domain.User{}, github).Return(nil),
+					repo.EXPECT().FindByIdentity(gomock.Any(), github).Return(domain.User{ID: 2}, nil),
+				)
+				return repo
+			},
+			identity: github,
+			wantUser: domain.User{ID: 2},
+		},
+		{
+			name: "并发创建",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				gomock.InOrder(
+					repo.EXPECT().FindByIdentity(gomock.Any(), github).Return(domain.User{}, repository.ErrUserNotFound),
+					repo.EXPECT().CreateWithIdentity(gomock.Any(), domain.User{}, github).
+						Return(repository.ErrIdentityDuplicated),
+					repo.EXPECT().FindByIdentity(gomock.Any(), github).Return(domain.User{ID: 2}, nil),
+				)
+				return repo
+			},
+			identity: github,
+			wantUser: domain.User{ID: 2},
+		},
+		{
+			name: "创建失败",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByIdentity(gomock.Any(), github).Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().CreateWithIdentity(gomock.Any(), domain.User{}, github).Return(errors.New("db err"))
+				return repo
+			},
+			identity: github,
+			wantErr:  errors.New("db err"),
+		},
+		{
+			name: "微信用户表上的 openid",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByWechatUnionID(gomock.Any(), "unionid-1").
+					Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().FindByWechat(gomock.Any(), "openid-1").
+					Return(domain.User{ID: 3}, nil)
+				return repo
+			},
+			identity: domain.Identity{Provider: domain.ProviderWechat, Subject: "openid-1", UnionID: "unionid-1"},
+			wantUser: domain.User{ID: 3},
+		},
+		{
+			name: "微信用户被封禁",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByWechatUnionID(gomock.Any(), "unionid-1").Return(domain.User{
+					ID: 3, Status: domain.UserStatusBanned, BannedUntil: time.Now().Add(time.Hour).UnixMilli(),
+				}, nil)
+				return repo
+			},
+			identity: domain.Identity{Provider: domain.ProviderWechat, Subject: "openid-1", UnionID: "unionid-1"},
+			wantErr:  ErrUserBanned,
+		},
+		{
+			name: "按 unionid 找到小程序登录创建的用户",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByWechatUnionID(gomock.Any(), "unionid-1").
+					Return(domain.User{ID: 5}, nil)
+				return repo
+			},
+			identity: domain.Identity{Provider: domain.ProviderWechat, Subject: "openid-1", UnionID: "unionid-1"},
+			wantUser: domain.User{ID: 5},
+		},
+		{
+			name: "微信新用户，填充昵称和头像",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				info := domain.WechatInfo{OpenID: "openid-2", UnionID: "unionid-2"}
+				repo.EXPECT().FindByWechatUnionID(gomock.Any(), "unionid-2").
+					Return(domain.User{}, repository.ErrUserNotFound).Times(2)
+				repo.EXPECT().FindByWechat(gomock.Any(), "openid-2").
+					Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().Create(gomock.Any(), domain.User{
+					Nickname:   "微信用户",
+					Avatar:     "https://thirdwx.qlogo.cn/mmopen/2/132",
+					WechatInfo: info,
+				}).Return(nil)
+				repo.EXPECT().FindByWechat(gomock.Any(), "openid-2").
+					Return(domain.User{ID: 4, Nickname: "微信用户", WechatInfo: info}, nil)
+				return repo
+			},
+			identity: domain.Identity{
+				Provider: domain.ProviderWechat,
+				Subject:  "openid-2",
+				UnionID:  "unionid-2",
+				Nickname: "微信用户",
+				Avatar:   "https://thirdwx.qlogo.cn/mmopen/2/132",
+			},
+			wantUser: domain.User{
+				ID:         4,
+				Nickname:   "微信用户",
+				WechatInfo: domain.WechatInfo{OpenID: "openid-2", UnionID: "unionid-2"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			svc := NewUserService(tc.mock(ctrl), nil, &password.Policy{}, nil, logger.NewNopLogger())
+			u, err := svc.FindOrCreateByIdentity(context.Background(), tc.identity)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantUser, u)
+		})
+	}
+}
+
+func Test_userService_LinkIdentity(t *testing.T) {
+	github := domain.Identity{Provider: domain.ProviderGitHub, Subject: "1001"}
+	wechat := domain.Identity{Provider: domain.ProviderWechat, Subject: "openid-1", UnionID: "unionid-1"}
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) repository.UserRepository
+		identity domain.Identity
+
+		wantErr error
+	}{
+		{
+			name: "绑定成功",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().LinkIdentity(gomock.Any(), int64(1), github).Return(nil)
+				return repo
+			},
+			identity: github,
+		},
+		{
+			name: "重复绑定",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().LinkIdentity(gomock.Any(), int64(1), github).Return(repository.ErrIdentityDuplicated)
+				repo.EXPECT().FindByIdentity(gomock.Any(), github).Return(domain.User{ID: 1}, nil)
+				return repo
+			},
+			identity: github,
+		},
+		{
+			name: "已经关联了其他用户",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().LinkIdentity(gomock.Any(), int64(1), github).Return(repository.ErrIdentityDuplicated)
+				repo.EXPECT().FindByIdentity(gomock.Any(), github).Return(domain.User{ID: 2}, nil)
+				return repo
+			},
+			identity: github,
+			wantErr:  ErrIdentityLinked,
+		},
+		{
+			name: "绑定微信",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByWechatUnionID(gomock.Any(), "unionid-1").Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().FindByWechat(gomock.Any(), "openid-1").Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().BindWechat(gomock.Any(), int64(1),
+					domain.WechatInfo{OpenID: "openid-1", UnionID: "unionid-1"}).Return(nil)
+				return repo
+			},
+			identity: wechat,
+		},
+		{
+			name: "微信已经关联了其他用户",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByWechatUnionID(gomock.Any(), "unionid-1").Return(domain.User{ID: 2}, nil)
+				return repo
+			},
+			identity: wechat,
+			wantErr:  ErrIdentityLinked,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			svc := NewUserService(tc.mock(ctrl), nil, &password.Policy{}, nil, logger.NewNopLogger())
+			err := svc.LinkIdentity(context.Background(), 1, tc.identity)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/service/oauth2/wechat"
+	"webook/pkg/cryptox"
+	"webook/pkg/logger"
+)
+
+var (
+	// ErrMiniProgramDisabled 没有配置小程序
+	ErrMiniProgramDisabled = errors.New("没有配置微信小程序")
+	// ErrMiniProgramAuthFailed code 无效或者调用微信失败
+	ErrMiniProgramAuthFailed = errors.New("小程序登录失败")
+	// ErrWechatDataInvalid 小程序传上来的加密数据校验失败，一般是 session_key 换了
+	ErrWechatDataInvalid = wechat.ErrInvalidData
+	// ErrWechatSessionNotFound 用户没有用小程序登录过
+	ErrWechatSessionNotFound = repository.ErrWechatSessionNotFound
+)
+
+type WechatMiniProgramService interface {
+	// Login 用 wx.login 的 code 登录，按 unionid、openid 找到或者创建用户
+	// userInfo 不为空的时候校验签名并解密，新用户用里面的昵称和头像
+	Login(ctx context.Context, code string, userInfo domain.WechatEncryptedData) (domain.User, error)
+	// DecryptPhone 用最近一次登录保存的 session_key 解密手机号，返回 E.164 格式
+	DecryptPhone(ctx context.Context, uid int64, data domain.WechatEncryptedData) (string, error)
+}
+
+type wechatMiniProgramService struct {
+	// client 和 cipher 没有配置小程序的时候为 nil
+	client  wechat.MiniProgram
+	cipher  *cryptox.Cipher
+	repo    repository.WechatSessionRepository
+	userSvc UserService
+	l       logger.Logger
+}
+
+func NewWechatMiniProgramService(client wechat.MiniProgram, cipher *cryptox.Cipher,
+	repo repository.WechatSessionRepository, userSvc UserService, l logger.Logger) WechatMiniProgramService {
+	return &wechatMiniProgramService{
+		client:  client,
+		cipher:  cipher,
+		repo:    repo,
+		userSvc: userSvc,
+		l:       l,
+	}
+}
+
+func (svc *wechatMiniProgramService) Login(ctx context.Context, code string,
+	userInfo domain.WechatEncryptedData) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "WechatMiniProgramService.Login")
+	defer span.End()
+	if svc.client == nil {
+		return domain.User{}, ErrMiniProgramDisabled
+	}
+	session, err := svc.client.Code2Session(ctx, code)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("%w: %w", ErrMiniProgramAuthFailed, err)
+	}
+	var profile domain.WechatProfile
+	if userInfo.EncryptedData != "" {
+		info, err := svc.client.DecryptUserInfo(session.SessionKey, userInfo)
+		if err != nil {
+			return domain.User{}, err
+		}
+		// 防止拿别人的加密数据来登录
+		if info.OpenID != session.OpenID {
+			return domain.User{}, ErrWechatDataInvalid
+		}
+		profile = domain.WechatProfile{Nickname: info.NickName, Avatar: info.AvatarURL}
+	}
+	u, err := svc.userSvc.FindOrCreateByWechat(ctx, domain.WechatInfo{
+		OpenID:  session.OpenID,
+		UnionID: session.UnionID,
+	}, profile)
+	if err != nil {
+		return domain.User{}, err
+	}
+	sessionKey, err := svc.cipher.Encrypt(session.SessionKey)
+	if err != nil {
+		return domain.User{}, err
+	}
+	err = svc.repo.Save(ctx, domain.WechatSession{
+		UserID:     u.ID,
+		OpenID:     session.OpenID,
+		SessionKey: sessionKey,
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+	return u, nil
+}
+
+func (svc *wechatMiniProgramService) DecryptPhone(ctx context.Context, uid int64,
+	data domain.WechatEncryptedData) (string, error) {
+	ctx, span := tracer.Start(ctx, "WechatMiniProgramService.DecryptPhone")
+	defer span.End()
+	if svc.client == nil {
+		return "", ErrMiniProgramDisabled
+	}
+	session, err := svc.repo.Find(ctx, uid)
+	if err != nil {
+		return "", err
+	}
+	sessionKey, err := svc.cipher.Decrypt(session.SessionKey)
+	if err != nil {
+		return "", err
+	}
+	info, err := svc.client.DecryptPhone(sessionKey, data)
+	if err != nil {
+		return "", err
+	}
+	// 和短信登录一样用 E.164 格式，不然同一个手机号会存成两种写法
+	return "+" + info.CountryCode + info.PurePhoneNumber, nil
+}
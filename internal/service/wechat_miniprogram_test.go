@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	mocksvc "webook/internal/repository/mock"
+	"webook/internal/service/oauth2/oauth2test"
+	"webook/internal/service/oauth2/wechat"
+	"webook/pkg/cryptox"
+	"webook/pkg/logger"
+	"webook/pkg/password"
+)
+
+func Test_wechatMiniProgramService_Login(t *testing.T) {
+	server := oauth2test.NewMiniProgramServer("wx-mini", "secret")
+	defer server.Close()
+	client := wechat.NewMiniProgram(wechat.MiniProgramConfig{AppID: "wx-mini", AppSecret: "secret", APIURL: server.URL})
+	cipher, err := cryptox.NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	info := domain.WechatInfo{OpenID: "mini-openid-1001", UnionID: "unionid-1001"}
+
+	testCases := []struct {
+		name   string
+		mock   func(ctrl *gomock.Controller) (repository.UserRepository, repository.WechatSessionRepository)
+		client wechat.MiniProgram
+		// userInfo 模拟小程序调用 wx.login 和 getUserProfile，返回 code 和加密的用户信息
+		userInfo func(t *testing.T) (string, domain.WechatEncryptedData)
+
+		wantUser domain.User
+		wantErr  error
+	}{
+		{
+			name: "新用户，用小程序的昵称和头像",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.WechatSessionRepository) {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				sessionRepo := mocksvc.NewMockWechatSessionRepository(ctrl)
+				repo.EXPECT().FindByWechatUnionID(gomock.Any(), "unionid-1001").
+					Return(domain.User{}, repository.ErrUserNotFound).Times(2)
+				repo.EXPECT().FindByWechat(gomock.Any(), "mini-openid-1001").
+					Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().Create(gomock.Any(), domain.User{
+					Nickname:   "微信用户",
+					Avatar:     "https://thirdwx.qlogo.cn/mmopen/1001/132",
+					WechatInfo: info,
+				}).Return(nil)
+				repo.EXPECT().FindByWechat(gomock.Any(), "mini-openid-1001").
+					Return(domain.User{ID: 1, WechatInfo: info}, nil)
+				sessionRepo.EXPECT().Save(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, s domain.WechatSession) error {
+						assert.Equal(t, int64(1), s.UserID)
+						assert.Equal(t, "mini-openid-1001", s.OpenID)
+						// 保存的是加密之后的 session_key
+						key, err := cipher.Decrypt(s.SessionKey)
+						require.NoError(t, err)
+						assert.NotEqual(t, key, s.SessionKey)
+						return nil
+					})
+				return repo, sessionRepo
+			},
+			client: client,
+			userInfo: func(t *testing.T) (string, domain.WechatEncryptedData) {
+				rawData, signature, encryptedData, iv, err := server.EncryptedUserInfo()
+				require.NoError(t, err)
+				return server.Login(), domain.WechatEncryptedData{
+					RawData: rawData, Signature: signature, EncryptedData: encryptedData, IV: iv,
+				}
+			},
+			wantUser: domain.User{ID: 1, WechatInfo: info},
+		},
+		{
+			name: "老用户，不带用户信息",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.WechatSessionRepository) {
+				repo := mocksvc.NewMockUserRepository(ctrl)
+				sessionRepo := mocksvc.NewMockWechatSessionRepository(ctrl)
+				repo.EXPECT().FindByWechatUnionID(gomock.Any(), "unionid-1001").
+					Return(domain.User{ID: 2}, nil)
+				sessionRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil)
+				return repo, sessionRepo
+			},
+			client: client,
+			userInfo: func(t *testing.T) (string, domain.WechatEncryptedData) {
+				return server.Login(), domain.WechatEncryptedData{}
+			},
+			wantUser: domain.User{ID: 2},
+		},
+		{
+			name: "用户信息被篡改",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.WechatSessionRepository) {
+				return nil, nil
+			},
+			client: client,
+			userInfo: func(t *testing.T) (string, domain.WechatEncryptedData) {
+				_, signature, encryptedData, iv, err := server.EncryptedUserInfo()
+				require.NoError(t, err)
+				return server.Login(), domain.WechatEncryptedData{
+					RawData: `{"nickName":"admin"}`, Signature: signature, EncryptedData: encryptedData, IV: iv,
+				}
+			},
+			wantErr: ErrWechatDataInvalid,
+		},
+		{
+			name: "code 无效",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.WechatSessionRepository) {
+				return nil, nil
+			},
+			client: client,
+			userInfo: func(t *testing.T) (string, domain.WechatEncryptedData) {
+				return "invalid-code", domain.WechatEncryptedData{}
+			},
+			wantErr: ErrMiniProgramAuthFailed,
+		},
+		{
+			name: "没有配置小程序",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.WechatSessionRepository) {
+				return nil, nil
+			},
+			userInfo: func(t *testing.T) (string, domain.WechatEncryptedData) {
+				return server.Login(), domain.WechatEncryptedData{}
+			},
+			wantErr: ErrMiniProgramDisabled,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo, sessionRepo := tc.mock(ctrl)
+			userSvc := NewUserService(repo, nil, &password.Policy{}, nil, logger.NewNopLogger())
+			svc := NewWechatMiniProgramService(tc.client, cipher, sessionRepo, userSvc, logger.NewNopLogger())
+			code, userInfo := tc.userInfo(t)
+			u, err := svc.Login(context.Background(), code, userInfo)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantUser, u)
+		})
+	}
+}
+
+func Test_wechatMiniProgramService_DecryptPhone(t *testing.T) {
+	server := oauth2test.NewMiniProgramServer("wx-mini", "secret")
+	defer server.Close()
+	client := wechat.NewMiniProgram(wechat.MiniProgramConfig{AppID: "wx-mini", AppSecret: "secret", APIURL: server.URL})
+	cipher, err := cryptox.NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	session, err := client.Code2Session(context.Background(), server.Login())
+	require.NoError(t, err)
+	sessionKey, err := cipher.Encrypt(session.SessionKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.WechatSessionRepository
+		// rotate 为 true 的时候微信已经换了 session_key
+		rotate bool
+
+		wantPhone string
+		wantErr   error
+	}{
+		{
+			name: "解密成功",
+			mock: func(ctrl *gomock.Controller) repository.WechatSessionRepository {
+				repo := mocksvc.NewMockWechatSessionRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.WechatSession{UserID: 1, OpenID: session.OpenID, SessionKey: sessionKey}, nil)
+				return repo
+			},
+			wantPhone: "+8613800138000",
+		},
+		{
+			name: "session_key 已经换了",
+			mock: func(ctrl *gomock.Controller) repository.WechatSessionRepository {
+				repo := mocksvc.NewMockWechatSessionRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.WechatSession{UserID: 1, OpenID: session.OpenID, SessionKey: sessionKey}, nil)
+				return repo
+			},
+			rotate:  true,
+			wantErr: ErrWechatDataInvalid,
+		},
+		{
+			name: "没有用小程序登录过",
+			mock: func(ctrl *gomock.Controller) repository.WechatSessionRepository {
+				repo := mocksvc.NewMockWechatSessionRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.WechatSession{}, repository.ErrWechatSessionNotFound)
+				return repo
+			},
+			wantErr: ErrWechatSessionNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			if tc.rotate {
+				server.RotateSessionKey()
+			}
+			encryptedData, iv, err := server.EncryptedPhone()
+			require.NoError(t, err)
+			svc := NewWechatMiniProgramService(client, cipher, tc.mock(ctrl), nil, logger.NewNopLogger())
+			phone, err := svc.DecryptPhone(context.Background(), 1, domain.WechatEncryptedData{
+				EncryptedData: encryptedData,
+				IV:            iv,
+			})
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantPhone, phone)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/service/oauth2"
+	"webook/pkg/cryptox"
+	"webook/pkg/logger"
+)
+
+var (
+	// ErrWechatTokenNotFound 用户没有用微信网页授权登录过
+	ErrWechatTokenNotFound = repository.ErrWechatTokenNotFound
+	// ErrWechatTokenExpired refresh_token 也过期了，需要用户重新授权
+	ErrWechatTokenExpired = errors.New("微信授权已经过期")
+	errWechatDisabled     = errors.New("没有配置微信登录")
+)
+
+const (
+	// wechatRefreshTokenTTL 微信的 refresh_token 固定 30 天有效，刷新不会延长
+	wechatRefreshTokenTTL = time.Hour * 24 * 30
+	// wechatRefreshAhead 提前刷新，避免拿到 token 之后马上过期
+	wechatRefreshAhead = time.Minute * 5
+)
+
+// WechatTokenService 保存微信网页授权的 token，调用微信接口的时候按需刷新
+type WechatTokenService interface {
+	// Save 授权登录或者绑定成功之后保存，覆盖之前的 token
+	Save(ctx context.Context, uid int64, openID string, token oauth2.Token) error
+	// AccessToken 返回可用的 access_token，快过期的时候用 refresh_token 刷新
+	AccessToken(ctx context.Context, uid int64) (string, error)
+}
+
+type wechatTokenService struct {
+	repo repository.WechatTokenRepository
+	// refresher 和 cipher 没有配置微信登录的时候为 nil
+	refresher oauth2.Refresher
+	cipher    *cryptox.Cipher
+	l         logger.Logger
+	now       func() time.Time
+}
+
+func NewWechatTokenService(repo repository.WechatTokenRepository, refresher oauth2.Refresher,
+	cipher *cryptox.Cipher, l logger.Logger) WechatTokenService {
+	return &wechatTokenService{
+		repo:      repo,
+		refresher: refresher,
+		cipher:    cipher,
+		l:         l,
+		now:       time.Now,
+	}
+}
+
+func (svc *wechatTokenService) Save(ctx context.Context, uid int64, openID string, token oauth2.Token) error {
+	ctx, span := tracer.Start(ctx, "WechatTokenService.Save")
+	defer span.End()
+	if svc.cipher == nil {
+		return errWechatDisabled
+	}
+	refreshExpiresAt := svc.now().Add(wechatRefreshTokenTTL)
+	return svc.save(ctx, uid, openID, token, refreshExpiresAt.UnixMilli())
+}
+
+func (svc *wechatTokenService) save(ctx context.Context, uid int64, openID string,
+	token oauth2.Token, refreshExpiresAt int64) error {
+	access, err := svc.cipher.Encrypt(token.AccessToken)
+	if err != nil {
+		return err
+	}
+	refresh, err := svc.cipher.Encrypt(token.RefreshToken)
+	if err != nil {
+		return err
+	}
+	return svc.repo.Save(ctx, domain.WechatToken{
+		UserID:           uid,
+		OpenID:           openID,
+		AccessToken:      access,
+		RefreshToken:     refresh,
+		Scope:            token.Extra["scope"],
+		ExpiresAt:        token.Expiry.UnixMilli(),
+		RefreshExpiresAt: refreshExpiresAt,
+	})
+}
+
+func (svc *wechatTokenService) AccessToken(ctx context.Context, uid int64) (string, error) {
+	ctx, span := tracer.Start(ctx, "WechatTokenService.AccessToken")
+	defer span.End()
+	if svc.cipher == nil || svc.refresher == nil {
+		return "", errWechatDisabled
+	}
+	t, err := svc.repo.Find(ctx, uid)
+	if err != nil {
+		return "", err
+	}
+	now := svc.now()
+	if now.Add(wechatRefreshAhead).UnixMilli() < t.ExpiresAt {
+		return svc.cipher.Decrypt(t.AccessToken)
+	}
+	if now.UnixMilli() >= t.RefreshExpiresAt {
+		return "", ErrWechatTokenExpired
+	}
+	refreshToken, err := svc.cipher.Decrypt(t.RefreshToken)
+	if err != nil {
+		return "", err
+	}
+	token, err := svc.refresher.Refresh(ctx, refreshToken)
+	if err != nil {
+		return "", err
+	}
+	// 刷新接口不返回 refresh_token 的时候继续用原来的
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	if err = svc.save(ctx, uid, t.OpenID, token, t.RefreshExpiresAt); err != nil {
+		// 新的 token 已经拿到了，保存失败下次再刷新
+		svc.l.Warn(ctx, "保存刷新后的微信 token 失败", logger.Int64("uid", uid), logger.Error(err))
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	mocksvc "webook/internal/repository/mock"
+	"webook/internal/service/oauth2"
+	"webook/internal/service/oauth2/oauth2test"
+	"webook/internal/service/oauth2/wechat"
+	"webook/pkg/cryptox"
+	"webook/pkg/logger"
+)
+
+func Test_wechatTokenService_Save(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cipher, err := cryptox.NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	now := time.UnixMilli(1700000000000)
+	expiry := now.Add(time.Hour * 2)
+
+	repo := mocksvc.NewMockWechatTokenRepository(ctrl)
+	repo.EXPECT().Save(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, token domain.WechatToken) error {
+			// 数据库里面不能是明文
+			assert.NotEqual(t, "access-1", token.AccessToken)
+			access, err := cipher.Decrypt(token.AccessToken)
+			require.NoError(t, err)
+			assert.Equal(t, "access-1", access)
+			refresh, err := cipher.Decrypt(token.RefreshToken)
+			require.NoError(t, err)
+			assert.Equal(t, "refresh-1", refresh)
+			assert.Equal(t, int64(1), token.UserID)
+			assert.Equal(t, "openid-1", token.OpenID)
+			assert.Equal(t, "snsapi_login", token.Scope)
+			assert.Equal(t, expiry.UnixMilli(), token.ExpiresAt)
+			assert.Equal(t, now.Add(wechatRefreshTokenTTL).UnixMilli(), token.RefreshExpiresAt)
+			return nil
+		})
+	svc := NewWechatTokenService(repo, nil, cipher, logger.NewNopLogger()).(*wechatTokenService)
+	svc.now = func() time.Time {
+		return now
+	}
+	err = svc.Save(context.Background(), 1, "openid-1", oauth2.Token{
+		AccessToken:  "access-1",
+		RefreshToken: "refresh-1",
+		Expiry:       expiry,
+		Extra:        map[string]string{"scope": "snsapi_login"},
+	})
+	require.NoError(t, err)
+}
+
+func Test_wechatTokenService_AccessToken(t *testing.T) {
+	server := oauth2test.NewWechatServer("wx123", "secret")
+	defer server.Close()
+	p := wechat.NewProvider(wechat.Config{
+		AppID:     "wx123",
+		AppSecret: "secret",
+		OpenURL:   server.URL,
+		APIURL:    server.URL,
+	})
+	ctx := context.Background()
+	authURL, err := p.AuthURL(ctx, "state-1", oauth2.AuthOptions{})
+	require.NoError(t, err)
+	code, _, err := server.Authorize(authURL)
+	require.NoError(t, err)
+	token, err := p.Exchange(ctx, code, oauth2.AuthOptions{})
+	require.NoError(t, err)
+
+	cipher, err := cryptox.NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	access, err := cipher.Encrypt(token.AccessToken)
+	require.NoError(t, err)
+	refresh, err := cipher.Encrypt(token.RefreshToken)
+	require.NoError(t, err)
+	now := time.Now()
+	stored := func(expiresIn, refreshExpiresIn time.Duration) domain.WechatToken {
+		return domain.WechatToken{
+			UserID:           1,
+			OpenID:           "openid-1001",
+			AccessToken:      access,
+			RefreshToken:     refresh,
+			ExpiresAt:        now.Add(expiresIn).UnixMilli(),
+			RefreshExpiresAt: now.Add(refreshExpiresIn).UnixMilli(),
+		}
+	}
+
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.WechatTokenRepository
+
+		// wantRefreshed 为 true 的时候返回新的 access_token，并且能获取用户信息
+		wantRefreshed bool
+		wantErr       error
+	}{
+		{
+			name: "没有过期",
+			mock: func(ctrl *gomock.Controller) repository.WechatTokenRepository {
+				repo := mocksvc.NewMockWechatTokenRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).Return(stored(time.Hour, time.Hour*24), nil)
+				return repo
+			},
+		},
+		{
+			name: "快过期了，刷新之后保存",
+			mock: func(ctrl *gomock.Controller) repository.WechatTokenRepository {
+				repo := mocksvc.NewMockWechatTokenRepository(ctrl)
+				t0 := stored(time.Minute, time.Hour*24)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).Return(t0, nil)
+				repo.EXPECT().Save(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, t1 domain.WechatToken) error {
+						assert.NotEqual(t, t0.AccessToken, t1.AccessToken)
+						assert.Greater(t, t1.ExpiresAt, t0.ExpiresAt)
+						// 刷新不会延长 refresh_token 的有效期
+						assert.Equal(t, t0.RefreshExpiresAt, t1.RefreshExpiresAt)
+						return nil
+					})
+				return repo
+			},
+			wantRefreshed: true,
+		},
+		{
+			name: "保存失败也返回新的 token",
+			mock: func(ctrl *gomock.Controller) repository.WechatTokenRepository {
+				repo := mocksvc.NewMockWechatTokenRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).Return(stored(-time.Minute, time.Hour*24), nil)
+				repo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(assert.AnError)
+				return repo
+			},
+			wantRefreshed: true,
+		},
+		{
+			name: "refresh_token 过期",
+			mock: func(ctrl *gomock.Controller) repository.WechatTokenRepository {
+				repo := mocksvc.NewMockWechatTokenRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).Return(stored(-time.Hour, -time.Minute), nil)
+				return repo
+			},
+			wantErr: ErrWechatTokenExpired,
+		},
+		{
+			name: "没有授权过",
+			mock: func(ctrl *gomock.Controller) repository.WechatTokenRepository {
+				repo := mocksvc.NewMockWechatTokenRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.WechatToken{}, repository.ErrWechatTokenNotFound)
+				return repo
+			},
+			wantErr: ErrWechatTokenNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			svc := NewWechatTokenService(tc.mock(ctrl), p, cipher, logger.NewNopLogger())
+			accessToken, err := svc.AccessToken(ctx, 1)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			if !tc.wantRefreshed {
+				assert.Equal(t, token.AccessToken, accessToken)
+				return
+			}
+			assert.NotEqual(t, token.AccessToken, accessToken)
+			identity, err := p.UserInfo(ctx, oauth2.Token{
+				AccessToken: accessToken,
+				Extra:       map[string]string{"openid": "openid-1001"},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "openid-1001", identity.Subject)
+		})
+	}
+}
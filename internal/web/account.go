@@ -0,0 +1,222 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"webook/internal/domain"
+	"webook/internal/service"
+	"webook/pkg/ginx"
+	"webook/pkg/logger"
+)
+
+// exportFileName zip 里面只有这一个文件，内容和 JSON 格式导出的 data 一样
+const exportFileName = "webook-export.json"
+
+// AccountHandler 导出个人数据和注销账号
+type AccountHandler struct {
+	svc service.AccountService
+	// events 为 nil 的时候不记录安全事件
+	events service.SecurityEventService
+	l      logger.Logger
+}
+
+func NewAccountHandler(svc service.AccountService, events service.SecurityEventService,
+	l logger.Logger) *AccountHandler {
+	return &AccountHandler{
+		svc:    svc,
+		events: events,
+		l:      l,
+	}
+}
+
+func (h *AccountHandler) RegisterRoutes(server *gin.Engine) {
+	ug := server.Group("/users")
+	ug.GET("/export", h.Export)
+	ug.POST("/delete", ginx.WrapReq(h.Delete))
+	ug.POST("/delete/cancel", ginx.Wrap(h.CancelDelete))
+}
+
+// ExportVO 和用户 ID 关联的所有数据，凭证类的字段不导出
+type ExportVO struct {
+	Profile    ExportProfileVO    `json:"profile"`
+	Identities []ExportIdentityVO `json:"identities"`
+	Passkeys   []PasskeyVO        `json:"passkeys"`
+	// TOTPEnabled 密钥和恢复码不导出
+	TOTPEnabled    bool              `json:"totpEnabled"`
+	Roles          []string          `json:"roles"`
+	SecurityEvents []SecurityEventVO `json:"securityEvents"`
+	ExportedAt     int64             `json:"exportedAt"`
+}
+
+type ExportProfileVO struct {
+	ID            int64  `json:"id"`
+	Email         string `json:"email"`
+	Phone         string `json:"phone"`
+	Nickname      string `json:"nickname"`
+	Avatar        string `json:"avatar"`
+	Locale        string `json:"locale"`
+	WechatOpenID  string `json:"wechatOpenId"`
+	WechatUnionID string `json:"wechatUnionId"`
+	DeleteAt      int64  `json:"deleteAt,omitempty"`
+	CreateTime    int64  `json:"createTime"`
+	UpdateTime    int64  `json:"updateTime"`
+}
+
+type ExportIdentityVO struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+	Email    string `json:"email"`
+}
+
+func newExportVO(e domain.UserExport) ExportVO {
+	u := e.User
+	identities := make([]ExportIdentityVO, 0, len(e.Identities))
+	for _, identity := range e.Identities {
+		identities = append(identities, ExportIdentityVO{
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		})
+	}
+	roles := e.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	events := make([]SecurityEventVO, 0, len(e.SecurityEvents))
+	for _, evt := range e.SecurityEvents {
+		events = append(events, newSecurityEventVO(evt))
+	}
+	return ExportVO{
+		Profile: ExportProfileVO{
+			ID:            u.ID,
+			Email:         u.Email,
+			Phone:         u.Phone,
+			Nickname:      u.Nickname,
+			Avatar:        u.Avatar,
+			Locale:        u.Locale,
+			WechatOpenID:  u.WechatInfo.OpenID,
+			WechatUnionID: u.WechatInfo.UnionID,
+			DeleteAt:      u.DeleteAt,
+			CreateTime:    u.CreatedAt,
+			UpdateTime:    u.UpdatedAt,
+		},
+		Identities:     identities,
+		Passkeys:       newPasskeyVOs(e.Passkeys),
+		TOTPEnabled:    e.TOTPEnabled,
+		Roles:          roles,
+		SecurityEvents: events,
+		ExportedAt:     e.ExportedAt,
+	}
+}
+
+type ExportReq struct {
+	// Format 默认 json，zip 的时候作为附件下载
+	Format string `form:"format" binding:"omitempty,oneof=json zip"`
+}
+
+// Export zip 格式不是 JSON 响应，出错的时候才返回 Result
+func (h *AccountHandler) Export(ctx *gin.Context) {
+	var req ExportReq
+	if err := ginx.Bind(ctx, &req); err != nil {
+		ginx.Write(ctx, nil, err)
+		return
+	}
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		ginx.Write(ctx, nil, ginx.ErrUnauthorized)
+		return
+	}
+	export, err := h.svc.Export(ctx, uid)
+	if err != nil {
+		h.l.Error(ctx, "导出用户数据失败", logger.Int64("uid", uid), logger.Error(err))
+		ginx.Write(ctx, nil, ginx.ErrInternal.Wrap(err))
+		return
+	}
+	vo := newExportVO(export)
+	if req.Format != "zip" {
+		ginx.Write(ctx, vo, nil)
+		return
+	}
+	data, err := zipExport(vo)
+	if err != nil {
+		h.l.Error(ctx, "打包用户数据失败", logger.Int64("uid", uid), logger.Error(err))
+		ginx.Write(ctx, nil, ginx.ErrInternal.Wrap(err))
+		return
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="webook-export-%d.zip"`, uid))
+	ctx.Data(http.StatusOK, "application/zip", data)
+}
+
+func zipExport(vo ExportVO) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(exportFileName)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(vo); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type DeleteAccountReq struct {
+	// Password 设置了密码的用户必填，没有密码的用户要先重新登录
+	Password string `json:"password" binding:"max=128"`
+	// Code 开启了二次验证的用户必填，验证码或者恢复码
+	Code string `json:"code" binding:"max=32"`
+}
+
+// DeleteAccountVO DeleteAt 之前重新登录，调用 /users/delete/cancel 可以撤销
+type DeleteAccountVO struct {
+	DeleteAt int64 `json:"deleteAt"`
+}
+
+func (h *AccountHandler) Delete(ctx *gin.Context, req DeleteAccountReq) (any, error) {
+	val, _ := ctx.Get(ClaimsKey)
+	claims, ok := val.(*UserClaims)
+	if !ok || claims.UserID == 0 {
+		return nil, ginx.ErrUnauthorized
+	}
+	reauth := domain.Reauth{Password: req.Password, TOTPCode: req.Code,
+		AuthenticatedAt: claims.AuthenticatedAt}
+	deleteAt, err := h.svc.ScheduleDelete(ctx, claims.UserID, reauth)
+	recordMFALocked(ctx, h.events, claims.UserID, err)
+	switch {
+	case err == nil:
+		return DeleteAccountVO{DeleteAt: deleteAt.UnixMilli()}, nil
+	case errors.Is(err, service.ErrReauthFailed):
+		return nil, ErrReauthFailed
+	case errors.Is(err, service.ErrReauthRequired):
+		return nil, ErrReauthRequired
+	case errors.Is(err, service.ErrMFACodeInvalid):
+		return nil, ErrMFACodeInvalid
+	case errors.Is(err, service.ErrMFALocked):
+		return nil, ErrMFALocked
+	default:
+		h.l.Error(ctx, "申请注销失败", logger.Int64("uid", claims.UserID), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+}
+
+func (h *AccountHandler) CancelDelete(ctx *gin.Context) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	if err := h.svc.CancelDelete(ctx, uid); err != nil {
+		h.l.Error(ctx, "撤销注销失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	return nil, nil
+}
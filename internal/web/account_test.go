@@ -0,0 +1,241 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/service"
+	mocksvc "webook/internal/service/mock"
+	"webook/pkg/logger"
+)
+
+func TestAccountHandler(t *testing.T) {
+	issuedAt := time.UnixMilli(1700000000000)
+	// authenticatedAt 真正登录的时间，比签发时间早，说明 token 刷新过
+	authenticatedAt := issuedAt.Add(-time.Hour)
+	testCases := []struct {
+		name   string
+		mock   func(ctrl *gomock.Controller) service.AccountService
+		method string
+		path   string
+		body   string
+		// login 为 false 的时候模拟没有登录
+		login bool
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "导出 JSON",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := mocksvc.NewMockAccountService(ctrl)
+				svc.EXPECT().Export(gomock.Any(), int64(123)).Return(domain.UserExport{
+					User:       domain.User{ID: 123, Email: "tom@qq.com"},
+					Identities: []domain.Identity{{Provider: domain.ProviderGitHub, Subject: "1001"}},
+					ExportedAt: 1700000000000,
+				}, nil)
+				return svc
+			},
+			method:   http.MethodGet,
+			path:     "/users/export",
+			login:    true,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":{"profile":{"id":123,"email":"tom@qq.com","phone":"","nickname":"",
+"avatar":"","locale":"","wechatOpenId":"","wechatUnionId":"","createTime":0,"updateTime":0},
+"identities":[{"provider":"github","subject":"1001","email":""}],"passkeys":[],"totpEnabled":false,
+"roles":[],"securityEvents":[],"exportedAt":1700000000000}}`,
+		},
+		{
+			name: "不支持的格式",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				return mocksvc.NewMockAccountService(ctrl)
+			},
+			method:   http.MethodGet,
+			path:     "/users/export?format=xml",
+			login:    true,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":100001,"msg":"参数错误","data":[{"field":"Format","tag":"oneof","msg":"Format 不合法"}]}`,
+		},
+		{
+			name: "没有登录",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				return mocksvc.NewMockAccountService(ctrl)
+			},
+			method:   http.MethodGet,
+			path:     "/users/export",
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":100002,"msg":"未登录","data":null}`,
+		},
+		{
+			name: "申请注销",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := mocksvc.NewMockAccountService(ctrl)
+				svc.EXPECT().ScheduleDelete(gomock.Any(), int64(123), domain.Reauth{
+					Password:        "QQqq11!!",
+					TOTPCode:        "123456",
+					AuthenticatedAt: authenticatedAt.UnixMilli(),
+				}).Return(time.UnixMilli(1701296000000), nil)
+				return svc
+			},
+			method:   http.MethodPost,
+			path:     "/users/delete",
+			body:     `{"password":"QQqq11!!","code":"123456"}`,
+			login:    true,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":{"deleteAt":1701296000000}}`,
+		},
+		{
+			name: "密码错误",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := mocksvc.NewMockAccountService(ctrl)
+				svc.EXPECT().ScheduleDelete(gomock.Any(), int64(123), gomock.Any()).
+					Return(time.Time{}, service.ErrReauthFailed)
+				return svc
+			},
+			method:   http.MethodPost,
+			path:     "/users/delete",
+			body:     `{"password":"QQqq11!"}`,
+			login:    true,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":200024,"msg":"密码错误","data":null}`,
+		},
+		{
+			name: "需要重新登录",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := mocksvc.NewMockAccountService(ctrl)
+				svc.EXPECT().ScheduleDelete(gomock.Any(), int64(123), gomock.Any()).
+					Return(time.Time{}, service.ErrReauthRequired)
+				return svc
+			},
+			method:   http.MethodPost,
+			path:     "/users/delete",
+			body:     `{}`,
+			login:    true,
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":200025,"msg":"请重新登录之后再操作","data":null}`,
+		},
+		{
+			name: "二次验证码错误",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := mocksvc.NewMockAccountService(ctrl)
+				svc.EXPECT().ScheduleDelete(gomock.Any(), int64(123), gomock.Any()).
+					Return(time.Time{}, service.ErrMFACodeInvalid)
+				return svc
+			},
+			method:   http.MethodPost,
+			path:     "/users/delete",
+			body:     `{"password":"QQqq11!!"}`,
+			login:    true,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":200013,"msg":"二次验证码错误","data":null}`,
+		},
+		{
+			name: "撤销注销",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := mocksvc.NewMockAccountService(ctrl)
+				svc.EXPECT().CancelDelete(gomock.Any(), int64(123)).Return(nil)
+				return svc
+			},
+			method:   http.MethodPost,
+			path:     "/users/delete/cancel",
+			login:    true,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":null}`,
+		},
+		{
+			name: "撤销注销失败",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := mocksvc.NewMockAccountService(ctrl)
+				svc.EXPECT().CancelDelete(gomock.Any(), int64(123)).Return(errors.New("db 错误"))
+				return svc
+			},
+			method:   http.MethodPost,
+			path:     "/users/delete/cancel",
+			login:    true,
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"code":100000,"msg":"系统错误","data":null}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			hdl := NewAccountHandler(tc.mock(ctrl), nil, logger.NewNopLogger())
+			server := gin.New()
+			// 模拟登录中间件
+			server.Use(func(ctx *gin.Context) {
+				if tc.login {
+					ctx.Set(ClaimsKey, &UserClaims{
+						UserID:           123,
+						RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(issuedAt)},
+						AuthenticatedAt:  authenticatedAt.UnixMilli(),
+					})
+				}
+			})
+			hdl.RegisterRoutes(server)
+
+			req, err := http.NewRequest(tc.method, tc.path, bytes.NewReader([]byte(tc.body)))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestAccountHandler_ExportZip(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mocksvc.NewMockAccountService(ctrl)
+	svc.EXPECT().Export(gomock.Any(), int64(123)).Return(domain.UserExport{
+		User:        domain.User{ID: 123, Email: "tom@qq.com"},
+		TOTPEnabled: true,
+		Roles:       []string{domain.RoleSupport},
+		ExportedAt:  1700000000000,
+	}, nil)
+	hdl := NewAccountHandler(svc, nil, logger.NewNopLogger())
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		ctx.Set(ClaimsKey, &UserClaims{UserID: 123})
+	})
+	hdl.RegisterRoutes(server)
+
+	req, err := http.NewRequest(http.MethodGet, "/users/export?format=zip", nil)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/zip", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="webook-export-123.zip"`, recorder.Header().Get("Content-Disposition"))
+
+	body := recorder.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 1)
+	assert.Equal(t, exportFileName, zr.File[0].Name)
+	f, err := zr.File[0].Open()
+	require.NoError(t, err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"profile":{"id":123,"email":"tom@qq.com","phone":"","nickname":"","avatar":"","locale":"",
+"wechatOpenId":"","wechatUnionId":"","createTime":0,"updateTime":0},"identities":[],"passkeys":[],
+"totpEnabled":true,"roles":["support"],"securityEvents":[],"exportedAt":1700000000000}`, string(data))
+}
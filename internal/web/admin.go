@@ -0,0 +1,217 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/service"
+	"webook/pkg/ginx"
+	"webook/pkg/logger"
+)
+
+// AdminHandler 运营后台，所有接口都要登录，再按 token 里面的角色校验权限
+type AdminHandler struct {
+	svc service.AdminService
+	l   logger.Logger
+}
+
+func NewAdminHandler(svc service.AdminService, l logger.Logger) *AdminHandler {
+	return &AdminHandler{
+		svc: svc,
+		l:   l,
+	}
+}
+
+func (h *AdminHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/admin")
+	g.GET("/users/search", RequirePermission(domain.PermUserRead), ginx.WrapReq(h.SearchUsers))
+	g.GET("/users/detail", RequirePermission(domain.PermUserRead), ginx.WrapReq(h.UserDetail))
+	g.POST("/users/disable", RequirePermission(domain.PermUserWrite), ginx.WrapReq(h.DisableUser))
+	g.POST("/users/enable", RequirePermission(domain.PermUserWrite), ginx.WrapReq(h.EnableUser))
+	g.POST("/users/ban", RequirePermission(domain.PermUserWrite), ginx.WrapReq(h.BanUser))
+	g.POST("/users/logout", RequirePermission(domain.PermUserWrite), ginx.WrapReq(h.ForceLogout))
+	g.POST("/users/roles", RequirePermission(domain.PermRoleWrite), ginx.WrapReq(h.SetRoles))
+	g.POST("/sms/reset", RequirePermission(domain.PermSMSReset), ginx.WrapReq(h.ResetSMSLimit))
+}
+
+// RequirePermission 当前用户的角色要有 perm，放在登录校验之后
+// 角色是签发 token 的时候查的，改了角色之后会强制下线
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		val, _ := ctx.Get(ClaimsKey)
+		claims, ok := val.(*UserClaims)
+		if !ok || claims.UserID == 0 {
+			ginx.Abort(ctx, ginx.ErrUnauthorized)
+			return
+		}
+		if !domain.HasPermission(claims.Roles, perm) {
+			ginx.Abort(ctx, ginx.ErrForbidden)
+			return
+		}
+	}
+}
+
+// AdminUserVO 管理后台看到的用户信息，不包含密码
+type AdminUserVO struct {
+	ID       int64  `json:"id"`
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
+	Nickname string `json:"nickname"`
+	Avatar   string `json:"avatar"`
+	Locale   string `json:"locale"`
+	// Status active、disabled、banned 或者 deleted，封禁过期了就是 active
+	Status string `json:"status"`
+	// BannedUntil 封禁截止时间，毫秒，只有 banned 的时候返回
+	BannedUntil int64 `json:"bannedUntil,omitempty"`
+	// DeleteAt 申请了注销的时候返回
+	DeleteAt    int64    `json:"deleteAt,omitempty"`
+	WechatBound bool     `json:"wechatBound"`
+	Roles       []string `json:"roles,omitempty"`
+	CreateTime  int64    `json:"createTime"`
+	UpdateTime  int64    `json:"updateTime"`
+}
+
+func newAdminUserVO(u domain.User, roles []string) AdminUserVO {
+	var (
+		status      = "active"
+		bannedUntil int64
+	)
+	switch u.StatusAt(time.Now()) {
+	case domain.UserStatusDisabled:
+		status = "disabled"
+	case domain.UserStatusBanned:
+		status = "banned"
+		bannedUntil = u.BannedUntil
+	case domain.UserStatusDeleted:
+		status = "deleted"
+	}
+	return AdminUserVO{
+		ID:          u.ID,
+		Email:       u.Email,
+		Phone:       u.Phone,
+		Nickname:    u.Nickname,
+		Avatar:      u.Avatar,
+		Locale:      u.Locale,
+		Status:      status,
+		BannedUntil: bannedUntil,
+		DeleteAt:    u.DeleteAt,
+		WechatBound: u.WechatInfo.OpenID != "",
+		Roles:       roles,
+		CreateTime:  u.CreatedAt,
+		UpdateTime:  u.UpdatedAt,
+	}
+}
+
+type AdminSearchUsersReq struct {
+	// Keyword 用户 ID、邮箱或者手机号
+	Keyword string `form:"keyword" binding:"required,max=128"`
+}
+
+func (h *AdminHandler) SearchUsers(ctx *gin.Context, req AdminSearchUsersReq) (any, error) {
+	users, err := h.svc.SearchUsers(ctx, operator(ctx), req.Keyword)
+	if err != nil {
+		h.l.Error(ctx, "搜索用户失败", logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	res := make([]AdminUserVO, 0, len(users))
+	for _, u := range users {
+		res = append(res, newAdminUserVO(u, nil))
+	}
+	return res, nil
+}
+
+type AdminUserReq struct {
+	ID int64 `form:"id" json:"id" binding:"required,min=1"`
+}
+
+func (h *AdminHandler) UserDetail(ctx *gin.Context, req AdminUserReq) (any, error) {
+	u, roles, err := h.svc.UserDetail(ctx, operator(ctx), req.ID)
+	if err != nil {
+		return nil, h.error(ctx, "查询用户失败", req.ID, err)
+	}
+	return newAdminUserVO(u, roles), nil
+}
+
+func (h *AdminHandler) DisableUser(ctx *gin.Context, req AdminUserReq) (any, error) {
+	if err := h.svc.SetStatus(ctx, operator(ctx), req.ID, domain.UserStatusDisabled); err != nil {
+		return nil, h.error(ctx, "禁用用户失败", req.ID, err)
+	}
+	return nil, nil
+}
+
+func (h *AdminHandler) EnableUser(ctx *gin.Context, req AdminUserReq) (any, error) {
+	if err := h.svc.SetStatus(ctx, operator(ctx), req.ID, domain.UserStatusActive); err != nil {
+		return nil, h.error(ctx, "启用用户失败", req.ID, err)
+	}
+	return nil, nil
+}
+
+type AdminBanReq struct {
+	ID int64 `json:"id" binding:"required,min=1"`
+	// Until 封禁截止时间，毫秒
+	Until int64 `json:"until" binding:"required,min=1"`
+}
+
+func (h *AdminHandler) BanUser(ctx *gin.Context, req AdminBanReq) (any, error) {
+	err := h.svc.Ban(ctx, operator(ctx), req.ID, time.UnixMilli(req.Until))
+	if errors.Is(err, service.ErrInvalidBanUntil) {
+		return nil, ErrAdminBanUntil
+	}
+	if err != nil {
+		return nil, h.error(ctx, "封禁用户失败", req.ID, err)
+	}
+	return nil, nil
+}
+
+func (h *AdminHandler) ForceLogout(ctx *gin.Context, req AdminUserReq) (any, error) {
+	if err := h.svc.ForceLogout(ctx, operator(ctx), req.ID); err != nil {
+		return nil, h.error(ctx, "强制下线失败", req.ID, err)
+	}
+	return nil, nil
+}
+
+type AdminSetRolesReq struct {
+	ID int64 `json:"id" binding:"required,min=1"`
+	// Roles 为空表示去掉所有角色
+	Roles []string `json:"roles" binding:"max=8,dive,max=32"`
+}
+
+func (h *AdminHandler) SetRoles(ctx *gin.Context, req AdminSetRolesReq) (any, error) {
+	err := h.svc.SetRoles(ctx, operator(ctx), req.ID, req.Roles)
+	if errors.Is(err, service.ErrInvalidRole) {
+		return nil, ErrAdminRoleInvalid
+	}
+	if err != nil {
+		return nil, h.error(ctx, "设置角色失败", req.ID, err)
+	}
+	return nil, nil
+}
+
+type AdminResetSMSReq struct {
+	// Phone E.164 格式，和发送验证码的时候一样
+	Phone string `json:"phone" binding:"required,e164"`
+}
+
+// ResetSMSLimit 用户收不到验证码、被防刷拦住的时候，客服帮忙清掉发送次数
+func (h *AdminHandler) ResetSMSLimit(ctx *gin.Context, req AdminResetSMSReq) (any, error) {
+	if err := h.svc.ResetSMSLimit(ctx, operator(ctx), bizLogin, req.Phone); err != nil {
+		h.l.Error(ctx, "重置短信发送次数失败", logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	return nil, nil
+}
+
+func (h *AdminHandler) error(ctx *gin.Context, msg string, uid int64, err error) error {
+	if errors.Is(err, service.ErrUserNotFound) {
+		return ErrAdminUserNotFound
+	}
+	h.l.Error(ctx, msg, logger.Int64("target", uid), logger.Error(err))
+	return ginx.ErrInternal.Wrap(err)
+}
+
+// operator 经过了 RequirePermission，一定是登录了的
+func operator(ctx *gin.Context) domain.Operator {
+	uid, _ := UserIDFromContext(ctx)
+	return domain.Operator{UserID: uid, IP: ctx.ClientIP()}
+}
@@ -0,0 +1,224 @@
+package web
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/service"
+	mocksvc "webook/internal/service/mock"
+	"webook/pkg/logger"
+)
+
+func TestAdminHandler(t *testing.T) {
+	testCases := []struct {
+		name   string
+		mock   func(ctrl *gomock.Controller) service.AdminService
+		method string
+		path   string
+		body   string
+		// roles 为 nil 的时候模拟没有登录
+		roles []string
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "客服搜索用户",
+			mock: func(ctrl *gomock.Controller) service.AdminService {
+				svc := mocksvc.NewMockAdminService(ctrl)
+				svc.EXPECT().SearchUsers(gomock.Any(), domain.Operator{UserID: 100, IP: "192.0.2.1"}, "tom@qq.com").
+					Return([]domain.User{{ID: 1, Email: "tom@qq.com", Status: domain.UserStatusDisabled}}, nil)
+				return svc
+			},
+			method:   http.MethodGet,
+			path:     "/admin/users/search?keyword=tom@qq.com",
+			roles:    []string{domain.RoleSupport},
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":[{"id":1,"email":"tom@qq.com","phone":"","nickname":"","avatar":"",
+"locale":"","status":"disabled","wechatBound":false,"createTime":0,"updateTime":0}]}`,
+		},
+		{
+			name: "查看用户详情",
+			mock: func(ctrl *gomock.Controller) service.AdminService {
+				svc := mocksvc.NewMockAdminService(ctrl)
+				svc.EXPECT().UserDetail(gomock.Any(), gomock.Any(), int64(1)).
+					Return(domain.User{ID: 1, WechatInfo: domain.WechatInfo{OpenID: "openid-1"}},
+						[]string{domain.RoleSupport}, nil)
+				return svc
+			},
+			method:   http.MethodGet,
+			path:     "/admin/users/detail?id=1",
+			roles:    []string{domain.RoleAdmin},
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":{"id":1,"email":"","phone":"","nickname":"","avatar":"",
+"locale":"","status":"active","wechatBound":true,"roles":["support"],"createTime":0,"updateTime":0}}`,
+		},
+		{
+			name: "用户不存在",
+			mock: func(ctrl *gomock.Controller) service.AdminService {
+				svc := mocksvc.NewMockAdminService(ctrl)
+				svc.EXPECT().UserDetail(gomock.Any(), gomock.Any(), int64(1)).
+					Return(domain.User{}, nil, service.ErrUserNotFound)
+				return svc
+			},
+			method:   http.MethodGet,
+			path:     "/admin/users/detail?id=1",
+			roles:    []string{domain.RoleAdmin},
+			wantCode: http.StatusNotFound,
+			wantBody: `{"code":202001,"msg":"用户不存在","data":null}`,
+		},
+		{
+			name: "禁用用户",
+			mock: func(ctrl *gomock.Controller) service.AdminService {
+				svc := mocksvc.NewMockAdminService(ctrl)
+				svc.EXPECT().SetStatus(gomock.Any(), gomock.Any(), int64(1), domain.UserStatusDisabled).Return(nil)
+				return svc
+			},
+			method:   http.MethodPost,
+			path:     "/admin/users/disable",
+			body:     `{"id":1}`,
+			roles:    []string{domain.RoleAdmin},
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":null}`,
+		},
+		{
+			name: "封禁用户",
+			mock: func(ctrl *gomock.Controller) service.AdminService {
+				svc := mocksvc.NewMockAdminService(ctrl)
+				svc.EXPECT().Ban(gomock.Any(), gomock.Any(), int64(1), time.UnixMilli(1893456000000)).Return(nil)
+				return svc
+			},
+			method:   http.MethodPost,
+			path:     "/admin/users/ban",
+			body:     `{"id":1,"until":1893456000000}`,
+			roles:    []string{domain.RoleAdmin},
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":null}`,
+		},
+		{
+			name: "封禁截止时间已经过了",
+			mock: func(ctrl *gomock.Controller) service.AdminService {
+				svc := mocksvc.NewMockAdminService(ctrl)
+				svc.EXPECT().Ban(gomock.Any(), gomock.Any(), int64(1), gomock.Any()).Return(service.ErrInvalidBanUntil)
+				return svc
+			},
+			method:   http.MethodPost,
+			path:     "/admin/users/ban",
+			body:     `{"id":1,"until":1}`,
+			roles:    []string{domain.RoleAdmin},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":202003,"msg":"封禁截止时间必须晚于当前时间","data":null}`,
+		},
+		{
+			name: "查看被封禁的用户",
+			mock: func(ctrl *gomock.Controller) service.AdminService {
+				svc := mocksvc.NewMockAdminService(ctrl)
+				svc.EXPECT().UserDetail(gomock.Any(), gomock.Any(), int64(1)).
+					Return(domain.User{ID: 1, Status: domain.UserStatusBanned, BannedUntil: 4102444800000}, nil, nil)
+				return svc
+			},
+			method:   http.MethodGet,
+			path:     "/admin/users/detail?id=1",
+			roles:    []string{domain.RoleSupport},
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":{"id":1,"email":"","phone":"","nickname":"","avatar":"",
+"locale":"","status":"banned","bannedUntil":4102444800000,"wechatBound":false,"createTime":0,"updateTime":0}}`,
+		},
+		{
+			name: "客服不能禁用用户",
+			mock: func(ctrl *gomock.Controller) service.AdminService {
+				return mocksvc.NewMockAdminService(ctrl)
+			},
+			method:   http.MethodPost,
+			path:     "/admin/users/disable",
+			body:     `{"id":1}`,
+			roles:    []string{domain.RoleSupport},
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":100003,"msg":"没有权限","data":null}`,
+		},
+		{
+			name: "普通用户不能访问",
+			mock: func(ctrl *gomock.Controller) service.AdminService {
+				return mocksvc.NewMockAdminService(ctrl)
+			},
+			method:   http.MethodGet,
+			path:     "/admin/users/search?keyword=1",
+			roles:    []string{},
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":100003,"msg":"没有权限","data":null}`,
+		},
+		{
+			name: "没有登录",
+			mock: func(ctrl *gomock.Controller) service.AdminService {
+				return mocksvc.NewMockAdminService(ctrl)
+			},
+			method:   http.MethodPost,
+			path:     "/admin/users/logout",
+			body:     `{"id":1}`,
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":100002,"msg":"未登录","data":null}`,
+		},
+		{
+			name: "设置未知的角色",
+			mock: func(ctrl *gomock.Controller) service.AdminService {
+				svc := mocksvc.NewMockAdminService(ctrl)
+				svc.EXPECT().SetRoles(gomock.Any(), gomock.Any(), int64(1), []string{"root"}).
+					Return(service.ErrInvalidRole)
+				return svc
+			},
+			method:   http.MethodPost,
+			path:     "/admin/users/roles",
+			body:     `{"id":1,"roles":["root"]}`,
+			roles:    []string{domain.RoleAdmin},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":202002,"msg":"未知的角色","data":null}`,
+		},
+		{
+			name: "重置短信发送次数",
+			mock: func(ctrl *gomock.Controller) service.AdminService {
+				svc := mocksvc.NewMockAdminService(ctrl)
+				svc.EXPECT().ResetSMSLimit(gomock.Any(), gomock.Any(), bizLogin, "+8613800138000").Return(nil)
+				return svc
+			},
+			method:   http.MethodPost,
+			path:     "/admin/sms/reset",
+			body:     `{"phone":"+8613800138000"}`,
+			roles:    []string{domain.RoleSupport},
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":null}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			hdl := NewAdminHandler(tc.mock(ctrl), logger.NewNopLogger())
+			server := gin.New()
+			// 模拟登录中间件
+			server.Use(func(ctx *gin.Context) {
+				if tc.roles != nil {
+					ctx.Set(ClaimsKey, &UserClaims{UserID: 100, Roles: tc.roles})
+				}
+			})
+			hdl.RegisterRoutes(server)
+
+			req, err := http.NewRequest(tc.method, tc.path, bytes.NewReader([]byte(tc.body)))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = "192.0.2.1:1234"
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
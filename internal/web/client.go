@@ -0,0 +1,17 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"webook/internal/domain"
+)
+
+// deviceIDHeader 前端上报设备指纹的请求头
+const deviceIDHeader = "X-Device-ID"
+
+func clientInfo(ctx *gin.Context) domain.ClientInfo {
+	return domain.ClientInfo{
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+		DeviceID:  ctx.GetHeader(deviceIDHeader),
+	}
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"net/http"
+	"webook/pkg/ginx"
+)
+
+// 用户模块的错误码 200xxx
+// 200001-200003、200006 是以前的参数错误，现在统一用 ginx.ErrInvalidParam 加字段详情，不要复用
+var (
+	ErrEmailDuplicated       = ginx.Register(200004, http.StatusConflict, "user.email_duplicated", "该邮箱已被注册")
+	ErrInvalidUserOrPassword = ginx.Register(200005, http.StatusUnauthorized, "user.invalid_user_or_password", "邮箱或密码错误")
+	ErrCaptchaRequired       = ginx.Register(200007, http.StatusForbidden, "user.captcha_required", "请先完成人机验证")
+	ErrCodeSendLimited       = ginx.Register(200008, http.StatusTooManyRequests, "user.code_send_limited", "短信发送次数过多，请稍后再试")
+	ErrCodeSendTooMany       = ginx.Register(200009, http.StatusTooManyRequests, "user.code_send_too_many", "短信发送太频繁，请稍后再试")
+	ErrCodeInvalid           = ginx.Register(200010, http.StatusBadRequest, "user.code_invalid", "验证码不对，请重新输入")
+	ErrLocaleUnsupported     = ginx.Register(200011, http.StatusBadRequest, "user.locale_unsupported", "不支持该语言")
+	ErrPasswordIncorrect     = ginx.Register(200012, http.StatusBadRequest, "user.password_incorrect", "原密码错误")
+	ErrMFACodeInvalid        = ginx.Register(200013, http.StatusBadRequest, "user.mfa_code_invalid", "二次验证码错误")
+	ErrMFATicketInvalid      = ginx.Register(200014, http.StatusUnauthorized, "user.mfa_ticket_invalid", "登录已过期，请重新登录")
+	ErrTOTPAlreadyEnabled    = ginx.Register(200015, http.StatusConflict, "user.totp_already_enabled", "已经开启了二次验证")
+	ErrTOTPNotEnabled        = ginx.Register(200016, http.StatusBadRequest, "user.totp_not_enabled", "没有开启二次验证")
+	ErrPasskeySessionInvalid = ginx.Register(200017, http.StatusBadRequest, "user.passkey_session_invalid", "操作超时，请重试")
+	ErrPasskeyInvalid        = ginx.Register(200018, http.StatusBadRequest, "user.passkey_invalid", "通行密钥验证失败")
+	ErrPasskeyDuplicated     = ginx.Register(200019, http.StatusConflict, "user.passkey_duplicated", "该通行密钥已经添加过了")
+	ErrPasskeyNotFound       = ginx.Register(200020, http.StatusNotFound, "user.passkey_not_found", "通行密钥不存在")
+	ErrPhoneDuplicated       = ginx.Register(200021, http.StatusConflict, "user.phone_duplicated", "该手机号已经绑定了其他账号")
+	ErrUserDisabled          = ginx.Register(200022, http.StatusForbidden, "user.disabled", "账号已被禁用，请联系客服")
+	ErrUserBanned            = ginx.Register(200023, http.StatusForbidden, "user.banned", "账号已被封禁，请稍后再试")
+	ErrReauthFailed          = ginx.Register(200024, http.StatusBadRequest, "user.reauth_failed", "密码错误")
+	ErrReauthRequired        = ginx.Register(200025, http.StatusForbidden, "user.reauth_required", "请重新登录之后再操作")
+	ErrUserNotFound          = ginx.Register(200026, http.StatusUnauthorized, "user.not_found", "账号不存在或者已经注销")
+	ErrMFALocked             = ginx.Register(200027, http.StatusTooManyRequests, "user.mfa_locked", "二次验证错误次数太多，请稍后再试")
+)
+
+// 第三方登录的错误码 201xxx
+var (
+	ErrOAuth2StateInvalid     = ginx.Register(201001, http.StatusBadRequest, "oauth2.state_invalid", "非法请求")
+	ErrOAuth2AuthFailed       = ginx.Register(201002, http.StatusBadRequest, "oauth2.auth_failed", "第三方授权失败")
+	ErrOAuth2ProviderNotFound = ginx.Register(201003, http.StatusNotFound, "oauth2.provider_not_found", "不支持该登录方式")
+	ErrOAuth2IdentityLinked   = ginx.Register(201004, http.StatusConflict, "oauth2.identity_linked", "该第三方账号已经绑定了其他用户")
+	ErrWechatDataInvalid      = ginx.Register(201005, http.StatusBadRequest, "oauth2.wechat_data_invalid", "微信数据校验失败，请重新登录")
+	ErrWechatSessionNotFound  = ginx.Register(201006, http.StatusBadRequest, "oauth2.wechat_session_not_found", "请先使用小程序登录")
+)
+
+// 管理后台的错误码 202xxx
+var (
+	ErrAdminUserNotFound = ginx.Register(202001, http.StatusNotFound, "admin.user_not_found", "用户不存在")
+	ErrAdminRoleInvalid  = ginx.Register(202002, http.StatusBadRequest, "admin.role_invalid", "未知的角色")
+	ErrAdminBanUntil     = ginx.Register(202003, http.StatusBadRequest, "admin.ban_until_invalid", "封禁截止时间必须晚于当前时间")
+)
@@ -5,77 +5,174 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"strings"
 	"time"
+	"webook/internal/service"
 )
 
+// ClaimsKey 登录校验通过之后，UserClaims 存放在 gin.Context 中的 key
+const ClaimsKey = "claims"
+
+// 签发时间精确到毫秒，强制下线之后马上重新登录拿到的 token 才能和强制下线之前签发的区分开
+func init() {
+	jwt.TimePrecision = time.Millisecond
+}
+
 type JWTHandler struct {
 	signingMethod jwt.SigningMethod
 	access_key    []byte
 	refresh_key   []byte
+	// roleSvc 为 nil 的时候 token 里面不带角色
+	roleSvc service.RoleService
+	// sessions 为 nil 的时候刷新 token 不检查强制下线
+	sessions service.SessionService
+	// events 为 nil 的时候不记录安全事件
+	events service.SecurityEventService
 }
 
 type UserClaims struct {
 	jwt.RegisteredClaims
 	UserID    int64
 	UserAgent string
+	// Locale 用户的语言偏好，为空表示跟随 Accept-Language
+	Locale string
+	// Roles 签发的时候查的角色，管理接口按角色校验权限
+	Roles []string
+	// AuthenticatedAt 真正登录的时间，毫秒，刷新 token 的时候不带过去，敏感操作按这个判断是不是刚刚登录过
+	AuthenticatedAt int64
 }
 
 type RefreshClaims struct {
 	jwt.RegisteredClaims
 	UserID    int64
 	UserAgent string
+	Locale    string
 }
 
-func NewJWTHandler() JWTHandler {
+func NewJWTHandler(accessKey, refreshKey []byte) JWTHandler {
 	return JWTHandler{
 		signingMethod: jwt.SigningMethodHS512,
-		access_key:    []byte("Hbzhtd0211"),
-		refresh_key:   []byte("Gaojc1111"),
+		access_key:    accessKey,
+		refresh_key:   refreshKey,
 	}
 }
 
-func (j *JWTHandler) setJWTToken(ctx *gin.Context, userID int64) error {
-	if err := j.setAccessJWTToken(ctx, userID); err != nil {
+// WithRoles 签发 access token 的时候带上用户的角色
+func (j JWTHandler) WithRoles(roleSvc service.RoleService) JWTHandler {
+	j.roleSvc = roleSvc
+	return j
+}
+
+// WithSessions 刷新 token 的时候检查用户有没有被强制下线
+func (j JWTHandler) WithSessions(sessions service.SessionService) JWTHandler {
+	j.sessions = sessions
+	return j
+}
+
+// WithSecurityEvents 登录、刷新 token、修改密码之类的操作记录安全事件
+func (j JWTHandler) WithSecurityEvents(events service.SecurityEventService) JWTHandler {
+	j.events = events
+	return j
+}
+
+// setJWTToken 登录成功之后调用，access token 记下登录时间
+func (j *JWTHandler) setJWTToken(ctx *gin.Context, userID int64, locale string) error {
+	return j.setTokens(ctx, userID, locale, time.Now().UnixMilli())
+}
+
+// setTokens authenticatedAt 为 0 表示不是登录，比如刷新 token
+func (j *JWTHandler) setTokens(ctx *gin.Context, userID int64, locale string, authenticatedAt int64) error {
+	if err := j.setAccessJWTToken(ctx, userID, locale, authenticatedAt); err != nil {
 		return err
 	}
-	if err := j.setRefreshJWTToken(ctx, userID); err != nil {
+	if err := j.setRefreshJWTToken(ctx, userID, locale); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (j *JWTHandler) setAccessJWTToken(ctx *gin.Context, userID int64) error {
+// newJWTToken 登录成功之后生成 access token 和 refresh token，不写到响应头里面
+func (j *JWTHandler) newJWTToken(ctx *gin.Context, userID int64, locale string) (string, string, error) {
+	accessToken, err := j.newAccessJWTToken(ctx, userID, locale, time.Now().UnixMilli())
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err := j.newRefreshJWTToken(ctx, userID, locale)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+func (j *JWTHandler) setAccessJWTToken(ctx *gin.Context, userID int64, locale string, authenticatedAt int64) error {
+	tokenStr, err := j.newAccessJWTToken(ctx, userID, locale, authenticatedAt)
+	if err != nil {
+		return err
+	}
+	ctx.Header("x-jwt-token", tokenStr)
+	return nil
+}
+
+func (j *JWTHandler) newAccessJWTToken(ctx *gin.Context, userID int64, locale string,
+	authenticatedAt int64) (string, error) {
+	var roles []string
+	if j.roleSvc != nil {
+		var err error
+		roles, err = j.roleSvc.Roles(ctx, userID)
+		if err != nil {
+			return "", err
+		}
+	}
+	now := time.Now()
 	claims := UserClaims{
 		UserID:    userID,
 		UserAgent: ctx.Request.UserAgent(),
+		Locale:    locale,
+		Roles:     roles,
+		// 签发时间刷新的时候也会变，不能用来判断是不是刚刚登录过
+		AuthenticatedAt: authenticatedAt,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			// 强制下线按签发时间判断
+			IssuedAt: jwt.NewNumericDate(now),
 		},
 	}
 	token := jwt.NewWithClaims(j.signingMethod, claims)
-	tokenStr, err := token.SignedString(j.access_key)
+	return token.SignedString(j.access_key)
+}
 
+func (j *JWTHandler) setRefreshJWTToken(ctx *gin.Context, userID int64, locale string) error {
+	tokenStr, err := j.newRefreshJWTToken(ctx, userID, locale)
 	if err != nil {
 		return err
 	}
-	ctx.Header("x-jwt-token", tokenStr)
+	ctx.Header("x-refresh-token", tokenStr)
 	return nil
 }
 
-func (j *JWTHandler) setRefreshJWTToken(ctx *gin.Context, userID int64) error {
+func (j *JWTHandler) newRefreshJWTToken(ctx *gin.Context, userID int64, locale string) (string, error) {
+	now := time.Now()
 	claims := RefreshClaims{
 		UserID:    userID,
 		UserAgent: ctx.Request.UserAgent(),
+		Locale:    locale,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 7)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour * 24 * 7)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 	token := jwt.NewWithClaims(j.signingMethod, claims)
-	tokenStr, err := token.SignedString(j.refresh_key)
-	if err != nil {
-		return err
+	return token.SignedString(j.refresh_key)
+}
+
+// revoked token 签发之后用户有没有被强制下线，以前签发的 token 没有签发时间，按零值算
+func (j *JWTHandler) revoked(ctx *gin.Context, userID int64, issuedAt *jwt.NumericDate) bool {
+	if j.sessions == nil {
+		return false
 	}
-	ctx.Header("x-refresh-token", tokenStr)
-	return nil
+	var iat time.Time
+	if issuedAt != nil {
+		iat = issuedAt.Time
+	}
+	return !j.sessions.Valid(ctx, userID, iat)
 }
 
 func ParseToken(ctx *gin.Context) string {
@@ -89,3 +186,16 @@ func ParseToken(ctx *gin.Context) string {
 	}
 	return segs[1]
 }
+
+// UserIDFromContext 从登录态中取出用户 ID，未登录返回 false
+func UserIDFromContext(ctx *gin.Context) (int64, bool) {
+	val, ok := ctx.Get(ClaimsKey)
+	if !ok {
+		return 0, false
+	}
+	claims, ok := val.(*UserClaims)
+	if !ok || claims.UserID == 0 {
+		return 0, false
+	}
+	return claims.UserID, true
+}
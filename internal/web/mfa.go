@@ -0,0 +1,144 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/service"
+	"webook/pkg/ginx"
+	"webook/pkg/logger"
+)
+
+// MFARequiredVO 密码校验通过，但是还需要二次验证
+type MFARequiredVO struct {
+	MFARequired bool `json:"mfa_required"`
+	// Ticket 提交验证码的时候带上，5 分钟内有效
+	Ticket string `json:"ticket"`
+}
+
+type Login2FAReq struct {
+	Ticket string `json:"ticket" binding:"required,max=64"`
+	// Code 6 位 TOTP 验证码或者恢复码
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+func (u *UserHandler) Login2FA(ctx *gin.Context, req Login2FAReq) (resp any, err error) {
+	var uid int64
+	defer func() {
+		u.recordLogin(ctx, domain.LoginMethodTOTP, "", uid, resp, err)
+	}()
+	uid, err = u.mfaSvc.VerifyTicket(ctx, req.Ticket, req.Code)
+	u.recordMFALocked(ctx, uid, err)
+	if err = mfaError(err); err != nil {
+		if errors.Is(err, ginx.ErrInternal) {
+			u.l.Error(ctx, "二次验证失败", logger.Error(err))
+		}
+		return nil, err
+	}
+	user, err := u.svc.Profile(ctx, uid)
+	if err != nil {
+		u.l.Error(ctx, "查询用户失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	if err = service.CheckStatus(user, time.Now()); err != nil {
+		return nil, accountError(err)
+	}
+	if err = u.setJWTToken(ctx, uid, user.Locale); err != nil {
+		u.l.Error(ctx, "设置 JWT 失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	return nil, nil
+}
+
+// TOTPEnrollVO 前端把 URI 转成二维码，扫不了的时候手动输入 Secret
+type TOTPEnrollVO struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+func (u *UserHandler) EnrollTOTP(ctx *gin.Context) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	user, err := u.svc.Profile(ctx, uid)
+	if err != nil {
+		u.l.Error(ctx, "查询用户失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	account := user.Email
+	if account == "" {
+		account = user.Phone
+	}
+	enrollment, err := u.mfaSvc.Enroll(ctx, uid, account)
+	if err = mfaError(err); err != nil {
+		if errors.Is(err, ginx.ErrInternal) {
+			u.l.Error(ctx, "绑定 TOTP 失败", logger.Int64("uid", uid), logger.Error(err))
+		}
+		return nil, err
+	}
+	return TOTPEnrollVO{Secret: enrollment.Secret, URI: enrollment.URI}, nil
+}
+
+type TOTPCodeReq struct {
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+// TOTPConfirmVO 恢复码只返回这一次，前端提示用户保存
+type TOTPConfirmVO struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+func (u *UserHandler) ConfirmTOTP(ctx *gin.Context, req TOTPCodeReq) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	codes, err := u.mfaSvc.Confirm(ctx, uid, req.Code)
+	if err = mfaError(err); err != nil {
+		if errors.Is(err, ginx.ErrInternal) {
+			u.l.Error(ctx, "确认 TOTP 失败", logger.Int64("uid", uid), logger.Error(err))
+		}
+		return nil, err
+	}
+	u.recordEvent(ctx, domain.SecurityEvent{UserID: uid, Type: domain.SecurityTOTPEnable})
+	return TOTPConfirmVO{RecoveryCodes: codes}, nil
+}
+
+func (u *UserHandler) DisableTOTP(ctx *gin.Context, req TOTPCodeReq) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	err := u.mfaSvc.Disable(ctx, uid, req.Code)
+	u.recordMFALocked(ctx, uid, err)
+	if err = mfaError(err); err != nil {
+		if errors.Is(err, ginx.ErrInternal) {
+			u.l.Error(ctx, "关闭 TOTP 失败", logger.Int64("uid", uid), logger.Error(err))
+		}
+		return nil, err
+	}
+	u.recordEvent(ctx, domain.SecurityEvent{UserID: uid, Type: domain.SecurityTOTPDisable})
+	return nil, nil
+}
+
+// mfaError 把 service 的错误转成错误码，其他错误都是系统错误
+func mfaError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, service.ErrMFACodeInvalid):
+		return ErrMFACodeInvalid
+	case errors.Is(err, service.ErrMFALocked):
+		return ErrMFALocked
+	case errors.Is(err, service.ErrMFATicketInvalid):
+		return ErrMFATicketInvalid
+	case errors.Is(err, service.ErrTOTPAlreadyEnabled):
+		return ErrTOTPAlreadyEnabled
+	case errors.Is(err, service.ErrTOTPNotEnabled):
+		return ErrTOTPNotEnabled
+	default:
+		return ginx.ErrInternal.Wrap(err)
+	}
+}
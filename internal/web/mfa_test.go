@@ -0,0 +1,154 @@
+package web
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/service"
+	mocksvc "webook/internal/service/mock"
+	"webook/pkg/logger"
+)
+
+func TestUserHandler_LoginJWT_MFA(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.UserService, service.MFAService)
+		path string
+		body string
+
+		wantCode int
+		wantBody string
+		wantJWT  bool
+	}{
+		{
+			name: "没有开启二次验证",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				userSvc.EXPECT().Login(gomock.Any(), "tom@qq.com", "Hello#123").
+					Return(domain.User{ID: 1}, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(false, nil)
+				return userSvc, mfaSvc
+			},
+			path:     "/users/login",
+			body:     `{"email":"tom@qq.com","password":"Hello#123"}`,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":null}`,
+			wantJWT:  true,
+		},
+		{
+			name: "需要二次验证",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				userSvc.EXPECT().Login(gomock.Any(), "tom@qq.com", "Hello#123").
+					Return(domain.User{ID: 1}, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(true, nil)
+				mfaSvc.EXPECT().CreateTicket(gomock.Any(), int64(1)).Return("ticket-1", nil)
+				return userSvc, mfaSvc
+			},
+			path:     "/users/login",
+			body:     `{"email":"tom@qq.com","password":"Hello#123"}`,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":{"mfa_required":true,"ticket":"ticket-1"}}`,
+		},
+		{
+			name: "二次验证成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				mfaSvc.EXPECT().VerifyTicket(gomock.Any(), "ticket-1", "123456").Return(int64(1), nil)
+				userSvc.EXPECT().Profile(gomock.Any(), int64(1)).Return(domain.User{ID: 1}, nil)
+				return userSvc, mfaSvc
+			},
+			path:     "/users/login/2fa",
+			body:     `{"ticket":"ticket-1","code":"123456"}`,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":null}`,
+			wantJWT:  true,
+		},
+		{
+			name: "密码正确但是账号已经注销",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				userSvc.EXPECT().Login(gomock.Any(), "tom@qq.com", "Hello#123").
+					Return(domain.User{}, service.ErrUserNotFound)
+				return userSvc, nil
+			},
+			path:     "/users/login",
+			body:     `{"email":"tom@qq.com","password":"Hello#123"}`,
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":200005,"msg":"邮箱或密码错误","data":null}`,
+		},
+		{
+			name: "二次验证通过但是账号已经注销",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				mfaSvc.EXPECT().VerifyTicket(gomock.Any(), "ticket-1", "123456").Return(int64(1), nil)
+				userSvc.EXPECT().Profile(gomock.Any(), int64(1)).
+					Return(domain.User{ID: 1, Status: domain.UserStatusDeleted}, nil)
+				return userSvc, mfaSvc
+			},
+			path:     "/users/login/2fa",
+			body:     `{"ticket":"ticket-1","code":"123456"}`,
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":200026,"msg":"账号不存在或者已经注销","data":null}`,
+		},
+		{
+			name: "二次验证码错误",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService) {
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				mfaSvc.EXPECT().VerifyTicket(gomock.Any(), "ticket-1", "123456").
+					Return(int64(0), service.ErrMFACodeInvalid)
+				return nil, mfaSvc
+			},
+			path:     "/users/login/2fa",
+			body:     `{"ticket":"ticket-1","code":"123456"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":200013,"msg":"二次验证码错误","data":null}`,
+		},
+		{
+			name: "票据失效",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService) {
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				mfaSvc.EXPECT().VerifyTicket(gomock.Any(), "ticket-1", "123456").
+					Return(int64(0), service.ErrMFATicketInvalid)
+				return nil, mfaSvc
+			},
+			path:     "/users/login/2fa",
+			body:     `{"ticket":"ticket-1","code":"123456"}`,
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":200014,"msg":"登录已过期，请重新登录","data":null}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			userSvc, mfaSvc := tc.mock(ctrl)
+			hdl := NewUserHandler(userSvc, nil, nil, mfaSvc, nil,
+				NewJWTHandler([]byte("access-key-for-test"), []byte("refresh-key-for-test")),
+				nil, logger.NewNopLogger())
+			server := gin.New()
+			hdl.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, tc.path, bytes.NewReader([]byte(tc.body)))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantJWT, recorder.Header().Get("x-jwt-token") != "")
+		})
+	}
+}
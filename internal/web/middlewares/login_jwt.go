@@ -4,19 +4,34 @@ import (
 	"encoding/gob"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"net/http"
 	"strings"
 	"time"
+	"webook/internal/service"
 	"webook/internal/web"
+	"webook/pkg/ginx"
+	"webook/pkg/i18n"
+	"webook/pkg/logger"
 )
 
 // LoginJWTMiddlewareBuilder JWT登录校验
 type LoginJWTMiddlewareBuilder struct {
-	paths []string
+	paths    []string
+	key      []byte
+	sessions service.SessionService
 }
 
-func NewLoginJWTMiddlewareBuilder() *LoginJWTMiddlewareBuilder {
-	return &LoginJWTMiddlewareBuilder{}
+// NewLoginJWTMiddlewareBuilder key 是 access token 的签名密钥
+func NewLoginJWTMiddlewareBuilder(key []byte) *LoginJWTMiddlewareBuilder {
+	return &LoginJWTMiddlewareBuilder{
+		key: key,
+	}
+}
+
+// Sessions 校验用户有没有被强制下线、账号有没有被禁用或者封禁，不设置的话只校验 token 本身
+// 账号状态走用户缓存，修改状态的时候删缓存，马上生效
+func (l *LoginJWTMiddlewareBuilder) Sessions(svc service.SessionService) *LoginJWTMiddlewareBuilder {
+	l.sessions = svc
+	return l
 }
 
 // IgnorePaths 对不用身份校验的HTTP请求放行
@@ -41,43 +56,59 @@ func (l *LoginJWTMiddlewareBuilder) Build() gin.HandlerFunc {
 		tokenStr := ctx.GetHeader("Authorization")
 		if tokenStr == "" {
 			// 没登录
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+			ginx.Abort(ctx, ginx.ErrUnauthorized)
 			return
 		}
 
 		segs := strings.Split(tokenStr, " ") // Bearer token...
 		if len(segs) != 2 {
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+			ginx.Abort(ctx, ginx.ErrUnauthorized)
 			return
 		}
 		tokenStr = segs[1]
 		claims := &web.UserClaims{}
 		// token校验，ParseWithClaims要传指针
 		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte("Hbzhtd0211"), nil
+			return l.key, nil
 		})
 		if err != nil {
 			// 没登录
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+			ginx.Abort(ctx, ginx.ErrUnauthorized)
 			return
 		}
 
 		// err为nil, token不为nil 约定
 		if !token.Valid {
 			// 没登录
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+			ginx.Abort(ctx, ginx.ErrUnauthorized)
 			return
 		}
 
 		if claims.UserAgent != ctx.Request.UserAgent() {
 			// 比如： 登录在谷歌，其他操作在bing
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+			ginx.Abort(ctx, ginx.ErrUnauthorized)
 			return
 		}
 		if claims.UserID == 0 {
 			// 没登录
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+			ginx.Abort(ctx, ginx.ErrUnauthorized)
+			return
 		}
-
+		if l.sessions != nil {
+			// 以前签发的 token 没有签发时间，被强制下线过的话也算失效
+			var iat time.Time
+			if claims.IssuedAt != nil {
+				iat = claims.IssuedAt.Time
+			}
+			if !l.sessions.Valid(ctx, claims.UserID, iat) {
+				ginx.Abort(ctx, ginx.ErrUnauthorized)
+				return
+			}
+		}
+		ctx.Set(web.ClaimsKey, claims)
+		// 之后的日志都带上用户 ID
+		reqCtx := logger.WithFields(ctx.Request.Context(), logger.Int64("user_id", claims.UserID))
+		// 用户设置过语言偏好的话，优先于 Accept-Language
+		ctx.Request = ctx.Request.WithContext(i18n.Prefer(reqCtx, claims.Locale))
 	}
 }
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"webook/internal/domain"
+	"webook/internal/service"
+	"webook/pkg/ginx"
+	"webook/pkg/logger"
+)
+
+// MiniProgramHandler 微信小程序登录，小程序里面不能跳转，不走 OAuth2Handler 的授权码流程
+type MiniProgramHandler struct {
+	svc     service.WechatMiniProgramService
+	userSvc service.UserService
+	mfaSvc  service.MFAService
+	JWTHandler
+	l logger.Logger
+}
+
+func NewMiniProgramHandler(svc service.WechatMiniProgramService, userSvc service.UserService,
+	mfaSvc service.MFAService, jwtHdl JWTHandler, l logger.Logger) *MiniProgramHandler {
+	return &MiniProgramHandler{
+		svc:        svc,
+		userSvc:    userSvc,
+		mfaSvc:     mfaSvc,
+		JWTHandler: jwtHdl,
+		l:          l,
+	}
+}
+
+func (h *MiniProgramHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/oauth2/wechat/miniprogram")
+	g.POST("/login", ginx.WrapReq(h.Login))
+	// 需要登录，JWT 中间件不放行
+	g.POST("/phone", ginx.WrapReq(h.BindPhone))
+}
+
+// MiniProgramLoginReq 用户信息是可选的，getUserProfile 拿到的字段原样传上来
+type MiniProgramLoginReq struct {
+	Code          string `json:"code" binding:"required,max=128"`
+	RawData       string `json:"rawData" binding:"max=4096"`
+	Signature     string `json:"signature" binding:"required_with=RawData,max=64"`
+	EncryptedData string `json:"encryptedData" binding:"required_with=RawData,max=8192"`
+	IV            string `json:"iv" binding:"required_with=EncryptedData,max=64"`
+}
+
+func (h *MiniProgramHandler) Login(ctx *gin.Context, req MiniProgramLoginReq) (resp any, err error) {
+	var uid int64
+	defer func() {
+		h.recordLogin(ctx, domain.LoginMethodMiniProgram, "", uid, resp, err)
+	}()
+	user, err := h.svc.Login(ctx, req.Code, domain.WechatEncryptedData{
+		RawData:       req.RawData,
+		Signature:     req.Signature,
+		EncryptedData: req.EncryptedData,
+		IV:            req.IV,
+	})
+	if err != nil {
+		return nil, h.error(ctx, "小程序登录失败", err)
+	}
+	uid = user.ID
+	// 开启了二次验证的用户，小程序登录也要验证
+	enabled, err := h.mfaSvc.Enabled(ctx, user.ID)
+	if err != nil {
+		h.l.Error(ctx, "查询二次验证失败", logger.Int64("uid", user.ID), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	if enabled {
+		ticket, err := h.mfaSvc.CreateTicket(ctx, user.ID)
+		if err != nil {
+			h.l.Error(ctx, "生成二次验证票据失败", logger.Int64("uid", user.ID), logger.Error(err))
+			return nil, ginx.ErrInternal.Wrap(err)
+		}
+		return MFARequiredVO{MFARequired: true, Ticket: ticket}, nil
+	}
+	if err = h.setJWTToken(ctx, user.ID, user.Locale); err != nil {
+		h.l.Error(ctx, "设置 JWT 失败", logger.Int64("uid", user.ID), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	return nil, nil
+}
+
+// MiniProgramPhoneReq getPhoneNumber 拿到的加密数据
+type MiniProgramPhoneReq struct {
+	EncryptedData string `json:"encryptedData" binding:"required,max=8192"`
+	IV            string `json:"iv" binding:"required,max=64"`
+}
+
+// BindPhone 解密手机号并绑定到当前用户，以后也可以用短信验证码登录
+func (h *MiniProgramHandler) BindPhone(ctx *gin.Context, req MiniProgramPhoneReq) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	phone, err := h.svc.DecryptPhone(ctx, uid, domain.WechatEncryptedData{
+		EncryptedData: req.EncryptedData,
+		IV:            req.IV,
+	})
+	if err != nil {
+		return nil, h.error(ctx, "解密手机号失败", err)
+	}
+	err = h.userSvc.BindPhone(ctx, uid, phone)
+	if errors.Is(err, service.ErrUserDuplicated) {
+		return nil, ErrPhoneDuplicated
+	}
+	if err != nil {
+		h.l.Error(ctx, "绑定手机号失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	return nil, nil
+}
+
+func (h *MiniProgramHandler) error(ctx *gin.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, service.ErrMiniProgramDisabled):
+		return ErrOAuth2ProviderNotFound
+	case errors.Is(err, service.ErrWechatDataInvalid):
+		h.l.Warn(ctx, msg, logger.Error(err))
+		return ErrWechatDataInvalid
+	case errors.Is(err, service.ErrWechatSessionNotFound):
+		return ErrWechatSessionNotFound
+	case accountError(err) != nil:
+		return accountError(err)
+	case errors.Is(err, service.ErrMiniProgramAuthFailed):
+		h.l.Warn(ctx, msg, logger.Error(err))
+		return ErrOAuth2AuthFailed.Wrap(err)
+	default:
+		h.l.Error(ctx, msg, logger.Error(err))
+		return ginx.ErrInternal.Wrap(err)
+	}
+}
@@ -0,0 +1,201 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/service"
+	mocksvc "webook/internal/service/mock"
+	"webook/pkg/logger"
+)
+
+func TestMiniProgramHandler(t *testing.T) {
+	type mocks struct {
+		svc     *mocksvc.MockWechatMiniProgramService
+		userSvc *mocksvc.MockUserService
+		mfaSvc  *mocksvc.MockMFAService
+	}
+	testCases := []struct {
+		name string
+		mock func(m mocks)
+		path string
+		body string
+		// login 为 true 的时候模拟已经登录
+		login bool
+
+		wantCode int
+		wantBody string
+		wantJWT  bool
+	}{
+		{
+			name: "登录成功",
+			mock: func(m mocks) {
+				m.svc.EXPECT().Login(gomock.Any(), "code-1", domain.WechatEncryptedData{}).
+					Return(domain.User{ID: 1}, nil)
+				m.mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(false, nil)
+			},
+			path:     "/oauth2/wechat/miniprogram/login",
+			body:     `{"code":"code-1"}`,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":null}`,
+			wantJWT:  true,
+		},
+		{
+			name: "需要二次验证",
+			mock: func(m mocks) {
+				m.svc.EXPECT().Login(gomock.Any(), "code-1", domain.WechatEncryptedData{}).
+					Return(domain.User{ID: 1}, nil)
+				m.mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(true, nil)
+				m.mfaSvc.EXPECT().CreateTicket(gomock.Any(), int64(1)).Return("ticket-1", nil)
+			},
+			path:     "/oauth2/wechat/miniprogram/login",
+			body:     `{"code":"code-1"}`,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":{"mfa_required":true,"ticket":"ticket-1"}}`,
+		},
+		{
+			name: "用户信息被篡改",
+			mock: func(m mocks) {
+				m.svc.EXPECT().Login(gomock.Any(), "code-1", gomock.Any()).
+					Return(domain.User{}, service.ErrWechatDataInvalid)
+			},
+			path:     "/oauth2/wechat/miniprogram/login",
+			body:     `{"code":"code-1","rawData":"{}","signature":"abc","encryptedData":"abc","iv":"abc"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":201005,"msg":"微信数据校验失败，请重新登录","data":null}`,
+		},
+		{
+			name: "账号被封禁",
+			mock: func(m mocks) {
+				m.svc.EXPECT().Login(gomock.Any(), "code-1", domain.WechatEncryptedData{}).
+					Return(domain.User{}, service.ErrUserBanned)
+			},
+			path:     "/oauth2/wechat/miniprogram/login",
+			body:     `{"code":"code-1"}`,
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":200023,"msg":"账号已被封禁，请稍后再试","data":null}`,
+		},
+		{
+			name: "code 无效",
+			mock: func(m mocks) {
+				m.svc.EXPECT().Login(gomock.Any(), "code-1", domain.WechatEncryptedData{}).
+					Return(domain.User{}, service.ErrMiniProgramAuthFailed)
+			},
+			path:     "/oauth2/wechat/miniprogram/login",
+			body:     `{"code":"code-1"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":201002,"msg":"第三方授权失败","data":null}`,
+		},
+		{
+			name: "没有配置小程序",
+			mock: func(m mocks) {
+				m.svc.EXPECT().Login(gomock.Any(), "code-1", domain.WechatEncryptedData{}).
+					Return(domain.User{}, service.ErrMiniProgramDisabled)
+			},
+			path:     "/oauth2/wechat/miniprogram/login",
+			body:     `{"code":"code-1"}`,
+			wantCode: http.StatusNotFound,
+			wantBody: `{"code":201003,"msg":"不支持该登录方式","data":null}`,
+		},
+		{
+			name: "绑定手机号成功",
+			mock: func(m mocks) {
+				m.svc.EXPECT().DecryptPhone(gomock.Any(), int64(123),
+					domain.WechatEncryptedData{EncryptedData: "abc", IV: "iv"}).Return("13800138000", nil)
+				m.userSvc.EXPECT().BindPhone(gomock.Any(), int64(123), "13800138000").Return(nil)
+			},
+			path:     "/oauth2/wechat/miniprogram/phone",
+			body:     `{"encryptedData":"abc","iv":"iv"}`,
+			login:    true,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":null}`,
+		},
+		{
+			name: "手机号已经绑定了其他账号",
+			mock: func(m mocks) {
+				m.svc.EXPECT().DecryptPhone(gomock.Any(), int64(123), gomock.Any()).Return("13800138000", nil)
+				m.userSvc.EXPECT().BindPhone(gomock.Any(), int64(123), "13800138000").
+					Return(service.ErrUserDuplicated)
+			},
+			path:     "/oauth2/wechat/miniprogram/phone",
+			body:     `{"encryptedData":"abc","iv":"iv"}`,
+			login:    true,
+			wantCode: http.StatusConflict,
+			wantBody: `{"code":200021,"msg":"该手机号已经绑定了其他账号","data":null}`,
+		},
+		{
+			name: "没有用小程序登录过",
+			mock: func(m mocks) {
+				m.svc.EXPECT().DecryptPhone(gomock.Any(), int64(123), gomock.Any()).
+					Return("", service.ErrWechatSessionNotFound)
+			},
+			path:     "/oauth2/wechat/miniprogram/phone",
+			body:     `{"encryptedData":"abc","iv":"iv"}`,
+			login:    true,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":201006,"msg":"请先使用小程序登录","data":null}`,
+		},
+		{
+			name: "绑定手机号系统错误",
+			mock: func(m mocks) {
+				m.svc.EXPECT().DecryptPhone(gomock.Any(), int64(123), gomock.Any()).Return("13800138000", nil)
+				m.userSvc.EXPECT().BindPhone(gomock.Any(), int64(123), "13800138000").
+					Return(errors.New("db 错误"))
+			},
+			path:     "/oauth2/wechat/miniprogram/phone",
+			body:     `{"encryptedData":"abc","iv":"iv"}`,
+			login:    true,
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"code":100000,"msg":"系统错误","data":null}`,
+		},
+		{
+			name:     "没有登录",
+			mock:     func(m mocks) {},
+			path:     "/oauth2/wechat/miniprogram/phone",
+			body:     `{"encryptedData":"abc","iv":"iv"}`,
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":100002,"msg":"未登录","data":null}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mocks{
+				svc:     mocksvc.NewMockWechatMiniProgramService(ctrl),
+				userSvc: mocksvc.NewMockUserService(ctrl),
+				mfaSvc:  mocksvc.NewMockMFAService(ctrl),
+			}
+			tc.mock(m)
+			hdl := NewMiniProgramHandler(m.svc, m.userSvc, m.mfaSvc,
+				NewJWTHandler([]byte("access-key-for-test"), []byte("refresh-key-for-test")),
+				logger.NewNopLogger())
+			server := gin.New()
+			// 模拟登录中间件
+			server.Use(func(ctx *gin.Context) {
+				if tc.login {
+					ctx.Set(ClaimsKey, &UserClaims{UserID: 123})
+				}
+			})
+			hdl.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, tc.path, bytes.NewReader([]byte(tc.body)))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantJWT, recorder.Header().Get("x-jwt-token") != "")
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	uuid "github.com/lithammer/shortuuid/v4"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/service"
+	"webook/internal/service/oauth2"
+	"webook/pkg/ginx"
+	"webook/pkg/logger"
+)
+
+// stateTTL 从跳转到第三方到回调的最长时间
+const stateTTL = time.Minute * 10
+
+// OAuth2Handler 第三方登录，路由里面的 :provider 是 oauth2.Provider 的 Name
+type OAuth2Handler struct {
+	providers *oauth2.Registry
+	userSvc   service.UserService
+	mfaSvc    service.MFAService
+	// wechatTokenSvc 微信登录或者绑定成功之后保存 token，后面调用微信接口要用
+	wechatTokenSvc service.WechatTokenService
+	JWTHandler
+	l               logger.Logger
+	stateCookieName string
+	// stateKey state cookie 单独的签名密钥，见 newStateKey
+	stateKey []byte
+	// secureCookie 回调地址是 https 的时候 state cookie 只在 https 下发送
+	secureCookie bool
+	// frontendURL 不为空的时候回调结束之后跳转回前端，否则返回 JSON
+	frontendURL string
+}
+
+// StateClaims 放在 cookie 里面，回调的时候和 URL 上的 state 比较，防止 CSRF
+type StateClaims struct {
+	jwt.RegisteredClaims
+	State    string
+	Provider string
+	// CodeVerifier 和 Nonce 在 Exchange 的时候要用
+	CodeVerifier string
+	Nonce        string
+	// UserID 不为 0 表示已登录的用户在绑定第三方账号
+	UserID int64
+}
+
+// jwtTokens 第三方登录成功之后生成的 token，写到响应头里面或者跳转回前端
+type jwtTokens struct {
+	access  string
+	refresh string
+}
+
+func NewOAuth2Handler(providers *oauth2.Registry, userSvc service.UserService, mfaSvc service.MFAService,
+	wechatTokenSvc service.WechatTokenService, jwtHdl JWTHandler, frontendURL string, l logger.Logger) *OAuth2Handler {
+	return &OAuth2Handler{
+		providers:       providers,
+		userSvc:         userSvc,
+		mfaSvc:          mfaSvc,
+		wechatTokenSvc:  wechatTokenSvc,
+		JWTHandler:      jwtHdl,
+		l:               l,
+		stateCookieName: "oauth2_state",
+		stateKey:        newStateKey(jwtHdl.access_key),
+		frontendURL:     frontendURL,
+	}
+}
+
+// WithSecureCookie state cookie 加上 Secure
+func (o *OAuth2Handler) WithSecureCookie(secure bool) *OAuth2Handler {
+	o.secureCookie = secure
+	return o
+}
+
+// newStateKey 从 access token 的密钥派生，state 和 access token 的签名互不通用
+// 不然绑定流程里面带着 UserID 的 state 可以直接当成 access token 用
+func newStateKey(accessKey []byte) []byte {
+	mac := hmac.New(sha256.New, accessKey)
+	mac.Write([]byte("oauth2_state"))
+	return mac.Sum(nil)
+}
+
+func (o *OAuth2Handler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/oauth2/:provider")
+	g.GET("/authurl", ginx.Wrap(o.AuthURL))
+	// 需要登录，JWT 中间件不放行
+	g.GET("/bind/authurl", ginx.Wrap(o.BindAuthURL))
+	g.Any("/callback", o.Callback)
+}
+
+func (o *OAuth2Handler) AuthURL(ctx *gin.Context) (any, error) {
+	return o.authURL(ctx, 0)
+}
+
+func (o *OAuth2Handler) BindAuthURL(ctx *gin.Context) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	return o.authURL(ctx, uid)
+}
+
+func (o *OAuth2Handler) authURL(ctx *gin.Context, uid int64) (any, error) {
+	p, ok := o.providers.Get(ctx.Param("provider"))
+	if !ok {
+		return nil, ErrOAuth2ProviderNotFound
+	}
+	opts, err := oauth2.NewAuthOptions()
+	if err != nil {
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	state := uuid.New()
+	authURL, err := p.AuthURL(ctx, state, opts)
+	if err != nil {
+		o.l.Error(ctx, "构造授权 URL 失败", logger.String("provider", p.Name()), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	claims := StateClaims{
+		State:        state,
+		Provider:     p.Name(),
+		CodeVerifier: opts.CodeVerifier,
+		Nonce:        opts.Nonce,
+		UserID:       uid,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(stateTTL)),
+		},
+	}
+	if err = o.setStateCookie(ctx, claims); err != nil {
+		o.l.Error(ctx, "设置 state cookie 失败", logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	return authURL, nil
+}
+
+// Callback 第三方授权之后浏览器跳转回来，整个流程只写一次响应
+func (o *OAuth2Handler) Callback(ctx *gin.Context) {
+	resp, err := o.callback(ctx)
+	tokens, login := resp.(jwtTokens)
+	if login {
+		resp = nil
+	}
+	if o.frontendURL != "" {
+		o.redirect(ctx, tokens, resp, err)
+		return
+	}
+	if login {
+		ctx.Header("x-jwt-token", tokens.access)
+		ctx.Header("x-refresh-token", tokens.refresh)
+	}
+	ginx.Write(ctx, resp, err)
+}
+
+// redirect 跳转回前端，结果放在 URL fragment 里面，格式和 Result 一样
+// fragment 不会发给服务端，也不会出现在 Referer 里面，前端拿到 token 之后要清掉
+func (o *OAuth2Handler) redirect(ctx *gin.Context, tokens jwtTokens, resp any, err error) {
+	_, res := ginx.Render(ctx, resp, err)
+	fragment := url.Values{}
+	fragment.Set("code", strconv.Itoa(res.Code))
+	fragment.Set("msg", res.Msg)
+	if tokens.access != "" {
+		fragment.Set("access_token", tokens.access)
+		fragment.Set("refresh_token", tokens.refresh)
+	}
+	if vo, ok := resp.(MFARequiredVO); ok {
+		fragment.Set("mfa_required", "true")
+		fragment.Set("ticket", vo.Ticket)
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Referrer-Policy", "no-referrer")
+	ctx.Redirect(http.StatusFound, o.frontendURL+"#"+fragment.Encode())
+}
+
+func (o *OAuth2Handler) callback(ctx *gin.Context) (any, error) {
+	p, ok := o.providers.Get(ctx.Param("provider"))
+	if !ok {
+		return nil, ErrOAuth2ProviderNotFound
+	}
+	claims, err := o.verifyState(ctx, p.Name())
+	if err != nil {
+		o.l.Warn(ctx, "第三方回调 state 校验失败", logger.String("provider", p.Name()), logger.Error(err))
+		return nil, ErrOAuth2StateInvalid
+	}
+	// state 只能用一次
+	o.clearStateCookie(ctx, p.Name())
+	// 用户拒绝授权
+	if e := ctx.Query("error"); e != "" {
+		o.l.Info(ctx, "用户没有授权", logger.String("provider", p.Name()), logger.String("error", e))
+		return nil, ErrOAuth2AuthFailed
+	}
+	opts := oauth2.AuthOptions{CodeVerifier: claims.CodeVerifier, Nonce: claims.Nonce}
+	token, err := p.Exchange(ctx, ctx.Query("code"), opts)
+	if err != nil {
+		o.l.Warn(ctx, "授权码换 token 失败", logger.String("provider", p.Name()), logger.Error(err))
+		return nil, ErrOAuth2AuthFailed.Wrap(err)
+	}
+	identity, err := p.UserInfo(ctx, token)
+	if err != nil {
+		o.l.Error(ctx, "获取第三方用户信息失败", logger.String("provider", p.Name()), logger.Error(err))
+		return nil, ErrOAuth2AuthFailed.Wrap(err)
+	}
+	if claims.UserID != 0 {
+		if err = o.link(ctx, claims.UserID, identity); err != nil {
+			return nil, err
+		}
+		o.saveToken(ctx, claims.UserID, identity, token)
+		return nil, nil
+	}
+	user, err := o.userSvc.FindOrCreateByIdentity(ctx, identity)
+	if aerr := accountError(err); aerr != nil {
+		o.recordLogin(ctx, p.Name(), "", 0, nil, aerr)
+		return nil, aerr
+	}
+	if err != nil {
+		o.l.Error(ctx, "第三方登录失败", logger.String("provider", p.Name()), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	o.saveToken(ctx, user.ID, identity, token)
+	// 开启了二次验证的用户，第三方登录也要验证
+	enabled, err := o.mfaSvc.Enabled(ctx, user.ID)
+	if err != nil {
+		o.l.Error(ctx, "查询二次验证失败", logger.Int64("uid", user.ID), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	if enabled {
+		ticket, err := o.mfaSvc.CreateTicket(ctx, user.ID)
+		if err != nil {
+			o.l.Error(ctx, "生成二次验证票据失败", logger.Int64("uid", user.ID), logger.Error(err))
+			return nil, ginx.ErrInternal.Wrap(err)
+		}
+		return MFARequiredVO{MFARequired: true, Ticket: ticket}, nil
+	}
+	access, refresh, err := o.newJWTToken(ctx, user.ID, user.Locale)
+	if err != nil {
+		o.l.Error(ctx, "生成 JWT 失败", logger.Int64("uid", user.ID), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	o.recordLogin(ctx, p.Name(), "", user.ID, nil, nil)
+	return jwtTokens{access: access, refresh: refresh}, nil
+}
+
+func (o *OAuth2Handler) link(ctx *gin.Context, uid int64, identity domain.Identity) error {
+	err := o.userSvc.LinkIdentity(ctx, uid, identity)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, service.ErrIdentityLinked):
+		return ErrOAuth2IdentityLinked
+	default:
+		o.l.Error(ctx, "绑定第三方账号失败", logger.Int64("uid", uid),
+			logger.String("provider", identity.Provider), logger.Error(err))
+		return ginx.ErrInternal.Wrap(err)
+	}
+}
+
+// saveToken 目前只保存微信的 token，保存失败不影响登录，下次登录的时候会覆盖
+func (o *OAuth2Handler) saveToken(ctx *gin.Context, uid int64, identity domain.Identity, token oauth2.Token) {
+	if identity.Provider != domain.ProviderWechat {
+		return
+	}
+	if err := o.wechatTokenSvc.Save(ctx, uid, identity.Subject, token); err != nil {
+		o.l.Warn(ctx, "保存微信 token 失败", logger.Int64("uid", uid), logger.Error(err))
+	}
+}
+
+func (o *OAuth2Handler) verifyState(ctx *gin.Context, provider string) (StateClaims, error) {
+	var claims StateClaims
+	stateCookie, err := ctx.Cookie(o.stateCookieName)
+	if err != nil {
+		return claims, fmt.Errorf("state cookie 不存在: %w", err)
+	}
+	// 过期时间由 jwt 校验
+	_, err = jwt.ParseWithClaims(stateCookie, &claims, func(token *jwt.Token) (interface{}, error) {
+		return o.stateKey, nil
+	}, jwt.WithValidMethods([]string{o.signingMethod.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return claims, fmt.Errorf("state cookie 无效: %w", err)
+	}
+	if claims.Provider != provider {
+		return claims, errors.New("state 不是这个提供方的")
+	}
+	if state := ctx.Query("state"); state == "" || state != claims.State {
+		return claims, errors.New("state 不匹配")
+	}
+	return claims, nil
+}
+
+func (o *OAuth2Handler) setStateCookie(ctx *gin.Context, claims StateClaims) error {
+	token := jwt.NewWithClaims(o.signingMethod, claims)
+	tokenStr, err := token.SignedString(o.stateKey)
+	if err != nil {
+		return err
+	}
+	// 第三方跳转回来是顶级导航，Lax 会带上 cookie
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(o.stateCookieName, tokenStr, int(stateTTL.Seconds()), o.callbackPath(claims.Provider), "",
+		o.secureCookie, true)
+	return nil
+}
+
+func (o *OAuth2Handler) clearStateCookie(ctx *gin.Context, provider string) {
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(o.stateCookieName, "", -1, o.callbackPath(provider), "", o.secureCookie, true)
+}
+
+func (o *OAuth2Handler) callbackPath(provider string) string {
+	return "/oauth2/" + provider + "/callback"
+}
@@ -0,0 +1,468 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/service"
+	mocksvc "webook/internal/service/mock"
+	"webook/internal/service/oauth2"
+	"webook/internal/service/oauth2/oauth2_mocksvc"
+	"webook/internal/service/oauth2/oauth2test"
+	"webook/internal/service/oauth2/wechat"
+	"webook/pkg/logger"
+)
+
+func TestOAuth2Handler(t *testing.T) {
+	identity := domain.Identity{Provider: domain.ProviderGitHub, Subject: "1001"}
+	// mockProvider AuthURL 把 state 放在地址里面，Exchange 要拿到同一个 AuthOptions
+	mockProvider := func(ctrl *gomock.Controller, exchange bool) oauth2.Provider {
+		p := oauth2_mocksvc.NewMockProvider(ctrl)
+		p.EXPECT().Name().Return(domain.ProviderGitHub).AnyTimes()
+		var authOpts oauth2.AuthOptions
+		p.EXPECT().AuthURL(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, state string, opts oauth2.AuthOptions) (string, error) {
+				authOpts = opts
+				return "https://github.com/login/oauth/authorize?state=" + state, nil
+			})
+		if exchange {
+			p.EXPECT().Exchange(gomock.Any(), "code-1", gomock.Any()).
+				DoAndReturn(func(ctx context.Context, code string, opts oauth2.AuthOptions) (oauth2.Token, error) {
+					assert.Equal(t, authOpts, opts)
+					return oauth2.Token{AccessToken: "token-1"}, nil
+				})
+			p.EXPECT().UserInfo(gomock.Any(), oauth2.Token{AccessToken: "token-1"}).Return(identity, nil)
+		}
+		return p
+	}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (oauth2.Provider, service.UserService, service.MFAService)
+		// bind 已登录的用户绑定第三方账号
+		bind bool
+		// query 回调的参数，state 是授权地址里面的
+		query    func(state string) url.Values
+		noCookie bool
+
+		wantCode  int
+		wantBody  string
+		wantJWT   bool
+		wantClear bool
+	}{
+		{
+			name: "登录成功",
+			mock: func(ctrl *gomock.Controller) (oauth2.Provider, service.UserService, service.MFAService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				userSvc.EXPECT().FindOrCreateByIdentity(gomock.Any(), identity).Return(domain.User{ID: 1}, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(false, nil)
+				return mockProvider(ctrl, true), userSvc, mfaSvc
+			},
+			query: func(state string) url.Values {
+				return url.Values{"state": {state}, "code": {"code-1"}}
+			},
+			wantCode:  http.StatusOK,
+			wantBody:  `{"code":0,"msg":"OK","data":null}`,
+			wantClear: true,
+			wantJWT:   true,
+		},
+		{
+			name: "需要二次验证",
+			mock: func(ctrl *gomock.Controller) (oauth2.Provider, service.UserService, service.MFAService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				userSvc.EXPECT().FindOrCreateByIdentity(gomock.Any(), identity).Return(domain.User{ID: 1}, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(true, nil)
+				mfaSvc.EXPECT().CreateTicket(gomock.Any(), int64(1)).Return("ticket-1", nil)
+				return mockProvider(ctrl, true), userSvc, mfaSvc
+			},
+			query: func(state string) url.Values {
+				return url.Values{"state": {state}, "code": {"code-1"}}
+			},
+			wantCode:  http.StatusOK,
+			wantBody:  `{"code":0,"msg":"OK","data":{"mfa_required":true,"ticket":"ticket-1"}}`,
+			wantClear: true,
+		},
+		{
+			name: "state 不匹配",
+			mock: func(ctrl *gomock.Controller) (oauth2.Provider, service.UserService, service.MFAService) {
+				return mockProvider(ctrl, false), nil, nil
+			},
+			query: func(state string) url.Values {
+				return url.Values{"state": {"other-state"}, "code": {"code-1"}}
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":201001,"msg":"非法请求","data":null}`,
+		},
+		{
+			name: "没有 state cookie",
+			mock: func(ctrl *gomock.Controller) (oauth2.Provider, service.UserService, service.MFAService) {
+				return mockProvider(ctrl, false), nil, nil
+			},
+			query: func(state string) url.Values {
+				return url.Values{"state": {state}, "code": {"code-1"}}
+			},
+			noCookie: true,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":201001,"msg":"非法请求","data":null}`,
+		},
+		{
+			name: "用户拒绝授权",
+			mock: func(ctrl *gomock.Controller) (oauth2.Provider, service.UserService, service.MFAService) {
+				return mockProvider(ctrl, false), nil, nil
+			},
+			query: func(state string) url.Values {
+				return url.Values{"state": {state}, "error": {"access_denied"}}
+			},
+			wantCode:  http.StatusBadRequest,
+			wantBody:  `{"code":201002,"msg":"第三方授权失败","data":null}`,
+			wantClear: true,
+		},
+		{
+			name: "绑定成功",
+			mock: func(ctrl *gomock.Controller) (oauth2.Provider, service.UserService, service.MFAService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				userSvc.EXPECT().LinkIdentity(gomock.Any(), int64(123), identity).Return(nil)
+				return mockProvider(ctrl, true), userSvc, nil
+			},
+			bind: true,
+			query: func(state string) url.Values {
+				return url.Values{"state": {state}, "code": {"code-1"}}
+			},
+			wantCode:  http.StatusOK,
+			wantBody:  `{"code":0,"msg":"OK","data":null}`,
+			wantClear: true,
+		},
+		{
+			name: "已经绑定了其他用户",
+			mock: func(ctrl *gomock.Controller) (oauth2.Provider, service.UserService, service.MFAService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				userSvc.EXPECT().LinkIdentity(gomock.Any(), int64(123), identity).Return(service.ErrIdentityLinked)
+				return mockProvider(ctrl, true), userSvc, nil
+			},
+			bind: true,
+			query: func(state string) url.Values {
+				return url.Values{"state": {state}, "code": {"code-1"}}
+			},
+			wantCode:  http.StatusConflict,
+			wantBody:  `{"code":201004,"msg":"该第三方账号已经绑定了其他用户","data":null}`,
+			wantClear: true,
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) (oauth2.Provider, service.UserService, service.MFAService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				userSvc.EXPECT().FindOrCreateByIdentity(gomock.Any(), identity).
+					Return(domain.User{}, errors.New("db err"))
+				return mockProvider(ctrl, true), userSvc, nil
+			},
+			query: func(state string) url.Values {
+				return url.Values{"state": {state}, "code": {"code-1"}}
+			},
+			wantCode:  http.StatusInternalServerError,
+			wantBody:  `{"code":100000,"msg":"系统错误","data":null}`,
+			wantClear: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			p, userSvc, mfaSvc := tc.mock(ctrl)
+			// GitHub 的 token 不保存
+			hdl := NewOAuth2Handler(oauth2.NewRegistry(p), userSvc, mfaSvc, nil,
+				NewJWTHandler([]byte("access-key-for-test"), []byte("refresh-key-for-test")),
+				"", logger.NewNopLogger()).WithSecureCookie(true)
+			server := gin.New()
+			// 模拟登录中间件
+			server.Use(func(ctx *gin.Context) {
+				if ctx.GetHeader("Authorization") != "" {
+					ctx.Set(ClaimsKey, &UserClaims{UserID: 123})
+				}
+			})
+			hdl.RegisterRoutes(server)
+
+			// 1. 获取授权地址
+			authPath := "/oauth2/github/authurl"
+			req := httptest.NewRequest(http.MethodGet, authPath, nil)
+			if tc.bind {
+				req = httptest.NewRequest(http.MethodGet, "/oauth2/github/bind/authurl", nil)
+				req.Header.Set("Authorization", "Bearer token")
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+			require.Equal(t, http.StatusOK, recorder.Code)
+			var res Result
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+			authURL, err := url.Parse(res.Data.(string))
+			require.NoError(t, err)
+			cookies := recorder.Result().Cookies()
+			require.Len(t, cookies, 1)
+			assert.Equal(t, "/oauth2/github/callback", cookies[0].Path)
+			assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+			assert.True(t, cookies[0].HttpOnly)
+			assert.True(t, cookies[0].Secure)
+			// state 不能当成 access token 用
+			_, err = jwt.ParseWithClaims(cookies[0].Value, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
+				return []byte("access-key-for-test"), nil
+			})
+			assert.ErrorIs(t, err, jwt.ErrSignatureInvalid)
+
+			// 2. 第三方回调
+			req = httptest.NewRequest(http.MethodGet,
+				"/oauth2/github/callback?"+tc.query(authURL.Query().Get("state")).Encode(), nil)
+			if !tc.noCookie {
+				req.AddCookie(cookies[0])
+			}
+			recorder = httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantJWT, recorder.Header().Get("x-jwt-token") != "")
+			if tc.wantJWT {
+				assert.NotEmpty(t, recorder.Header().Get("x-refresh-token"))
+			}
+			// state 校验通过之后就删掉，校验失败的时候不删，避免伪造的回调打断正常的登录
+			if tc.wantClear {
+				cleared := recorder.Result().Cookies()
+				require.Len(t, cleared, 1)
+				assert.Equal(t, "oauth2_state", cleared[0].Name)
+				assert.True(t, cleared[0].MaxAge < 0)
+				assert.True(t, cleared[0].Secure)
+			}
+		})
+	}
+}
+
+// TestOAuth2Handler_Redirect 配置了前端地址，用本地的微信模拟服务走完整个流程
+func TestOAuth2Handler_Redirect(t *testing.T) {
+	const frontendURL = "http://localhost:3000/oauth2/callback"
+	wechatServer := oauth2test.NewWechatServer("wx-test", "wx-secret")
+	defer wechatServer.Close()
+	jwtHdl := NewJWTHandler([]byte("access-key-for-test"), []byte("refresh-key-for-test"))
+	wechatIdentity := domain.Identity{
+		Provider: domain.ProviderWechat,
+		Subject:  "openid-1001",
+		UnionID:  "unionid-1001",
+		Nickname: "微信用户",
+		Avatar:   "https://thirdwx.qlogo.cn/mmopen/1001/132",
+	}
+	// saveToken 模拟服务发的 token 是随机的，只检查 openid 和 refresh_token
+	saveToken := func(tokenSvc *mocksvc.MockWechatTokenService, err error) {
+		tokenSvc.EXPECT().Save(gomock.Any(), int64(1), "openid-1001", gomock.Any()).
+			DoAndReturn(func(ctx context.Context, uid int64, openID string, token oauth2.Token) error {
+				assert.NotEmpty(t, token.AccessToken)
+				assert.NotEmpty(t, token.RefreshToken)
+				return err
+			})
+	}
+
+	testCases := []struct {
+		name      string
+		mock      func(ctrl *gomock.Controller) (service.UserService, service.MFAService, service.WechatTokenService)
+		appSecret string
+		// cookie 修改 state cookie，返回 nil 表示不修改
+		cookie func(state string) *http.Cookie
+
+		wantFragment func(t *testing.T, fragment url.Values)
+	}{
+		{
+			name: "登录成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService, service.WechatTokenService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				tokenSvc := mocksvc.NewMockWechatTokenService(ctrl)
+				userSvc.EXPECT().FindOrCreateByIdentity(gomock.Any(), wechatIdentity).
+					Return(domain.User{ID: 1}, nil)
+				saveToken(tokenSvc, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(false, nil)
+				return userSvc, mfaSvc, tokenSvc
+			},
+			appSecret: "wx-secret",
+			wantFragment: func(t *testing.T, fragment url.Values) {
+				assert.Equal(t, "0", fragment.Get("code"))
+				var claims UserClaims
+				_, err := jwt.ParseWithClaims(fragment.Get("access_token"), &claims,
+					func(token *jwt.Token) (interface{}, error) {
+						return []byte("access-key-for-test"), nil
+					})
+				require.NoError(t, err)
+				assert.Equal(t, int64(1), claims.UserID)
+				assert.NotEmpty(t, fragment.Get("refresh_token"))
+			},
+		},
+		{
+			name: "保存 token 失败不影响登录",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService, service.WechatTokenService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				tokenSvc := mocksvc.NewMockWechatTokenService(ctrl)
+				userSvc.EXPECT().FindOrCreateByIdentity(gomock.Any(), wechatIdentity).
+					Return(domain.User{ID: 1}, nil)
+				saveToken(tokenSvc, errors.New("db error"))
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(false, nil)
+				return userSvc, mfaSvc, tokenSvc
+			},
+			appSecret: "wx-secret",
+			wantFragment: func(t *testing.T, fragment url.Values) {
+				assert.Equal(t, "0", fragment.Get("code"))
+				assert.NotEmpty(t, fragment.Get("access_token"))
+			},
+		},
+		{
+			name: "需要二次验证",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService, service.WechatTokenService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				tokenSvc := mocksvc.NewMockWechatTokenService(ctrl)
+				userSvc.EXPECT().FindOrCreateByIdentity(gomock.Any(), wechatIdentity).
+					Return(domain.User{ID: 1}, nil)
+				saveToken(tokenSvc, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(true, nil)
+				mfaSvc.EXPECT().CreateTicket(gomock.Any(), int64(1)).Return("ticket-1", nil)
+				return userSvc, mfaSvc, tokenSvc
+			},
+			appSecret: "wx-secret",
+			wantFragment: func(t *testing.T, fragment url.Values) {
+				assert.Equal(t, url.Values{
+					"code":         {"0"},
+					"msg":          {"OK"},
+					"mfa_required": {"true"},
+					"ticket":       {"ticket-1"},
+				}, fragment)
+			},
+		},
+		{
+			name: "微信授权失败",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService, service.WechatTokenService) {
+				return nil, nil, nil
+			},
+			appSecret: "wrong-secret",
+			wantFragment: func(t *testing.T, fragment url.Values) {
+				assert.Equal(t, url.Values{"code": {"201002"}, "msg": {"第三方授权失败"}}, fragment)
+			},
+		},
+		{
+			name: "state 过期",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService, service.WechatTokenService) {
+				return nil, nil, nil
+			},
+			appSecret: "wx-secret",
+			cookie: func(state string) *http.Cookie {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS512, StateClaims{
+					State:    state,
+					Provider: domain.ProviderWechat,
+					RegisteredClaims: jwt.RegisteredClaims{
+						ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+					},
+				})
+				tokenStr, err := token.SignedString(newStateKey([]byte("access-key-for-test")))
+				require.NoError(t, err)
+				return &http.Cookie{Name: "oauth2_state", Value: tokenStr}
+			},
+			wantFragment: func(t *testing.T, fragment url.Values) {
+				assert.Equal(t, url.Values{"code": {"201001"}, "msg": {"非法请求"}}, fragment)
+			},
+		},
+		{
+			name: "用 access token 的密钥签名的 state",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService, service.WechatTokenService) {
+				return nil, nil, nil
+			},
+			appSecret: "wx-secret",
+			cookie: func(state string) *http.Cookie {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS512, StateClaims{
+					State:    state,
+					Provider: domain.ProviderWechat,
+					RegisteredClaims: jwt.RegisteredClaims{
+						ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+					},
+				})
+				tokenStr, err := token.SignedString([]byte("access-key-for-test"))
+				require.NoError(t, err)
+				return &http.Cookie{Name: "oauth2_state", Value: tokenStr}
+			},
+			wantFragment: func(t *testing.T, fragment url.Values) {
+				assert.Equal(t, url.Values{"code": {"201001"}, "msg": {"非法请求"}}, fragment)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			userSvc, mfaSvc, tokenSvc := tc.mock(ctrl)
+			p := wechat.NewProvider(wechat.Config{
+				AppID:       "wx-test",
+				AppSecret:   tc.appSecret,
+				RedirectURL: "http://localhost:8080/oauth2/wechat/callback",
+				OpenURL:     wechatServer.URL,
+				APIURL:      wechatServer.URL,
+			})
+			hdl := NewOAuth2Handler(oauth2.NewRegistry(p), userSvc, mfaSvc, tokenSvc, jwtHdl, frontendURL,
+				logger.NewNopLogger())
+			server := gin.New()
+			hdl.RegisterRoutes(server)
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/oauth2/wechat/authurl", nil))
+			require.Equal(t, http.StatusOK, recorder.Code)
+			var res Result
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+			cookies := recorder.Result().Cookies()
+			require.Len(t, cookies, 1)
+			cookie := cookies[0]
+
+			// 用户扫码确认之后微信跳转回来
+			code, state, err := wechatServer.Authorize(res.Data.(string))
+			require.NoError(t, err)
+			if tc.cookie != nil {
+				cookie = tc.cookie(state)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/oauth2/wechat/callback?"+
+				url.Values{"code": {code}, "state": {state}}.Encode(), nil)
+			req.AddCookie(cookie)
+			recorder = httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			// 只有一次跳转，token 不在响应头里面
+			require.Equal(t, http.StatusFound, recorder.Code)
+			assert.Empty(t, recorder.Header().Get("x-jwt-token"))
+			assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+			location, err := url.Parse(recorder.Header().Get("Location"))
+			require.NoError(t, err)
+			assert.Equal(t, frontendURL, (&url.URL{Scheme: location.Scheme, Host: location.Host, Path: location.Path}).String())
+			fragment, err := url.ParseQuery(location.Fragment)
+			require.NoError(t, err)
+			tc.wantFragment(t, fragment)
+		})
+	}
+}
+
+func TestOAuth2Handler_ProviderNotFound(t *testing.T) {
+	hdl := NewOAuth2Handler(oauth2.NewRegistry(), nil, nil, nil,
+		NewJWTHandler([]byte("access-key-for-test"), []byte("refresh-key-for-test")),
+		"", logger.NewNopLogger())
+	server := gin.New()
+	hdl.RegisterRoutes(server)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/oauth2/unknown/authurl", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.JSONEq(t, `{"code":201003,"msg":"不支持该登录方式","data":null}`, recorder.Body.String())
+}
@@ -0,0 +1,185 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/service"
+	"webook/pkg/ginx"
+	"webook/pkg/logger"
+)
+
+// PasskeyBeginVO Options 直接传给 navigator.credentials.create/get，Session 在 finish 的时候带回来
+type PasskeyBeginVO struct {
+	Session string `json:"session"`
+	Options any    `json:"options"`
+}
+
+func (u *UserHandler) BeginPasskeyRegistration(ctx *gin.Context) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	user, err := u.svc.Profile(ctx, uid)
+	if err != nil {
+		u.l.Error(ctx, "查询用户失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	creation, session, err := u.passkeySvc.BeginRegistration(ctx, user)
+	if err != nil {
+		u.l.Error(ctx, "开始注册通行密钥失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	return PasskeyBeginVO{Session: session, Options: creation}, nil
+}
+
+type PasskeyRegisterReq struct {
+	Session string `json:"session" binding:"required,max=64"`
+	// Name 为空的时候前端用设备名
+	Name string `json:"name" binding:"max=64"`
+	// Credential navigator.credentials.create 返回的 PublicKeyCredential
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+func (u *UserHandler) FinishPasskeyRegistration(ctx *gin.Context, req PasskeyRegisterReq) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(req.Credential))
+	if err != nil {
+		return nil, ErrPasskeyInvalid
+	}
+	user, err := u.svc.Profile(ctx, uid)
+	if err != nil {
+		u.l.Error(ctx, "查询用户失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	_, err = u.passkeySvc.FinishRegistration(ctx, user, req.Session, req.Name, parsed)
+	if err = u.passkeyError(ctx, uid, err); err != nil {
+		return nil, err
+	}
+	u.recordEvent(ctx, domain.SecurityEvent{UserID: uid, Type: domain.SecurityPasskeyAdd})
+	return nil, nil
+}
+
+func (u *UserHandler) BeginPasskeyLogin(ctx *gin.Context) (any, error) {
+	assertion, session, err := u.passkeySvc.BeginLogin(ctx)
+	if err != nil {
+		u.l.Error(ctx, "开始通行密钥登录失败", logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	return PasskeyBeginVO{Session: session, Options: assertion}, nil
+}
+
+type PasskeyLoginReq struct {
+	Session string `json:"session" binding:"required,max=64"`
+	// Credential navigator.credentials.get 返回的 PublicKeyCredential
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+// FinishPasskeyLogin 通行密钥要求验证用户（指纹、PIN 等），本身就是多因素，不再要求 TOTP
+func (u *UserHandler) FinishPasskeyLogin(ctx *gin.Context, req PasskeyLoginReq) (resp any, err error) {
+	var uid int64
+	defer func() {
+		u.recordLogin(ctx, domain.LoginMethodPasskey, "", uid, resp, err)
+	}()
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(req.Credential))
+	if err != nil {
+		return nil, ErrPasskeyInvalid
+	}
+	uid, err = u.passkeySvc.FinishLogin(ctx, req.Session, parsed)
+	if err = u.passkeyError(ctx, 0, err); err != nil {
+		return nil, err
+	}
+	user, err := u.svc.Profile(ctx, uid)
+	if err != nil {
+		u.l.Error(ctx, "查询用户失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	if err = service.CheckStatus(user, time.Now()); err != nil {
+		return nil, accountError(err)
+	}
+	if err = u.setJWTToken(ctx, uid, user.Locale); err != nil {
+		u.l.Error(ctx, "设置 JWT 失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	return nil, nil
+}
+
+// PasskeyVO 不返回公钥之类的字段
+type PasskeyVO struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Synced     bool   `json:"synced"`
+	CreatedAt  int64  `json:"createdAt"`
+	LastUsedAt int64  `json:"lastUsedAt"`
+}
+
+func (u *UserHandler) ListPasskeys(ctx *gin.Context) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	passkeys, err := u.passkeySvc.List(ctx, uid)
+	if err != nil {
+		u.l.Error(ctx, "查询通行密钥失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	return newPasskeyVOs(passkeys), nil
+}
+
+func newPasskeyVOs(passkeys []domain.Passkey) []PasskeyVO {
+	res := make([]PasskeyVO, 0, len(passkeys))
+	for _, p := range passkeys {
+		res = append(res, PasskeyVO{
+			ID:         p.ID,
+			Name:       p.Name,
+			Synced:     p.BackupState,
+			CreatedAt:  p.CreatedAt,
+			LastUsedAt: p.LastUsedAt,
+		})
+	}
+	return res
+}
+
+type DeletePasskeyReq struct {
+	ID int64 `json:"id" binding:"required,min=1"`
+}
+
+func (u *UserHandler) DeletePasskey(ctx *gin.Context, req DeletePasskeyReq) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	err := u.passkeySvc.Delete(ctx, uid, req.ID)
+	if err = u.passkeyError(ctx, uid, err); err != nil {
+		return nil, err
+	}
+	u.recordEvent(ctx, domain.SecurityEvent{UserID: uid, Type: domain.SecurityPasskeyDelete})
+	return nil, nil
+}
+
+// passkeyError 校验失败的原因只记录日志，不返回给前端
+func (u *UserHandler) passkeyError(ctx *gin.Context, uid int64, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, service.ErrPasskeySessionInvalid):
+		return ErrPasskeySessionInvalid
+	case errors.Is(err, service.ErrPasskeyVerifyFailed):
+		u.l.Info(ctx, "通行密钥校验失败", logger.Int64("uid", uid), logger.Error(err))
+		return ErrPasskeyInvalid
+	case errors.Is(err, service.ErrPasskeyDuplicated):
+		return ErrPasskeyDuplicated
+	case errors.Is(err, service.ErrPasskeyNotFound):
+		return ErrPasskeyNotFound
+	default:
+		u.l.Error(ctx, "通行密钥操作失败", logger.Int64("uid", uid), logger.Error(err))
+		return ginx.ErrInternal.Wrap(err)
+	}
+}
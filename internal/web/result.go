@@ -1,7 +1,6 @@
 package web
 
-type Result struct {
-	Code int    `json:"code"`
-	Msg  string `json:"msg"`
-	Data any    `json:"data"`
-}
+import "webook/pkg/ginx"
+
+// Result 统一的响应格式
+type Result = ginx.Result
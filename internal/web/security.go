@@ -0,0 +1,142 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"webook/internal/domain"
+	"webook/internal/service"
+	"webook/pkg/ginx"
+	"webook/pkg/logger"
+)
+
+// recordEvent 补上请求来源，没有配置安全事件的时候什么都不做
+func (j *JWTHandler) recordEvent(ctx *gin.Context, evt domain.SecurityEvent) {
+	recordEvent(ctx, j.events, evt)
+}
+
+// recordMFALocked err 是二次验证的结果，这一次失败触发了锁定的时候记录安全事件
+func (j *JWTHandler) recordMFALocked(ctx *gin.Context, uid int64, err error) {
+	recordMFALocked(ctx, j.events, uid, err)
+}
+
+func recordEvent(ctx *gin.Context, events service.SecurityEventService, evt domain.SecurityEvent) {
+	if events == nil {
+		return
+	}
+	evt.Client = clientInfo(ctx)
+	events.Record(ctx, evt)
+}
+
+func recordMFALocked(ctx *gin.Context, events service.SecurityEventService, uid int64, err error) {
+	if uid == 0 || !errors.Is(err, service.ErrMFALockTriggered) {
+		return
+	}
+	recordEvent(ctx, events, domain.SecurityEvent{UserID: uid, Type: domain.SecurityTOTPLocked})
+}
+
+// recordLogin resp 和 err 是返回给前端的结果
+// 还要二次验证的时候不算登录成功，系统错误也不算登录失败
+func (j *JWTHandler) recordLogin(ctx *gin.Context, method, subject string, uid int64, resp any, err error) {
+	evt := domain.SecurityEvent{
+		UserID:  uid,
+		Type:    domain.SecurityLoginSuccess,
+		Method:  method,
+		Subject: subject,
+	}
+	if err != nil {
+		var e *ginx.Error
+		if !errors.As(err, &e) || errors.Is(err, ginx.ErrInternal) {
+			return
+		}
+		evt.Type = domain.SecurityLoginFailure
+		evt.Reason = e.MsgKey
+	} else if _, ok := resp.(MFARequiredVO); ok {
+		return
+	}
+	j.recordEvent(ctx, evt)
+}
+
+// SecurityHandler 用户查看自己的安全事件
+type SecurityHandler struct {
+	svc service.SecurityEventService
+	l   logger.Logger
+}
+
+func NewSecurityHandler(svc service.SecurityEventService, l logger.Logger) *SecurityHandler {
+	return &SecurityHandler{
+		svc: svc,
+		l:   l,
+	}
+}
+
+func (h *SecurityHandler) RegisterRoutes(server *gin.Engine) {
+	sg := server.Group("/users/security")
+	sg.GET("/events", ginx.WrapReq(h.Events))
+}
+
+type SecurityEventsReq struct {
+	// Cursor 上一页最后一个事件的 ID，第一页不传
+	Cursor int64 `form:"cursor" binding:"min=0"`
+	Limit  int   `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// SecurityEventVO 不返回登录用的账号和设备指纹
+type SecurityEventVO struct {
+	ID     int64  `json:"id"`
+	Type   string `json:"type"`
+	Method string `json:"method,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	IP     string `json:"ip"`
+	// UserAgent 前端解析成浏览器和系统展示
+	UserAgent  string        `json:"userAgent"`
+	Location   GeoLocationVO `json:"location"`
+	NewDevice  bool          `json:"newDevice"`
+	CreateTime int64         `json:"createTime"`
+}
+
+type GeoLocationVO struct {
+	CountryCode string `json:"countryCode"`
+	Country     string `json:"country"`
+	Region      string `json:"region"`
+	City        string `json:"city"`
+}
+
+func newSecurityEventVO(evt domain.SecurityEvent) SecurityEventVO {
+	return SecurityEventVO{
+		ID:        evt.ID,
+		Type:      evt.Type,
+		Method:    evt.Method,
+		Reason:    evt.Reason,
+		IP:        evt.Client.IP,
+		UserAgent: evt.Client.UserAgent,
+		Location: GeoLocationVO{
+			CountryCode: evt.Geo.CountryCode,
+			Country:     evt.Geo.Country,
+			Region:      evt.Geo.Region,
+			City:        evt.Geo.City,
+		},
+		NewDevice:  evt.NewDevice,
+		CreateTime: evt.CreatedAt,
+	}
+}
+
+func (h *SecurityHandler) Events(ctx *gin.Context, req SecurityEventsReq) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = 20
+	}
+	events, err := h.svc.List(ctx, uid, req.Cursor, limit)
+	if err != nil {
+		h.l.Error(ctx, "查询安全事件失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	res := make([]SecurityEventVO, 0, len(events))
+	for _, evt := range events {
+		res = append(res, newSecurityEventVO(evt))
+	}
+	return res, nil
+}
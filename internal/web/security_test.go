@@ -0,0 +1,308 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/service"
+	mocksvc "webook/internal/service/mock"
+	"webook/pkg/logger"
+)
+
+func TestSecurityHandler_Events(t *testing.T) {
+	testCases := []struct {
+		name  string
+		mock  func(ctrl *gomock.Controller) service.SecurityEventService
+		path  string
+		login bool
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "第一页",
+			mock: func(ctrl *gomock.Controller) service.SecurityEventService {
+				svc := mocksvc.NewMockSecurityEventService(ctrl)
+				svc.EXPECT().List(gomock.Any(), int64(123), int64(0), 20).Return([]domain.SecurityEvent{
+					{
+						ID:      2,
+						UserID:  123,
+						Type:    domain.SecurityLoginSuccess,
+						Method:  domain.LoginMethodPassword,
+						Subject: "tom@qq.com",
+						Client:  domain.ClientInfo{IP: "1.0.0.1", UserAgent: "Chrome", DeviceID: "device-1"},
+						Geo: domain.GeoLocation{CountryCode: "CN", Country: "China",
+							Region: "Beijing", City: "Beijing"},
+						NewDevice: true,
+						CreatedAt: 1700000000000,
+					},
+				}, nil)
+				return svc
+			},
+			path:     "/users/security/events",
+			login:    true,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":[{"id":2,"type":"login.success","method":"password",
+"ip":"1.0.0.1","userAgent":"Chrome","location":{"countryCode":"CN","country":"China","region":"Beijing",
+"city":"Beijing"},"newDevice":true,"createTime":1700000000000}]}`,
+		},
+		{
+			name: "翻页",
+			mock: func(ctrl *gomock.Controller) service.SecurityEventService {
+				svc := mocksvc.NewMockSecurityEventService(ctrl)
+				svc.EXPECT().List(gomock.Any(), int64(123), int64(2), 10).Return(nil, nil)
+				return svc
+			},
+			path:     "/users/security/events?cursor=2&limit=10",
+			login:    true,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":[]}`,
+		},
+		{
+			name: "一页太多",
+			mock: func(ctrl *gomock.Controller) service.SecurityEventService {
+				return mocksvc.NewMockSecurityEventService(ctrl)
+			},
+			path:     "/users/security/events?limit=101",
+			login:    true,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":100001,"msg":"参数错误","data":[{"field":"Limit","tag":"max","msg":"Limit 不合法"}]}`,
+		},
+		{
+			name: "没有登录",
+			mock: func(ctrl *gomock.Controller) service.SecurityEventService {
+				return mocksvc.NewMockSecurityEventService(ctrl)
+			},
+			path:     "/users/security/events",
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":100002,"msg":"未登录","data":null}`,
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) service.SecurityEventService {
+				svc := mocksvc.NewMockSecurityEventService(ctrl)
+				svc.EXPECT().List(gomock.Any(), int64(123), int64(0), 20).Return(nil, errors.New("db 错误"))
+				return svc
+			},
+			path:     "/users/security/events",
+			login:    true,
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"code":100000,"msg":"系统错误","data":null}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			hdl := NewSecurityHandler(tc.mock(ctrl), logger.NewNopLogger())
+			server := gin.New()
+			// 模拟登录中间件
+			server.Use(func(ctx *gin.Context) {
+				if tc.login {
+					ctx.Set(ClaimsKey, &UserClaims{UserID: 123})
+				}
+			})
+			hdl.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			assert.NoError(t, err)
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestUserHandler_LoginJWT_SecurityEvent(t *testing.T) {
+	client := domain.ClientInfo{IP: "192.0.2.1", UserAgent: "Chrome", DeviceID: "device-1"}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.UserService, service.MFAService,
+			service.SecurityEventService)
+
+		wantCode int
+	}{
+		{
+			name: "登录成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService,
+				service.SecurityEventService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				events := mocksvc.NewMockSecurityEventService(ctrl)
+				userSvc.EXPECT().Login(gomock.Any(), "tom@qq.com", "Hello#123").
+					Return(domain.User{ID: 1}, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(false, nil)
+				events.EXPECT().Record(gomock.Any(), domain.SecurityEvent{
+					UserID:  1,
+					Type:    domain.SecurityLoginSuccess,
+					Method:  domain.LoginMethodPassword,
+					Subject: "tom@qq.com",
+					Client:  client,
+				})
+				return userSvc, mfaSvc, events
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "密码错误，记录失败原因",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService,
+				service.SecurityEventService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				events := mocksvc.NewMockSecurityEventService(ctrl)
+				userSvc.EXPECT().Login(gomock.Any(), "tom@qq.com", "Hello#123").
+					Return(domain.User{}, service.ErrInvalidUserOrPassword)
+				events.EXPECT().Record(gomock.Any(), domain.SecurityEvent{
+					Type:    domain.SecurityLoginFailure,
+					Method:  domain.LoginMethodPassword,
+					Subject: "tom@qq.com",
+					Reason:  "user.invalid_user_or_password",
+					Client:  client,
+				})
+				return userSvc, nil, events
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "还要二次验证，不算登录成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService,
+				service.SecurityEventService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				userSvc.EXPECT().Login(gomock.Any(), "tom@qq.com", "Hello#123").
+					Return(domain.User{ID: 1}, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(true, nil)
+				mfaSvc.EXPECT().CreateTicket(gomock.Any(), int64(1)).Return("ticket-1", nil)
+				return userSvc, mfaSvc, mocksvc.NewMockSecurityEventService(ctrl)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "系统错误，不算登录失败",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.MFAService,
+				service.SecurityEventService) {
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				userSvc.EXPECT().Login(gomock.Any(), "tom@qq.com", "Hello#123").
+					Return(domain.User{}, errors.New("db 错误"))
+				return userSvc, nil, mocksvc.NewMockSecurityEventService(ctrl)
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			userSvc, mfaSvc, events := tc.mock(ctrl)
+			hdl := NewUserHandler(userSvc, nil, nil, mfaSvc, nil,
+				NewJWTHandler([]byte("access-key-for-test"), []byte("refresh-key-for-test")).
+					WithSecurityEvents(events),
+				nil, logger.NewNopLogger())
+			server := gin.New()
+			hdl.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login",
+				bytes.NewReader([]byte(`{"email":"tom@qq.com","password":"Hello#123"}`)))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", "Chrome")
+			req.Header.Set(deviceIDHeader, "device-1")
+			req.RemoteAddr = "192.0.2.1:1234"
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+		})
+	}
+}
+
+func TestUserHandler_Login2FA_LockedEvent(t *testing.T) {
+	client := domain.ClientInfo{IP: "192.0.2.1", UserAgent: "Chrome", DeviceID: "device-1"}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.MFAService, service.SecurityEventService)
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "这一次触发了锁定，记录锁定事件",
+			mock: func(ctrl *gomock.Controller) (service.MFAService, service.SecurityEventService) {
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				events := mocksvc.NewMockSecurityEventService(ctrl)
+				mfaSvc.EXPECT().VerifyTicket(gomock.Any(), "ticket-1", "123456").
+					Return(int64(1), service.ErrMFALockTriggered)
+				events.EXPECT().Record(gomock.Any(), domain.SecurityEvent{
+					UserID: 1,
+					Type:   domain.SecurityTOTPLocked,
+					Client: client,
+				})
+				events.EXPECT().Record(gomock.Any(), domain.SecurityEvent{
+					UserID: 1,
+					Type:   domain.SecurityLoginFailure,
+					Method: domain.LoginMethodTOTP,
+					Reason: "user.mfa_locked",
+					Client: client,
+				})
+				return mfaSvc, events
+			},
+			wantCode: http.StatusTooManyRequests,
+			wantBody: `{"code":200027,"msg":"二次验证错误次数太多，请稍后再试","data":null}`,
+		},
+		{
+			name: "已经锁定了，只记录登录失败",
+			mock: func(ctrl *gomock.Controller) (service.MFAService, service.SecurityEventService) {
+				mfaSvc := mocksvc.NewMockMFAService(ctrl)
+				events := mocksvc.NewMockSecurityEventService(ctrl)
+				mfaSvc.EXPECT().VerifyTicket(gomock.Any(), "ticket-1", "123456").
+					Return(int64(1), service.ErrMFALocked)
+				events.EXPECT().Record(gomock.Any(), domain.SecurityEvent{
+					UserID: 1,
+					Type:   domain.SecurityLoginFailure,
+					Method: domain.LoginMethodTOTP,
+					Reason: "user.mfa_locked",
+					Client: client,
+				})
+				return mfaSvc, events
+			},
+			wantCode: http.StatusTooManyRequests,
+			wantBody: `{"code":200027,"msg":"二次验证错误次数太多，请稍后再试","data":null}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mfaSvc, events := tc.mock(ctrl)
+			hdl := NewUserHandler(nil, nil, nil, mfaSvc, nil,
+				NewJWTHandler([]byte("access-key-for-test"), []byte("refresh-key-for-test")).
+					WithSecurityEvents(events),
+				nil, logger.NewNopLogger())
+			server := gin.New()
+			hdl.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login/2fa",
+				bytes.NewReader([]byte(`{"ticket":"ticket-1","code":"123456"}`)))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", "Chrome")
+			req.Header.Set(deviceIDHeader, "device-1")
+			req.RemoteAddr = "192.0.2.1:1234"
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
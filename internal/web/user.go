@@ -4,43 +4,42 @@ import (
 	"errors"
 	"github.com/gin-contrib/sessions"
 	"github.com/golang-jwt/jwt/v5"
-	"net/http"
 	"webook/internal/domain"
 	"webook/internal/service"
+	"webook/pkg/ginx"
+	"webook/pkg/i18n"
+	"webook/pkg/logger"
 
-	regexp "github.com/dlclark/regexp2"
 	"github.com/gin-gonic/gin"
 )
 
-const (
-	emailRegex    = `^\w+(-+.\w+)*@\w+(-.\w+)*.\w+(-.\w+)*$`
-	passwordRegex = `^(?=.*\d)(?=.*[a-z])(?=.*[A-Z])(?=.*[^a-zA-Z\d]).{8,20}$`
-	bizLogin      = "Login"
-)
-
-var (
-	// 正则校验
-	regexEmail    = regexp.MustCompile(emailRegex, 0)
-	regexPassword = regexp.MustCompile(passwordRegex, regexp.None)
-)
+const bizLogin = "Login"
 
 // UserHandler 定义用户相关路由
 type UserHandler struct {
-	svc     service.UserService
-	codeSvc service.CodeService
+	svc        service.UserService
+	codeSvc    service.CodeService
+	codeGuard  service.CodeGuard
+	mfaSvc     service.MFAService
+	passkeySvc service.PasskeyService
 	JWTHandler
-	regexpEmail    *regexp.Regexp
-	regexpPassword *regexp.Regexp
+	bundle *i18n.Bundle
+	l      logger.Logger
 }
 
-// NewUserHandler 新建一个UserHandler 包含email 和 password 的正则预编译
-func NewUserHandler(svc service.UserService, codeSvc service.CodeService) *UserHandler {
+// NewUserHandler 新建一个UserHandler，请求参数的校验见 validators.go
+func NewUserHandler(svc service.UserService, codeSvc service.CodeService,
+	codeGuard service.CodeGuard, mfaSvc service.MFAService, passkeySvc service.PasskeyService,
+	jwtHdl JWTHandler, bundle *i18n.Bundle, l logger.Logger) *UserHandler {
 	return &UserHandler{
-		svc:            svc,
-		codeSvc:        codeSvc,
-		JWTHandler:     NewJWTHandler(),
-		regexpEmail:    regexEmail,
-		regexpPassword: regexPassword,
+		svc:        svc,
+		codeSvc:    codeSvc,
+		codeGuard:  codeGuard,
+		mfaSvc:     mfaSvc,
+		passkeySvc: passkeySvc,
+		JWTHandler: jwtHdl,
+		bundle:     bundle,
+		l:          l,
 	}
 }
 
@@ -49,124 +48,133 @@ func (u *UserHandler) RegisterRoutes(server *gin.Engine) {
 	// todo
 	ug := server.Group("/users")
 	{
-		ug.POST("/signup", u.SignUp)
-		ug.POST("/login", u.LoginJWT)
-		ug.POST("/refresh_token", u.RefreshToken)
-		ug.POST("/logout", u.Logout)
-		ug.POST("/edit", u.Edit)
-		ug.GET("/profile", u.Profile)
+		ug.POST("/signup", ginx.WrapReq(u.SignUp))
+		ug.POST("/login", ginx.WrapReq(u.LoginJWT))
+		ug.POST("/refresh_token", ginx.Wrap(u.RefreshToken))
+		ug.POST("/logout", ginx.Wrap(u.LogoutJWT))
+		ug.POST("/edit", ginx.Wrap(u.Edit))
+		ug.GET("/profile", ginx.Wrap(u.Profile))
+		ug.POST("/locale", ginx.WrapReq(u.UpdateLocale))
+		ug.POST("/password", ginx.WrapReq(u.ChangePassword))
 	}
 	{
-		ug.POST("/login_sms/code/send", u.SendSmsCode) // 获取验证码
-		ug.POST("/login_sms", u.LoginBySMS)            // 校验验证码
-	}
-}
-
-func (u *UserHandler) SignUp(ctx *gin.Context) {
-	type SignUpReq struct {
-		Email           string `json:"email"`
-		Password        string `json:"password"`
-		ConfirmPassword string `json:"confirmPassword"`
-	}
-
-	var req SignUpReq
-	// 根据Content-Type 解析数据到 req中，
-	// 错误自动写回 400
-	// 注意要传地址
-	if err := ctx.Bind(&req); err != nil {
-		return
+		ug.POST("/login_sms/code/send", ginx.WrapReq(u.SendSmsCode)) // 获取验证码
+		ug.POST("/login_sms", ginx.WrapReq(u.LoginBySMS))            // 校验验证码
 	}
-
-	if isMatch, err := u.regexpEmail.MatchString(req.Email); err != nil {
-		// todo 记录日志
-		ctx.String(http.StatusInternalServerError, "系统错误")
-		return
-	} else if !isMatch {
-		ctx.String(http.StatusBadRequest, "无效邮箱")
-		return
+	{
+		ug.POST("/login/2fa", ginx.WrapReq(u.Login2FA))
+		ug.POST("/2fa/totp/enroll", ginx.Wrap(u.EnrollTOTP))
+		ug.POST("/2fa/totp/confirm", ginx.WrapReq(u.ConfirmTOTP))
+		ug.POST("/2fa/totp/disable", ginx.WrapReq(u.DisableTOTP))
 	}
-
-	if isMatch, err := u.regexpPassword.MatchString(req.Password); err != nil {
-		// todo 记录日志
-		ctx.String(http.StatusInternalServerError, "系统错误")
-		return
-	} else if !isMatch {
-		ctx.String(http.StatusBadRequest, "无效密码")
-		return
+	pg := ug.Group("/passkey")
+	{
+		pg.POST("/register/begin", ginx.Wrap(u.BeginPasskeyRegistration))
+		pg.POST("/register/finish", ginx.WrapReq(u.FinishPasskeyRegistration))
+		pg.POST("/login/begin", ginx.Wrap(u.BeginPasskeyLogin))
+		pg.POST("/login/finish", ginx.WrapReq(u.FinishPasskeyLogin))
+		pg.GET("/list", ginx.Wrap(u.ListPasskeys))
+		pg.POST("/delete", ginx.WrapReq(u.DeletePasskey))
 	}
+}
 
-	// 密码确认 校验
-	if req.ConfirmPassword != req.Password {
-		ctx.String(http.StatusBadRequest, "密码不一致")
-		return
-	}
+type SignUpReq struct {
+	Email string `json:"email" binding:"required,max=128,user_email"`
+	// Password 强度由 service 按照密码策略检查
+	Password        string `json:"password" binding:"required,max=128"`
+	ConfirmPassword string `json:"confirmPassword" binding:"required,eqfield=Password"`
+}
 
+func (u *UserHandler) SignUp(ctx *gin.Context, req SignUpReq) (any, error) {
 	// 调用service
 	err := u.svc.SignUp(ctx, domain.User{
 		Email:    req.Email,
 		Password: req.Password,
 	})
-
-	if err == service.ErrUserDuplicated {
-		ctx.String(http.StatusBadRequest, "该邮箱已被注册")
-		return
+	if errors.Is(err, service.ErrUserDuplicated) {
+		return nil, ErrEmailDuplicated
+	}
+	if fields, ok := passwordFieldErrors(ctx, "password", err); ok {
+		return fields, ginx.ErrInvalidParam
 	}
 	if err != nil {
-		ctx.String(http.StatusInternalServerError, "系统错误")
-		return
+		u.l.Error(ctx, "注册失败", logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
 	}
-
-	ctx.String(http.StatusOK, "注册成功")
-
+	return nil, nil
 }
 
-func (u *UserHandler) LoginJWT(ctx *gin.Context) {
-	type LoginReq struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+// accountError 账号被禁用、封禁或者已经注销的时候返回对应的错误码，其他错误返回 nil
+func accountError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return ErrUserNotFound
+	case errors.Is(err, service.ErrUserDisabled):
+		return ErrUserDisabled
+	case errors.Is(err, service.ErrUserBanned):
+		return ErrUserBanned
 	}
-	var req LoginReq
+	return nil
+}
 
-	// 解析JSON数据
-	if err := ctx.Bind(&req); err != nil {
-		return
-	}
+type LoginReq struct {
+	Email    string `json:"email" binding:"required,max=128"`
+	Password string `json:"password" binding:"required,max=64"`
+}
+
+func (u *UserHandler) LoginJWT(ctx *gin.Context, req LoginReq) (resp any, err error) {
+	var uid int64
+	defer func() {
+		u.recordLogin(ctx, domain.LoginMethodPassword, req.Email, uid, resp, err)
+	}()
 	// 身份校验
 	user, err := u.svc.Login(ctx, req.Email, req.Password)
-	switch err {
-	case nil:
-		err := u.setJWTToken(ctx, user.ID)
+	// 已经注销的账号也当成密码错误，免得用来探测账号
+	if errors.Is(err, service.ErrInvalidUserOrPassword) || errors.Is(err, service.ErrUserNotFound) {
+		return nil, ErrInvalidUserOrPassword
+	}
+	if aerr := accountError(err); aerr != nil {
+		return nil, aerr
+	}
+	if err != nil {
+		u.l.Error(ctx, "登录失败", logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	uid = user.ID
+	// 开启了二次验证的用户先拿票据，验证码校验通过之后才发 JWT
+	enabled, err := u.mfaSvc.Enabled(ctx, user.ID)
+	if err != nil {
+		u.l.Error(ctx, "查询二次验证失败", logger.Int64("uid", user.ID), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	if enabled {
+		ticket, err := u.mfaSvc.CreateTicket(ctx, user.ID)
 		if err != nil {
-			ctx.String(http.StatusOK, "系统错误")
+			u.l.Error(ctx, "生成二次验证票据失败", logger.Int64("uid", user.ID), logger.Error(err))
+			return nil, ginx.ErrInternal.Wrap(err)
 		}
-		ctx.String(http.StatusOK, "登录成功")
-	case service.ErrInvalidUserOrPassword:
-		ctx.String(http.StatusOK, "邮箱或密码错误")
-	default:
-		ctx.String(http.StatusOK, "系统错误")
+		return MFARequiredVO{MFARequired: true, Ticket: ticket}, nil
 	}
-}
-
-func (u *UserHandler) Login(ctx *gin.Context) {
-	type LoginReq struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+	if err = u.setJWTToken(ctx, user.ID, user.Locale); err != nil {
+		u.l.Error(ctx, "设置 JWT 失败", logger.Int64("uid", user.ID), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
 	}
-	var req LoginReq
+	return nil, nil
+}
 
-	// 解析JSON数据
-	if err := ctx.Bind(&req); err != nil {
-		return
-	}
+// Login 基于 session 的登录，没有注册路由，保留作为参考
+func (u *UserHandler) Login(ctx *gin.Context, req LoginReq) (any, error) {
 	// 身份校验
 	user, err := u.svc.Login(ctx, req.Email, req.Password)
-	if errors.Is(err, service.ErrInvalidUserOrPassword) {
-		ctx.String(http.StatusOK, "邮箱或密码错误")
-		return
+	// 已经注销的账号也当成密码错误，免得用来探测账号
+	if errors.Is(err, service.ErrInvalidUserOrPassword) || errors.Is(err, service.ErrUserNotFound) {
+		return nil, ErrInvalidUserOrPassword
+	}
+	if aerr := accountError(err); aerr != nil {
+		return nil, aerr
 	}
 	if err != nil {
-		ctx.String(http.StatusOK, "系统错误")
-		return
+		return nil, ginx.ErrInternal.Wrap(err)
 	}
 
 	// 2.设置session
@@ -175,131 +183,225 @@ func (u *UserHandler) Login(ctx *gin.Context) {
 	session.Options(sessions.Options{
 		MaxAge: 60 * 30, //30min
 	})
-	err = session.Save()
 	// session 保存失败
-	if err != nil {
-		ctx.String(http.StatusOK, "系统错误")
-		return
+	if err = session.Save(); err != nil {
+		return nil, ginx.ErrInternal.Wrap(err)
 	}
+	return nil, nil
+}
 
-	ctx.String(http.StatusOK, "登录成功")
-	return
+// LogoutJWT token 是无状态的，前端丢掉 token 就退出了，这里只记录安全事件
+func (u *UserHandler) LogoutJWT(ctx *gin.Context) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	u.recordEvent(ctx, domain.SecurityEvent{UserID: uid, Type: domain.SecurityLogout})
+	return nil, nil
 }
 
-func (u *UserHandler) Logout(ctx *gin.Context) {
+// Logout 基于 session 的退出，没有注册路由，保留作为参考
+func (u *UserHandler) Logout(ctx *gin.Context) (any, error) {
 	session := sessions.Default(ctx)
 	session.Options(sessions.Options{
 		MaxAge: -1,
 	})
-	err := session.Save()
+	if err := session.Save(); err != nil {
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	return nil, nil
+}
+
+func (u *UserHandler) Edit(ctx *gin.Context) (any, error) {
+	return nil, nil
+}
+
+// ProfileVO 返回给前端的个人信息，不包含密码之类的字段
+type ProfileVO struct {
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
+	Nickname string `json:"nickname"`
+	Avatar   string `json:"avatar"`
+	Locale   string `json:"locale"`
+	// DeleteAt 申请了注销的时候返回，前端提示可以撤销
+	DeleteAt int64 `json:"deleteAt,omitempty"`
+}
+
+func (u *UserHandler) Profile(ctx *gin.Context) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	user, err := u.svc.Profile(ctx, uid)
 	if err != nil {
-		return
+		u.l.Error(ctx, "查询个人信息失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
 	}
-	ctx.String(http.StatusOK, "退出登录成功...")
+	return ProfileVO{
+		Email:    user.Email,
+		Phone:    user.Phone,
+		Nickname: user.Nickname,
+		Avatar:   user.Avatar,
+		Locale:   user.Locale,
+		DeleteAt: user.DeleteAt,
+	}, nil
+}
+
+type ChangePasswordReq struct {
+	OldPassword     string `json:"oldPassword" binding:"required,max=128"`
+	NewPassword     string `json:"newPassword" binding:"required,max=128"`
+	ConfirmPassword string `json:"confirmPassword" binding:"required,eqfield=NewPassword"`
 }
 
-func (u *UserHandler) Edit(ctx *gin.Context) {
-	_ = ctx
+func (u *UserHandler) ChangePassword(ctx *gin.Context, req ChangePasswordReq) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	err := u.svc.ChangePassword(ctx, uid, req.OldPassword, req.NewPassword)
+	if errors.Is(err, service.ErrPasswordIncorrect) {
+		return nil, ErrPasswordIncorrect
+	}
+	if fields, ok := passwordFieldErrors(ctx, "newPassword", err); ok {
+		return fields, ginx.ErrInvalidParam
+	}
+	if err != nil {
+		u.l.Error(ctx, "修改密码失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	u.recordEvent(ctx, domain.SecurityEvent{UserID: uid, Type: domain.SecurityPasswordChange})
+	return nil, nil
 }
 
-func (u *UserHandler) Profile(ctx *gin.Context) {
-	ctx.String(200, "profile")
+type UpdateLocaleReq struct {
+	// Locale 为空表示清除偏好，跟随 Accept-Language
+	Locale string `json:"locale" binding:"max=16"`
 }
 
-func (h *UserHandler) LoginBySMS(ctx *gin.Context) {
-	type Req struct {
-		Phone string `json:"phone"`
-		Code  string `json:"code"`
+func (u *UserHandler) UpdateLocale(ctx *gin.Context, req UpdateLocaleReq) (any, error) {
+	uid, ok := UserIDFromContext(ctx)
+	if !ok {
+		return nil, ginx.ErrUnauthorized
+	}
+	locale := req.Locale
+	if locale != "" {
+		if !u.bundle.Supported(locale) {
+			return nil, ErrLocaleUnsupported
+		}
+		// 统一成 en-US 这种写法
+		locale = u.bundle.Match(locale).String()
+	}
+	if err := u.svc.UpdateLocale(ctx, uid, locale); err != nil {
+		u.l.Error(ctx, "修改语言偏好失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
 	}
-	var req Req
-	if err := ctx.Bind(&req); err != nil {
-		return
+	// 语言偏好在 JWT 里面，重新签发才能生效
+	if err := u.setJWTToken(ctx, uid, locale); err != nil {
+		u.l.Error(ctx, "设置 JWT 失败", logger.Int64("uid", uid), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
 	}
+	return nil, nil
+}
+
+type LoginSMSReq struct {
+	// Phone E.164 格式，比如 +8613800138000
+	Phone string `json:"phone" binding:"required,e164"`
+	Code  string `json:"code" binding:"required,len=6,numeric"`
+}
 
+func (h *UserHandler) LoginBySMS(ctx *gin.Context, req LoginSMSReq) (resp any, err error) {
+	var uid int64
+	defer func() {
+		h.recordLogin(ctx, domain.LoginMethodSMS, req.Phone, uid, resp, err)
+	}()
 	ok, err := h.codeSvc.Verify(ctx, bizLogin, req.Phone, req.Code)
 	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Code: 5,
-			Msg:  "系统异常",
-		})
-		return
+		h.l.Error(ctx, "校验验证码失败", logger.String("phone", req.Phone), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
 	}
 	if !ok {
-		ctx.JSON(http.StatusOK, Result{
-			Code: 4,
-			Msg:  "验证码不对，请重新输入",
-		})
-		return
+		return nil, ErrCodeInvalid
 	}
+	h.recordEvent(ctx, domain.SecurityEvent{
+		Type:    domain.SecuritySMSVerified,
+		Method:  domain.LoginMethodSMS,
+		Subject: req.Phone,
+	})
 	u, err := h.svc.FindOrCreate(ctx, req.Phone)
+	if aerr := accountError(err); aerr != nil {
+		return nil, aerr
+	}
 	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Code: 5,
-			Msg:  "系统错误",
-		})
-		return
+		h.l.Error(ctx, "手机号登录失败", logger.String("phone", req.Phone), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
 	}
-	h.setJWTToken(ctx, u.ID)
-	ctx.JSON(http.StatusOK, Result{
-		Code: 200,
-		Msg:  "登录成功",
-	})
+	uid = u.ID
+	if err = h.setJWTToken(ctx, u.ID, u.Locale); err != nil {
+		h.l.Error(ctx, "设置 JWT 失败", logger.Int64("uid", u.ID), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
+	}
+	return nil, nil
 }
 
-func (h *UserHandler) SendSmsCode(ctx *gin.Context) {
-	type Req struct {
-		Phone string `json:"phone"`
-	}
-	var req Req
-	if err := ctx.Bind(&req); err != nil {
-		return
-	}
-	// 你这边可以校验 Req
-	if req.Phone == "" {
-		ctx.JSON(http.StatusOK, Result{
-			Code: 4,
-			Msg:  "请输入手机号码",
-		})
-		return
+type SendSMSCodeReq struct {
+	// Phone E.164 格式，比如 +8613800138000
+	Phone string `json:"phone" binding:"required,e164"`
+	// 触发防刷之后，前端完成人机验证拿到的票据
+	Captcha string `json:"captcha" binding:"max=2048"`
+}
+
+func (h *UserHandler) SendSmsCode(ctx *gin.Context, req SendSMSCodeReq) (any, error) {
+	client := clientInfo(ctx)
+	err := h.codeGuard.Check(ctx, bizLogin, req.Phone, client, req.Captcha)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrCaptchaRequired):
+		// 告诉前端弹出人机验证
+		return map[string]bool{"captcha": true}, ErrCaptchaRequired
+	case errors.Is(err, service.ErrCodeSendLimited):
+		return nil, ErrCodeSendLimited
+	default:
+		h.l.Error(ctx, "验证码防刷检查失败", logger.String("phone", req.Phone), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
 	}
-	err := h.codeSvc.Send(ctx, bizLogin, req.Phone)
-	switch err {
-	case nil:
-		ctx.JSON(http.StatusOK, Result{
-			Msg: "发送成功",
-		})
-	case service.ErrCodeSendTooMany:
-		ctx.JSON(http.StatusOK, Result{
-			Code: 4,
-			Msg:  "短信发送太频繁，请稍后再试",
+	err = h.codeSvc.Send(ctx, bizLogin, req.Phone)
+	switch {
+	case err == nil:
+		// 只有真正发出去的验证码才计数，验证码已经发了，计数失败不影响这次请求
+		if err = h.codeGuard.Record(ctx, bizLogin, req.Phone, client); err != nil {
+			h.l.Error(ctx, "验证码发送计数失败", logger.String("phone", req.Phone), logger.Error(err))
+		}
+		h.recordEvent(ctx, domain.SecurityEvent{
+			Type:    domain.SecuritySMSSent,
+			Method:  domain.LoginMethodSMS,
+			Subject: req.Phone,
 		})
+		return nil, nil
+	case errors.Is(err, service.ErrCodeSendTooMany):
+		return nil, ErrCodeSendTooMany
 	default:
-		ctx.JSON(http.StatusOK, Result{
-			Code: 5,
-			Msg:  "系统错误",
-		})
-		// todo：补日志
+		h.l.Error(ctx, "发送验证码失败", logger.String("phone", req.Phone), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
 	}
 }
 
-func (u *UserHandler) RefreshToken(ctx *gin.Context) {
+func (u *UserHandler) RefreshToken(ctx *gin.Context) (any, error) {
 	tokenStr := ParseToken(ctx)
 	var claims RefreshClaims
 	token, err := jwt.ParseWithClaims(tokenStr, &claims, func(token *jwt.Token) (interface{}, error) {
 		return u.refresh_key, nil
 	})
-	if err != nil {
-		ctx.AbortWithStatus(http.StatusUnauthorized)
-		return
+	if err != nil || token == nil || !token.Valid {
+		return nil, ginx.ErrUnauthorized
 	}
-	if token == nil || !token.Valid {
-		ctx.AbortWithStatus(http.StatusUnauthorized)
-		return
+	if u.revoked(ctx, claims.UserID, claims.IssuedAt) {
+		return nil, ginx.ErrUnauthorized
 	}
-	err = u.setJWTToken(ctx, claims.UserID)
-	if err != nil {
-		ctx.String(http.StatusBadRequest, "系统错误")
-		return
+	// 刷新不算重新登录，新的 access token 不带登录时间
+	if err = u.setTokens(ctx, claims.UserID, claims.Locale, 0); err != nil {
+		u.l.Error(ctx, "刷新 JWT 失败", logger.Int64("uid", claims.UserID), logger.Error(err))
+		return nil, ginx.ErrInternal.Wrap(err)
 	}
-	ctx.String(http.StatusOK, "刷新成功")
+	u.recordEvent(ctx, domain.SecurityEvent{UserID: claims.UserID, Type: domain.SecurityTokenRefresh})
+	return nil, nil
 }
@@ -5,7 +5,9 @@ import (
 	"context"
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	"net/http"
 	"net/http/httptest"
@@ -13,6 +15,8 @@ import (
 	"webook/internal/domain"
 	"webook/internal/service"
 	mocksvc "webook/internal/service/mock"
+	"webook/pkg/logger"
+	"webook/pkg/password"
 )
 
 // 测试UserHandler注册路由
@@ -49,7 +53,7 @@ func TestUserHandler_SignUp(t *testing.T) {
 				return req
 			},
 			wantCode: 200,
-			wantBody: "注册成功",
+			wantBody: `{"code":0,"msg":"OK","data":null}`,
 		},
 		{
 			name: "Bind 失败",
@@ -69,6 +73,7 @@ func TestUserHandler_SignUp(t *testing.T) {
 				return req
 			},
 			wantCode: 400,
+			wantBody: `{"code":100001,"msg":"参数错误","data":null}`,
 		},
 		{
 			name: "无效邮箱",
@@ -86,12 +91,17 @@ func TestUserHandler_SignUp(t *testing.T) {
 				return req
 			},
 			wantCode: 400,
-			wantBody: "无效邮箱",
+			wantBody: `{"code":100001,"msg":"参数错误","data":[{"field":"email","tag":"user_email","msg":"email 不合法"}]}`,
 		},
 		{
-			name: "无效密码",
+			name: "密码不满足策略",
 			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
-				return nil, nil
+				userSvc := mocksvc.NewMockUserService(ctrl)
+				userSvc.EXPECT().SignUp(gomock.Any(), domain.User{
+					Email:    "111@qq.com",
+					Password: "1111",
+				}).Return(&password.PolicyError{Rule: password.RuleMinLength, Param: "8"})
+				return userSvc, nil
 			},
 			reqBuild: func(t *testing.T) *http.Request {
 				req, err := http.NewRequest(http.MethodPost, "/users/signup", bytes.NewReader([]byte(`{
@@ -104,7 +114,7 @@ func TestUserHandler_SignUp(t *testing.T) {
 				return req
 			},
 			wantCode: 400,
-			wantBody: "无效密码",
+			wantBody: `{"code":100001,"msg":"参数错误","data":[{"field":"password","tag":"password_min_length","msg":"password 不合法"}]}`,
 		},
 		{
 			name: "密码不一致",
@@ -122,7 +132,7 @@ func TestUserHandler_SignUp(t *testing.T) {
 				return req
 			},
 			wantCode: 400,
-			wantBody: "密码不一致",
+			wantBody: `{"code":100001,"msg":"参数错误","data":[{"field":"confirmPassword","tag":"eqfield","msg":"confirmPassword 不合法"}]}`,
 		},
 		{
 			name: "该邮箱已被注册",
@@ -145,8 +155,8 @@ func TestUserHandler_SignUp(t *testing.T) {
 				assert.NoError(t, err)
 				return req
 			},
-			wantCode: 400,
-			wantBody: "该邮箱已被注册",
+			wantCode: 409,
+			wantBody: `{"code":200004,"msg":"该邮箱已被注册","data":null}`,
 		},
 		{
 			name: "系统错误",
@@ -170,7 +180,7 @@ func TestUserHandler_SignUp(t *testing.T) {
 				return req
 			},
 			wantCode: 500,
-			wantBody: "系统错误",
+			wantBody: `{"code":100000,"msg":"系统错误","data":null}`,
 		},
 	}
 
@@ -181,7 +191,7 @@ func TestUserHandler_SignUp(t *testing.T) {
 
 			//mock需要的service
 			userSvc, codeSvc := tc.mock(ctrl)
-			hdl := NewUserHandler(userSvc, codeSvc)
+			hdl := NewUserHandler(userSvc, codeSvc, nil, nil, nil, JWTHandler{}, nil, logger.NewNopLogger())
 
 			// 构造server & 注册路由
 			server := gin.Default()
@@ -200,6 +210,96 @@ func TestUserHandler_SignUp(t *testing.T) {
 	}
 }
 
+func TestUserHandler_SendSmsCode(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.CodeService, service.CodeGuard)
+		body string
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "发送成功",
+			mock: func(ctrl *gomock.Controller) (service.CodeService, service.CodeGuard) {
+				codeSvc := mocksvc.NewMockCodeService(ctrl)
+				guard := mocksvc.NewMockCodeGuard(ctrl)
+				guard.EXPECT().Check(gomock.Any(), bizLogin, "+8615212345678", gomock.Any(), "").Return(nil)
+				codeSvc.EXPECT().Send(gomock.Any(), bizLogin, "+8615212345678").Return(nil)
+				guard.EXPECT().Record(gomock.Any(), bizLogin, "+8615212345678", gomock.Any()).Return(nil)
+				return codeSvc, guard
+			},
+			body:     `{"phone":"+8615212345678"}`,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":null}`,
+		},
+		{
+			name: "发送太频繁，不计数",
+			mock: func(ctrl *gomock.Controller) (service.CodeService, service.CodeGuard) {
+				codeSvc := mocksvc.NewMockCodeService(ctrl)
+				guard := mocksvc.NewMockCodeGuard(ctrl)
+				guard.EXPECT().Check(gomock.Any(), bizLogin, "+8615212345678", gomock.Any(), "").Return(nil)
+				codeSvc.EXPECT().Send(gomock.Any(), bizLogin, "+8615212345678").Return(service.ErrCodeSendTooMany)
+				return codeSvc, guard
+			},
+			body:     `{"phone":"+8615212345678"}`,
+			wantCode: http.StatusTooManyRequests,
+			wantBody: `{"code":200009,"msg":"短信发送太频繁，请稍后再试","data":null}`,
+		},
+		{
+			name: "手机号码格式不对",
+			mock: func(ctrl *gomock.Controller) (service.CodeService, service.CodeGuard) {
+				return nil, nil
+			},
+			body:     `{"phone":"15212345678"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":100001,"msg":"参数错误","data":[{"field":"phone","tag":"e164","msg":"phone 不合法"}]}`,
+		},
+		{
+			name: "缺少手机号码",
+			mock: func(ctrl *gomock.Controller) (service.CodeService, service.CodeGuard) {
+				return nil, nil
+			},
+			body:     `{}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":100001,"msg":"参数错误","data":[{"field":"phone","tag":"required","msg":"phone 不合法"}]}`,
+		},
+		{
+			name: "需要人机验证",
+			mock: func(ctrl *gomock.Controller) (service.CodeService, service.CodeGuard) {
+				guard := mocksvc.NewMockCodeGuard(ctrl)
+				guard.EXPECT().Check(gomock.Any(), bizLogin, "+8615212345678", gomock.Any(), "").
+					Return(service.ErrCaptchaRequired)
+				return nil, guard
+			},
+			body:     `{"phone":"+8615212345678"}`,
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":200007,"msg":"请先完成人机验证","data":{"captcha":true}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			codeSvc, guard := tc.mock(ctrl)
+			hdl := NewUserHandler(nil, codeSvc, guard, nil, nil, JWTHandler{}, nil, logger.NewNopLogger())
+			server := gin.New()
+			hdl.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login_sms/code/send", bytes.NewReader([]byte(tc.body)))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
 func TestMock(t *testing.T) {
 	// mock使用
 	// 初始化控制器
@@ -219,3 +319,44 @@ func TestMock(t *testing.T) {
 	})
 	t.Log(err)
 }
+
+// TestUserHandler_RefreshToken_AuthenticatedAt 登录的时候记下登录时间，刷新出来的 token 不带
+func TestUserHandler_RefreshToken_AuthenticatedAt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userSvc := mocksvc.NewMockUserService(ctrl)
+	mfaSvc := mocksvc.NewMockMFAService(ctrl)
+	userSvc.EXPECT().Login(gomock.Any(), "tom@qq.com", "Hello#123").Return(domain.User{ID: 1}, nil)
+	mfaSvc.EXPECT().Enabled(gomock.Any(), int64(1)).Return(false, nil)
+	accessKey := []byte("access-key-for-test")
+	hdl := NewUserHandler(userSvc, nil, nil, mfaSvc, nil,
+		NewJWTHandler(accessKey, []byte("refresh-key-for-test")), nil, logger.NewNopLogger())
+	server := gin.New()
+	hdl.RegisterRoutes(server)
+	parse := func(tokenStr string) UserClaims {
+		var claims UserClaims
+		_, err := jwt.ParseWithClaims(tokenStr, &claims, func(token *jwt.Token) (interface{}, error) {
+			return accessKey, nil
+		})
+		require.NoError(t, err)
+		return claims
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/users/login",
+		bytes.NewReader([]byte(`{"email":"tom@qq.com","password":"Hello#123"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.NotZero(t, parse(recorder.Header().Get("x-jwt-token")).AuthenticatedAt)
+
+	req = httptest.NewRequest(http.MethodPost, "/users/refresh_token", nil)
+	req.Header.Set("Authorization", "Bearer "+recorder.Header().Get("x-refresh-token"))
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	claims := parse(recorder.Header().Get("x-jwt-token"))
+	assert.Equal(t, int64(1), claims.UserID)
+	assert.Zero(t, claims.AuthenticatedAt)
+}
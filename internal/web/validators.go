@@ -0,0 +1,38 @@
+package web
+
+import (
+	"errors"
+	regexp "github.com/dlclark/regexp2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"webook/pkg/ginx"
+	"webook/pkg/password"
+)
+
+const emailRegex = `^\w+(-+.\w+)*@\w+(-.\w+)*.\w+(-.\w+)*$`
+
+var regexEmail = regexp.MustCompile(emailRegex, 0)
+
+// 请求结构体上的自定义校验规则：user_email 邮箱格式
+// 密码强度需要结合邮箱、历史密码判断，在 service 里面按照密码策略检查，见 passwordFieldErrors
+func init() {
+	ginx.RegisterValidation("user_email", regexValidator(regexEmail))
+}
+
+// passwordFieldErrors 密码不满足策略的时候，和 binding tag 一样返回字段详情，tag 是 password_<rule>
+func passwordFieldErrors(ctx *gin.Context, field string, err error) ([]ginx.FieldError, bool) {
+	var pe *password.PolicyError
+	if !errors.As(err, &pe) {
+		return nil, false
+	}
+	return []ginx.FieldError{
+		ginx.NewFieldError(ctx.Request.Context(), field, "password_"+pe.Rule, pe.Param),
+	}, true
+}
+
+func regexValidator(re *regexp.Regexp) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		ok, err := re.MatchString(fl.Field().String())
+		return err == nil && ok
+	}
+}
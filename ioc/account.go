@@ -0,0 +1,21 @@
+package ioc
+
+import (
+	"webook/config"
+	"webook/internal/job"
+	"webook/internal/repository"
+	"webook/internal/service"
+	"webook/pkg/logger"
+	"webook/pkg/password"
+)
+
+func InitAccountService(userRepo repository.UserRepository, passkeyRepo repository.PasskeyRepository,
+	roleRepo repository.RoleRepository, eventRepo repository.SecurityEventRepository, mfaSvc service.MFAService,
+	sessions service.SessionService, hasher password.Hasher, l logger.Logger) service.AccountService {
+	return service.NewAccountService(userRepo, passkeyRepo, roleRepo, eventRepo, mfaSvc, sessions, hasher,
+		config.Config.Account.DeleteGracePeriod, l)
+}
+
+func InitAccountPurgeJob(svc service.AccountService, l logger.Logger) *job.AccountPurgeJob {
+	return job.NewAccountPurgeJob(svc, config.Config.Account.PurgeInterval, l)
+}
@@ -0,0 +1,35 @@
+package ioc
+
+import (
+	"context"
+	"webook/config"
+	"webook/internal/service/captcha"
+	"webook/internal/service/captcha/localcaptcha"
+	"webook/internal/service/captcha/nocaptcha"
+	"webook/internal/service/captcha/siteverify"
+	"webook/pkg/logger"
+)
+
+func InitCaptchaService(l logger.Logger) captcha.Service {
+	cfg := config.Config.Captcha
+	switch cfg.Provider {
+	case "local":
+		return localcaptcha.NewService(l)
+	case "turnstile":
+		return siteverify.NewService(verifyURL(cfg, siteverify.TurnstileURL), cfg.Secret)
+	case "recaptcha":
+		return siteverify.NewService(verifyURL(cfg, siteverify.RecaptchaURL), cfg.Secret)
+	case "hcaptcha":
+		return siteverify.NewService(verifyURL(cfg, siteverify.HCaptchaURL), cfg.Secret)
+	default:
+		l.Warn(context.Background(), "没有配置人机验证，需要人机验证的请求都会被拒绝")
+		return nocaptcha.NewService()
+	}
+}
+
+func verifyURL(cfg config.CaptchaConfig, def string) string {
+	if cfg.VerifyURL != "" {
+		return cfg.VerifyURL
+	}
+	return def
+}
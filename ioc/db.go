@@ -5,6 +5,7 @@ import (
 	"gorm.io/gorm"
 	"webook/config"
 	"webook/internal/repository/dao"
+	"webook/pkg/gormx"
 )
 
 func InitDB() *gorm.DB {
@@ -12,6 +13,14 @@ func InitDB() *gorm.DB {
 	if err != nil {
 		panic(err)
 	}
+	err = db.Use(gormx.NewPrometheusPlugin("webook", "gorm", "query_duration_seconds", "SQL 执行时间"))
+	if err != nil {
+		panic(err)
+	}
+	err = db.Use(gormx.NewTracingPlugin())
+	if err != nil {
+		panic(err)
+	}
 	err = dao.InitTable(db)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,16 @@
+package ioc
+
+import (
+	"golang.org/x/text/language"
+	"webook/locales"
+	"webook/pkg/i18n"
+)
+
+// InitI18n 默认简体中文
+func InitI18n() *i18n.Bundle {
+	bundle := i18n.NewBundle(language.MustParse("zh-CN"))
+	if err := bundle.LoadFS(locales.FS, "."); err != nil {
+		panic(err)
+	}
+	return bundle
+}
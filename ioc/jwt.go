@@ -0,0 +1,16 @@
+package ioc
+
+import (
+	"webook/config"
+	"webook/internal/service"
+	"webook/internal/web"
+)
+
+func InitJWTHandler(roleSvc service.RoleService, sessions service.SessionService,
+	events service.SecurityEventService) web.JWTHandler {
+	cfg := config.Config.JWT
+	return web.NewJWTHandler([]byte(cfg.AccessKey), []byte(cfg.RefreshKey)).
+		WithRoles(roleSvc).
+		WithSessions(sessions).
+		WithSecurityEvents(events)
+}
@@ -0,0 +1,96 @@
+package ioc
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+	"webook/config"
+	"webook/pkg/ginx/middleware/ratelimit"
+	"webook/pkg/limiter"
+	"webook/pkg/logger"
+)
+
+// newLimiter 根据配置选择限流算法和 Redis 不可用时的降级策略
+func newLimiter(cmd redis.Cmdable, interval time.Duration, rate int) limiter.Limiter {
+	cfg := config.Config.Limiter
+	var l limiter.Limiter
+	switch cfg.Type {
+	case "", "sliding_window":
+		l = limiter.NewRedisSlidingWindowLimiter(cmd, interval, rate)
+	case "fixed_window":
+		l = limiter.NewRedisFixedWindowLimiter(cmd, interval, rate)
+	case "token_bucket":
+		l = limiter.NewRedisTokenBucketLimiter(cmd, interval, rate)
+	case "local":
+		return limiter.NewLocalTokenBucketLimiter(interval, rate)
+	default:
+		panic(fmt.Sprintf("未知的限流算法 %s", cfg.Type))
+	}
+
+	var fallback limiter.Limiter
+	if cfg.FailPolicy == "local" {
+		// 每个实例只承担自己那一份流量
+		localRate := rate
+		if cfg.Instances > 1 {
+			localRate = max(rate/cfg.Instances, 1)
+		}
+		fallback = limiter.NewLocalTokenBucketLimiter(interval, localRate)
+	}
+	// 所有限流器和缓存共用一个健康检查
+	return limiter.NewFailoverLimiter(l, fallback, redisHealth(cmd))
+}
+
+func limiterFailPolicy() ratelimit.FailPolicy {
+	if config.Config.Limiter.FailPolicy == "open" {
+		return ratelimit.FailOpen
+	}
+	return ratelimit.FailClosed
+}
+
+// initRouteRules 加载路由限流规则，配置文件里的规则变更之后自动生效
+// 按用户限流的规则要拿到登录态，交给登录中间件后面的 userBuilder，其他的交给前面的 ipBuilder
+func initRouteRules(ipBuilder, userBuilder *ratelimit.Builder, l logger.Logger) {
+	if err := setRouteRules(ipBuilder, userBuilder, config.Config.Limiter.Rules); err != nil {
+		panic(err)
+	}
+	config.OnChange("limiter.rules", func(cfg config.AppConfig) {
+		// 规则不合法的时候两边都保留原来的规则
+		if err := setRouteRules(ipBuilder, userBuilder, cfg.Limiter.Rules); err != nil {
+			l.Error(context.Background(), "加载限流规则失败", logger.Error(err))
+		}
+	})
+}
+
+// setRouteRules 两边的规则都校验通过之后才一起替换，不会出现一半新一半旧的配置
+func setRouteRules(ipBuilder, userBuilder *ratelimit.Builder, rules []config.LimiterRule) error {
+	ipRules, userRules := toRouteRules(rules)
+	ipTable, err := ipBuilder.CompileRouteRules(ipRules)
+	if err != nil {
+		return err
+	}
+	userTable, err := userBuilder.CompileRouteRules(userRules)
+	if err != nil {
+		return fmt.Errorf("按用户限流的规则不合法: %w", err)
+	}
+	ipTable.Apply()
+	userTable.Apply()
+	return nil
+}
+
+func toRouteRules(rules []config.LimiterRule) (ipRules, userRules []ratelimit.RouteRule) {
+	for _, r := range rules {
+		res := &ipRules
+		if r.Key == "user" {
+			res = &userRules
+		}
+		*res = append(*res, ratelimit.RouteRule{
+			Method: r.Method,
+			Path:   r.Path,
+			Key:    r.Key,
+			Window: r.Window,
+			Rate:   r.Rate,
+		})
+	}
+	return ipRules, userRules
+}
@@ -0,0 +1,32 @@
+package ioc
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"webook/config"
+	"webook/pkg/logger"
+)
+
+// InitLogger 日志级别来自配置，修改配置文件之后立刻生效
+func InitLogger() logger.Logger {
+	level, err := zap.ParseAtomicLevel(config.Config.Log.Level)
+	if err != nil {
+		panic(err)
+	}
+	cfg := zap.NewProductionConfig()
+	cfg.Level = level
+	zl, err := cfg.Build()
+	if err != nil {
+		panic(err)
+	}
+	l := logger.NewZapLogger(zl)
+	config.SetLogger(l)
+	config.OnChange("log.level", func(cfg config.AppConfig) {
+		if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+			l.Error(context.Background(), "修改日志级别失败", logger.Error(err))
+			return
+		}
+		l.Info(context.Background(), "修改日志级别", logger.String("level", cfg.Log.Level))
+	})
+	return l
+}
@@ -0,0 +1,18 @@
+package ioc
+
+import (
+	"webook/config"
+	"webook/internal/repository"
+	"webook/internal/service"
+	"webook/pkg/cryptox"
+	"webook/pkg/logger"
+)
+
+func InitMFAService(repo repository.MFARepository, l logger.Logger) service.MFAService {
+	cfg := config.Config.MFA
+	cipher, err := cryptox.NewCipher([]byte(cfg.EncryptKey))
+	if err != nil {
+		panic(err)
+	}
+	return service.NewMFAService(repo, cipher, cfg.Issuer, l)
+}
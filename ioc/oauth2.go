@@ -0,0 +1,57 @@
+package ioc
+
+import (
+	"strings"
+	"webook/config"
+	"webook/internal/service"
+	"webook/internal/service/oauth2"
+	"webook/internal/service/oauth2/github"
+	"webook/internal/service/oauth2/oidc"
+	"webook/internal/service/oauth2/wechat"
+	"webook/internal/web"
+	"webook/pkg/logger"
+)
+
+func InitOAuth2Handler(providers *oauth2.Registry, userSvc service.UserService, mfaSvc service.MFAService,
+	wechatTokenSvc service.WechatTokenService, jwtHdl web.JWTHandler, l logger.Logger) *web.OAuth2Handler {
+	cfg := config.Config.OAuth2
+	// 第三方跳转回来的地址是 https 的时候，state cookie 只在 https 下发送
+	return web.NewOAuth2Handler(providers, userSvc, mfaSvc, wechatTokenSvc, jwtHdl, cfg.FrontendURL, l).
+		WithSecureCookie(strings.HasPrefix(cfg.RedirectBaseURL, "https://"))
+}
+
+// InitOAuth2Providers 只注册配置了的第三方登录
+func InitOAuth2Providers() *oauth2.Registry {
+	cfg := config.Config
+	redirect := func(name string) string {
+		return cfg.OAuth2.RedirectBaseURL + "/oauth2/" + name + "/callback"
+	}
+	var providers []oauth2.Provider
+	if cfg.WeChat.AppID != "" {
+		providers = append(providers, wechat.NewProvider(wechat.Config{
+			AppID:       cfg.WeChat.AppID,
+			AppSecret:   cfg.WeChat.AppSecret,
+			RedirectURL: redirect("wechat"),
+			OpenURL:     cfg.WeChat.OpenURL,
+			APIURL:      cfg.WeChat.APIURL,
+		}))
+	}
+	if gh := cfg.OAuth2.GitHub; gh.ClientID != "" {
+		providers = append(providers, github.NewProvider(github.Config{
+			ClientID:     gh.ClientID,
+			ClientSecret: gh.ClientSecret,
+			RedirectURL:  redirect("github"),
+		}))
+	}
+	for _, p := range cfg.OAuth2.OIDC {
+		providers = append(providers, oidc.NewProvider(oidc.Config{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  redirect(p.Name),
+			Scopes:       p.Scopes,
+		}))
+	}
+	return oauth2.NewRegistry(providers...)
+}
@@ -0,0 +1,52 @@
+package ioc
+
+import (
+	"os"
+	"webook/config"
+	"webook/pkg/password"
+)
+
+func InitPasswordPolicy() *password.Policy {
+	cfg := config.Config.Password
+	return &password.Policy{
+		MinLength:      cfg.MinLength,
+		MaxLength:      cfg.MaxLength,
+		RequireUpper:   cfg.RequireUpper,
+		RequireLower:   cfg.RequireLower,
+		RequireDigit:   cfg.RequireDigit,
+		RequireSpecial: cfg.RequireSpecial,
+		HistorySize:    cfg.HistorySize,
+		Breached:       initBreachedList(cfg),
+	}
+}
+
+func initBreachedList(cfg config.PasswordConfig) *password.BreachedList {
+	if cfg.BreachedListFile == "" {
+		return password.DefaultBreachedList()
+	}
+	f, err := os.Open(cfg.BreachedListFile)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	list, err := password.LoadBreachedList(f, cfg.BreachedTopN)
+	if err != nil {
+		panic(err)
+	}
+	return list
+}
+
+// InitPasswordHasher 切换算法或者调整参数之后，老的哈希依旧能校验，登录的时候重新哈希
+func InitPasswordHasher() password.Hasher {
+	cfg := config.Config.Password.Hash
+	if cfg.Algorithm == "argon2id" {
+		return password.NewArgon2idHasher(password.Argon2idParams{
+			Memory:      cfg.Argon2Memory,
+			Iterations:  cfg.Argon2Iterations,
+			Parallelism: cfg.Argon2Parallelism,
+			SaltLength:  16,
+			KeyLength:   32,
+		})
+	}
+	return password.NewBcryptHasher(cfg.BcryptCost)
+}
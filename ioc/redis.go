@@ -1,14 +1,44 @@
 package ioc
 
 import (
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
+	"sync"
+	"time"
 	"webook/config"
+	"webook/pkg/cachex"
+	"webook/pkg/limiter"
+	"webook/pkg/redisx"
+)
+
+var (
+	redisHealthOnce    sync.Once
+	redisHealthChecker *limiter.RedisHealthChecker
 )
 
 func InitRedis() redis.Cmdable {
-	return redis.NewClient(&redis.Options{
+	client := redis.NewClient(&redis.Options{
 		Addr:     config.Config.Redis.Addr,
 		Password: config.Config.Redis.Password,
 		DB:       config.Config.Redis.DB,
 	})
+	hook := redisx.NewPrometheusHook("webook", "redis")
+	if err := hook.Register(prometheus.DefaultRegisterer); err != nil {
+		panic(err)
+	}
+	client.AddHook(hook)
+	client.AddHook(redisx.NewTracingHook())
+	return client
+}
+
+// InitRedisHealthChecker 缓存和限流共用，一边发现 Redis 不可用，另一边也不再等 Redis 超时
+func InitRedisHealthChecker(cmd redis.Cmdable) cachex.HealthChecker {
+	return redisHealth(cmd)
+}
+
+func redisHealth(cmd redis.Cmdable) *limiter.RedisHealthChecker {
+	redisHealthOnce.Do(func() {
+		redisHealthChecker = limiter.NewRedisHealthChecker(cmd, time.Second)
+	})
+	return redisHealthChecker
 }
@@ -0,0 +1,43 @@
+package ioc
+
+import (
+	"os"
+	"webook/config"
+	"webook/internal/repository"
+	"webook/internal/service"
+	"webook/internal/service/notify"
+	"webook/internal/service/notify/lognotify"
+	"webook/internal/service/notify/webhook"
+	"webook/pkg/geoip"
+	"webook/pkg/logger"
+)
+
+func InitSecurityEventService(repo repository.SecurityEventRepository, userRepo repository.UserRepository,
+	l logger.Logger) service.SecurityEventService {
+	cfg := config.Config.Security
+	return service.NewSecurityEventService(repo, userRepo, initGeoDB(cfg), initSecurityNotifier(cfg, l), l)
+}
+
+// initGeoDB 没有配置的时候返回 nil，不查地理位置
+func initGeoDB(cfg config.SecurityConfig) *geoip.DB {
+	if cfg.GeoDBFile == "" {
+		return nil
+	}
+	f, err := os.Open(cfg.GeoDBFile)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	db, err := geoip.Load(f)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func initSecurityNotifier(cfg config.SecurityConfig, l logger.Logger) notify.Service {
+	if cfg.NotifyWebhook == "" {
+		return lognotify.NewService(l)
+	}
+	return webhook.NewService(cfg.NotifyWebhook, cfg.NotifySecret)
+}
@@ -1,10 +1,79 @@
 package ioc
 
 import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	tencentsms "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms/v20210111"
+	"webook/config"
+	"webook/internal/service"
+	"webook/internal/service/captcha"
 	"webook/internal/service/sms"
 	"webook/internal/service/sms/localsms"
+	"webook/internal/service/sms/metrics"
+	"webook/internal/service/sms/opentelemetry"
+	"webook/internal/service/sms/tencent"
+	"webook/pkg/logger"
 )
 
-func InitSMSService() sms.Service {
-	return localsms.NewService()
+func InitSMSService(l logger.Logger) sms.Service {
+	cfg := config.Config.SMS
+	var svc sms.Service
+	switch cfg.Provider {
+	case "tencent":
+		svc = initTencentSMSService(cfg.Tencent, l)
+	default:
+		svc = localsms.NewService(l)
+	}
+	counter := metrics.NewSendCounter("webook", "sms")
+	prometheus.MustRegister(counter)
+	svc = metrics.NewPrometheusSMSService(svc, cfg.Provider, counter)
+	return opentelemetry.NewService(svc, cfg.Provider)
+}
+
+func initTencentSMSService(cfg config.TencentSMSConfig, l logger.Logger) sms.Service {
+	client, err := tencentsms.NewClient(common.NewCredential(cfg.SecretID, cfg.SecretKey),
+		cfg.Region, profile.NewClientProfile())
+	if err != nil {
+		panic(err)
+	}
+	return tencent.NewService(client, cfg.AppID, cfg.SignName, l)
+}
+
+// InitCodeTemplates 验证码短信模板，按语言区分
+func InitCodeTemplates() service.CodeTemplates {
+	cfg := config.Config.SMS
+	tpls := service.CodeTemplates{
+		Default: cfg.CodeTemplate,
+		Locales: make(map[string]string, len(cfg.CodeTemplates)),
+	}
+	for _, tpl := range cfg.CodeTemplates {
+		tpls.Locales[tpl.Locale] = tpl.TplID
+	}
+	return tpls
+}
+
+// guardKeys 配置里面的限流对象
+var guardKeys = map[string]service.CodeGuardKeyFunc{
+	"phone":  service.GuardByPhone,
+	"ip":     service.GuardByIP,
+	"device": service.GuardByDevice,
+	"global": service.GuardGlobal,
+}
+
+// InitCodeGuard 验证码防刷规则，按 biz 配置，修改之后需要重启
+func InitCodeGuard(cmd redis.Cmdable, captchaSvc captcha.Service) service.CodeGuard {
+	rules := make(map[string][]service.CodeGuardRule, len(config.Config.SMS.Guards))
+	for _, g := range config.Config.SMS.Guards {
+		for _, r := range g.Rules {
+			rules[g.Biz] = append(rules[g.Biz], service.CodeGuardRule{
+				Name:         r.Key,
+				Key:          guardKeys[r.Key],
+				Limiter:      newLimiter(cmd, r.Window, r.Rate),
+				CaptchaAfter: r.CaptchaAfter,
+			})
+		}
+	}
+	return service.NewCodeGuard(rules, captchaSvc)
 }
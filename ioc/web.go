@@ -3,34 +3,105 @@ package ioc
 import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 	"strings"
 	"time"
+	"webook/config"
+	"webook/internal/service"
+	"webook/internal/service/oauth2"
 	"webook/internal/web"
 	"webook/internal/web/middlewares"
+	"webook/pkg/ginx/middleware/accesslog"
+	"webook/pkg/ginx/middleware/locale"
+	ginprom "webook/pkg/ginx/middleware/prometheus"
 	"webook/pkg/ginx/middleware/ratelimit"
+	"webook/pkg/ginx/middleware/recovery"
+	"webook/pkg/ginx/middleware/requestid"
+	"webook/pkg/ginx/middleware/trace"
+	"webook/pkg/i18n"
 	"webook/pkg/limiter"
+	"webook/pkg/logger"
 )
 
-func InitWebServer(userHandler *web.UserHandler, wechatHandler *web.OAuth2WechatHandler, middlewares []gin.HandlerFunc) *gin.Engine {
-	server := gin.Default()
+func InitWebServer(userHandler *web.UserHandler, oauth2Handler *web.OAuth2Handler,
+	miniProgramHandler *web.MiniProgramHandler, adminHandler *web.AdminHandler,
+	accountHandler *web.AccountHandler, securityHandler *web.SecurityHandler,
+	middlewares []gin.HandlerFunc) *gin.Engine {
+	// 访问日志和 recover 都在 InitGinMiddlewares 里面
+	server := gin.New()
+	// 业务代码拿 *gin.Context 当 context.Context 用，要能读到请求上的日志字段
+	server.ContextWithFallback = true
 	server.Use(middlewares...)
-	wechatHandler.RegisterRoutes(server)
+	server.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	oauth2Handler.RegisterRoutes(server)
+	miniProgramHandler.RegisterRoutes(server)
 	userHandler.RegisterRoutes(server)
+	adminHandler.RegisterRoutes(server)
+	accountHandler.RegisterRoutes(server)
+	securityHandler.RegisterRoutes(server)
 	return server
 }
 
-func InitGinMiddlewares(redisClient redis.Cmdable) []gin.HandlerFunc {
+func InitGinMiddlewares(redisClient redis.Cmdable, providers *oauth2.Registry, sessions service.SessionService,
+	bundle *i18n.Bundle, l logger.Logger) []gin.HandlerFunc {
+	factory := func(interval time.Duration, rate int) limiter.Limiter {
+		return newLimiter(redisClient, interval, rate)
+	}
+	// 按 IP 和路由限流放在登录校验前面，没有登录的请求和伪造的 token 也要先限流
+	ipLimitBuilder := ratelimit.NewBuilder(newLimiter(redisClient, time.Second, 1000)).
+		FailPolicy(limiterFailPolicy()).
+		Logger(l).
+		LimiterFactory(factory)
+	// 按用户限流要拿到登录态，放在登录校验后面
+	byUser := ratelimit.KeyByUser(web.UserIDFromContext)
+	userLimitBuilder := ratelimit.NewRuleBuilder(ratelimit.Rule{
+		Name:    "user",
+		Key:     byUser,
+		Limiter: newLimiter(redisClient, time.Second, 100),
+	}).
+		FailPolicy(limiterFailPolicy()).
+		Logger(l).
+		LimiterFactory(factory).
+		RegisterKey("user", byUser)
+	initRouteRules(ipLimitBuilder, userLimitBuilder, l)
+	jwtBuilder := middlewares.NewLoginJWTMiddlewareBuilder([]byte(config.Config.JWT.AccessKey)).
+		Sessions(sessions).
+		IgnorePaths("/users/login").
+		IgnorePaths("/users/login/2fa").
+		IgnorePaths("/users/signup").
+		IgnorePaths("/users/login_sms/code/send").
+		IgnorePaths("/users/login_sms").
+		IgnorePaths("/users/passkey/login/begin").
+		IgnorePaths("/users/passkey/login/finish").
+		IgnorePaths("/oauth2/wechat/miniprogram/login").
+		IgnorePaths("/metrics")
+	// 第三方登录，绑定用的 /oauth2/<name>/bind/authurl 需要登录
+	for _, name := range providers.Names() {
+		jwtBuilder.IgnorePaths("/oauth2/" + name + "/authurl").
+			IgnorePaths("/oauth2/" + name + "/callback")
+	}
 	return []gin.HandlerFunc{
 		// 中间件 先注册先执行
+		// 最先分配 request id，后面的日志都能带上
+		requestid.NewBuilder().Build(),
+		// 后面所有中间件和 handler 的 panic 都在这里捕获，日志带上 request id
+		recovery.NewBuilder(l).Build(),
+		// 链路追踪，trace id 写入响应头
+		trace.NewBuilder().Build(),
+		// 根据 Accept-Language 选择语言，要在返回错误的中间件之前
+		locale.NewBuilder(bundle).Build(),
+		initAccessLog(l).Build(),
+		ginprom.NewBuilder("webook", "http", "request", "HTTP 请求响应时间").Build(),
 		// 解决跨域问题
 		// https://github.com/gin-contrib/cors
 		cors.New(cors.Config{
 			//AllowOrigins:     []string{"http://localhost:3000"},
 			AllowMethods: []string{"PUT", "PATCH", "GET", "POST"},
-			AllowHeaders: []string{"Origin", "Content-Type", "Authorization"},
+			AllowHeaders: []string{"Origin", "Content-Type", "Authorization", "Accept-Language", "X-Device-ID", "X-Request-ID"},
 			// JWT 放行
-			ExposeHeaders: []string{"Content-Length", "x-jwt-token", "x-refresh-token"},
+			ExposeHeaders: []string{"Content-Length", "x-jwt-token", "x-refresh-token", "X-Request-ID", "X-Trace-ID",
+				"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 
 			AllowCredentials: true,
 			// 放行所有包含http://localhost 前缀的域名
@@ -39,18 +110,38 @@ func InitGinMiddlewares(redisClient redis.Cmdable) []gin.HandlerFunc {
 			},
 			MaxAge: 12 * time.Hour,
 		}),
-		// jwt 中间件
-		middlewares.NewLoginJWTMiddlewareBuilder().
-			IgnorePaths("/users/login").
-			IgnorePaths("/users/signup").
-			IgnorePaths("/users/login_sms/code/send").
-			IgnorePaths("/users/login_sms").
-			IgnorePaths("/oauth2/wechat/authurl").
-			IgnorePaths("/oauth2/wechat/callback").
-			Build(),
 		// redis限流中间件
-		ratelimit.NewBuilder(limiter.NewRedisSlidingWindowLimiter(redisClient, time.Second, 1000)).Build(),
-		gin.Logger(),
-		gin.Recovery(),
+		ipLimitBuilder.Build(),
+		// jwt 中间件
+		jwtBuilder.Build(),
+		userLimitBuilder.Build(),
+	}
+}
+
+// initAccessLog 访问日志的开关、采样率修改配置文件之后立刻生效
+func initAccessLog(l logger.Logger) *accesslog.Builder {
+	b := accesslog.NewBuilder(l).SetConfig(toAccessLogConfig(config.Config.AccessLog))
+	config.OnChange("accessLog", func(cfg config.AppConfig) {
+		b.SetConfig(toAccessLogConfig(cfg.AccessLog))
+	})
+	return b
+}
+
+func toAccessLogConfig(cfg config.AccessLogConfig) accesslog.Config {
+	routes := make([]accesslog.RouteSampling, 0, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		routes = append(routes, accesslog.RouteSampling{
+			Method:     r.Method,
+			Path:       r.Path,
+			SampleRate: r.SampleRate,
+		})
+	}
+	return accesslog.Config{
+		Enabled:     cfg.Enabled,
+		ReqBody:     cfg.ReqBody,
+		RespBody:    cfg.RespBody,
+		MaxBodySize: cfg.MaxBodySize,
+		SampleRate:  cfg.SampleRate,
+		Routes:      routes,
 	}
 }
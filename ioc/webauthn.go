@@ -0,0 +1,26 @@
+package ioc
+
+import (
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"webook/config"
+)
+
+func InitWebAuthn() *webauthn.WebAuthn {
+	cfg := config.Config.WebAuthn
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+		// 通行密钥登录不再要求 TOTP，所以必须验证用户
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			UserVerification:   protocol.VerificationRequired,
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return wa
+}
@@ -1,20 +1,47 @@
 package ioc
 
 import (
+	"webook/config"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/service"
+	"webook/internal/service/oauth2"
 	"webook/internal/service/oauth2/wechat"
+	"webook/pkg/cryptox"
+	"webook/pkg/logger"
 )
 
-func InitWechatService() wechat.Service {
-	//appID, ok := os.LookupEnv("WECHAT_APP_ID")
-	//if !ok {
-	//	panic("WECHAT_APP_ID not found")
-	//}
-	//appSecret, ok := os.LookupEnv("WECHAT_APP_SECRET")
-	//if !ok {
-	//	panic("WECHAT_APP_SECRET not found")
-	//}
-	// todo 微信开发者appID 有时间再搞吧...
-	appID := "123"
-	appSecret := "123"
-	return wechat.NewService(appID, appSecret)
+// InitWechatTokenService 没有开启微信登录的时候不会保存 token
+func InitWechatTokenService(repo repository.WechatTokenRepository, providers *oauth2.Registry,
+	l logger.Logger) service.WechatTokenService {
+	p, ok := providers.Get(domain.ProviderWechat)
+	if !ok {
+		return service.NewWechatTokenService(repo, nil, nil, l)
+	}
+	refresher, _ := p.(oauth2.Refresher)
+	return service.NewWechatTokenService(repo, refresher, wechatCipher(), l)
+}
+
+// InitWechatMiniProgramService 没有配置小程序的时候登录接口返回不支持
+func InitWechatMiniProgramService(repo repository.WechatSessionRepository, userSvc service.UserService,
+	l logger.Logger) service.WechatMiniProgramService {
+	cfg := config.Config.WeChat
+	if cfg.MiniProgram.AppID == "" {
+		return service.NewWechatMiniProgramService(nil, nil, repo, userSvc, l)
+	}
+	client := wechat.NewMiniProgram(wechat.MiniProgramConfig{
+		AppID:     cfg.MiniProgram.AppID,
+		AppSecret: cfg.MiniProgram.AppSecret,
+		APIURL:    cfg.APIURL,
+	})
+	return service.NewWechatMiniProgramService(client, wechatCipher(), repo, userSvc, l)
+}
+
+// wechatCipher 微信 token 和小程序 session_key 用同一个密钥加密
+func wechatCipher() *cryptox.Cipher {
+	cipher, err := cryptox.NewCipher([]byte(config.Config.WeChat.EncryptKey))
+	if err != nil {
+		panic(err)
+	}
+	return cipher
 }
@@ -0,0 +1,7 @@
+// Package locales 用户可见的文案，每种语言一个文件，key 和 ginx.Error 的 MsgKey 对应
+package locales
+
+import "embed"
+
+//go:embed *.yaml
+var FS embed.FS
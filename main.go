@@ -1,8 +1,28 @@
 package main
 
+import (
+	"context"
+	"os"
+	"time"
+	"webook/config"
+	"webook/ioc"
+)
+
 func main() {
-	server := initWebServer()
-	err := server.Run(":8080")
+	if err := config.Load(os.Args[1:]); err != nil {
+		panic(err)
+	}
+	shutdownTracer := ioc.InitTracer()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		_ = shutdownTracer(ctx)
+	}()
+	app := initApp()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go app.AccountPurge.Start(ctx)
+	err := app.Server.Run(config.Config.Server.Addr)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,221 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/sync/singleflight"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+	"webook/pkg/logger"
+	"webook/pkg/redisx"
+)
+
+type Options struct {
+	// TTL 正常数据的过期时间
+	TTL time.Duration
+	// NegativeTTL “数据不存在”的过期时间，要短，数据创建之后很快就能查到
+	NegativeTTL time.Duration
+	// Jitter 过期时间随机加上 [0, TTL*Jitter)，避免同一批 key 一起过期
+	Jitter float64
+	// DeleteDelay 延迟双删的间隔，要比一次查库加回写的时间长，为 0 的时候只删一次
+	DeleteDelay time.Duration
+	// Timeout 异步回写和延迟删除的超时时间
+	Timeout time.Duration
+	// LoadTimeout load 的超时时间，load 不跟着发起的请求取消，为 0 的时候不限制
+	LoadTimeout time.Duration
+	// NotFound load 返回这个错误的时候缓存“数据不存在”，命中的时候也返回这个错误
+	NotFound error
+}
+
+// Aside 旁路缓存：先查缓存，没有的时候查库再回写，更新数据之后删除缓存
+//   - 同一个 key 并发未命中的时候只有一个请求查库
+//   - 数据不存在的时候也缓存一小段时间，防止缓存穿透
+//   - 过期时间随机浮动，防止缓存雪崩
+//   - 缓存不可用的时候直接查库，不再等缓存超时，直到 HealthChecker 探测到恢复
+//   - 删除失败的 key 记下来，缓存恢复之后先删掉再读缓存
+type Aside[K comparable, V any] struct {
+	store  Store[K, V]
+	health HealthChecker
+	opts   Options
+	group  singleflight.Group
+	l      logger.Logger
+
+	mu sync.Mutex
+	// pending 删除失败的 key，只记在本进程里面，重启之后靠过期时间兜底
+	// value 是记下来的序号，重试期间又删除失败过的 key 序号会变，不能当成已经删掉了
+	pending    map[K]uint64
+	pendingSeq uint64
+	hasPending atomic.Bool
+}
+
+func NewAside[K comparable, V any](store Store[K, V], health HealthChecker, opts Options,
+	l logger.Logger) *Aside[K, V] {
+	return &Aside[K, V]{
+		store:   store,
+		health:  health,
+		opts:    opts,
+		l:       l,
+		pending: make(map[K]uint64),
+	}
+}
+
+// Get load 查数据源，并发的请求共用一次 load
+// load 用的 ctx 不会被取消，只受 LoadTimeout 限制，请求自己被取消的时候先返回，load 接着给其他请求用
+func (a *Aside[K, V]) Get(ctx context.Context, key K, load func(ctx context.Context) (V, error)) (V, error) {
+	cacheable := a.health.Healthy()
+	if cacheable && a.hasPending.Load() {
+		// 删除失败的 key 缓存里面可能还是旧数据
+		cacheable = a.retryPending(ctx)
+	}
+	if cacheable {
+		val, err := a.store.Get(ctx, key)
+		switch {
+		case err == nil:
+			return val, nil
+		case errors.Is(err, ErrNegative):
+			return val, a.opts.NotFound
+		case errors.Is(err, ErrMiss):
+		case errors.Is(err, ErrCorrupted):
+			// 数据坏了，比如改了结构体的字段类型，当作未命中，删掉之后回写新的
+			a.l.Warn(ctx, "缓存数据损坏", logger.String("key", fmt.Sprint(key)), logger.Error(err))
+			if err = a.del(ctx, key); err != nil {
+				a.fail(ctx, "删除损坏的缓存失败", key, err)
+				cacheable = false
+			}
+		case errors.Is(err, context.Canceled):
+			// 客户端自己断开了，跟缓存没有关系
+			return val, err
+		default:
+			// 缓存出错了不回写，很可能也写不进去
+			a.fail(ctx, "查询缓存失败", key, err)
+			cacheable = false
+		}
+	}
+	ch := a.group.DoChan(fmt.Sprint(key), func() (any, error) {
+		lctx := context.WithoutCancel(ctx)
+		if a.opts.LoadTimeout > 0 {
+			var cancel context.CancelFunc
+			lctx, cancel = context.WithTimeout(lctx, a.opts.LoadTimeout)
+			defer cancel()
+		}
+		val, err := load(lctx)
+		if cacheable {
+			a.setAsync(lctx, key, val, err)
+		}
+		return val, err
+	})
+	select {
+	case res := <-ch:
+		return res.Val.(V), res.Err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// setAsync 回写不阻塞查询，回写的旧数据由延迟双删兜底
+func (a *Aside[K, V]) setAsync(ctx context.Context, key K, val V, err error) {
+	notFound := a.opts.NotFound != nil && errors.Is(err, a.opts.NotFound)
+	if err != nil && !notFound {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, a.opts.Timeout)
+		defer cancel()
+		var err error
+		if notFound {
+			err = a.store.SetNotFound(ctx, key, a.ttl(a.opts.NegativeTTL))
+		} else {
+			err = a.store.Set(ctx, key, val, a.ttl(a.opts.TTL))
+		}
+		if err != nil {
+			a.fail(ctx, "回写缓存失败", key, err)
+		}
+	}()
+}
+
+// Invalidate 更新数据之后调用，马上删一次，DeleteDelay 之后再删一次
+// 第二次删除是为了删掉并发的查询在更新之前查到、在第一次删除之后才回写的旧数据
+// 缓存不可用的时候也要删，删不掉的等缓存恢复之后重新删，返回第一次删除的错误
+func (a *Aside[K, V]) Invalidate(ctx context.Context, key K) error {
+	err := a.del(ctx, key)
+	if redisx.IsUnavailable(err) {
+		a.health.MarkFailed()
+	}
+	if a.opts.DeleteDelay <= 0 {
+		return err
+	}
+	dctx := context.WithoutCancel(ctx)
+	time.AfterFunc(a.opts.DeleteDelay, func() {
+		ctx, cancel := context.WithTimeout(dctx, a.opts.Timeout)
+		defer cancel()
+		if err := a.del(ctx, key); err != nil {
+			a.fail(ctx, "延迟删除缓存失败", key, err)
+		}
+	})
+	return err
+}
+
+// del 删除失败的 key 记到 pending 里面，删除成功的从 pending 里面去掉
+func (a *Aside[K, V]) del(ctx context.Context, key K) error {
+	err := a.store.Del(ctx, key)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		a.pendingSeq++
+		a.pending[key] = a.pendingSeq
+	} else {
+		delete(a.pending, key)
+	}
+	a.hasPending.Store(len(a.pending) > 0)
+	return err
+}
+
+// retryPending 缓存恢复之后重新删除之前没删掉的 key，全部删掉之前不读缓存
+// 删除的时候不持有锁，不然 Redis 慢的时候 Invalidate 和其他请求的重试都要排队
+func (a *Aside[K, V]) retryPending(ctx context.Context) bool {
+	a.mu.Lock()
+	keys := make(map[K]uint64, len(a.pending))
+	for key, seq := range a.pending {
+		keys[key] = seq
+	}
+	a.mu.Unlock()
+
+	done := make([]K, 0, len(keys))
+	for key := range keys {
+		if err := a.store.Del(ctx, key); err != nil {
+			a.fail(ctx, "重新删除缓存失败", key, err)
+			break
+		}
+		done = append(done, key)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, key := range done {
+		if a.pending[key] == keys[key] {
+			delete(a.pending, key)
+		}
+	}
+	a.hasPending.Store(len(a.pending) > 0)
+	return len(a.pending) == 0
+}
+
+func (a *Aside[K, V]) ttl(base time.Duration) time.Duration {
+	if a.opts.Jitter <= 0 {
+		return base
+	}
+	return base + time.Duration(rand.Float64()*a.opts.Jitter*float64(base))
+}
+
+// fail 只有连接不上或者超时才标记缓存不可用，见 redisx.IsUnavailable
+func (a *Aside[K, V]) fail(ctx context.Context, msg string, key K, err error) {
+	if redisx.IsUnavailable(err) {
+		a.health.MarkFailed()
+	}
+	a.l.Warn(ctx, msg, logger.String("key", fmt.Sprint(key)), logger.Error(err))
+}
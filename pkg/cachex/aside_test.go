@@ -0,0 +1,471 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	cachex_mocksvc "webook/pkg/cachex/mock"
+	"webook/pkg/logger"
+)
+
+var errNotFound = errors.New("数据不存在")
+
+var testOptions = Options{
+	TTL:         time.Minute,
+	NegativeTTL: time.Second,
+	Timeout:     time.Second,
+	NotFound:    errNotFound,
+}
+
+func TestAside_Get(t *testing.T) {
+	testCases := []struct {
+		name string
+		// done 异步回写完成的时候关闭
+		mock func(ctrl *gomock.Controller, done chan struct{}) (Store[int64, string], HealthChecker)
+		load func(ctx context.Context) (string, error)
+
+		// wantAsync 要等异步回写
+		wantAsync bool
+		wantVal   string
+		wantErr   error
+	}{
+		{
+			name: "缓存命中",
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (Store[int64, string], HealthChecker) {
+				store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
+				store.EXPECT().Get(gomock.Any(), int64(1)).Return("tom", nil)
+				return store, health
+			},
+			wantVal: "tom",
+		},
+		{
+			name: "缓存了数据不存在",
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (Store[int64, string], HealthChecker) {
+				store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
+				store.EXPECT().Get(gomock.Any(), int64(1)).Return("", ErrNegative)
+				return store, health
+			},
+			wantErr: errNotFound,
+		},
+		{
+			name: "未命中，查库之后回写",
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (Store[int64, string], HealthChecker) {
+				store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
+				store.EXPECT().Get(gomock.Any(), int64(1)).Return("", ErrMiss)
+				store.EXPECT().Set(gomock.Any(), int64(1), "tom", time.Minute).
+					DoAndReturn(func(ctx context.Context, key int64, val string, ttl time.Duration) error {
+						close(done)
+						return nil
+					})
+				return store, health
+			},
+			load: func(ctx context.Context) (string, error) {
+				return "tom", nil
+			},
+			wantAsync: true,
+			wantVal:   "tom",
+		},
+		{
+			name: "数据不存在，缓存一小段时间",
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (Store[int64, string], HealthChecker) {
+				store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
+				store.EXPECT().Get(gomock.Any(), int64(1)).Return("", ErrMiss)
+				store.EXPECT().SetNotFound(gomock.Any(), int64(1), time.Second).
+					DoAndReturn(func(ctx context.Context, key int64, ttl time.Duration) error {
+						close(done)
+						return nil
+					})
+				return store, health
+			},
+			load: func(ctx context.Context) (string, error) {
+				return "", errNotFound
+			},
+			wantAsync: true,
+			wantErr:   errNotFound,
+		},
+		{
+			name: "回写失败，标记缓存不可用",
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (Store[int64, string], HealthChecker) {
+				store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
+				store.EXPECT().Get(gomock.Any(), int64(1)).Return("", ErrMiss)
+				store.EXPECT().Set(gomock.Any(), int64(1), "tom", time.Minute).
+					Return(context.DeadlineExceeded)
+				health.EXPECT().MarkFailed().Do(func() {
+					close(done)
+				})
+				return store, health
+			},
+			load: func(ctx context.Context) (string, error) {
+				return "tom", nil
+			},
+			wantAsync: true,
+			wantVal:   "tom",
+		},
+		{
+			name: "查缓存出错，查库但是不回写",
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (Store[int64, string], HealthChecker) {
+				store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
+				store.EXPECT().Get(gomock.Any(), int64(1)).Return("", context.DeadlineExceeded)
+				health.EXPECT().MarkFailed()
+				return store, health
+			},
+			load: func(ctx context.Context) (string, error) {
+				return "tom", nil
+			},
+			wantVal: "tom",
+		},
+		{
+			name: "命令出错，Redis 还能用，不标记",
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (Store[int64, string], HealthChecker) {
+				store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
+				store.EXPECT().Get(gomock.Any(), int64(1)).
+					Return("", errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"))
+				return store, health
+			},
+			load: func(ctx context.Context) (string, error) {
+				return "tom", nil
+			},
+			wantVal: "tom",
+		},
+		{
+			name: "缓存数据损坏，删掉之后查库回写",
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (Store[int64, string], HealthChecker) {
+				store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
+				store.EXPECT().Get(gomock.Any(), int64(1)).
+					Return("", fmt.Errorf("%w: unexpected end of JSON input", ErrCorrupted))
+				store.EXPECT().Del(gomock.Any(), int64(1)).Return(nil)
+				store.EXPECT().Set(gomock.Any(), int64(1), "tom", time.Minute).
+					DoAndReturn(func(ctx context.Context, key int64, val string, ttl time.Duration) error {
+						close(done)
+						return nil
+					})
+				return store, health
+			},
+			load: func(ctx context.Context) (string, error) {
+				return "tom", nil
+			},
+			wantAsync: true,
+			wantVal:   "tom",
+		},
+		{
+			name: "缓存数据损坏，删除也失败，查库但是不回写",
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (Store[int64, string], HealthChecker) {
+				store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
+				store.EXPECT().Get(gomock.Any(), int64(1)).
+					Return("", fmt.Errorf("%w: unexpected end of JSON input", ErrCorrupted))
+				store.EXPECT().Del(gomock.Any(), int64(1)).Return(context.DeadlineExceeded)
+				health.EXPECT().MarkFailed()
+				return store, health
+			},
+			load: func(ctx context.Context) (string, error) {
+				return "tom", nil
+			},
+			wantVal: "tom",
+		},
+		{
+			name: "缓存不可用，直接查库",
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (Store[int64, string], HealthChecker) {
+				store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(false)
+				return store, health
+			},
+			load: func(ctx context.Context) (string, error) {
+				return "", errNotFound
+			},
+			wantErr: errNotFound,
+		},
+		{
+			name: "查库出错，不回写",
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (Store[int64, string], HealthChecker) {
+				store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
+				store.EXPECT().Get(gomock.Any(), int64(1)).Return("", ErrMiss)
+				return store, health
+			},
+			load: func(ctx context.Context) (string, error) {
+				return "", errors.New("db 错误")
+			},
+			wantErr: errors.New("db 错误"),
+		},
+		{
+			name: "客户端断开",
+			mock: func(ctrl *gomock.Controller, done chan struct{}) (Store[int64, string], HealthChecker) {
+				store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+				health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+				health.EXPECT().Healthy().Return(true)
+				store.EXPECT().Get(gomock.Any(), int64(1)).Return("", context.Canceled)
+				return store, health
+			},
+			wantErr: context.Canceled,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			done := make(chan struct{})
+			store, health := tc.mock(ctrl, done)
+			aside := NewAside(store, health, testOptions, logger.NewNopLogger())
+			val, err := aside.Get(context.Background(), 1, tc.load)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantVal, val)
+			if tc.wantAsync {
+				select {
+				case <-done:
+				case <-time.After(time.Second):
+					t.Fatal("没有回写缓存")
+				}
+			}
+		})
+	}
+}
+
+func TestAside_Get_Singleflight(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+	health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+	health.EXPECT().Healthy().Return(true).AnyTimes()
+	store.EXPECT().Get(gomock.Any(), int64(1)).Return("", ErrMiss).AnyTimes()
+	written := make(chan struct{})
+	store.EXPECT().Set(gomock.Any(), int64(1), "tom", time.Minute).
+		DoAndReturn(func(ctx context.Context, key int64, val string, ttl time.Duration) error {
+			close(written)
+			return nil
+		})
+	aside := NewAside(store, health, testOptions, logger.NewNopLogger())
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		loads.Add(1)
+		<-release
+		return "tom", nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := aside.Get(context.Background(), 1, load)
+			assert.NoError(t, err)
+			assert.Equal(t, "tom", val)
+		}()
+	}
+	// 等所有请求都进到 singleflight 里面
+	time.Sleep(time.Millisecond * 100)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), loads.Load())
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("没有回写缓存")
+	}
+}
+
+// TestAside_Get_Canceled 请求取消之后先返回，load 接着跑完，回写缓存
+func TestAside_Get_Canceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+	health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+	health.EXPECT().Healthy().Return(true)
+	store.EXPECT().Get(gomock.Any(), int64(1)).Return("", ErrMiss)
+	written := make(chan struct{})
+	store.EXPECT().Set(gomock.Any(), int64(1), "tom", time.Minute).
+		DoAndReturn(func(ctx context.Context, key int64, val string, ttl time.Duration) error {
+			close(written)
+			return nil
+		})
+	aside := NewAside(store, health, testOptions, logger.NewNopLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	var loadErr atomic.Value
+	load := func(ctx context.Context) (string, error) {
+		cancel()
+		<-release
+		loadErr.Store(fmt.Sprint(ctx.Err()))
+		return "tom", nil
+	}
+	_, err := aside.Get(ctx, 1, load)
+	assert.Equal(t, context.Canceled, err)
+	close(release)
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("没有回写缓存")
+	}
+	assert.Equal(t, "<nil>", loadErr.Load())
+}
+
+func TestAside_Invalidate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+	health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+	// 第一次删除失败也要延迟再删一次
+	store.EXPECT().Del(gomock.Any(), int64(1)).Return(context.DeadlineExceeded)
+	health.EXPECT().MarkFailed()
+	deleted := make(chan struct{})
+	store.EXPECT().Del(gomock.Any(), int64(1)).DoAndReturn(func(ctx context.Context, key int64) error {
+		close(deleted)
+		return nil
+	})
+	opts := testOptions
+	opts.DeleteDelay = time.Millisecond * 10
+	aside := NewAside(store, health, opts, logger.NewNopLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := aside.Invalidate(ctx, 1)
+	// 请求结束了，延迟删除照样要做
+	cancel()
+	assert.Equal(t, context.DeadlineExceeded, err)
+	select {
+	case <-deleted:
+	case <-time.After(time.Second):
+		t.Fatal("没有延迟删除")
+	}
+}
+
+func TestAside_ttl(t *testing.T) {
+	opts := testOptions
+	opts.Jitter = 0.1
+	aside := NewAside[int64, string](nil, nil, opts, logger.NewNopLogger())
+	for i := 0; i < 1000; i++ {
+		ttl := aside.ttl(time.Minute)
+		assert.GreaterOrEqual(t, ttl, time.Minute)
+		assert.Less(t, ttl, time.Minute+time.Second*6)
+	}
+}
+
+// TestAside_Invalidate_Retry 删除失败的 key 等缓存恢复之后重新删，删掉之前不读缓存
+func TestAside_Invalidate_Retry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+	health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+	aside := NewAside(store, health, testOptions, logger.NewNopLogger())
+
+	store.EXPECT().Del(gomock.Any(), int64(1)).Return(context.DeadlineExceeded)
+	health.EXPECT().MarkFailed()
+	err := aside.Invalidate(context.Background(), 1)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// 还没恢复，直接查库
+	health.EXPECT().Healthy().Return(false)
+	val, err := aside.Get(context.Background(), 2, func(ctx context.Context) (string, error) {
+		return "jerry", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "jerry", val)
+
+	// 恢复之后重新删除还是失败，也不读缓存
+	health.EXPECT().Healthy().Return(true)
+	store.EXPECT().Del(gomock.Any(), int64(1)).Return(errors.New("WRONGTYPE"))
+	val, err = aside.Get(context.Background(), 2, func(ctx context.Context) (string, error) {
+		return "jerry", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "jerry", val)
+
+	// 删掉之后正常读缓存
+	health.EXPECT().Healthy().Return(true)
+	store.EXPECT().Del(gomock.Any(), int64(1)).Return(nil)
+	store.EXPECT().Get(gomock.Any(), int64(2)).Return("jerry", nil)
+	val, err = aside.Get(context.Background(), 2, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "jerry", val)
+
+	// 已经删掉了，不再重试
+	health.EXPECT().Healthy().Return(true)
+	store.EXPECT().Get(gomock.Any(), int64(1)).Return("tom", nil)
+	val, err = aside.Get(context.Background(), 1, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "tom", val)
+}
+
+// TestAside_retryPending_Unlocked 重试删除的时候不持有锁，重试期间又删除失败的 key 不能当成已经删掉了
+func TestAside_retryPending_Unlocked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := cachex_mocksvc.NewMockStore[int64, string](ctrl)
+	health := cachex_mocksvc.NewMockHealthChecker(ctrl)
+	health.EXPECT().MarkFailed().AnyTimes()
+	aside := NewAside(store, health, testOptions, logger.NewNopLogger())
+
+	store.EXPECT().Del(gomock.Any(), int64(1)).Return(context.DeadlineExceeded)
+	assert.Equal(t, context.DeadlineExceeded, aside.Invalidate(context.Background(), 1))
+
+	// 重试删除卡住的时候，Invalidate 不用等
+	started, release := make(chan struct{}), make(chan struct{})
+	health.EXPECT().Healthy().Return(true)
+	store.EXPECT().Del(gomock.Any(), int64(1)).DoAndReturn(func(ctx context.Context, key int64) error {
+		close(started)
+		<-release
+		return nil
+	})
+	done := make(chan string)
+	go func() {
+		val, _ := aside.Get(context.Background(), 2, func(ctx context.Context) (string, error) {
+			return "jerry", nil
+		})
+		done <- val
+	}()
+	<-started
+	store.EXPECT().Del(gomock.Any(), int64(1)).Return(context.DeadlineExceeded)
+	invalidated := make(chan error)
+	go func() {
+		invalidated <- aside.Invalidate(context.Background(), 1)
+	}()
+	select {
+	case err := <-invalidated:
+		assert.Equal(t, context.DeadlineExceeded, err)
+	case <-time.After(time.Second):
+		t.Fatal("Invalidate 被重试删除阻塞了")
+	}
+	close(release)
+	// 重试期间又删除失败了，还是不读缓存
+	assert.Equal(t, "jerry", <-done)
+
+	// 下一次重新删
+	health.EXPECT().Healthy().Return(true)
+	store.EXPECT().Del(gomock.Any(), int64(1)).Return(nil)
+	store.EXPECT().Get(gomock.Any(), int64(2)).Return("jerry", nil)
+	val, err := aside.Get(context.Background(), 2, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "jerry", val)
+}
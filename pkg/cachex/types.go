@@ -0,0 +1,35 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+var (
+	// ErrMiss 缓存里没有，和 go-redis 一致，Store 可以直接返回 redis.Nil
+	ErrMiss = redis.Nil
+	// ErrNegative 缓存的是“数据不存在”
+	ErrNegative = errors.New("cachex: 缓存的数据不存在")
+	// ErrCorrupted 缓存的数据解析不了，Store 用 %w 包装具体的错误
+	ErrCorrupted = errors.New("cachex: 缓存的数据损坏")
+)
+
+// Store 具体的缓存，过期时间由 Aside 决定
+// 除了 ErrMiss、ErrNegative 和 ErrCorrupted 之外的错误都当作出错，连接不上或者超时的时候才标记缓存不可用
+type Store[K comparable, V any] interface {
+	// Get 没有缓存的时候返回 ErrMiss，缓存了“数据不存在”的时候返回 ErrNegative，解析不了的时候返回 ErrCorrupted
+	Get(ctx context.Context, key K) (V, error)
+	Set(ctx context.Context, key K, val V, ttl time.Duration) error
+	// SetNotFound 缓存“数据不存在”，防止缓存穿透
+	SetNotFound(ctx context.Context, key K, ttl time.Duration) error
+	Del(ctx context.Context, key K) error
+}
+
+// HealthChecker 缓存是否可用，limiter.RedisHealthChecker 就满足这个接口
+type HealthChecker interface {
+	Healthy() bool
+	// MarkFailed 访问缓存出错的时候调用
+	MarkFailed()
+}
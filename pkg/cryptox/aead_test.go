@@ -0,0 +1,35 @@
+package cryptox
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCipher(t *testing.T) {
+	c, err := NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+
+	ciphertext, err := c.Encrypt("JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.NotContains(t, ciphertext, "JBSWY3DPEHPK3PXP")
+	plaintext, err := c.Decrypt(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", plaintext)
+
+	// 同样的明文每次加密的结果不一样
+	other, err := c.Encrypt("JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.NotEqual(t, ciphertext, other)
+
+	// 换了密钥解不开
+	c2, err := NewCipher([]byte("fedcba9876543210fedcba9876543210"))
+	require.NoError(t, err)
+	_, err = c2.Decrypt(ciphertext)
+	assert.Equal(t, ErrCiphertext, err)
+	_, err = c.Decrypt("bad")
+	assert.Equal(t, ErrCiphertext, err)
+
+	_, err = NewCipher([]byte("short"))
+	assert.Error(t, err)
+}
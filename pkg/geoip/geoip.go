@@ -0,0 +1,157 @@
+package geoip
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// Location 查不到的时候是零值
+type Location struct {
+	// CountryCode ISO 3166 两位国家代码，比如 CN
+	CountryCode string
+	Country     string
+	Region      string
+	City        string
+}
+
+// DB 离线的 IP 地理位置库，加载之后只读，可以并发查询
+type DB struct {
+	ranges []ipRange
+}
+
+type ipRange struct {
+	start netip.Addr
+	end   netip.Addr
+	loc   Location
+}
+
+// Load 读取 IP2Location LITE DB3 格式的 CSV，一行一个 IP 段：
+// 起始 IP,结束 IP,国家代码,国家,省份,城市
+// IP 可以是十进制整数（IP2Location 的格式，IPv6 版本里面 IPv4 是 ::ffff:0:0/96 段），也可以直接写 IP
+// 空行和 # 开头的行会被忽略，- 表示未知
+func Load(r io.Reader) (*DB, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 6
+	reader.ReuseRecord = true
+	db := &DB{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, end, err := parseRange(record[0], record[1])
+		if err != nil {
+			return nil, err
+		}
+		db.ranges = append(db.ranges, ipRange{
+			start: start,
+			end:   end,
+			loc: Location{
+				CountryCode: field(record[2]),
+				Country:     field(record[3]),
+				Region:      field(record[4]),
+				City:        field(record[5]),
+			},
+		})
+	}
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return db.ranges[i].start.Less(db.ranges[j].start)
+	})
+	return db, nil
+}
+
+// Lookup nil 的 DB 和解析不了的 IP 都返回零值
+func (db *DB) Lookup(ip string) Location {
+	if db == nil {
+		return Location{}
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Location{}
+	}
+	addr = addr.Unmap().WithZone("")
+	// 第一个起始 IP 大于 addr 的段的前一个段
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return addr.Less(db.ranges[i].start)
+	}) - 1
+	if i < 0 || db.ranges[i].end.Less(addr) {
+		return Location{}
+	}
+	return db.ranges[i].loc
+}
+
+// Len 有多少个 IP 段
+func (db *DB) Len() int {
+	if db == nil {
+		return 0
+	}
+	return len(db.ranges)
+}
+
+func parseRange(startStr, endStr string) (netip.Addr, netip.Addr, error) {
+	startStr, endStr = strings.TrimSpace(startStr), strings.TrimSpace(endStr)
+	var start, end netip.Addr
+	var err error
+	if strings.ContainsAny(startStr, ".:") {
+		start, end, err = parseText(startStr, endStr)
+	} else {
+		start, end, err = parseDecimal(startStr, endStr)
+	}
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, err
+	}
+	if start.BitLen() != end.BitLen() || end.Less(start) {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("geoip: IP 段 %s-%s 不合法", startStr, endStr)
+	}
+	return start, end, nil
+}
+
+func parseText(startStr, endStr string) (netip.Addr, netip.Addr, error) {
+	start, err := netip.ParseAddr(startStr)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, err
+	}
+	end, err := netip.ParseAddr(endStr)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, err
+	}
+	return start.Unmap(), end.Unmap(), nil
+}
+
+// parseDecimal 结束 IP 超过 32 位的时候两个都按 IPv6 处理，IPv6 版本的第一段是从 0 开始的
+func parseDecimal(startStr, endStr string) (netip.Addr, netip.Addr, error) {
+	start, ok1 := new(big.Int).SetString(startStr, 10)
+	end, ok2 := new(big.Int).SetString(endStr, 10)
+	if !ok1 || !ok2 || start.Sign() < 0 || start.Cmp(end) > 0 || end.BitLen() > 128 {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("geoip: IP 段 %s-%s 不合法", startStr, endStr)
+	}
+	if end.BitLen() <= 32 {
+		var s, e [4]byte
+		start.FillBytes(s[:])
+		end.FillBytes(e[:])
+		return netip.AddrFrom4(s), netip.AddrFrom4(e), nil
+	}
+	var s, e [16]byte
+	start.FillBytes(s[:])
+	end.FillBytes(e[:])
+	// ::ffff:0:0/96 段换成 IPv4，查询的时候 IP 也会换成 IPv4
+	return netip.AddrFrom16(s).Unmap(), netip.AddrFrom16(e).Unmap(), nil
+}
+
+func field(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "-" {
+		return ""
+	}
+	return s
+}
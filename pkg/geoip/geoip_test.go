@@ -0,0 +1,127 @@
+package geoip
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestDB_Lookup(t *testing.T) {
+	db, err := Load(strings.NewReader(`# IP2Location LITE DB3
+"16777216","16777471","AU","Australia","Queensland","Brisbane"
+"3758096128","3758096383","AU","Australia","-","-"
+"1.0.1.0","1.0.3.255","CN","China","Fujian","Fuzhou"
+"0","281470681743359","-","-","-","-"
+"281470698521600","281470698521855","US","United States of America","California","Los Angeles"
+"2001:db8::","2001:db8::ffff","JP","Japan","Tokyo","Tokyo"
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 6, db.Len())
+
+	testCases := []struct {
+		name string
+		ip   string
+
+		want Location
+	}{
+		{
+			name: "十进制的 IPv4 段",
+			ip:   "1.0.0.8",
+			want: Location{CountryCode: "AU", Country: "Australia", Region: "Queensland", City: "Brisbane"},
+		},
+		{
+			name: "段的结束 IP",
+			ip:   "1.0.0.255",
+			want: Location{CountryCode: "AU", Country: "Australia", Region: "Queensland", City: "Brisbane"},
+		},
+		{
+			name: "未知的省份和城市",
+			ip:   "223.255.255.1",
+			want: Location{CountryCode: "AU", Country: "Australia"},
+		},
+		{
+			name: "点分格式的 IP 段",
+			ip:   "1.0.2.3",
+			want: Location{CountryCode: "CN", Country: "China", Region: "Fujian", City: "Fuzhou"},
+		},
+		{
+			name: "IPv6 版本里面的 IPv4 段",
+			ip:   "1.0.4.1",
+			want: Location{CountryCode: "US", Country: "United States of America", Region: "California", City: "Los Angeles"},
+		},
+		{
+			name: "IPv4 映射的 IPv6 地址",
+			ip:   "::ffff:1.0.4.1",
+			want: Location{CountryCode: "US", Country: "United States of America", Region: "California", City: "Los Angeles"},
+		},
+		{
+			name: "IPv6",
+			ip:   "2001:db8::1",
+			want: Location{CountryCode: "JP", Country: "Japan", Region: "Tokyo", City: "Tokyo"},
+		},
+		{
+			name: "不在任何段里面",
+			ip:   "8.8.8.8",
+		},
+		{
+			name: "比第一个段还小",
+			ip:   "0.0.0.1",
+		},
+		{
+			name: "不是 IP",
+			ip:   "localhost",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, db.Lookup(tc.ip))
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+
+		wantErr bool
+	}{
+		{
+			name:    "结束 IP 比起始 IP 小",
+			data:    `"16777471","16777216","AU","Australia","-","-"`,
+			wantErr: true,
+		},
+		{
+			name:    "IPv4 和 IPv6 混在一个段里面",
+			data:    `"1.0.0.0","2001:db8::","AU","Australia","-","-"`,
+			wantErr: true,
+		},
+		{
+			name:    "字段数不对",
+			data:    `"16777216","16777471","AU"`,
+			wantErr: true,
+		},
+		{
+			name:    "不是数字",
+			data:    `"abc","16777471","AU","Australia","-","-"`,
+			wantErr: true,
+		},
+		{
+			name: "空文件",
+			data: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(strings.NewReader(tc.data))
+			assert.Equal(t, tc.wantErr, err != nil)
+		})
+	}
+}
+
+func TestDB_Nil(t *testing.T) {
+	var db *DB
+	assert.Equal(t, Location{}, db.Lookup("1.0.0.1"))
+	assert.Equal(t, 0, db.Len())
+}
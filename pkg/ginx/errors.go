@@ -0,0 +1,80 @@
+package ginx
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Error 业务错误，带着错误码、HTTP 状态码和文案的 i18n key
+// 同一个错误码只能通过 Register 注册一次，保证错误码全局唯一
+type Error struct {
+	// Code 业务错误码，前端根据它做判断
+	Code int
+	// Status 返回的 HTTP 状态码
+	Status int
+	// MsgKey 文案在语言包里面的 key
+	MsgKey string
+	// Msg 默认文案，语言包里面找不到的时候使用
+	Msg string
+
+	cause error
+}
+
+func (e *Error) Error() string {
+	if e.cause == nil {
+		return fmt.Sprintf("%d %s", e.Code, e.Msg)
+	}
+	return fmt.Sprintf("%d %s: %s", e.Code, e.Msg, e.cause)
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is 错误码相同就认为是同一个错误，所以 Wrap 之后依旧可以用 errors.Is 判断
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap 返回一个带着底层原因的副本，原因只用于日志和链路，不会返回给前端
+func (e *Error) Wrap(cause error) *Error {
+	res := *e
+	res.cause = cause
+	return &res
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[int]*Error{}
+)
+
+// Register 注册错误码，重复注册直接 panic，一般在包级别的 var 里面调用
+func Register(code, status int, msgKey, msg string) *Error {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[code]; ok {
+		panic(fmt.Sprintf("ginx: 错误码 %d 重复注册", code))
+	}
+	e := &Error{Code: code, Status: status, MsgKey: msgKey, Msg: msg}
+	registry[code] = e
+	return e
+}
+
+// Lookup 根据错误码查找注册过的错误
+func Lookup(code int) (*Error, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	e, ok := registry[code]
+	return e, ok
+}
+
+// 通用错误码，业务模块的错误码从 200000 开始，每个模块占一段
+var (
+	ErrInternal        = Register(100000, http.StatusInternalServerError, "common.internal", "系统错误")
+	ErrInvalidParam    = Register(100001, http.StatusBadRequest, "common.invalid_param", "参数错误")
+	ErrUnauthorized    = Register(100002, http.StatusUnauthorized, "common.unauthorized", "未登录")
+	ErrForbidden       = Register(100003, http.StatusForbidden, "common.forbidden", "没有权限")
+	ErrTooManyRequests = Register(100004, http.StatusTooManyRequests, "common.too_many_requests", "请求太频繁，请稍后再试")
+)
@@ -0,0 +1,168 @@
+package accesslog
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"io"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+	"webook/pkg/logger"
+)
+
+// sensitiveBody 匹配 JSON 里 key 以这些词结尾的字段，比如 "confirmPassword":"xxx"、
+// 二次验证的 "ticket"、"uri"（otpauth 链接里面有密钥）和 "recoveryCodes":["xxx"]
+// 值是字符串或者字符串数组，数字不处理，响应里面的 "code":0 是错误码
+// 用正则而不是反序列化，截断之后的请求体也能脱敏，所以结尾的引号和括号可以没有
+var sensitiveBody = regexp.MustCompile(`(?i)("[^"]*(?:password|codes?|captcha|tokens?|secret|ticket|session_?key|uri)"\s*:\s*)` +
+	`(?:"(?:[^"\\]|\\.)*"?|\[(?:[^\]"]|"(?:[^"\\]|\\.)*"?)*\]?)`)
+
+// Config 访问日志的配置，可以在运行期间通过 SetConfig 修改
+type Config struct {
+	// Enabled 关闭之后中间件直接放行
+	Enabled bool
+	// ReqBody、RespBody 是否记录请求体和响应体
+	ReqBody  bool
+	RespBody bool
+	// MaxBodySize 请求体和响应体最多记录的字节数
+	MaxBodySize int
+	// SampleRate 默认的采样率，0 到 1
+	SampleRate float64
+	// Routes 路由维度的采样率，优先于 SampleRate
+	Routes []RouteSampling
+}
+
+type RouteSampling struct {
+	// Method 为空或者 * 表示所有方法
+	Method string
+	// Path 注册的路由，以 * 结尾表示前缀匹配
+	Path       string
+	SampleRate float64
+}
+
+func (r RouteSampling) match(method, route string) bool {
+	if r.Method != "" && r.Method != "*" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return strings.HasPrefix(route, prefix)
+	}
+	return r.Path == route
+}
+
+type Builder struct {
+	l   logger.Logger
+	cfg atomic.Pointer[Config]
+}
+
+// NewBuilder 默认记录所有请求，不记录请求体和响应体
+func NewBuilder(l logger.Logger) *Builder {
+	b := &Builder{l: l}
+	b.SetConfig(Config{
+		Enabled:     true,
+		MaxBodySize: 1024,
+		SampleRate:  1,
+	})
+	return b
+}
+
+// SetConfig 替换配置，可以在运行期间调用，比如线上临时打开请求体记录排查问题
+func (b *Builder) SetConfig(cfg Config) *Builder {
+	b.cfg.Store(&cfg)
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		cfg := b.cfg.Load()
+		if !cfg.Enabled {
+			ctx.Next()
+			return
+		}
+		start := time.Now()
+		// 没有采中的请求不读请求体，但是出错的时候仍然记录
+		sampled := rand.Float64() < sampleRate(cfg, ctx.Request.Method, ctx.FullPath())
+
+		fields := []logger.Field{
+			logger.String("method", ctx.Request.Method),
+			logger.String("path", ctx.Request.URL.Path),
+			logger.String("route", ctx.FullPath()),
+			logger.String("ip", ctx.ClientIP()),
+		}
+		if sampled && cfg.ReqBody && ctx.Request.Body != nil {
+			fields = append(fields, logger.String("req_body", readBody(ctx, cfg.MaxBodySize)))
+		}
+		var resp *responseWriter
+		if sampled && cfg.RespBody {
+			resp = &responseWriter{ResponseWriter: ctx.Writer, limit: cfg.MaxBodySize}
+			ctx.Writer = resp
+		}
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		if !sampled && status < http.StatusInternalServerError {
+			return
+		}
+		fields = append(fields,
+			logger.Int("status", status),
+			logger.Duration("latency", time.Since(start)))
+		if resp != nil {
+			fields = append(fields, logger.String("resp_body", redact(resp.body.Bytes())))
+		}
+		if len(ctx.Errors) > 0 {
+			fields = append(fields, logger.String("errors", ctx.Errors.String()))
+		}
+		// 用请求上的 ctx，登录校验之后会带上 user id
+		b.l.Info(ctx.Request.Context(), "access", fields...)
+	}
+}
+
+func sampleRate(cfg *Config, method, route string) float64 {
+	for _, r := range cfg.Routes {
+		if r.match(method, route) {
+			return r.SampleRate
+		}
+	}
+	return cfg.SampleRate
+}
+
+// readBody 读取请求体用于记录，并且放回去，不影响后面的 Bind
+func readBody(ctx *gin.Context, limit int) string {
+	data, err := io.ReadAll(io.LimitReader(ctx.Request.Body, int64(limit)))
+	ctx.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), ctx.Request.Body))
+	if err != nil {
+		return ""
+	}
+	return redact(data)
+}
+
+func redact(body []byte) string {
+	return sensitiveBody.ReplaceAllString(string(body), `$1"******"`)
+}
+
+// responseWriter 写响应的同时记录下前 limit 个字节
+type responseWriter struct {
+	gin.ResponseWriter
+	body  bytes.Buffer
+	limit int
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseWriter) capture(data []byte) {
+	if left := w.limit - w.body.Len(); left > 0 {
+		w.body.Write(data[:min(left, len(data))])
+	}
+}
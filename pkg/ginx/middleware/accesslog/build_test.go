@@ -0,0 +1,176 @@
+package accesslog
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/pkg/logger"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cfg    Config
+		path   string
+		body   string
+		status int
+
+		// nil 表示不应该有日志
+		wantFields map[string]any
+	}{
+		{
+			name: "记录请求体和响应体，密码和验证码脱敏",
+			cfg: Config{Enabled: true, ReqBody: true, RespBody: true,
+				MaxBodySize: 1024, SampleRate: 1},
+			path:   "/users/login",
+			body:   `{"email":"a@qq.com","password":"hello#world123","code":"123456"}`,
+			status: http.StatusOK,
+			wantFields: map[string]any{
+				"method":    "POST",
+				"path":      "/users/login",
+				"route":     "/users/login",
+				"ip":        "192.0.2.1",
+				"status":    int64(http.StatusOK),
+				"user_id":   int64(123),
+				"req_body":  `{"email":"a@qq.com","password":"******","code":"******"}`,
+				"resp_body": `{"token":"******"}`,
+			},
+		},
+		{
+			name: "请求体截断",
+			cfg: Config{Enabled: true, ReqBody: true,
+				MaxBodySize: 20, SampleRate: 1},
+			path:   "/users/login",
+			body:   `{"password":"hello#world123"}`,
+			status: http.StatusOK,
+			wantFields: map[string]any{
+				"method":   "POST",
+				"path":     "/users/login",
+				"route":    "/users/login",
+				"ip":       "192.0.2.1",
+				"status":   int64(http.StatusOK),
+				"user_id":  int64(123),
+				"req_body": `{"password":"******"`,
+			},
+		},
+		{
+			name:   "关闭",
+			cfg:    Config{Enabled: false, SampleRate: 1},
+			path:   "/users/login",
+			status: http.StatusOK,
+		},
+		{
+			name: "路由采样率为 0",
+			cfg: Config{Enabled: true, SampleRate: 1, Routes: []RouteSampling{
+				{Method: "post", Path: "/users/*", SampleRate: 0},
+			}},
+			path:   "/users/login",
+			status: http.StatusOK,
+		},
+		{
+			name: "没有采中，但是出错了",
+			cfg: Config{Enabled: true, ReqBody: true, SampleRate: 0,
+				MaxBodySize: 1024},
+			path:   "/users/login",
+			body:   `{}`,
+			status: http.StatusInternalServerError,
+			wantFields: map[string]any{
+				"method":  "POST",
+				"path":    "/users/login",
+				"route":   "/users/login",
+				"ip":      "192.0.2.1",
+				"status":  int64(http.StatusInternalServerError),
+				"user_id": int64(123),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			b := NewBuilder(logger.NewZapLogger(zap.New(core))).SetConfig(tc.cfg)
+
+			var gotBody string
+			server := gin.New()
+			server.Use(b.Build())
+			server.POST("/users/login", func(ctx *gin.Context) {
+				// 模拟登录校验中间件放进去的用户 ID
+				ctx.Request = ctx.Request.WithContext(
+					logger.WithFields(ctx.Request.Context(), logger.Int64("user_id", 123)))
+				data, _ := ctx.GetRawData()
+				gotBody = string(data)
+				ctx.String(tc.status, `{"token":"abc"}`)
+			})
+
+			req, err := http.NewRequest(http.MethodPost, tc.path, bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+			req.RemoteAddr = "192.0.2.1:1234"
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			// 记录请求体不影响业务读取
+			assert.Equal(t, tc.body, gotBody)
+			assert.Equal(t, `{"token":"abc"}`, recorder.Body.String())
+			if tc.wantFields == nil {
+				assert.Equal(t, 0, logs.Len())
+				return
+			}
+			require.Equal(t, 1, logs.Len())
+			fields := logs.All()[0].ContextMap()
+			assert.NotZero(t, fields["latency"])
+			delete(fields, "latency")
+			assert.Equal(t, tc.wantFields, fields)
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "二次验证的票据",
+			body: `{"ticket":"mfa-ticket","code":"123456"}`,
+			want: `{"ticket":"******","code":"******"}`,
+		},
+		{
+			name: "otpauth 链接和密钥",
+			body: `{"code":0,"data":{"secret":"JBSWY3DP","uri":"otpauth://totp/webook:tom?secret=JBSWY3DP"}}`,
+			want: `{"code":0,"data":{"secret":"******","uri":"******"}}`,
+		},
+		{
+			name: "恢复码数组",
+			body: `{"data":{"recoveryCodes":["aaaa-bbbb","cccc-dddd"]},"msg":"OK"}`,
+			want: `{"data":{"recoveryCodes":"******"},"msg":"OK"}`,
+		},
+		{
+			name: "截断的数组",
+			body: `{"recoveryCodes":["aaaa-bbbb","cc`,
+			want: `{"recoveryCodes":"******"`,
+		},
+		{
+			name: "带转义的字符串",
+			body: `{"refresh_token":"a\"b","session_key":"sk"}`,
+			want: `{"refresh_token":"******","session_key":"******"}`,
+		},
+		{
+			name: "不是敏感字段",
+			body: `{"email":"a@qq.com","securityEvents":[{"ip":"1.0.0.1"}]}`,
+			want: `{"email":"a@qq.com","securityEvents":[{"ip":"1.0.0.1"}]}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, redact([]byte(tc.body)))
+		})
+	}
+}
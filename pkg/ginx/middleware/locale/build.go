@@ -0,0 +1,26 @@
+package locale
+
+import (
+	"github.com/gin-gonic/gin"
+	"webook/pkg/i18n"
+)
+
+// Builder 根据 Accept-Language 选择语言，把 i18n.Localizer 放进请求的 context
+// 登录用户的语言偏好在 JWT 中间件里面通过 i18n.Prefer 覆盖
+type Builder struct {
+	bundle *i18n.Bundle
+}
+
+func NewBuilder(bundle *i18n.Bundle) *Builder {
+	return &Builder{
+		bundle: bundle,
+	}
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		tag := b.bundle.Match(ctx.GetHeader("Accept-Language"))
+		ctx.Request = ctx.Request.WithContext(
+			i18n.WithLocalizer(ctx.Request.Context(), b.bundle.Localizer(tag)))
+	}
+}
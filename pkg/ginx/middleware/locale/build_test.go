@@ -0,0 +1,53 @@
+package locale
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/pkg/ginx"
+	"webook/pkg/i18n"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	bundle := i18n.NewBundle(language.MustParse("zh-CN"))
+	bundle.Add(language.MustParse("zh-CN"), map[string]string{"common.unauthorized": "未登录"})
+	bundle.Add(language.MustParse("en-US"), map[string]string{"common.unauthorized": "Please log in first"})
+
+	testCases := []struct {
+		name           string
+		acceptLanguage string
+		wantBody       string
+	}{
+		{
+			name:     "默认语言",
+			wantBody: `{"code":100002,"msg":"未登录","data":null}`,
+		},
+		{
+			name:           "英文",
+			acceptLanguage: "en-GB,en;q=0.9",
+			wantBody:       `{"code":100002,"msg":"Please log in first","data":null}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(NewBuilder(bundle).Build())
+			server.GET("/profile", ginx.Wrap(func(ctx *gin.Context) (any, error) {
+				return nil, ginx.ErrUnauthorized
+			}))
+
+			req, err := http.NewRequest(http.MethodGet, "/profile", nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusUnauthorized, resp.Code)
+			assert.JSONEq(t, tc.wantBody, resp.Body.String())
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package prometheus
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"time"
+)
+
+// Builder 统计每个路由的响应时间和正在处理的请求数
+type Builder struct {
+	Namespace string
+	Subsystem string
+	Name      string
+	Help      string
+	reg       prometheus.Registerer
+}
+
+func NewBuilder(namespace, subsystem, name, help string) *Builder {
+	return &Builder{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      name,
+		Help:      help,
+		reg:       prometheus.DefaultRegisterer,
+	}
+}
+
+// Registerer 默认注册到 prometheus.DefaultRegisterer
+func (b *Builder) Registerer(reg prometheus.Registerer) *Builder {
+	b.reg = reg
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	latency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: b.Namespace,
+		Subsystem: b.Subsystem,
+		Name:      b.Name + "_duration_seconds",
+		Help:      b.Help,
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{"method", "route", "status"})
+	active := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: b.Namespace,
+		Subsystem: b.Subsystem,
+		Name:      "active_requests",
+		Help:      "正在处理的请求数",
+	})
+	b.reg.MustRegister(latency, active)
+
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		active.Inc()
+		defer func() {
+			active.Dec()
+			// 没有匹配到路由的请求统一记为 unknown，避免 404 把 label 撑爆
+			route := ctx.FullPath()
+			if route == "" {
+				route = "unknown"
+			}
+			latency.WithLabelValues(ctx.Request.Method, route,
+				strconv.Itoa(ctx.Writer.Status())).Observe(time.Since(start).Seconds())
+		}()
+		ctx.Next()
+	}
+}
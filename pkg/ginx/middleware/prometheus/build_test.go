@@ -0,0 +1,39 @@
+package prometheus
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	server := gin.New()
+	server.Use(NewBuilder("webook", "http", "request", "HTTP 请求响应时间").Registerer(reg).Build())
+	server.GET("/users/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/not-found"} {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err)
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// 同一个路由的请求合并成一个 label，没有匹配到路由的记为 unknown
+	expected := `
+# HELP webook_http_active_requests 正在处理的请求数
+# TYPE webook_http_active_requests gauge
+webook_http_active_requests 0
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "webook_http_active_requests"))
+	count, err := testutil.GatherAndCount(reg, "webook_http_request_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
@@ -4,19 +4,64 @@ import (
 	_ "embed"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"log"
-	"net/http"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"webook/pkg/ginx"
 	"webook/pkg/limiter"
+	"webook/pkg/logger"
+)
+
+// KeyFunc 计算限流对象，返回空字符串表示这条规则不适用于当前请求
+type KeyFunc func(ctx *gin.Context) string
+
+// Rule 一条限流规则，多条规则同时生效，任意一条触发就限流
+// 通过 AddRule 添加的规则对所有路由生效，路由维度的规则见 RouteRule
+type Rule struct {
+	Name    string
+	Key     KeyFunc
+	Limiter limiter.Limiter
+}
+
+// FailPolicy 限流器出错（比如 Redis 崩溃）时的处理策略
+// 需要降级到本地限流的，用 limiter.FailoverLimiter 包装限流器，这种情况下限流器不会再返回错误
+type FailPolicy int
+
+const (
+	// FailClosed 保守做法：因为借助于 Redis 来做限流，那么 Redis 崩溃了，为了防止系统崩溃，直接限流
+	FailClosed FailPolicy = iota
+	// FailOpen 激进做法：虽然 Redis 崩溃了，但是这个时候还是要尽量服务正常的用户，所以不限流
+	FailOpen
 )
 
 type Builder struct {
-	prefix  string
-	limiter limiter.Limiter
+	prefix     string
+	rules      []Rule
+	failPolicy FailPolicy
+	l          logger.Logger
+
+	// 路由规则，支持热更新
+	factory LimiterFactory
+	keys    map[string]KeyFunc
+	routes  atomic.Pointer[[]routeRule]
 }
 
+// NewBuilder 默认按照 IP 限流
 func NewBuilder(l limiter.Limiter) *Builder {
+	return NewRuleBuilder(Rule{Name: "ip", Key: KeyByIP, Limiter: l})
+}
+
+// NewRuleBuilder 只使用传入的规则，比如放在登录中间件后面只按用户限流
+func NewRuleBuilder(rules ...Rule) *Builder {
 	return &Builder{
-		limiter: l,
+		prefix: "ip-limiter",
+		rules:  rules,
+		keys: map[string]KeyFunc{
+			"ip":    KeyByIP,
+			"route": KeyByRoute,
+		},
+		l: logger.NewNopLogger(),
 	}
 }
 
@@ -25,24 +70,120 @@ func (b *Builder) Prefix(prefix string) *Builder {
 	return b
 }
 
+func (b *Builder) FailPolicy(policy FailPolicy) *Builder {
+	b.failPolicy = policy
+	return b
+}
+
+func (b *Builder) Logger(l logger.Logger) *Builder {
+	b.l = l
+	return b
+}
+
+// AddRule 增加一条限流规则，比如按用户、按路由限流
+func (b *Builder) AddRule(name string, key KeyFunc, l limiter.Limiter) *Builder {
+	b.rules = append(b.rules, Rule{Name: name, Key: key, Limiter: l})
+	return b
+}
+
+// KeyByIP 按客户端 IP 限流
+func KeyByIP(ctx *gin.Context) string {
+	return ctx.ClientIP()
+}
+
+// KeyByRoute 每个路由单独计数
+func KeyByRoute(ctx *gin.Context) string {
+	return ctx.Request.Method + ":" + routeOf(ctx)
+}
+
+// KeyByUser 按登录用户限流，userID 从登录态中获取，未登录的请求不适用
+func KeyByUser(userID func(ctx *gin.Context) (int64, bool)) KeyFunc {
+	return func(ctx *gin.Context) string {
+		uid, ok := userID(ctx)
+		if !ok {
+			return ""
+		}
+		return strconv.FormatInt(uid, 10)
+	}
+}
+
+// KeyByUserRoute 同一个用户在每个路由上单独计数
+func KeyByUserRoute(userID func(ctx *gin.Context) (int64, bool)) KeyFunc {
+	byUser := KeyByUser(userID)
+	return func(ctx *gin.Context) string {
+		uid := byUser(ctx)
+		if uid == "" {
+			return ""
+		}
+		return uid + ":" + KeyByRoute(ctx)
+	}
+}
+
+func routeOf(ctx *gin.Context) string {
+	// 用注册的路由而不是实际路径，避免 /users/:id 这种路由被拆成无数个 key
+	if route := ctx.FullPath(); route != "" {
+		return route
+	}
+	return ctx.Request.URL.Path
+}
+
 func (b *Builder) Build() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		limited, err := b.limiter.Limit(ctx, fmt.Sprintf("%s:%s", b.prefix, ctx.ClientIP()))
-		if err != nil {
-			log.Println(err)
-			// 这一步很有意思，就是如果这边出错了
-			// 要怎么办？
-			// 保守做法：因为借助于 Redis 来做限流，那么 Redis 崩溃了，为了防止系统崩溃，直接限流
-			ctx.AbortWithStatus(http.StatusInternalServerError)
-			// 激进做法：虽然 Redis 崩溃了，但是这个时候还是要尽量服务正常的用户，所以不限流
-			// ctx.Next()
-			return
+		// 记录剩余配额最少的那条规则，用来生成响应头
+		var tightest *limiter.Result
+		rules := b.rules
+		if routes := b.matchRoutes(ctx); len(routes) > 0 {
+			rules = append(routes, rules...)
+		}
+		for _, rule := range rules {
+			key := rule.Key(ctx)
+			if key == "" {
+				continue
+			}
+			res, err := rule.Limiter.Limit(ctx, fmt.Sprintf("%s:%s:%s", b.prefix, rule.Name, key))
+			if err != nil {
+				b.l.Error(ctx, "限流器出错", logger.String("rule", rule.Name), logger.Error(err))
+				if b.failPolicy == FailOpen {
+					// 跳过这条规则
+					continue
+				}
+				ginx.Abort(ctx, ginx.ErrInternal.Wrap(err))
+				return
+			}
+			if res.Limited {
+				setHeaders(ctx, res)
+				ginx.Abort(ctx, ginx.ErrTooManyRequests)
+				return
+			}
+			if tightest == nil || res.Remaining < tightest.Remaining {
+				tightest = &res
+			}
 		}
-		if limited {
-			log.Println(err)
-			ctx.AbortWithStatus(http.StatusTooManyRequests)
-			return
+		if tightest != nil && tighterThanHeaders(ctx, *tightest) {
+			setHeaders(ctx, *tightest)
 		}
 		ctx.Next()
 	}
 }
+
+// tighterThanHeaders 前面的限流中间件已经写了响应头的时候，保留剩余配额更少的那个
+func tighterThanHeaders(ctx *gin.Context, res limiter.Result) bool {
+	cur, err := strconv.Atoi(ctx.Writer.Header().Get("RateLimit-Remaining"))
+	return err != nil || res.Remaining < cur
+}
+
+// setHeaders 写入 RateLimit-* 和 Retry-After 响应头
+// https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+func setHeaders(ctx *gin.Context, res limiter.Result) {
+	ctx.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+	ctx.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	ctx.Header("RateLimit-Reset", strconv.Itoa(seconds(res.ResetAfter)))
+	if res.Limited {
+		ctx.Header("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+	}
+}
+
+// seconds 向上取整，避免客户端过早重试
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
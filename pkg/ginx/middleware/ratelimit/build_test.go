@@ -0,0 +1,207 @@
+package ratelimit
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webook/pkg/limiter"
+	limiter_mocksvc "webook/pkg/limiter/mock"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	userID := func(ctx *gin.Context) (int64, bool) {
+		uid := ctx.GetInt64("uid")
+		return uid, uid > 0
+	}
+	testCases := []struct {
+		name        string
+		mock        func(ctrl *gomock.Controller) *Builder
+		uid         int64
+		wantCode    int
+		wantHeaders map[string]string
+	}{
+		{
+			name: "不限流，返回剩余配额最少的规则",
+			mock: func(ctrl *gomock.Controller) *Builder {
+				ipLimiter := limiter_mocksvc.NewMockLimiter(ctrl)
+				ipLimiter.EXPECT().Limit(gomock.Any(), "ip-limiter:ip:192.0.2.1").
+					Return(limiter.Result{Limit: 100, Remaining: 99, ResetAfter: time.Second}, nil)
+				userLimiter := limiter_mocksvc.NewMockLimiter(ctrl)
+				userLimiter.EXPECT().Limit(gomock.Any(), "ip-limiter:user:123:GET:/users/profile").
+					Return(limiter.Result{Limit: 10, Remaining: 3, ResetAfter: time.Millisecond * 1500}, nil)
+				return NewBuilder(ipLimiter).AddRule("user", KeyByUserRoute(userID), userLimiter)
+			},
+			uid:      123,
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit":     "10",
+				"RateLimit-Remaining": "3",
+				"RateLimit-Reset":     "2",
+				"Retry-After":         "",
+			},
+		},
+		{
+			name: "未登录，跳过按用户限流",
+			mock: func(ctrl *gomock.Controller) *Builder {
+				ipLimiter := limiter_mocksvc.NewMockLimiter(ctrl)
+				ipLimiter.EXPECT().Limit(gomock.Any(), gomock.Any()).
+					Return(limiter.Result{Limit: 100, Remaining: 99, ResetAfter: time.Second}, nil)
+				userLimiter := limiter_mocksvc.NewMockLimiter(ctrl)
+				return NewBuilder(ipLimiter).AddRule("user", KeyByUser(userID), userLimiter)
+			},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit":     "100",
+				"RateLimit-Remaining": "99",
+			},
+		},
+		{
+			name: "触发限流",
+			mock: func(ctrl *gomock.Controller) *Builder {
+				ipLimiter := limiter_mocksvc.NewMockLimiter(ctrl)
+				ipLimiter.EXPECT().Limit(gomock.Any(), gomock.Any()).
+					Return(limiter.Result{Limited: true, Limit: 100, ResetAfter: time.Second,
+						RetryAfter: time.Millisecond * 200}, nil)
+				return NewBuilder(ipLimiter).AddRule("route", KeyByRoute, limiter_mocksvc.NewMockLimiter(ctrl))
+			},
+			wantCode: http.StatusTooManyRequests,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit":     "100",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "1",
+				"Retry-After":         "1",
+			},
+		},
+		{
+			name: "限流器出错",
+			mock: func(ctrl *gomock.Controller) *Builder {
+				ipLimiter := limiter_mocksvc.NewMockLimiter(ctrl)
+				ipLimiter.EXPECT().Limit(gomock.Any(), gomock.Any()).
+					Return(limiter.Result{}, errors.New("redis err"))
+				return NewBuilder(ipLimiter)
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name: "限流器出错，放行",
+			mock: func(ctrl *gomock.Controller) *Builder {
+				ipLimiter := limiter_mocksvc.NewMockLimiter(ctrl)
+				ipLimiter.EXPECT().Limit(gomock.Any(), gomock.Any()).
+					Return(limiter.Result{}, limiter.ErrLimiterUnavailable)
+				return NewBuilder(ipLimiter).FailPolicy(FailOpen)
+			},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit": "",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				if tc.uid > 0 {
+					ctx.Set("uid", tc.uid)
+				}
+			}, tc.mock(ctrl).Build())
+			server.GET("/users/profile", func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, "profile")
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "/users/profile", nil)
+			assert.NoError(t, err)
+			req.RemoteAddr = "192.0.2.1:1234"
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			for k, v := range tc.wantHeaders {
+				assert.Equal(t, v, recorder.Header().Get(k), k)
+			}
+		})
+	}
+}
+
+// 按 IP 限流在登录校验前面，按用户限流在后面
+func TestBuilder_Build_BeforeAndAfterLogin(t *testing.T) {
+	userID := func(ctx *gin.Context) (int64, bool) {
+		uid := ctx.GetInt64("uid")
+		return uid, uid > 0
+	}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (before, after *Builder)
+		// login 模拟登录中间件，为 false 的时候拒绝
+		login bool
+
+		wantCode      int
+		wantRemaining string
+	}{
+		{
+			name: "没有登录，按 IP 限流之后被登录校验拒绝",
+			mock: func(ctrl *gomock.Controller) (before, after *Builder) {
+				ipLimiter := limiter_mocksvc.NewMockLimiter(ctrl)
+				ipLimiter.EXPECT().Limit(gomock.Any(), "ip-limiter:ip:192.0.2.1").
+					Return(limiter.Result{Limit: 100, Remaining: 99, ResetAfter: time.Second}, nil)
+				return NewBuilder(ipLimiter),
+					NewRuleBuilder(Rule{Name: "user", Key: KeyByUser(userID),
+						Limiter: limiter_mocksvc.NewMockLimiter(ctrl)})
+			},
+			wantCode:      http.StatusUnauthorized,
+			wantRemaining: "99",
+		},
+		{
+			name: "登录之后按用户限流，保留剩余配额更少的响应头",
+			mock: func(ctrl *gomock.Controller) (before, after *Builder) {
+				ipLimiter := limiter_mocksvc.NewMockLimiter(ctrl)
+				ipLimiter.EXPECT().Limit(gomock.Any(), "ip-limiter:ip:192.0.2.1").
+					Return(limiter.Result{Limit: 100, Remaining: 5, ResetAfter: time.Second}, nil)
+				userLimiter := limiter_mocksvc.NewMockLimiter(ctrl)
+				userLimiter.EXPECT().Limit(gomock.Any(), "ip-limiter:user:123").
+					Return(limiter.Result{Limit: 10, Remaining: 9, ResetAfter: time.Second}, nil)
+				return NewBuilder(ipLimiter),
+					NewRuleBuilder(Rule{Name: "user", Key: KeyByUser(userID), Limiter: userLimiter})
+			},
+			login:         true,
+			wantCode:      http.StatusOK,
+			wantRemaining: "5",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			before, after := tc.mock(ctrl)
+			server := gin.New()
+			server.Use(before.Build(), func(ctx *gin.Context) {
+				if !tc.login {
+					ctx.AbortWithStatus(http.StatusUnauthorized)
+					return
+				}
+				ctx.Set("uid", int64(123))
+			}, after.Build())
+			server.GET("/users/profile", func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, "profile")
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "/users/profile", nil)
+			assert.NoError(t, err)
+			req.RemoteAddr = "192.0.2.1:1234"
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantRemaining, recorder.Header().Get("RateLimit-Remaining"))
+		})
+	}
+}
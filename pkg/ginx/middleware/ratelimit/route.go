@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"strings"
+	"time"
+	"webook/pkg/limiter"
+)
+
+// bodyKeyPrefix 形如 body:phone 的 key 表示按请求体（JSON）里的字段限流
+const bodyKeyPrefix = "body:"
+
+// maxBodySize 按请求体字段限流时最多读取的字节数
+const maxBodySize = 1 << 20
+
+// RouteRule 路由维度的限流规则，一般来自配置
+type RouteRule struct {
+	// Method 为空或者 * 表示所有方法
+	Method string
+	// Path 注册的路由，比如 /users/login_sms/code/send
+	// 以 * 结尾表示前缀匹配，比如 /users/*
+	Path string
+	// Key 限流对象：ip、route，通过 RegisterKey 注册的名字，或者 body:字段名
+	Key string
+	// Window 窗口大小，比如 1s、1m
+	Window string
+	Rate   int
+}
+
+// LimiterFactory 根据窗口大小和阈值创建限流器，加载路由规则时使用
+type LimiterFactory func(interval time.Duration, rate int) limiter.Limiter
+
+type routeRule struct {
+	method string
+	path   string
+	// window 和 rate 没变的规则，热更新的时候继续用原来的限流器
+	window time.Duration
+	rate   int
+	Rule
+}
+
+// RouteTable 校验过的路由规则，Apply 之后才生效
+type RouteTable struct {
+	b      *Builder
+	routes []routeRule
+}
+
+// Apply 替换掉 Builder 的全部路由规则
+func (t *RouteTable) Apply() {
+	t.b.routes.Store(&t.routes)
+}
+
+func (r routeRule) match(method, route string) bool {
+	if r.method != "" && r.method != "*" && r.method != method {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.path, "*"); ok {
+		return strings.HasPrefix(route, prefix)
+	}
+	return r.path == route
+}
+
+// LimiterFactory 设置路由规则使用的限流器
+func (b *Builder) LimiterFactory(factory LimiterFactory) *Builder {
+	b.factory = factory
+	return b
+}
+
+// RegisterKey 注册路由规则中可以使用的限流对象
+func (b *Builder) RegisterKey(name string, key KeyFunc) *Builder {
+	b.keys[name] = key
+	return b
+}
+
+// SetRouteRules 替换全部路由规则，可以在运行期间调用，实现热更新
+// 规则有任何一条不合法，都不会生效
+func (b *Builder) SetRouteRules(rules []RouteRule) error {
+	t, err := b.CompileRouteRules(rules)
+	if err != nil {
+		return err
+	}
+	t.Apply()
+	return nil
+}
+
+// CompileRouteRules 校验路由规则，不影响正在用的规则
+// 几个 Builder 的规则要一起更新的时候，先全部校验通过再分别 Apply，免得只更新了一半
+// 和正在用的规则一模一样的，沿用原来的限流器，本地降级的计数不会因为热更新清零
+func (b *Builder) CompileRouteRules(rules []RouteRule) (*RouteTable, error) {
+	if b.factory == nil {
+		return nil, fmt.Errorf("没有设置 LimiterFactory")
+	}
+	current := make(map[routeRuleID][]limiter.Limiter)
+	if routes := b.routes.Load(); routes != nil {
+		for _, r := range *routes {
+			id := r.id()
+			current[id] = append(current[id], r.Limiter)
+		}
+	}
+	compiled := make([]routeRule, 0, len(rules))
+	for _, r := range rules {
+		key, err := b.keyFunc(r.Key)
+		if err != nil {
+			return nil, err
+		}
+		window, err := time.ParseDuration(r.Window)
+		if err != nil {
+			return nil, fmt.Errorf("限流规则 %s %s 的窗口不合法: %w", r.Method, r.Path, err)
+		}
+		if window <= 0 || r.Rate <= 0 {
+			return nil, fmt.Errorf("限流规则 %s %s 的窗口和阈值必须大于 0", r.Method, r.Path)
+		}
+		method := strings.ToUpper(r.Method)
+		rr := routeRule{
+			method: method,
+			path:   r.Path,
+			window: window,
+			rate:   r.Rate,
+			Rule: Rule{
+				Name: fmt.Sprintf("route:%s:%s:%s", method, r.Path, r.Key),
+				Key:  key,
+			},
+		}
+		// 重复的规则各用各的，每个旧的限流器只沿用一次
+		id := rr.id()
+		if ls := current[id]; len(ls) > 0 {
+			rr.Limiter, current[id] = ls[0], ls[1:]
+		} else {
+			rr.Limiter = b.factory(window, r.Rate)
+		}
+		compiled = append(compiled, rr)
+	}
+	return &RouteTable{b: b, routes: compiled}, nil
+}
+
+// routeRuleID 名字里面已经有方法、路径和限流对象
+type routeRuleID struct {
+	name   string
+	window time.Duration
+	rate   int
+}
+
+func (r routeRule) id() routeRuleID {
+	return routeRuleID{name: r.Name, window: r.window, rate: r.rate}
+}
+
+func (b *Builder) keyFunc(name string) (KeyFunc, error) {
+	if field, ok := strings.CutPrefix(name, bodyKeyPrefix); ok && field != "" {
+		return KeyByJSONField(field), nil
+	}
+	key, ok := b.keys[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的限流对象 %s", name)
+	}
+	return key, nil
+}
+
+// matchRoutes 找出当前请求命中的路由规则
+func (b *Builder) matchRoutes(ctx *gin.Context) []Rule {
+	routes := b.routes.Load()
+	if routes == nil {
+		return nil
+	}
+	var res []Rule
+	route := routeOf(ctx)
+	for _, r := range *routes {
+		if r.match(ctx.Request.Method, route) {
+			res = append(res, r.Rule)
+		}
+	}
+	return res
+}
+
+// KeyByJSONField 按 JSON 请求体里的字段限流，比如发送验证码时按手机号限流
+// 读完之后会把请求体放回去，不影响后面的 Bind
+func KeyByJSONField(field string) KeyFunc {
+	return func(ctx *gin.Context) string {
+		if ctx.Request.Body == nil {
+			return ""
+		}
+		data, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxBodySize))
+		if err != nil {
+			return ""
+		}
+		ctx.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), ctx.Request.Body))
+		var body map[string]any
+		if err = json.Unmarshal(data, &body); err != nil {
+			return ""
+		}
+		val, ok := body[field]
+		if !ok {
+			return ""
+		}
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,167 @@
+package ratelimit
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webook/pkg/limiter"
+	limiter_mocksvc "webook/pkg/limiter/mock"
+)
+
+func TestBuilder_SetRouteRules(t *testing.T) {
+	testCases := []struct {
+		name  string
+		rules []RouteRule
+		// 按创建顺序，每个路由规则限流器的预期
+		mock func(ctrl *gomock.Controller) []limiter.Limiter
+
+		method   string
+		path     string
+		body     string
+		wantCode int
+		// handler 读到的请求体
+		wantBody string
+	}{
+		{
+			name: "按手机号限流，请求体可以继续读取",
+			rules: []RouteRule{
+				{Method: "post", Path: "/users/login_sms/code/send", Key: "body:phone", Window: "1m", Rate: 1},
+			},
+			mock: func(ctrl *gomock.Controller) []limiter.Limiter {
+				l := limiter_mocksvc.NewMockLimiter(ctrl)
+				l.EXPECT().Limit(gomock.Any(), "ip-limiter:route:POST:/users/login_sms/code/send:body:phone:15212345678").
+					Return(limiter.Result{Limit: 1}, nil)
+				return []limiter.Limiter{l}
+			},
+			method:   http.MethodPost,
+			path:     "/users/login_sms/code/send",
+			body:     `{"phone":"15212345678"}`,
+			wantCode: http.StatusOK,
+			wantBody: `{"phone":"15212345678"}`,
+		},
+		{
+			name: "前缀匹配，触发限流",
+			rules: []RouteRule{
+				{Path: "/users/*", Key: "ip", Window: "1s", Rate: 10},
+			},
+			mock: func(ctrl *gomock.Controller) []limiter.Limiter {
+				l := limiter_mocksvc.NewMockLimiter(ctrl)
+				l.EXPECT().Limit(gomock.Any(), "ip-limiter:route::/users/*:ip:192.0.2.1").
+					Return(limiter.Result{Limited: true, Limit: 10}, nil)
+				return []limiter.Limiter{l}
+			},
+			method:   http.MethodGet,
+			path:     "/users/profile",
+			wantCode: http.StatusTooManyRequests,
+		},
+		{
+			name: "方法不匹配",
+			rules: []RouteRule{
+				{Method: http.MethodGet, Path: "/users/login_sms/code/send", Key: "ip", Window: "1s", Rate: 10},
+			},
+			mock: func(ctrl *gomock.Controller) []limiter.Limiter {
+				return []limiter.Limiter{limiter_mocksvc.NewMockLimiter(ctrl)}
+			},
+			method:   http.MethodPost,
+			path:     "/users/login_sms/code/send",
+			body:     `{}`,
+			wantCode: http.StatusOK,
+			wantBody: `{}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// 全局规则始终放行
+			global := limiter_mocksvc.NewMockLimiter(ctrl)
+			global.EXPECT().Limit(gomock.Any(), gomock.Any()).
+				Return(limiter.Result{Limit: 100, Remaining: 100}, nil).AnyTimes()
+			limiters := tc.mock(ctrl)
+			b := NewBuilder(global).LimiterFactory(func(interval time.Duration, rate int) limiter.Limiter {
+				l := limiters[0]
+				limiters = limiters[1:]
+				return l
+			})
+			require.NoError(t, b.SetRouteRules(tc.rules))
+
+			var gotBody string
+			handler := func(ctx *gin.Context) {
+				data, _ := ctx.GetRawData()
+				gotBody = string(data)
+			}
+			server := gin.New()
+			server.Use(b.Build())
+			server.POST("/users/login_sms/code/send", handler)
+			server.GET("/users/profile", handler)
+
+			req, err := http.NewRequest(tc.method, tc.path, bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+			req.RemoteAddr = "192.0.2.1:1234"
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, gotBody)
+		})
+	}
+}
+
+func TestBuilder_SetRouteRules_Invalid(t *testing.T) {
+	factory := func(interval time.Duration, rate int) limiter.Limiter {
+		return limiter.NewLocalTokenBucketLimiter(interval, rate)
+	}
+	valid := RouteRule{Path: "/users/login", Key: "ip", Window: "1m", Rate: 5}
+	testCases := []struct {
+		name string
+		rule RouteRule
+	}{
+		{name: "未知的限流对象", rule: RouteRule{Path: "/users/login", Key: "user", Window: "1m", Rate: 5}},
+		{name: "窗口不合法", rule: RouteRule{Path: "/users/login", Key: "ip", Window: "1x", Rate: 5}},
+		{name: "阈值不合法", rule: RouteRule{Path: "/users/login", Key: "ip", Window: "1m"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewBuilder(nil).LimiterFactory(factory)
+			require.NoError(t, b.SetRouteRules([]RouteRule{valid}))
+			assert.Error(t, b.SetRouteRules([]RouteRule{valid, tc.rule}))
+			// 出错的时候保留原来的规则
+			assert.Len(t, *b.routes.Load(), 1)
+		})
+	}
+}
+
+func TestBuilder_CompileRouteRules_Reuse(t *testing.T) {
+	created := 0
+	factory := func(interval time.Duration, rate int) limiter.Limiter {
+		created++
+		return limiter.NewLocalTokenBucketLimiter(interval, rate)
+	}
+	login := RouteRule{Path: "/users/login", Key: "ip", Window: "1m", Rate: 5}
+	signup := RouteRule{Path: "/users/signup", Key: "ip", Window: "1m", Rate: 5}
+	b := NewBuilder(nil).LimiterFactory(factory)
+	require.NoError(t, b.SetRouteRules([]RouteRule{login, signup}))
+	old := *b.routes.Load()
+	require.Equal(t, 2, created)
+
+	// 只改了注册的阈值，登录的限流器沿用原来的
+	signup.Rate = 10
+	table, err := b.CompileRouteRules([]RouteRule{login, signup})
+	require.NoError(t, err)
+	assert.Equal(t, 3, created)
+	// Apply 之前还是原来的规则
+	assert.Same(t, old[1].Limiter, (*b.routes.Load())[1].Limiter)
+
+	table.Apply()
+	routes := *b.routes.Load()
+	assert.Same(t, old[0].Limiter, routes[0].Limiter)
+	assert.NotSame(t, old[1].Limiter, routes[1].Limiter)
+}
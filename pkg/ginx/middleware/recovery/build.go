@@ -0,0 +1,53 @@
+package recovery
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"runtime/debug"
+	"syscall"
+	"webook/pkg/ginx"
+	"webook/pkg/logger"
+)
+
+// Builder 捕获后面的中间件和 handler 里面的 panic，记日志之后返回 ginx.ErrInternal
+// 放在 requestid 后面，日志能带上 request id，其他中间件 panic 也能捕获
+type Builder struct {
+	l logger.Logger
+}
+
+func NewBuilder(l logger.Logger) *Builder {
+	return &Builder{l: l}
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if brokenPipe(rec) {
+				// 客户端已经断开了，写不回去，也不用打调用栈
+				b.l.Warn(ctx, "客户端断开连接", logger.String("path", ctx.Request.URL.Path),
+					logger.String("panic", fmt.Sprint(rec)))
+				ctx.Abort()
+				return
+			}
+			b.l.Error(ctx, "处理请求的时候 panic", logger.String("path", ctx.Request.URL.Path),
+				logger.String("panic", fmt.Sprint(rec)), logger.String("stack", string(debug.Stack())))
+			if ctx.Writer.Written() {
+				// 已经开始写响应了，只能中断
+				ctx.Abort()
+				return
+			}
+			ginx.Abort(ctx, ginx.ErrInternal.Wrap(fmt.Errorf("panic: %v", rec)))
+		}()
+		ctx.Next()
+	}
+}
+
+func brokenPipe(rec any) bool {
+	err, ok := rec.(error)
+	return ok && (errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET))
+}
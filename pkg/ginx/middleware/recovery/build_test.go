@@ -0,0 +1,85 @@
+package recovery
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/pkg/ginx/middleware/requestid"
+	"webook/pkg/logger"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler gin.HandlerFunc
+
+		wantCode int
+		wantBody string
+		// wantLog 为空表示不应该有日志
+		wantLog string
+	}{
+		{
+			name: "中间件 panic",
+			handler: func(ctx *gin.Context) {
+				panic("限流器没有初始化")
+			},
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"code":100000,"msg":"系统错误","data":null}`,
+			wantLog:  "处理请求的时候 panic",
+		},
+		{
+			name: "已经写了响应",
+			handler: func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, "hello")
+				panic("写完之后 panic")
+			},
+			wantCode: http.StatusOK,
+			wantBody: "hello",
+			wantLog:  "处理请求的时候 panic",
+		},
+		{
+			name: "没有 panic",
+			handler: func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, "hello")
+			},
+			wantCode: http.StatusOK,
+			wantBody: "hello",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.InfoLevel)
+			server := gin.New()
+			server.ContextWithFallback = true
+			server.Use(requestid.NewBuilder().Build(),
+				NewBuilder(logger.NewZapLogger(zap.New(core))).Build(),
+				tc.handler)
+			server.GET("/hello", func(ctx *gin.Context) {})
+
+			req, err := http.NewRequest(http.MethodGet, "/hello", nil)
+			require.NoError(t, err)
+			req.Header.Set("X-Request-ID", "req-1")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			if tc.wantLog == "" {
+				assert.Zero(t, logs.Len())
+				return
+			}
+			require.Equal(t, 1, logs.Len())
+			entry := logs.All()[0]
+			assert.Equal(t, tc.wantLog, entry.Message)
+			fields := entry.ContextMap()
+			assert.Equal(t, "req-1", fields[requestid.Key])
+			assert.NotEmpty(t, fields["stack"])
+		})
+	}
+}
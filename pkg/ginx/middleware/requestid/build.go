@@ -0,0 +1,43 @@
+package requestid
+
+import (
+	"github.com/gin-gonic/gin"
+	uuid "github.com/lithammer/shortuuid/v4"
+	"webook/pkg/logger"
+)
+
+// Key request id 存放在 gin.Context 中的 key
+const Key = "request_id"
+
+// Builder 给每个请求分配 request id，写入响应头，并且放进日志字段
+// 需要打开 gin.Engine.ContextWithFallback，业务代码直接用 *gin.Context 打日志才能带上
+type Builder struct {
+	header string
+}
+
+func NewBuilder() *Builder {
+	return &Builder{
+		header: "X-Request-ID",
+	}
+}
+
+// Header 修改读取和写回 request id 的请求头
+func (b *Builder) Header(header string) *Builder {
+	b.header = header
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// 网关或者上游已经分配了，就沿用，方便串起整条链路
+		id := ctx.GetHeader(b.header)
+		if id == "" || len(id) > 64 {
+			id = uuid.New()
+		}
+		ctx.Set(Key, id)
+		ctx.Header(b.header, id)
+		ctx.Request = ctx.Request.WithContext(
+			logger.WithFields(ctx.Request.Context(), logger.String(Key, id)))
+		ctx.Next()
+	}
+}
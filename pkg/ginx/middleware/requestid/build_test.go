@@ -0,0 +1,51 @@
+package requestid
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/pkg/logger"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header string
+		// 为空表示只检查生成了 request id
+		wantID string
+	}{
+		{name: "沿用上游的 request id", header: "req-from-gateway", wantID: "req-from-gateway"},
+		{name: "生成新的 request id"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var fields []logger.Field
+			server := gin.New()
+			server.ContextWithFallback = true
+			server.Use(NewBuilder().Build())
+			server.GET("/hello", func(ctx *gin.Context) {
+				// 业务代码拿到的是 *gin.Context
+				fields = logger.FieldsFromContext(ctx)
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "/hello", nil)
+			require.NoError(t, err)
+			if tc.header != "" {
+				req.Header.Set("X-Request-ID", tc.header)
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			id := recorder.Header().Get("X-Request-ID")
+			require.NotEmpty(t, id)
+			if tc.wantID != "" {
+				assert.Equal(t, tc.wantID, id)
+			}
+			assert.Equal(t, []logger.Field{logger.String(Key, id)}, fields)
+		})
+	}
+}
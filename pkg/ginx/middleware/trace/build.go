@@ -0,0 +1,72 @@
+package trace
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"webook/pkg/logger"
+)
+
+// Builder 为每个请求创建 span，并且把 trace id 写入响应头和日志字段
+// 上游通过 traceparent 请求头传过来的链路会被延续
+type Builder struct {
+	header string
+}
+
+func NewBuilder() *Builder {
+	return &Builder{
+		header: "X-Trace-ID",
+	}
+}
+
+// Header 修改写回 trace id 的响应头
+func (b *Builder) Header(header string) *Builder {
+	b.header = header
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	// 全局的 Tracer 会代理到之后设置的 TracerProvider
+	tracer := otel.Tracer("webook/pkg/ginx/middleware/trace")
+	return func(ctx *gin.Context) {
+		reqCtx := otel.GetTextMapPropagator().Extract(ctx.Request.Context(),
+			propagation.HeaderCarrier(ctx.Request.Header))
+		route := ctx.FullPath()
+		name := ctx.Request.Method + " " + route
+		if route == "" {
+			// 没有匹配到路由，不用实际路径，避免 span 名字太多
+			name = ctx.Request.Method
+		}
+		reqCtx, span := tracer.Start(reqCtx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(ctx.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(ctx.Request.URL.Path),
+				semconv.ClientAddress(ctx.ClientIP()),
+			))
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.HasTraceID() {
+			id := sc.TraceID().String()
+			ctx.Header(b.header, id)
+			reqCtx = logger.WithFields(reqCtx, logger.String("trace_id", id))
+		}
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if len(ctx.Errors) > 0 {
+			span.RecordError(ctx.Errors.Last())
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package trace
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/pkg/logger"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	testCases := []struct {
+		name        string
+		traceparent string
+		status      int
+
+		wantTraceID    string
+		wantSpanName   string
+		wantStatusCode codes.Code
+	}{
+		{
+			name:           "新的链路",
+			status:         http.StatusOK,
+			wantSpanName:   "GET /users/:id",
+			wantStatusCode: codes.Unset,
+		},
+		{
+			name:           "延续上游的链路",
+			traceparent:    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			status:         http.StatusInternalServerError,
+			wantTraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpanName:   "GET /users/:id",
+			wantStatusCode: codes.Error,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var fields []logger.Field
+			var handlerTraceID string
+			server := gin.New()
+			server.ContextWithFallback = true
+			server.Use(NewBuilder().Build())
+			server.GET("/users/:id", func(ctx *gin.Context) {
+				fields = logger.FieldsFromContext(ctx)
+				// 业务代码拿到的 ctx 里面有 span
+				handlerTraceID = trace.SpanContextFromContext(ctx).TraceID().String()
+				ctx.Status(tc.status)
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "/users/123", nil)
+			require.NoError(t, err)
+			if tc.traceparent != "" {
+				req.Header.Set("traceparent", tc.traceparent)
+			}
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+
+			id := resp.Header().Get("X-Trace-ID")
+			require.Len(t, id, 32)
+			if tc.wantTraceID != "" {
+				assert.Equal(t, tc.wantTraceID, id)
+			}
+			assert.Equal(t, id, handlerTraceID)
+			assert.Equal(t, []logger.Field{logger.String("trace_id", id)}, fields)
+
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+			assert.Equal(t, tc.wantSpanName, span.Name())
+			assert.Equal(t, tc.wantStatusCode, span.Status().Code)
+		})
+	}
+}
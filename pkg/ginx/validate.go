@@ -0,0 +1,86 @@
+package ginx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"strings"
+	"sync"
+	"webook/pkg/i18n"
+)
+
+// FieldError 字段校验失败的详情，放在 Result.Data 里面返回
+type FieldError struct {
+	// Field 字段名，优先用 json tag
+	Field string `json:"field"`
+	// Tag 没通过的校验规则，比如 required、e164
+	Tag string `json:"tag"`
+	Msg string `json:"msg"`
+}
+
+var initValidator sync.Once
+
+// validate gin 的 binding 用的 validator，第一次使用的时候让错误里的字段名使用 json tag
+func validate() *validator.Validate {
+	v := binding.Validator.Engine().(*validator.Validate)
+	initValidator.Do(func() {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" || name == "" {
+				return field.Name
+			}
+			return name
+		})
+	})
+	return v
+}
+
+// RegisterValidation 注册自定义的校验规则，请求结构体上用 binding tag 引用
+// 一般在 init 里面调用，tag 重名或者不合法直接 panic
+func RegisterValidation(tag string, fn validator.Func) {
+	if err := validate().RegisterValidation(tag, fn); err != nil {
+		panic(err)
+	}
+}
+
+// Bind 按 Content-Type 解析请求并且校验 binding tag
+// 失败返回 ErrInvalidParam，写回的时候字段级别的错误放在 Result.Data 里面
+func Bind(ctx *gin.Context, req any) error {
+	validate()
+	if err := ctx.ShouldBind(req); err != nil {
+		return ErrInvalidParam.Wrap(err)
+	}
+	return nil
+}
+
+// fieldErrors 把校验错误翻译成 FieldError，文案的 key 是 validation.<tag>
+// 文案用 %[1]s 引用字段名，%[2]s 引用规则的参数，比如 max=64 的 64
+func fieldErrors(ctx context.Context, err error) []FieldError {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+	res := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		res = append(res, NewFieldError(ctx, fe.Field(), fe.Tag(), fe.Param()))
+	}
+	return res
+}
+
+// NewFieldError 业务代码自己校验失败的时候用，文案规则和 binding tag 的一样
+// 和 ErrInvalidParam 一起返回：return []ginx.FieldError{...}, ginx.ErrInvalidParam
+func NewFieldError(ctx context.Context, field, tag, param string) FieldError {
+	msg := i18n.Message(ctx, "validation."+tag, "")
+	if msg == "" {
+		msg = i18n.Message(ctx, "validation.default", "%[1]s 不合法")
+	}
+	return FieldError{
+		Field: field,
+		Tag:   tag,
+		Msg:   fmt.Sprintf(msg, field, param),
+	}
+}
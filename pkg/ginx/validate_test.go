@@ -0,0 +1,86 @@
+package ginx
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/pkg/i18n"
+)
+
+func TestBind(t *testing.T) {
+	RegisterValidation("test_even", func(fl validator.FieldLevel) bool {
+		return fl.Field().Int()%2 == 0
+	})
+	type Req struct {
+		Name  string `json:"name" binding:"required,max=4"`
+		Count int    `json:"count" binding:"test_even"`
+	}
+	bundle := i18n.NewBundle(language.MustParse("zh-CN"))
+	bundle.Add(language.MustParse("en-US"), map[string]string{
+		"common.invalid_param": "Invalid parameters",
+		"validation.default":   "%[1]s is invalid",
+		"validation.max":       "%[1]s must be at most %[2]s characters long",
+	})
+
+	testCases := []struct {
+		name           string
+		body           string
+		acceptLanguage string
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "校验通过",
+			body:     `{"name":"tom","count":2}`,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":"tom"}`,
+		},
+		{
+			name:     "默认文案",
+			body:     `{"count":1}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":100001,"msg":"参数错误","data":[
+				{"field":"name","tag":"required","msg":"name 不合法"},
+				{"field":"count","tag":"test_even","msg":"count 不合法"}]}`,
+		},
+		{
+			name:           "翻译文案",
+			body:           `{"name":"jerry","count":1}`,
+			acceptLanguage: "en-US",
+			wantCode:       http.StatusBadRequest,
+			wantBody: `{"code":100001,"msg":"Invalid parameters","data":[
+				{"field":"name","tag":"max","msg":"name must be at most 4 characters long"},
+				{"field":"count","tag":"test_even","msg":"count is invalid"}]}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				tag := bundle.Match(ctx.GetHeader("Accept-Language"))
+				ctx.Request = ctx.Request.WithContext(
+					i18n.WithLocalizer(ctx.Request.Context(), bundle.Localizer(tag)))
+			})
+			server.POST("/test", WrapReq(func(ctx *gin.Context, req Req) (any, error) {
+				return req.Name, nil
+			}))
+
+			req, err := http.NewRequest(http.MethodPost, "/test", bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.JSONEq(t, tc.wantBody, resp.Body.String())
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package ginx
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"webook/pkg/i18n"
+)
+
+const (
+	// MsgOK 成功时候的默认文案
+	MsgOK = "OK"
+	// MsgKeyOK 成功时候文案的 i18n key
+	MsgKeyOK = "common.ok"
+)
+
+// Wrap 把 (resp, error) 风格的 handler 转成 gin.HandlerFunc
+// resp 放在 Result.Data 里面，出错的时候也会带上，比如提示前端需要人机验证
+func Wrap(fn func(ctx *gin.Context) (any, error)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		resp, err := fn(ctx)
+		Write(ctx, resp, err)
+	}
+}
+
+// WrapReq 和 Wrap 一样，只是会先用 Bind 解析和校验请求，失败返回 ErrInvalidParam
+func WrapReq[Req any](fn func(ctx *gin.Context, req Req) (any, error)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req Req
+		if err := Bind(ctx, &req); err != nil {
+			Write(ctx, nil, err)
+			return
+		}
+		resp, err := fn(ctx, req)
+		Write(ctx, resp, err)
+	}
+}
+
+// Write 根据 err 写回 Result，见 Render
+func Write(ctx *gin.Context, resp any, err error) {
+	status, res := Render(ctx, resp, err)
+	ctx.JSON(status, res)
+}
+
+// Render 根据 err 生成 HTTP 状态码和 Result，文案按请求的语言翻译，没有对应的文案就用默认文案
+// 不是 *Error 的错误一律当成 ErrInternal，5xx 的错误原因记录到 ctx.Errors 里面，
+// 访问日志和链路会把它带上；校验失败的时候 Data 是 []FieldError
+// 不返回 JSON 的 handler，比如跳转回前端，用它拿到和 Write 一样的结果
+func Render(ctx *gin.Context, resp any, err error) (int, Result) {
+	if err == nil {
+		return http.StatusOK, Result{
+			Msg:  i18n.Message(ctx.Request.Context(), MsgKeyOK, MsgOK),
+			Data: resp,
+		}
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		e = ErrInternal.Wrap(err)
+	}
+	if e.Status >= http.StatusInternalServerError {
+		_ = ctx.Error(err)
+	}
+	if fields := fieldErrors(ctx.Request.Context(), err); fields != nil && resp == nil {
+		resp = fields
+	}
+	return e.Status, Result{
+		Code: e.Code,
+		Msg:  i18n.Message(ctx.Request.Context(), e.MsgKey, e.Msg),
+		Data: resp,
+	}
+}
+
+// Abort 用在 middleware 里面，写回错误并且中断后续的 handler
+func Abort(ctx *gin.Context, err error) {
+	ctx.Abort()
+	Write(ctx, nil, err)
+}
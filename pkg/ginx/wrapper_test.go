@@ -0,0 +1,115 @@
+package ginx
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var errBizTest = Register(999001, http.StatusConflict, "test.conflict", "冲突")
+
+func TestWrapReq(t *testing.T) {
+	type Req struct {
+		Name string `json:"name"`
+	}
+	testCases := []struct {
+		name string
+		body string
+		fn   func(ctx *gin.Context, req Req) (any, error)
+
+		wantCode   int
+		wantBody   string
+		wantErrors int
+	}{
+		{
+			name: "成功",
+			body: `{"name":"tom"}`,
+			fn: func(ctx *gin.Context, req Req) (any, error) {
+				return req.Name, nil
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"OK","data":"tom"}`,
+		},
+		{
+			name: "Bind 失败",
+			body: `{"name":`,
+			fn: func(ctx *gin.Context, req Req) (any, error) {
+				t.Fatal("不应该调用 handler")
+				return nil, nil
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":100001,"msg":"参数错误","data":null}`,
+		},
+		{
+			name: "业务错误带着数据",
+			body: `{}`,
+			fn: func(ctx *gin.Context, req Req) (any, error) {
+				return map[string]bool{"retry": true}, errBizTest
+			},
+			wantCode: http.StatusConflict,
+			wantBody: `{"code":999001,"msg":"冲突","data":{"retry":true}}`,
+		},
+		{
+			name: "包装过的业务错误",
+			body: `{}`,
+			fn: func(ctx *gin.Context, req Req) (any, error) {
+				return nil, errBizTest.Wrap(errors.New("db err"))
+			},
+			wantCode: http.StatusConflict,
+			wantBody: `{"code":999001,"msg":"冲突","data":null}`,
+		},
+		{
+			name: "未知错误",
+			body: `{}`,
+			fn: func(ctx *gin.Context, req Req) (any, error) {
+				return nil, errors.New("db err")
+			},
+			wantCode:   http.StatusInternalServerError,
+			wantBody:   `{"code":100000,"msg":"系统错误","data":null}`,
+			wantErrors: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var errs int
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Next()
+				errs = len(ctx.Errors)
+			})
+			server.POST("/test", WrapReq(tc.fn))
+
+			req, err := http.NewRequest(http.MethodPost, "/test", bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.JSONEq(t, tc.wantBody, resp.Body.String())
+			assert.Equal(t, tc.wantErrors, errs)
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	cause := errors.New("db err")
+	err := error(errBizTest.Wrap(cause))
+	assert.True(t, errors.Is(err, errBizTest))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, ErrInternal))
+
+	e, ok := Lookup(999001)
+	assert.True(t, ok)
+	assert.Equal(t, errBizTest, e)
+
+	assert.Panics(t, func() {
+		Register(999001, http.StatusOK, "test.dup", "重复")
+	})
+}
@@ -0,0 +1,30 @@
+package gormx
+
+import "gorm.io/gorm"
+
+// registerCallbacks 在所有操作的前后注册回调，op 为 create、query、update、delete、raw、row
+func registerCallbacks(db *gorm.DB, name string,
+	before func(op string) func(*gorm.DB), after func(op string) func(*gorm.DB)) error {
+	cb := db.Callback()
+	processors := []struct {
+		op     string
+		before func(name string, fn func(*gorm.DB)) error
+		after  func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("*").Register, cb.Create().After("*").Register},
+		{"query", cb.Query().Before("*").Register, cb.Query().After("*").Register},
+		{"update", cb.Update().Before("*").Register, cb.Update().After("*").Register},
+		{"delete", cb.Delete().Before("*").Register, cb.Delete().After("*").Register},
+		{"raw", cb.Raw().Before("*").Register, cb.Raw().After("*").Register},
+		{"row", cb.Row().Before("*").Register, cb.Row().After("*").Register},
+	}
+	for _, p := range processors {
+		if err := p.before(name+"_"+p.op+"_before", before(p.op)); err != nil {
+			return err
+		}
+		if err := p.after(name+"_"+p.op+"_after", after(p.op)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
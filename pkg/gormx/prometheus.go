@@ -0,0 +1,71 @@
+package gormx
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+	"time"
+)
+
+const startTimeKey = "gormx:start_time"
+
+// PrometheusPlugin 按表和操作统计 SQL 的执行时间
+// 使用：db.Use(gormx.NewPrometheusPlugin(...))
+type PrometheusPlugin struct {
+	vector *prometheus.HistogramVec
+	reg    prometheus.Registerer
+}
+
+func NewPrometheusPlugin(namespace, subsystem, name, help string) *PrometheusPlugin {
+	return &PrometheusPlugin{
+		vector: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      name,
+			Help:      help,
+			Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+		}, []string{"table", "op"}),
+		reg: prometheus.DefaultRegisterer,
+	}
+}
+
+// Registerer 默认注册到 prometheus.DefaultRegisterer
+func (p *PrometheusPlugin) Registerer(reg prometheus.Registerer) *PrometheusPlugin {
+	p.reg = reg
+	return p
+}
+
+func (p *PrometheusPlugin) Name() string {
+	return "prometheus"
+}
+
+func (p *PrometheusPlugin) Initialize(db *gorm.DB) error {
+	if err := p.reg.Register(p.vector); err != nil {
+		return err
+	}
+	return registerCallbacks(db, "prometheus", p.before, p.after)
+}
+
+func (p *PrometheusPlugin) before(op string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		db.InstanceSet(startTimeKey, time.Now())
+	}
+}
+
+func (p *PrometheusPlugin) after(op string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		val, ok := db.InstanceGet(startTimeKey)
+		if !ok {
+			return
+		}
+		start, ok := val.(time.Time)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			// 原生 SQL 拿不到表名
+			table = "unknown"
+		}
+		p.vector.WithLabelValues(table, op).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,64 @@
+package gormx
+
+import (
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "gormx:span"
+
+// TracingPlugin 给每次 SQL 创建一个 span，挂在调用方 ctx 里的 span 下面
+// 使用：db.Use(gormx.NewTracingPlugin())，调用方需要 db.WithContext(ctx)
+type TracingPlugin struct {
+	tracer trace.Tracer
+}
+
+func NewTracingPlugin() *TracingPlugin {
+	return &TracingPlugin{
+		tracer: otel.Tracer("webook/pkg/gormx"),
+	}
+}
+
+func (p *TracingPlugin) Name() string {
+	return "tracing"
+}
+
+func (p *TracingPlugin) Initialize(db *gorm.DB) error {
+	return registerCallbacks(db, "tracing", p.before, p.after)
+}
+
+func (p *TracingPlugin) before(op string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx, span := p.tracer.Start(db.Statement.Context, "gorm."+op,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemMySQL, semconv.DBOperationName(op)))
+		db.Statement.Context = ctx
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func (p *TracingPlugin) after(op string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		val, ok := db.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span, ok := val.(trace.Span)
+		if !ok {
+			return
+		}
+		defer span.End()
+		// SQL 里面是占位符，不会带上参数
+		span.SetAttributes(
+			semconv.DBCollectionName(db.Statement.Table),
+			semconv.DBQueryText(db.Statement.SQL.String()))
+		if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package httpx
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracingTransport(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: NewTracingTransport(http.DefaultTransport)}
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	for _, path := range []string{
+		"/sns/oauth2/access_token?appid=wx123&secret=app-secret&code=auth-code",
+		"/fail?access_token=user-token&refresh_token=refresh#frag",
+	} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		// 链路信息带给下游
+		assert.NotEmpty(t, traceparent)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	wantSpans := []struct {
+		url  string
+		code codes.Code
+	}{
+		{url: server.URL + "/sns/oauth2/access_token", code: codes.Unset},
+		{url: server.URL + "/fail", code: codes.Error},
+	}
+	for i, want := range wantSpans {
+		span := spans[i]
+		assert.Equal(t, "HTTP GET", span.Name())
+		assert.Equal(t, want.code, span.Status().Code)
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+		attrs := make(map[string]string)
+		for _, attr := range span.Attributes() {
+			val := attr.Value.Emit()
+			assert.NotContains(t, val, "secret=")
+			assert.NotContains(t, val, "access_token=")
+			assert.NotContains(t, val, "code=")
+			attrs[string(attr.Key)] = val
+		}
+		assert.Equal(t, want.url, attrs["url.full"])
+	}
+}
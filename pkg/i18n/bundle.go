@@ -0,0 +1,148 @@
+package i18n
+
+import (
+	"fmt"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Bundle 所有语言的文案
+// 文案文件按语言命名，比如 zh-CN.yaml、en-US.yaml，嵌套的 key 用 . 拼起来：
+//
+//	user:
+//	  invalid_email: 无效邮箱
+//
+// 对应的 key 是 user.invalid_email
+type Bundle struct {
+	def      language.Tag
+	tags     []language.Tag
+	matcher  language.Matcher
+	messages map[language.Tag]map[string]string
+}
+
+// NewBundle def 是默认语言，找不到合适的语言或者文案的时候使用
+func NewBundle(def language.Tag) *Bundle {
+	b := &Bundle{
+		def:      def,
+		messages: map[language.Tag]map[string]string{},
+	}
+	b.addTag(def)
+	return b
+}
+
+// LoadFS 加载目录下所有的 yaml 文案文件
+func (b *Bundle) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := path.Ext(name)
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		tag, err := language.Parse(strings.TrimSuffix(name, ext))
+		if err != nil {
+			return fmt.Errorf("i18n: 文案文件 %s 的语言不对: %w", name, err)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		var raw map[string]any
+		if err = yaml.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("i18n: 解析文案文件 %s 失败: %w", name, err)
+		}
+		msgs := map[string]string{}
+		flatten("", raw, msgs)
+		b.Add(tag, msgs)
+	}
+	return nil
+}
+
+// Add 添加文案，已有的 key 会被覆盖
+func (b *Bundle) Add(tag language.Tag, msgs map[string]string) {
+	b.addTag(tag)
+	dst := b.messages[tag]
+	for k, v := range msgs {
+		dst[k] = v
+	}
+}
+
+func (b *Bundle) addTag(tag language.Tag) {
+	if _, ok := b.messages[tag]; ok {
+		return
+	}
+	b.messages[tag] = map[string]string{}
+	b.tags = append(b.tags, tag)
+	// 第一个是默认语言，匹配不上的时候返回它
+	b.matcher = language.NewMatcher(b.tags)
+}
+
+// Supported 是否有这个语言的文案，用来校验用户设置的语言
+func (b *Bundle) Supported(locale string) bool {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return false
+	}
+	_, ok := b.messages[tag]
+	return ok
+}
+
+// Match 按顺序匹配最合适的语言，prefs 可以是语言（en-US），也可以是 Accept-Language 的值
+// 都匹配不上返回默认语言
+func (b *Bundle) Match(prefs ...string) language.Tag {
+	for _, pref := range prefs {
+		if tag, ok := b.match(pref); ok {
+			return tag
+		}
+	}
+	return b.def
+}
+
+func (b *Bundle) match(pref string) (language.Tag, bool) {
+	if pref == "" {
+		return language.Tag{}, false
+	}
+	tags, _, err := language.ParseAcceptLanguage(pref)
+	if err != nil || len(tags) == 0 {
+		return language.Tag{}, false
+	}
+	_, idx, conf := b.matcher.Match(tags...)
+	if conf == language.No {
+		return language.Tag{}, false
+	}
+	return b.tags[idx], true
+}
+
+// Localizer 返回某个语言的 Localizer
+func (b *Bundle) Localizer(tag language.Tag) *Localizer {
+	return &Localizer{bundle: b, tag: tag}
+}
+
+func (b *Bundle) lookup(tag language.Tag, key string) (string, bool) {
+	if msg, ok := b.messages[tag][key]; ok {
+		return msg, true
+	}
+	msg, ok := b.messages[b.def][key]
+	return msg, ok
+}
+
+func flatten(prefix string, raw map[string]any, dst map[string]string) {
+	for k, v := range raw {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch val := v.(type) {
+		case map[string]any:
+			flatten(key, val, dst)
+		default:
+			dst[key] = fmt.Sprint(val)
+		}
+	}
+}
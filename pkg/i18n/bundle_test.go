@@ -0,0 +1,65 @@
+package i18n
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestBundle(t *testing.T) *Bundle {
+	fsys := fstest.MapFS{
+		"locales/zh-CN.yaml": {Data: []byte("user:\n  invalid_email: 无效邮箱\n  hello: 你好 %s\n")},
+		"locales/en-US.yaml": {Data: []byte("user:\n  invalid_email: Invalid email\n")},
+		"locales/README.md":  {Data: []byte("不是文案文件")},
+	}
+	b := NewBundle(language.MustParse("zh-CN"))
+	require.NoError(t, b.LoadFS(fsys, "locales"))
+	return b
+}
+
+func TestBundle_Match(t *testing.T) {
+	b := newTestBundle(t)
+	testCases := []struct {
+		name  string
+		prefs []string
+		want  string
+	}{
+		{name: "没有偏好", want: "zh-CN"},
+		{name: "精确匹配", prefs: []string{"en-US"}, want: "en-US"},
+		{name: "Accept-Language", prefs: []string{"fr-FR,en;q=0.8,zh;q=0.5"}, want: "en-US"},
+		{name: "不支持的语言", prefs: []string{"fr-FR"}, want: "zh-CN"},
+		{name: "按顺序匹配", prefs: []string{"", "fr", "en-GB"}, want: "en-US"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, b.Match(tc.prefs...).String())
+		})
+	}
+	assert.True(t, b.Supported("en-US"))
+	assert.False(t, b.Supported("fr-FR"))
+}
+
+func TestLocalizer(t *testing.T) {
+	b := newTestBundle(t)
+	en := b.Localizer(language.MustParse("en-US"))
+	assert.Equal(t, "Invalid email", en.T("user.invalid_email"))
+	// en-US 没有的文案回退到默认语言
+	assert.Equal(t, "你好 tom", en.T("user.hello", "tom"))
+	assert.Equal(t, "user.unknown", en.T("user.unknown"))
+
+	ctx := context.Background()
+	assert.Equal(t, "默认", Message(ctx, "user.invalid_email", "默认"))
+	assert.Equal(t, "", Locale(ctx))
+
+	ctx = WithLocalizer(ctx, b.Localizer(language.MustParse("zh-CN")))
+	assert.Equal(t, "无效邮箱", Message(ctx, "user.invalid_email", "默认"))
+	assert.Equal(t, "默认", Message(ctx, "user.unknown", "默认"))
+
+	// 用户偏好覆盖 Accept-Language，不支持的偏好忽略
+	assert.Equal(t, "en-US", Locale(Prefer(ctx, "en-US")))
+	assert.Equal(t, "zh-CN", Locale(Prefer(ctx, "fr-FR")))
+	assert.Equal(t, "zh-CN", Locale(Prefer(ctx, "")))
+}
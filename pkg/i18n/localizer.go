@@ -0,0 +1,78 @@
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/text/language"
+)
+
+// Localizer 某个语言的文案
+type Localizer struct {
+	bundle *Bundle
+	tag    language.Tag
+}
+
+// Locale 语言，比如 zh-CN
+func (l *Localizer) Locale() string {
+	return l.tag.String()
+}
+
+// T 翻译 key，args 用于 fmt.Sprintf 格式化
+// 当前语言没有的 key 用默认语言，都没有就返回 key 本身
+func (l *Localizer) T(key string, args ...any) string {
+	msg, ok := l.bundle.lookup(l.tag, key)
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+type localizerKey struct{}
+
+// WithLocalizer 把 Localizer 放进 ctx，一般由 locale 中间件调用
+func WithLocalizer(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, localizerKey{}, l)
+}
+
+// FromContext 取出 ctx 里面的 Localizer，没有返回 nil
+func FromContext(ctx context.Context) *Localizer {
+	l, _ := ctx.Value(localizerKey{}).(*Localizer)
+	return l
+}
+
+// Prefer 用户明确设置了语言的时候调用，比如个人资料里的语言偏好
+// 会覆盖从 Accept-Language 里面解析出来的语言；ctx 里面没有 Localizer 或者不支持这个语言的时候原样返回
+func Prefer(ctx context.Context, locale string) context.Context {
+	l := FromContext(ctx)
+	if l == nil {
+		return ctx
+	}
+	tag, ok := l.bundle.match(locale)
+	if !ok || tag == l.tag {
+		return ctx
+	}
+	return WithLocalizer(ctx, l.bundle.Localizer(tag))
+}
+
+// Locale 返回 ctx 对应的语言，没有 Localizer 的时候返回空字符串
+func Locale(ctx context.Context) string {
+	if l := FromContext(ctx); l != nil {
+		return l.Locale()
+	}
+	return ""
+}
+
+// Message 翻译 key，ctx 里面没有 Localizer 或者找不到 key 的时候返回 fallback
+func Message(ctx context.Context, key, fallback string) string {
+	l := FromContext(ctx)
+	if l == nil {
+		return fallback
+	}
+	if msg, ok := l.bundle.lookup(l.tag, key); ok {
+		return msg
+	}
+	return fallback
+}
@@ -0,0 +1,63 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"webook/pkg/redisx"
+)
+
+var ErrLimiterUnavailable = errors.New("限流器不可用")
+
+// FailoverLimiter 主限流器（一般是 Redis）不可用的时候切换到 fallback，见 redisx.IsUnavailable
+// fallback 为 nil 的时候直接返回 ErrLimiterUnavailable，由调用方决定放行还是拒绝
+// 不可用期间不再访问主限流器，避免每个请求都要等 Redis 超时
+type FailoverLimiter struct {
+	primary  Limiter
+	fallback Limiter
+	health   HealthChecker
+}
+
+func NewFailoverLimiter(primary Limiter, fallback Limiter, health HealthChecker) *FailoverLimiter {
+	return &FailoverLimiter{
+		primary:  primary,
+		fallback: fallback,
+		health:   health,
+	}
+}
+
+func (f *FailoverLimiter) Limit(ctx context.Context, key string) (Result, error) {
+	return f.call(ctx, func(l Limiter) (Result, error) {
+		return l.Limit(ctx, key)
+	})
+}
+
+func (f *FailoverLimiter) Peek(ctx context.Context, key string) (Result, error) {
+	return f.call(ctx, func(l Limiter) (Result, error) {
+		return l.Peek(ctx, key)
+	})
+}
+
+func (f *FailoverLimiter) call(ctx context.Context, fn func(l Limiter) (Result, error)) (Result, error) {
+	if f.health.Healthy() {
+		res, err := fn(f.primary)
+		if !redisx.IsUnavailable(err) {
+			// 脚本出错、客户端自己断开之类的跟 Redis 是否可用没有关系，不切换
+			return res, err
+		}
+		f.health.MarkFailed()
+	}
+	if f.fallback == nil {
+		return Result{}, ErrLimiterUnavailable
+	}
+	return fn(f.fallback)
+}
+
+// Reset 两边都清掉，降级期间用的是 fallback 的计数
+func (f *FailoverLimiter) Reset(ctx context.Context, key string) error {
+	if f.fallback != nil {
+		if err := f.fallback.Reset(ctx, key); err != nil {
+			return err
+		}
+	}
+	return f.primary.Reset(ctx, key)
+}
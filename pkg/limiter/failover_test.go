@@ -0,0 +1,90 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// 用 miniredis 模拟 Redis 宕机和恢复
+func TestFailoverLimiter_Limit(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{
+		Addr:        mr.Addr(),
+		MaxRetries:  -1,
+		DialTimeout: time.Millisecond * 100,
+	})
+	health := NewRedisHealthChecker(rdb, time.Millisecond*20)
+	ctx := context.Background()
+
+	testCases := []struct {
+		name     string
+		fallback Limiter
+		// 宕机期间的预期
+		wantErr   error
+		wantLimit int
+	}{
+		{
+			name:      "降级到本地限流",
+			fallback:  NewLocalTokenBucketLimiter(time.Second, 1),
+			wantLimit: 1,
+		},
+		{
+			name:    "没有降级限流器",
+			wantErr: ErrLimiterUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewFailoverLimiter(NewRedisFixedWindowLimiter(rdb, time.Second, 10), tc.fallback, health)
+
+			// Redis 正常
+			res, err := l.Limit(ctx, tc.name)
+			require.NoError(t, err)
+			assert.Equal(t, 10, res.Limit)
+
+			// Redis 宕机，第一次请求出错之后切换
+			mr.Close()
+			res, err = l.Limit(ctx, tc.name)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantLimit, res.Limit)
+			assert.False(t, health.Healthy())
+			// 不可用期间直接走降级逻辑
+			res, err = l.Limit(ctx, tc.name)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantLimit, res.Limit)
+
+			// Redis 恢复之后自动切回去
+			require.NoError(t, mr.Restart())
+			assert.Eventually(t, health.Healthy, time.Second, time.Millisecond*10)
+			res, err = l.Limit(ctx, tc.name)
+			require.NoError(t, err)
+			assert.Equal(t, 10, res.Limit)
+		})
+	}
+}
+
+// 命令本身出错的时候 Redis 还能用，不切换
+func TestFailoverLimiter_Limit_CommandError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	health := NewRedisHealthChecker(rdb, time.Millisecond*20)
+	ctx := context.Background()
+	l := NewFailoverLimiter(NewRedisTokenBucketLimiter(rdb, time.Second, 10),
+		NewLocalTokenBucketLimiter(time.Second, 1), health)
+
+	// 类型不对，令牌桶脚本执行 HMGET 会报 WRONGTYPE
+	require.NoError(t, mr.Set("wrong-type", "1"))
+	_, err := l.Limit(ctx, "wrong-type")
+	assert.ErrorContains(t, err, "WRONGTYPE")
+	assert.True(t, health.Healthy())
+
+	res, err := l.Limit(ctx, "ok")
+	require.NoError(t, err)
+	assert.Equal(t, 10, res.Limit)
+}
@@ -0,0 +1,34 @@
+-- 固定窗口计数器
+-- 每个窗口只占用一个 key，内存开销远小于滑动窗口
+
+-- 限流对象
+local key = KEYS[1]
+-- 窗口大小
+local window = tonumber(ARGV[1])
+-- 阈值
+local threshold = tonumber(ARGV[2])
+-- 为 1 的时候只查询，不记录这次请求
+local peek = ARGV[3] == '1'
+
+local cnt
+if peek then
+    -- 算上这一次
+    cnt = tonumber(redis.call('GET', key) or 0) + 1
+else
+    cnt = redis.call('INCR', key)
+    if cnt == 1 then
+        -- 窗口的第一个请求，设置窗口的过期时间
+        redis.call('PEXPIRE', key, window)
+    end
+end
+-- 当前窗口剩余的时间
+local ttl = redis.call('PTTL', key)
+if ttl < 0 then
+    ttl = window
+end
+if cnt > threshold then
+    -- 执行限流，等下一个窗口
+    return {1, 0, ttl, ttl}
+else
+    return {0, threshold - cnt, ttl, 0}
+end
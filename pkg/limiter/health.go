@@ -0,0 +1,57 @@
+package limiter
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"sync/atomic"
+	"time"
+)
+
+// HealthChecker 判断限流依赖的存储是否可用
+type HealthChecker interface {
+	Healthy() bool
+	// MarkFailed 请求出错时调用
+	MarkFailed()
+}
+
+// RedisHealthChecker 出错之后标记为不可用，并且在后台定时 PING
+// 直到 Redis 恢复。正常情况下不会产生额外的请求
+type RedisHealthChecker struct {
+	cmd      redis.Cmdable
+	interval time.Duration
+	healthy  atomic.Bool
+}
+
+func NewRedisHealthChecker(cmd redis.Cmdable, interval time.Duration) *RedisHealthChecker {
+	h := &RedisHealthChecker{
+		cmd:      cmd,
+		interval: interval,
+	}
+	h.healthy.Store(true)
+	return h
+}
+
+func (h *RedisHealthChecker) Healthy() bool {
+	return h.healthy.Load()
+}
+
+func (h *RedisHealthChecker) MarkFailed() {
+	// 只有第一个把状态改掉的 goroutine 负责探测
+	if h.healthy.CompareAndSwap(true, false) {
+		go h.probe()
+	}
+}
+
+func (h *RedisHealthChecker) probe() {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), h.interval)
+		err := h.cmd.Ping(ctx).Err()
+		cancel()
+		if err == nil {
+			h.healthy.Store(true)
+			return
+		}
+	}
+}
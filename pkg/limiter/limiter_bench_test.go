@@ -0,0 +1,46 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+)
+
+// 对比几种实现的吞吐，Redis 版本需要本地启动 Redis
+// go test -bench=. -benchmem ./pkg/limiter
+func BenchmarkLimiter(b *testing.B) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	benchmarks := []struct {
+		name    string
+		redis   bool
+		limiter Limiter
+	}{
+		{name: "sliding_window", redis: true, limiter: NewRedisSlidingWindowLimiter(rdb, time.Second, 1000)},
+		{name: "fixed_window", redis: true, limiter: NewRedisFixedWindowLimiter(rdb, time.Second, 1000)},
+		{name: "token_bucket", redis: true, limiter: NewRedisTokenBucketLimiter(rdb, time.Second, 1000)},
+		{name: "local_token_bucket", limiter: NewLocalTokenBucketLimiter(time.Second, 1000)},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			if bm.redis {
+				if err := rdb.Ping(context.Background()).Err(); err != nil {
+					b.Skip("Redis 不可用", err)
+				}
+			}
+			key := fmt.Sprintf("bench:limiter:%s", bm.name)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				for pb.Next() {
+					if _, err := bm.limiter.Limit(ctx, key); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
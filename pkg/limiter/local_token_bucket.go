@@ -0,0 +1,107 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LocalTokenBucketLimiter 单机内存版令牌桶
+// 用于单实例部署，或者 Redis 不可用时的降级
+type LocalTokenBucketLimiter struct {
+	interval time.Duration
+	// 桶容量
+	rate int
+
+	mu        sync.Mutex
+	buckets   map[string]*localBucket
+	lastSweep time.Time
+}
+
+type localBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewLocalTokenBucketLimiter(interval time.Duration, rate int) *LocalTokenBucketLimiter {
+	return &LocalTokenBucketLimiter{
+		interval:  interval,
+		rate:      rate,
+		buckets:   make(map[string]*localBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *LocalTokenBucketLimiter) Limit(ctx context.Context, key string) (Result, error) {
+	return l.take(key, false), nil
+}
+
+func (l *LocalTokenBucketLimiter) Peek(ctx context.Context, key string) (Result, error) {
+	return l.take(key, true), nil
+}
+
+// take peek 的时候只计算，不修改桶
+func (l *LocalTokenBucketLimiter) take(key string, peek bool) Result {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &localBucket{tokens: float64(l.rate), last: now}
+		if !peek {
+			l.buckets[key] = b
+		}
+	}
+	tokens := l.refill(b, now)
+	res := Result{Limit: l.rate}
+	if tokens < 1 {
+		res.Limited = true
+		res.RetryAfter = l.durationFor(1 - tokens)
+	} else {
+		tokens--
+	}
+	if !peek {
+		b.tokens = tokens
+		b.last = now
+	}
+	res.Remaining = int(tokens)
+	res.ResetAfter = l.durationFor(float64(l.rate) - tokens)
+	return res
+}
+
+func (l *LocalTokenBucketLimiter) Reset(ctx context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.buckets, key)
+	return nil
+}
+
+// durationFor 攒够 tokens 个令牌需要的时间
+func (l *LocalTokenBucketLimiter) durationFor(tokens float64) time.Duration {
+	return time.Duration(tokens * float64(l.interval) / float64(l.rate))
+}
+
+func (l *LocalTokenBucketLimiter) refill(b *localBucket, now time.Time) float64 {
+	elapsed := now.Sub(b.last)
+	tokens := b.tokens + float64(l.rate)*float64(elapsed)/float64(l.interval)
+	if tokens > float64(l.rate) {
+		return float64(l.rate)
+	}
+	return tokens
+}
+
+// sweep 每个 interval 清理一次已经放满的桶，避免 key 越来越多
+// 桶满了和桶不存在是等价的
+func (l *LocalTokenBucketLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.interval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.interval {
+			delete(l.buckets, key)
+		}
+	}
+}
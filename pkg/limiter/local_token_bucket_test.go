@@ -0,0 +1,60 @@
+package limiter
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLocalTokenBucketLimiter_Limit(t *testing.T) {
+	ctx := context.Background()
+	l := NewLocalTokenBucketLimiter(time.Millisecond*100, 3)
+
+	// 桶容量是 3，前三次放行
+	for i := 0; i < 3; i++ {
+		res, err := l.Limit(ctx, "ip:127.0.0.1")
+		assert.NoError(t, err)
+		assert.False(t, res.Limited)
+		assert.Equal(t, 3, res.Limit)
+		assert.Equal(t, 2-i, res.Remaining)
+	}
+	res, err := l.Limit(ctx, "ip:127.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, res.Limited)
+	assert.Equal(t, 0, res.Remaining)
+	assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= time.Millisecond*100/3)
+	assert.True(t, res.ResetAfter > time.Millisecond*90 && res.ResetAfter <= time.Millisecond*100)
+
+	// 不同的 key 互不影响
+	res, err = l.Limit(ctx, "ip:127.0.0.2")
+	assert.NoError(t, err)
+	assert.False(t, res.Limited)
+
+	// 等一个 interval，桶放满
+	time.Sleep(time.Millisecond * 100)
+	for i := 0; i < 3; i++ {
+		res, err = l.Limit(ctx, "ip:127.0.0.1")
+		assert.NoError(t, err)
+		assert.False(t, res.Limited)
+	}
+	// 放满的桶在下一次访问时被清理
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestLocalTokenBucketLimiter_Reset(t *testing.T) {
+	ctx := context.Background()
+	l := NewLocalTokenBucketLimiter(time.Hour, 1)
+	res, err := l.Limit(ctx, "phone:138")
+	assert.NoError(t, err)
+	assert.False(t, res.Limited)
+	res, err = l.Limit(ctx, "phone:138")
+	assert.NoError(t, err)
+	assert.True(t, res.Limited)
+
+	// 重置之后桶是满的
+	assert.NoError(t, l.Reset(ctx, "phone:138"))
+	res, err = l.Limit(ctx, "phone:138")
+	assert.NoError(t, err)
+	assert.False(t, res.Limited)
+}
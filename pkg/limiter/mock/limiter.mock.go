@@ -12,6 +12,7 @@ package limiter_mocksvc
 import (
 	context "context"
 	reflect "reflect"
+	limiter "webook/pkg/limiter"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -40,10 +41,10 @@ func (m *MockLimiter) EXPECT() *MockLimiterMockRecorder {
 }
 
 // Limit mocks base method.
-func (m *MockLimiter) Limit(ctx context.Context, key string) (bool, error) {
+func (m *MockLimiter) Limit(ctx context.Context, key string) (limiter.Result, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Limit", ctx, key)
-	ret0, _ := ret[0].(bool)
+	ret0, _ := ret[0].(limiter.Result)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -53,3 +54,32 @@ func (mr *MockLimiterMockRecorder) Limit(ctx, key any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Limit", reflect.TypeOf((*MockLimiter)(nil).Limit), ctx, key)
 }
+
+// Peek mocks base method.
+func (m *MockLimiter) Peek(ctx context.Context, key string) (limiter.Result, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Peek", ctx, key)
+	ret0, _ := ret[0].(limiter.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Peek indicates an expected call of Peek.
+func (mr *MockLimiterMockRecorder) Peek(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Peek", reflect.TypeOf((*MockLimiter)(nil).Peek), ctx, key)
+}
+
+// Reset mocks base method.
+func (m *MockLimiter) Reset(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockLimiterMockRecorder) Reset(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockLimiter)(nil).Reset), ctx, key)
+}
@@ -0,0 +1,49 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed fixed_window.lua
+var luaFixedWindow string
+
+// RedisFixedWindowLimiter 固定窗口计数器，每个限流对象只有一个计数
+// 缺点是窗口边界上可能放过两倍的请求
+type RedisFixedWindowLimiter struct {
+	interval time.Duration
+	// 阈值
+	rate int
+	cmd  redis.Cmdable
+}
+
+func NewRedisFixedWindowLimiter(cmd redis.Cmdable, interval time.Duration, rate int) *RedisFixedWindowLimiter {
+	return &RedisFixedWindowLimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+	}
+}
+
+func (b *RedisFixedWindowLimiter) Limit(ctx context.Context, key string) (Result, error) {
+	return b.eval(ctx, key, false)
+}
+
+func (b *RedisFixedWindowLimiter) Peek(ctx context.Context, key string) (Result, error) {
+	return b.eval(ctx, key, true)
+}
+
+func (b *RedisFixedWindowLimiter) eval(ctx context.Context, key string, peek bool) (Result, error) {
+	val, err := b.cmd.Eval(ctx, luaFixedWindow, []string{key},
+		b.interval.Milliseconds(), b.rate, peekArg(peek)).Result()
+	if err != nil {
+		return Result{}, err
+	}
+	return parseResult(val, b.rate)
+}
+
+func (b *RedisFixedWindowLimiter) Reset(ctx context.Context, key string) error {
+	return b.cmd.Del(ctx, key).Err()
+}
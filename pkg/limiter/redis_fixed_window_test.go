@@ -0,0 +1,78 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRedisFixedWindowLimiter_Limit(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	// 每秒 2 次
+	l := NewRedisFixedWindowLimiter(rdb, time.Second, 2)
+
+	type step struct {
+		// wait 请求之前让时间过去多久
+		wait time.Duration
+		peek bool
+		want Result
+	}
+	testCases := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "超过阈值之后限流",
+			steps: []step{
+				{want: Result{Limit: 2, Remaining: 1, ResetAfter: time.Second}},
+				{wait: time.Millisecond * 400, want: Result{Limit: 2, Remaining: 0,
+					ResetAfter: time.Millisecond * 600}},
+				{want: Result{Limited: true, Limit: 2, Remaining: 0,
+					ResetAfter: time.Millisecond * 600, RetryAfter: time.Millisecond * 600}},
+			},
+		},
+		{
+			name: "窗口过了重新计数",
+			steps: []step{
+				{want: Result{Limit: 2, Remaining: 1, ResetAfter: time.Second}},
+				{want: Result{Limit: 2, Remaining: 0, ResetAfter: time.Second}},
+				{want: Result{Limited: true, Limit: 2, Remaining: 0,
+					ResetAfter: time.Second, RetryAfter: time.Second}},
+				{wait: time.Second, want: Result{Limit: 2, Remaining: 1, ResetAfter: time.Second}},
+			},
+		},
+		{
+			name: "peek 不计数",
+			steps: []step{
+				{peek: true, want: Result{Limit: 2, Remaining: 1, ResetAfter: time.Second}},
+				{peek: true, want: Result{Limit: 2, Remaining: 1, ResetAfter: time.Second}},
+				{want: Result{Limit: 2, Remaining: 1, ResetAfter: time.Second}},
+				{want: Result{Limit: 2, Remaining: 0, ResetAfter: time.Second}},
+				{peek: true, want: Result{Limited: true, Limit: 2, Remaining: 0,
+					ResetAfter: time.Second, RetryAfter: time.Second}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			for i, s := range tc.steps {
+				mr.FastForward(s.wait)
+				var res Result
+				var err error
+				if s.peek {
+					res, err = l.Peek(ctx, tc.name)
+				} else {
+					res, err = l.Limit(ctx, tc.name)
+				}
+				require.NoError(t, err)
+				assert.Equal(t, s.want, res, "第 %d 步", i+1)
+			}
+		})
+	}
+}
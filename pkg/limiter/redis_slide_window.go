@@ -25,7 +25,23 @@ func NewRedisSlidingWindowLimiter(cmd redis.Cmdable, interval time.Duration, rat
 	}
 }
 
-func (b RedisSlidingWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
-	return b.cmd.Eval(ctx, luaScript, []string{key},
-		b.interval.Milliseconds(), b.rate, time.Now().UnixMilli()).Bool()
+func (b RedisSlidingWindowLimiter) Limit(ctx context.Context, key string) (Result, error) {
+	return b.eval(ctx, key, false)
+}
+
+func (b RedisSlidingWindowLimiter) Peek(ctx context.Context, key string) (Result, error) {
+	return b.eval(ctx, key, true)
+}
+
+func (b RedisSlidingWindowLimiter) eval(ctx context.Context, key string, peek bool) (Result, error) {
+	val, err := b.cmd.Eval(ctx, luaScript, []string{key},
+		b.interval.Milliseconds(), b.rate, time.Now().UnixMilli(), peekArg(peek)).Result()
+	if err != nil {
+		return Result{}, err
+	}
+	return parseResult(val, b.rate)
+}
+
+func (b RedisSlidingWindowLimiter) Reset(ctx context.Context, key string) error {
+	return b.cmd.Del(ctx, key).Err()
 }
@@ -0,0 +1,50 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed token_bucket.lua
+var luaTokenBucket string
+
+// RedisTokenBucketLimiter 令牌桶，桶容量为 rate，每 interval 放满一次
+// 允许突发流量，每个限流对象只占用一个 hash
+type RedisTokenBucketLimiter struct {
+	interval time.Duration
+	// 桶容量
+	rate int
+	cmd  redis.Cmdable
+}
+
+func NewRedisTokenBucketLimiter(cmd redis.Cmdable, interval time.Duration, rate int) *RedisTokenBucketLimiter {
+	return &RedisTokenBucketLimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+	}
+}
+
+func (b *RedisTokenBucketLimiter) Limit(ctx context.Context, key string) (Result, error) {
+	return b.eval(ctx, key, false)
+}
+
+func (b *RedisTokenBucketLimiter) Peek(ctx context.Context, key string) (Result, error) {
+	return b.eval(ctx, key, true)
+}
+
+func (b *RedisTokenBucketLimiter) eval(ctx context.Context, key string, peek bool) (Result, error) {
+	ms := b.interval.Milliseconds()
+	val, err := b.cmd.Eval(ctx, luaTokenBucket, []string{key},
+		b.rate, float64(b.rate)/float64(ms), time.Now().UnixMilli(), ms, peekArg(peek)).Result()
+	if err != nil {
+		return Result{}, err
+	}
+	return parseResult(val, b.rate)
+}
+
+func (b *RedisTokenBucketLimiter) Reset(ctx context.Context, key string) error {
+	return b.cmd.Del(ctx, key).Err()
+}
@@ -0,0 +1,84 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// 直接执行脚本，用参数控制当前时间
+func TestTokenBucketLua(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	// 容量是 2，每 1024 毫秒放一个令牌，选 2 的幂避免浮点误差
+	const capacity = 2
+	const rate = 1.0 / 1024
+	const ttl = 2048
+
+	type step struct {
+		// now 当前时间，毫秒
+		now  int64
+		peek bool
+		want Result
+	}
+	testCases := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "拿完令牌之后限流",
+			steps: []step{
+				{now: 0, want: Result{Limit: 2, Remaining: 1, ResetAfter: time.Millisecond * 1024}},
+				{now: 0, want: Result{Limit: 2, Remaining: 0, ResetAfter: time.Millisecond * 2048}},
+				{now: 0, want: Result{Limited: true, Limit: 2, Remaining: 0,
+					ResetAfter: time.Millisecond * 2048, RetryAfter: time.Millisecond * 1024}},
+			},
+		},
+		{
+			name: "按时间补充令牌",
+			steps: []step{
+				{now: 0, want: Result{Limit: 2, Remaining: 1, ResetAfter: time.Millisecond * 1024}},
+				{now: 0, want: Result{Limit: 2, Remaining: 0, ResetAfter: time.Millisecond * 2048}},
+				// 补了半个令牌，还不够
+				{now: 512, want: Result{Limited: true, Limit: 2, Remaining: 0,
+					ResetAfter: time.Millisecond * 1536, RetryAfter: time.Millisecond * 512}},
+				// 又补了半个令牌，限流的时候补的半个没有丢
+				{now: 1024, want: Result{Limit: 2, Remaining: 0, ResetAfter: time.Millisecond * 2048}},
+			},
+		},
+		{
+			name: "补充的令牌不超过容量",
+			steps: []step{
+				{now: 0, want: Result{Limit: 2, Remaining: 1, ResetAfter: time.Millisecond * 1024}},
+				{now: 100000, want: Result{Limit: 2, Remaining: 1, ResetAfter: time.Millisecond * 1024}},
+			},
+		},
+		{
+			name: "peek 不拿走令牌",
+			steps: []step{
+				{now: 0, peek: true, want: Result{Limit: 2, Remaining: 1, ResetAfter: time.Millisecond * 1024}},
+				{now: 0, peek: true, want: Result{Limit: 2, Remaining: 1, ResetAfter: time.Millisecond * 1024}},
+				{now: 0, want: Result{Limit: 2, Remaining: 1, ResetAfter: time.Millisecond * 1024}},
+				{now: 0, want: Result{Limit: 2, Remaining: 0, ResetAfter: time.Millisecond * 2048}},
+				{now: 0, peek: true, want: Result{Limited: true, Limit: 2, Remaining: 0,
+					ResetAfter: time.Millisecond * 2048, RetryAfter: time.Millisecond * 1024}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i, s := range tc.steps {
+				val, err := rdb.Eval(context.Background(), luaTokenBucket, []string{tc.name},
+					capacity, rate, s.now, ttl, peekArg(s.peek)).Result()
+				require.NoError(t, err)
+				res, err := parseResult(val, capacity)
+				require.NoError(t, err)
+				assert.Equal(t, s.want, res, "第 %d 步", i+1)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package limiter
+
+import (
+	"fmt"
+	"time"
+)
+
+// peekArg 传给 lua 脚本的参数，1 表示只查询
+func peekArg(peek bool) int {
+	if peek {
+		return 1
+	}
+	return 0
+}
+
+// parseResult 解析 lua 脚本的返回值
+// 约定脚本返回 {是否限流(0/1), 剩余配额, 恢复毫秒数, 重试毫秒数}
+func parseResult(val any, limit int) (Result, error) {
+	arr, ok := val.([]any)
+	if !ok || len(arr) != 4 {
+		return Result{}, fmt.Errorf("限流脚本返回值格式不对 %v", val)
+	}
+	nums := make([]int64, len(arr))
+	for i, v := range arr {
+		n, ok := v.(int64)
+		if !ok {
+			return Result{}, fmt.Errorf("限流脚本返回值格式不对 %v", val)
+		}
+		nums[i] = n
+	}
+	return Result{
+		Limited:    nums[0] == 1,
+		Limit:      limit,
+		Remaining:  int(nums[1]),
+		ResetAfter: time.Duration(nums[2]) * time.Millisecond,
+		RetryAfter: time.Duration(nums[3]) * time.Millisecond,
+	}, nil
+}
@@ -9,6 +9,8 @@ local window = tonumber(ARGV[1])
 -- 阈值
 local threshold = tonumber( ARGV[2])
 local now = tonumber(ARGV[3])
+-- 为 1 的时候只查询，不记录这次请求
+local peek = ARGV[4] == '1'
 -- 窗口的起始时间
 local min = now - window
 
@@ -17,10 +19,23 @@ local cnt = redis.call('ZCOUNT', key, '-inf', '+inf')
 -- local cnt = redis.call('ZCOUNT', key, min, '+inf')
 if cnt >= threshold then
     -- 执行限流
-    return "true"
+    -- 最早的请求滑出窗口之后才能重试，最晚的请求滑出窗口之后配额完全恢复
+    local retry = window
+    local reset = window
+    local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+    if oldest[2] ~= nil then
+        retry = tonumber(oldest[2]) + window - now
+    end
+    local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+    if newest[2] ~= nil then
+        reset = tonumber(newest[2]) + window - now
+    end
+    return {1, 0, reset, retry}
 else
-    -- 把 score 和 member 都设置成 now
-    redis.call('ZADD', key, now, now)
-    redis.call('PEXPIRE', key, window)
-    return "false"
-end
\ No newline at end of file
+    if not peek then
+        -- 把 score 和 member 都设置成 now
+        redis.call('ZADD', key, now, now)
+        redis.call('PEXPIRE', key, window)
+    end
+    return {0, threshold - cnt - 1, window, 0}
+end
@@ -0,0 +1,45 @@
+-- 令牌桶
+-- 用一个 hash 记录剩余令牌数和上一次放令牌的时间
+
+-- 限流对象
+local key = KEYS[1]
+-- 桶容量
+local capacity = tonumber(ARGV[1])
+-- 每毫秒放入的令牌数
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+-- 桶放满所需的时间，用作过期时间
+local ttl = tonumber(ARGV[4])
+-- 为 1 的时候只查询，不拿走令牌
+local peek = ARGV[5] == '1'
+
+local bucket = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+    -- 第一次访问，桶是满的
+    tokens = capacity
+    ts = now
+end
+
+-- 补充这段时间内放入的令牌
+local elapsed = math.max(0, now - ts)
+tokens = math.min(capacity, tokens + elapsed * rate)
+
+local limited = 0
+local retry = 0
+if tokens < 1 then
+    -- 执行限流，等攒够一个令牌
+    limited = 1
+    retry = math.ceil((1 - tokens) / rate)
+else
+    tokens = tokens - 1
+end
+
+if not peek then
+    redis.call('HSET', key, 'tokens', tokens, 'ts', now)
+    redis.call('PEXPIRE', key, ttl)
+end
+-- 桶放满还需要的时间
+local reset = math.ceil((capacity - tokens) / rate)
+return {limited, math.floor(tokens), reset, retry}
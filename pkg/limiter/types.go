@@ -1,7 +1,28 @@
 package limiter
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Limiter interface {
-	Limit(ctx context.Context, key string) (bool, error)
+	Limit(ctx context.Context, key string) (Result, error)
+	// Peek 和 Limit 的结果一样，但是不消耗配额，比如先检查、真正用掉的时候再调用 Limit
+	Peek(ctx context.Context, key string) (Result, error)
+	// Reset 清空 key 的计数，比如客服帮用户解除限制
+	Reset(ctx context.Context, key string) error
+}
+
+// Result 一次限流判断的结果
+type Result struct {
+	// Limited 为 true 表示触发限流
+	Limited bool
+	// Limit 窗口内的阈值（令牌桶为桶容量）
+	Limit int
+	// Remaining 本次请求之后剩余的配额
+	Remaining int
+	// ResetAfter 配额完全恢复还需要的时间
+	ResetAfter time.Duration
+	// RetryAfter 触发限流时，多久之后可以重试
+	RetryAfter time.Duration
 }
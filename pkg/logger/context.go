@@ -0,0 +1,28 @@
+package logger
+
+import "context"
+
+type fieldsKey struct{}
+
+// WithFields 把请求相关的字段放进 ctx，之后用这个 ctx 打印的日志都会带上这些字段
+// 在 gin 里面要放进 ctx.Request.Context()，并且打开 gin.Engine.ContextWithFallback
+func WithFields(ctx context.Context, args ...Field) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+	fields := FieldsFromContext(ctx)
+	// 复制一份，避免多个 ctx 共用底层数组
+	merged := make([]Field, 0, len(fields)+len(args))
+	merged = append(merged, fields...)
+	merged = append(merged, args...)
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FieldsFromContext 取出 WithFields 放进去的字段
+func FieldsFromContext(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).([]Field)
+	return fields
+}
@@ -0,0 +1,27 @@
+package logger
+
+import "context"
+
+// NopLogger 什么也不做，测试或者不关心日志的地方使用
+type NopLogger struct {
+}
+
+func NewNopLogger() Logger {
+	return &NopLogger{}
+}
+
+func (n *NopLogger) Debug(ctx context.Context, msg string, args ...Field) {
+}
+
+func (n *NopLogger) Info(ctx context.Context, msg string, args ...Field) {
+}
+
+func (n *NopLogger) Warn(ctx context.Context, msg string, args ...Field) {
+}
+
+func (n *NopLogger) Error(ctx context.Context, msg string, args ...Field) {
+}
+
+func (n *NopLogger) With(args ...Field) Logger {
+	return n
+}
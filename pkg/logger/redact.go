@@ -0,0 +1,50 @@
+package logger
+
+import "strings"
+
+const mask = "******"
+
+// sensitiveKeys key 里面包含这些词的字段都要整个隐藏，不区分大小写
+// 比如 confirmPassword、refresh_token、session_key、recoveryCodes
+var sensitiveKeys = []string{
+	"password", "code", "captcha", "token", "secret", "ticket",
+	"session_key", "sessionkey", "credential", "cookie", "authorization",
+}
+
+// Redact 对敏感字段脱敏，日志实现在输出之前调用
+func Redact(f Field) Field {
+	fn := redactor(strings.ToLower(f.Key))
+	if fn == nil {
+		return f
+	}
+	val, ok := f.Val.(string)
+	if !ok {
+		// 不是字符串的敏感字段，比如恢复码的切片，整个隐藏
+		return Field{Key: f.Key, Val: mask}
+	}
+	return Field{Key: f.Key, Val: fn(val)}
+}
+
+func redactor(key string) func(val string) string {
+	if strings.Contains(key, "phone") {
+		return maskPhone
+	}
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return maskAll
+		}
+	}
+	return nil
+}
+
+// maskPhone 保留前三位和后四位，比如 152****5678
+func maskPhone(phone string) string {
+	if len(phone) < 8 {
+		return mask
+	}
+	return phone[:3] + "****" + phone[len(phone)-4:]
+}
+
+func maskAll(string) string {
+	return mask
+}
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"context"
+	"time"
+)
+
+// Logger 统一的日志接口，业务代码只依赖这个接口，不直接依赖具体的日志库
+// ctx 用来携带请求相关的字段（request id、user id），见 WithFields
+type Logger interface {
+	Debug(ctx context.Context, msg string, args ...Field)
+	Info(ctx context.Context, msg string, args ...Field)
+	Warn(ctx context.Context, msg string, args ...Field)
+	Error(ctx context.Context, msg string, args ...Field)
+	// With 返回一个固定带上 args 的 Logger
+	With(args ...Field) Logger
+}
+
+type Field struct {
+	Key string
+	Val any
+}
+
+func String(key, val string) Field {
+	return Field{Key: key, Val: val}
+}
+
+func Int(key string, val int) Field {
+	return Field{Key: key, Val: val}
+}
+
+func Int64(key string, val int64) Field {
+	return Field{Key: key, Val: val}
+}
+
+func Bool(key string, val bool) Field {
+	return Field{Key: key, Val: val}
+}
+
+func Duration(key string, val time.Duration) Field {
+	return Field{Key: key, Val: val}
+}
+
+func Error(err error) Field {
+	return Field{Key: "error", Val: err}
+}
+
+func Any(key string, val any) Field {
+	return Field{Key: key, Val: val}
+}
@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"context"
+	"go.uber.org/zap"
+)
+
+type ZapLogger struct {
+	l *zap.Logger
+}
+
+// NewZapLogger 基于 zap 的实现，日志级别由传入的 zap.Logger 控制
+func NewZapLogger(l *zap.Logger) Logger {
+	return &ZapLogger{
+		// 跳过 ZapLogger 自己这一层，caller 才是业务代码
+		l: l.WithOptions(zap.AddCallerSkip(1)),
+	}
+}
+
+func (z *ZapLogger) Debug(ctx context.Context, msg string, args ...Field) {
+	z.l.Debug(msg, toZapFields(FieldsFromContext(ctx), args)...)
+}
+
+func (z *ZapLogger) Info(ctx context.Context, msg string, args ...Field) {
+	z.l.Info(msg, toZapFields(FieldsFromContext(ctx), args)...)
+}
+
+func (z *ZapLogger) Warn(ctx context.Context, msg string, args ...Field) {
+	z.l.Warn(msg, toZapFields(FieldsFromContext(ctx), args)...)
+}
+
+func (z *ZapLogger) Error(ctx context.Context, msg string, args ...Field) {
+	z.l.Error(msg, toZapFields(FieldsFromContext(ctx), args)...)
+}
+
+func (z *ZapLogger) With(args ...Field) Logger {
+	return &ZapLogger{
+		l: z.l.With(toZapFields(args)...),
+	}
+}
+
+// toZapFields 依次转换请求相关的字段和调用方传入的字段，都经过脱敏
+func toZapFields(groups ...[]Field) []zap.Field {
+	n := 0
+	for _, g := range groups {
+		n += len(g)
+	}
+	res := make([]zap.Field, 0, n)
+	for _, g := range groups {
+		for _, f := range g {
+			f = Redact(f)
+			res = append(res, zap.Any(f.Key, f.Val))
+		}
+	}
+	return res
+}
@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"testing"
+)
+
+func TestZapLogger(t *testing.T) {
+	testCases := []struct {
+		name string
+		ctx  context.Context
+		log  func(ctx context.Context, l Logger)
+
+		wantFields map[string]any
+	}{
+		{
+			name: "带上请求相关的字段",
+			ctx: WithFields(context.Background(),
+				String("request_id", "req-1"), Int64("user_id", 123)),
+			log: func(ctx context.Context, l Logger) {
+				l.Error(ctx, "系统错误", Error(errors.New("db error")))
+			},
+			wantFields: map[string]any{
+				"request_id": "req-1",
+				"user_id":    int64(123),
+				"error":      "db error",
+			},
+		},
+		{
+			name: "手机号和验证码脱敏",
+			ctx:  context.Background(),
+			log: func(ctx context.Context, l Logger) {
+				l.Info(ctx, "发送验证码", String("phone", "15212345678"),
+					String("code", "123456"), Int("Password", 123))
+			},
+			wantFields: map[string]any{
+				"phone":    "152****5678",
+				"code":     "******",
+				"Password": "******",
+			},
+		},
+		{
+			name: "key 里面包含敏感词的字段",
+			ctx:  context.Background(),
+			log: func(ctx context.Context, l Logger) {
+				l.Info(ctx, "登录", String("refresh_token", "rt"), String("session_key", "sk"),
+					String("mfaTicket", "ticket"), Any("recoveryCodes", []string{"a", "b"}),
+					String("userPhone", "15212345678"), String("ip", "127.0.0.1"))
+			},
+			wantFields: map[string]any{
+				"refresh_token": "******",
+				"session_key":   "******",
+				"mfaTicket":     "******",
+				"recoveryCodes": "******",
+				"userPhone":     "152****5678",
+				"ip":            "127.0.0.1",
+			},
+		},
+		{
+			name: "With 的字段也会脱敏",
+			ctx:  WithFields(context.Background(), String("request_id", "req-2")),
+			log: func(ctx context.Context, l Logger) {
+				l.With(String("phone", "152")).Warn(ctx, "手机号不合法")
+			},
+			wantFields: map[string]any{
+				"request_id": "req-2",
+				"phone":      "******",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			tc.log(tc.ctx, NewZapLogger(zap.New(core)))
+
+			require.Equal(t, 1, logs.Len())
+			assert.Equal(t, tc.wantFields, logs.All()[0].ContextMap())
+		})
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	parent := WithFields(context.Background(), String("request_id", "req-1"))
+	child1 := WithFields(parent, Int64("user_id", 1))
+	child2 := WithFields(parent, Int64("user_id", 2))
+	assert.Equal(t, []Field{String("request_id", "req-1")}, FieldsFromContext(parent))
+	assert.Equal(t, []Field{String("request_id", "req-1"), Int64("user_id", 1)}, FieldsFromContext(child1))
+	assert.Equal(t, []Field{String("request_id", "req-1"), Int64("user_id", 2)}, FieldsFromContext(child2))
+}
@@ -0,0 +1,58 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"strings"
+)
+
+//go:embed breached.txt
+var defaultBreached string
+
+// BreachedList 离线的泄露密码列表，按出现频率从高到低排列，一行一个
+// 比较的时候忽略大小写
+type BreachedList struct {
+	passwords map[string]struct{}
+}
+
+// DefaultBreachedList 内置的常见密码列表
+func DefaultBreachedList() *BreachedList {
+	l, _ := LoadBreachedList(strings.NewReader(defaultBreached), 0)
+	return l
+}
+
+// LoadBreachedList 读取前 topN 个密码，topN 为 0 表示全部读取
+// 空行和 # 开头的行会被忽略
+func LoadBreachedList(r io.Reader, topN int) (*BreachedList, error) {
+	l := &BreachedList{passwords: map[string]struct{}{}}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if topN > 0 && len(l.passwords) >= topN {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		l.passwords[strings.ToLower(line)] = struct{}{}
+	}
+	return l, scanner.Err()
+}
+
+// Contains nil 的列表什么都不包含
+func (l *BreachedList) Contains(password string) bool {
+	if l == nil {
+		return false
+	}
+	_, ok := l.passwords[strings.ToLower(password)]
+	return ok
+}
+
+// Len 列表里面有多少个密码
+func (l *BreachedList) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.passwords)
+}
@@ -0,0 +1,82 @@
+package password
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	breached, err := LoadBreachedList(strings.NewReader("# 注释\n123456\nP@ssw0rd\nqwerty\n"), 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, breached.Len())
+	policy := &Policy{
+		MinLength:      8,
+		MaxLength:      20,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireDigit:   true,
+		RequireSpecial: true,
+		Breached:       breached,
+	}
+	testCases := []struct {
+		name     string
+		password string
+		email    string
+		wantErr  error
+	}{
+		{name: "通过", password: "QQqq11!!", email: "tom@qq.com"},
+		{name: "太短", password: "Qq1!", wantErr: &PolicyError{Rule: RuleMinLength, Param: "8"}},
+		{name: "太长", password: "QQqq11!!QQqq11!!QQqq11!!", wantErr: &PolicyError{Rule: RuleMaxLength, Param: "20"}},
+		{name: "缺少大写字母", password: "qqqq11!!", wantErr: &PolicyError{Rule: RuleUpper}},
+		{name: "缺少小写字母", password: "QQQQ11!!", wantErr: &PolicyError{Rule: RuleLower}},
+		{name: "缺少数字", password: "QQqqqq!!", wantErr: &PolicyError{Rule: RuleDigit}},
+		{name: "缺少特殊字符", password: "QQqq1111", wantErr: &PolicyError{Rule: RuleSpecial}},
+		{name: "包含邮箱前缀", password: "Tommy-11!", email: "TOMMY@qq.com", wantErr: &PolicyError{Rule: RuleEmail}},
+		{name: "邮箱前缀太短不检查", password: "Tom-pass1!", email: "to@qq.com"},
+		{name: "泄露的密码，忽略大小写", password: "p@SSW0RD", wantErr: &PolicyError{Rule: RuleBreached}},
+		// 只加载了前 2 个
+		{name: "不在前 N 个里面", password: "Qwerty-1!"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantErr, policy.Check(tc.password, tc.email))
+		})
+	}
+	assert.True(t, DefaultBreachedList().Contains("Password1!"))
+}
+
+func TestHasher(t *testing.T) {
+	params := Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	bcryptHasher := NewBcryptHasher(4)
+	argonHasher := NewArgon2idHasher(params)
+
+	bcryptHash, err := bcryptHasher.Hash("QQqq11!!")
+	require.NoError(t, err)
+	argonHash, err := argonHasher.Hash("QQqq11!!")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(argonHash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	// 两种格式都能校验
+	for _, h := range []Hasher{bcryptHasher, argonHasher} {
+		for _, hash := range []string{bcryptHash, argonHash} {
+			ok, err := h.Verify(hash, "QQqq11!!")
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			ok, err = h.Verify(hash, "QQqq11!")
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		}
+	}
+	_, err = bcryptHasher.Verify("", "QQqq11!!")
+	assert.Equal(t, ErrUnknownHash, err)
+
+	assert.False(t, bcryptHasher.NeedsRehash(bcryptHash))
+	assert.True(t, NewBcryptHasher(5).NeedsRehash(bcryptHash))
+	assert.True(t, bcryptHasher.NeedsRehash(argonHash))
+	assert.False(t, argonHasher.NeedsRehash(argonHash))
+	params.Iterations = 2
+	assert.True(t, NewArgon2idHasher(params).NeedsRehash(argonHash))
+	assert.True(t, argonHasher.NeedsRehash(bcryptHash))
+}
@@ -0,0 +1,98 @@
+package password
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 不满足的规则，前端可以根据它提示用户
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleUpper     = "upper"
+	RuleLower     = "lower"
+	RuleDigit     = "digit"
+	RuleSpecial   = "special"
+	// RuleEmail 包含了邮箱 @ 前面的部分
+	RuleEmail = "email"
+	// RuleBreached 在泄露的密码列表里面
+	RuleBreached = "breached"
+	// RuleReused 和最近用过的密码重复，由调用方检查，见 Policy.HistorySize
+	RuleReused = "reused"
+)
+
+// PolicyError 密码不满足策略
+type PolicyError struct {
+	Rule string
+	// Param 规则的参数，比如最小长度
+	Param string
+}
+
+func (e *PolicyError) Error() string {
+	if e.Param == "" {
+		return "password: 不满足规则 " + e.Rule
+	}
+	return fmt.Sprintf("password: 不满足规则 %s=%s", e.Rule, e.Param)
+}
+
+// Policy 密码策略
+type Policy struct {
+	// MinLength 按字符计算
+	MinLength int
+	// MaxLength 按字节计算，bcrypt 最多只支持 72 字节，0 表示不限制
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	// HistorySize 不能和最近用过的几个密码重复，包括当前密码，0 表示不限制
+	HistorySize int
+	// Breached 泄露的密码列表，为 nil 表示不检查
+	Breached *BreachedList
+}
+
+// Check 检查密码，email 用来防止密码里面包含邮箱前缀，可以为空
+// 返回第一个不满足的规则，类型是 *PolicyError
+func (p *Policy) Check(password, email string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return &PolicyError{Rule: RuleMinLength, Param: strconv.Itoa(p.MinLength)}
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		return &PolicyError{Rule: RuleMaxLength, Param: strconv.Itoa(p.MaxLength)}
+	}
+	var upper, lower, digit, special bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			special = true
+		}
+	}
+	switch {
+	case p.RequireUpper && !upper:
+		return &PolicyError{Rule: RuleUpper}
+	case p.RequireLower && !lower:
+		return &PolicyError{Rule: RuleLower}
+	case p.RequireDigit && !digit:
+		return &PolicyError{Rule: RuleDigit}
+	case p.RequireSpecial && !special:
+		return &PolicyError{Rule: RuleSpecial}
+	}
+	// 太短的前缀比如 a@qq.com 不检查，不然误伤太多
+	if local, _, ok := strings.Cut(email, "@"); ok && utf8.RuneCountInString(local) >= 3 &&
+		strings.Contains(strings.ToLower(password), strings.ToLower(local)) {
+		return &PolicyError{Rule: RuleEmail}
+	}
+	if p.Breached.Contains(password) {
+		return &PolicyError{Rule: RuleBreached}
+	}
+	return nil
+}
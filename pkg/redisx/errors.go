@@ -0,0 +1,44 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"io"
+	"net"
+	"strings"
+)
+
+// unavailablePrefixes Redis 回复了，但是暂时处理不了命令
+var unavailablePrefixes = []string{"LOADING ", "READONLY ", "MASTERDOWN ", "CLUSTERDOWN ", "TRYAGAIN "}
+
+// IsUnavailable 连接不上、超时或者 Redis 暂时处理不了命令，这种时候才应该降级
+// NOSCRIPT、WRONGTYPE、脚本报错之类的是命令本身的问题，换个请求照样能用，不算不可用
+func IsUnavailable(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, redis.ErrClosed) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := err.Error()
+	// 连接池的错误没有导出
+	if msg == "redis: connection pool timeout" || msg == "redis: connection pool exhausted" ||
+		msg == "ERR max number of clients reached" {
+		return true
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		for _, prefix := range unavailablePrefixes {
+			if strings.HasPrefix(msg, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
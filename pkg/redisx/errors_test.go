@@ -0,0 +1,89 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestIsUnavailable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	ctx := context.Background()
+	require.NoError(t, client.Set(ctx, "key", "val", 0).Err())
+
+	testCases := []struct {
+		name string
+		err  func() error
+
+		want bool
+	}{
+		{
+			name: "没有出错",
+			err:  func() error { return nil },
+		},
+		{
+			name: "key 不存在",
+			err:  func() error { return client.Get(ctx, "not-exist").Err() },
+		},
+		{
+			name: "类型不对",
+			err:  func() error { return client.HGet(ctx, "key", "field").Err() },
+		},
+		{
+			name: "脚本不存在",
+			err:  func() error { return client.EvalSha(ctx, "not-exist", nil).Err() },
+		},
+		{
+			name: "脚本报错",
+			err:  func() error { return client.Eval(ctx, "return redis.call('HGET', 'key', 'f')", nil).Err() },
+		},
+		{
+			name: "客户端断开",
+			err:  func() error { return context.Canceled },
+		},
+		{
+			name: "超时",
+			err:  func() error { return fmt.Errorf("查询缓存: %w", context.DeadlineExceeded) },
+			want: true,
+		},
+		{
+			name: "Redis 在加载数据",
+			err: func() error {
+				mr.SetError("LOADING Redis is loading the dataset in memory")
+				defer mr.SetError("")
+				return client.Get(ctx, "key").Err()
+			},
+			want: true,
+		},
+		{
+			name: "客户端关了",
+			err:  func() error { return redis.ErrClosed },
+			want: true,
+		},
+		{
+			name: "连接不上",
+			err: func() error {
+				down := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1,
+					DialTimeout: time.Millisecond * 100})
+				return down.Get(ctx, "key").Err()
+			},
+			want: true,
+		},
+		{
+			name: "其他错误",
+			err:  func() error { return errors.New("限流脚本返回值格式不对") },
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, IsUnavailable(tc.err()))
+		})
+	}
+}
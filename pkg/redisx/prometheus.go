@@ -0,0 +1,74 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"net"
+)
+
+// PrometheusHook 按命令统计调用次数和出错次数，redis.Nil 不算出错
+// 使用：client.AddHook(redisx.NewPrometheusHook(...))
+type PrometheusHook struct {
+	commands *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+}
+
+func NewPrometheusHook(namespace, subsystem string) *PrometheusHook {
+	return &PrometheusHook{
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "commands_total",
+			Help:      "Redis 命令调用次数",
+		}, []string{"cmd"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "errors_total",
+			Help:      "Redis 命令出错次数",
+		}, []string{"cmd"}),
+	}
+}
+
+// Register 注册到 reg，一般传 prometheus.DefaultRegisterer
+func (h *PrometheusHook) Register(reg prometheus.Registerer) error {
+	if err := reg.Register(h.commands); err != nil {
+		return err
+	}
+	return reg.Register(h.errors)
+}
+
+func (h *PrometheusHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		h.observe("dial", err)
+		return conn, err
+	}
+}
+
+func (h *PrometheusHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		h.observe(cmd.Name(), err)
+		return err
+	}
+}
+
+func (h *PrometheusHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		for _, cmd := range cmds {
+			h.observe(cmd.Name(), cmd.Err())
+		}
+		return err
+	}
+}
+
+func (h *PrometheusHook) observe(cmd string, err error) {
+	h.commands.WithLabelValues(cmd).Inc()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		h.errors.WithLabelValues(cmd).Inc()
+	}
+}
@@ -0,0 +1,38 @@
+package redisx
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPrometheusHook(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	hook := NewPrometheusHook("webook", "redis")
+	require.NoError(t, hook.Register(prometheus.NewRegistry()))
+	client.AddHook(hook)
+
+	ctx := context.Background()
+	require.NoError(t, client.Set(ctx, "key", "val", 0).Err())
+	// 不存在的 key 不算出错
+	assert.Equal(t, redis.Nil, client.Get(ctx, "not-exist").Err())
+	// 类型不对
+	assert.Error(t, client.HGet(ctx, "key", "field").Err())
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Get(ctx, "key")
+		pipe.Get(ctx, "key")
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(hook.commands.WithLabelValues("set")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(hook.commands.WithLabelValues("get")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(hook.errors.WithLabelValues("get")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(hook.errors.WithLabelValues("hget")))
+}
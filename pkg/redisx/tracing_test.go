@@ -0,0 +1,46 @@
+package redisx
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestTracingHook(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	client.AddHook(NewTracingHook())
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	require.NoError(t, client.Set(ctx, "key", "val", 0).Err())
+	assert.Equal(t, redis.Nil, client.Get(ctx, "not-exist").Err())
+	assert.Error(t, client.HGet(ctx, "key", "field").Err())
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 4)
+	wantSpans := []struct {
+		name string
+		code codes.Code
+	}{
+		{name: "redis.set", code: codes.Unset},
+		// 不存在的 key 不算出错
+		{name: "redis.get", code: codes.Unset},
+		{name: "redis.hget", code: codes.Error},
+	}
+	for i, want := range wantSpans {
+		assert.Equal(t, want.name, spans[i].Name())
+		assert.Equal(t, want.code, spans[i].Status().Code)
+		assert.Equal(t, parent.SpanContext().SpanID(), spans[i].Parent().SpanID())
+	}
+}
@@ -3,7 +3,6 @@
 package main
 
 import (
-	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
 	"webook/internal/repository"
 	"webook/internal/repository/cache"
@@ -13,25 +12,45 @@ import (
 	"webook/ioc"
 )
 
-func initWebServer() *gin.Engine {
+func initApp() *App {
 	wire.Build(
 		// 底层存储
-		ioc.InitDB, ioc.InitRedis,
+		ioc.InitLogger, ioc.InitDB, ioc.InitRedis, ioc.InitRedisHealthChecker, ioc.InitI18n,
 
 		// dao & cache
-		dao.NewUserDAO,
-		cache.NewUserCache, cache.NewCodeCache,
+		dao.NewUserDAO, dao.NewPasswordHistoryDAO, dao.NewMFADAO, dao.NewPasskeyDAO,
+		dao.NewWechatTokenDAO, dao.NewWechatSessionDAO, dao.NewRoleDAO, dao.NewAdminAuditDAO,
+		dao.NewSecurityEventDAO,
+		cache.NewUserCache, cache.NewCodeCache, cache.NewMFATicketCache, cache.NewMFAFailureCache,
+		cache.NewPasskeySessionCache, cache.NewSessionCache,
 
 		// repository
 		repository.NewCachedUserRepository, repository.NewCodeRepository,
+		repository.NewPasswordHistoryRepository, repository.NewMFARepository,
+		repository.NewPasskeyRepository, repository.NewWechatTokenRepository,
+		repository.NewWechatSessionRepository, repository.NewRoleRepository,
+		repository.NewAdminAuditRepository, repository.NewSessionRepository,
+		repository.NewSecurityEventRepository,
 
 		// service
-		ioc.InitSMSService, ioc.InitWechatService,
+		ioc.InitSMSService, ioc.InitOAuth2Providers, ioc.InitCodeTemplates,
+		ioc.InitPasswordPolicy, ioc.InitPasswordHasher,
 		service.NewUserService, service.NewCodeService,
+		ioc.InitCaptchaService, ioc.InitCodeGuard, ioc.InitMFAService,
+		ioc.InitWebAuthn, service.NewPasskeyService, ioc.InitWechatTokenService,
+		ioc.InitWechatMiniProgramService,
+		service.NewRoleService, service.NewSessionService, service.NewAdminService,
+		ioc.InitAccountService, ioc.InitSecurityEventService,
 
 		// handler
-		web.NewUserHandler, ioc.InitGinMiddlewares, ioc.InitWebServer,
-		web.NewOAuth2WechatHandler,
+		ioc.InitJWTHandler, web.NewUserHandler, ioc.InitGinMiddlewares, ioc.InitWebServer,
+		ioc.InitOAuth2Handler, web.NewMiniProgramHandler, web.NewAdminHandler,
+		web.NewAccountHandler, web.NewSecurityHandler,
+
+		// job
+		ioc.InitAccountPurgeJob,
+
+		wire.Struct(new(App), "*"),
 	)
-	return gin.Default()
+	return new(App)
 }